@@ -60,6 +60,9 @@ func main() {
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 
+	// Publish domain events recorded in the outbox
+	services.Event.Start()
+
 	// Start server
 	go func() {
 		if err = srv.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -69,6 +72,7 @@ func main() {
 
 	// Wait for interrupt signal to gracefully shutdown the server
 	<-ctx.Done()
+	services.Event.Stop()
 	ctx, cancel := context.WithTimeout(context.Background(), DefaultContextTimeout*time.Second)
 
 	if err = srv.Shutdown(ctx); err != nil {
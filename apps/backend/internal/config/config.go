@@ -18,7 +18,10 @@ type Config struct {
 	Auth          AuthConfig           `koanf:"auth" validate:"required"`
 	Redis         RedisConfig          `koanf:"redis" validate:"required"`
 	Integration   IntegrationConfig    `koanf:"integration" validate:"required"`
-	AWS           AWSConfig            `koanf:"aws" validate:"required"`
+	Storage       StorageConfig        `koanf:"storage" validate:"required"`
+	Scan          ScanConfig           `koanf:"scan"`
+	Events        EventsConfig         `koanf:"events"`
+	AWS           AWSConfig            `koanf:"aws" validate:"-"`
 	Observability *ObservabilityConfig `koanf:"observability"`
 }
 
@@ -32,6 +35,8 @@ type ServerConfig struct {
 	WriteTimeout       int      `koanf:"write_timeout" validate:"required"`
 	IdleTimeout        int      `koanf:"idle_timeout" validate:"required"`
 	CORSAllowedOrigins []string `koanf:"cors_allowed_origins" validate:"required"`
+	// FrontendURL is the base URL used for links in outgoing emails
+	FrontendURL string `koanf:"frontend_url"`
 }
 
 type DatabaseConfig struct {
@@ -52,7 +57,138 @@ type RedisConfig struct {
 }
 
 type IntegrationConfig struct {
-	ResendAPIKey string `koanf:"resend_api_key" validate:"required"`
+	// EmailTransport selects how emails are sent: resend, smtp, or file to write
+	// .eml files to EmailFileDir for local development and tests
+	EmailTransport string `koanf:"email_transport" validate:"required,oneof=resend smtp file"`
+	// EmailFrom and EmailReplyTo are RFC 5322 addresses, e.g. "Tasker <hello@tasker.app>"
+	EmailFrom    string `koanf:"email_from" validate:"required"`
+	EmailReplyTo string `koanf:"email_reply_to"`
+	EmailFileDir string `koanf:"email_file_dir" validate:"required_if=EmailTransport file"`
+	ResendAPIKey string `koanf:"resend_api_key" validate:"required_if=EmailTransport resend"`
+	SMTPHost     string `koanf:"smtp_host" validate:"required_if=EmailTransport smtp"`
+	SMTPPort     int    `koanf:"smtp_port" validate:"required_if=EmailTransport smtp"`
+	SMTPUsername string `koanf:"smtp_username"`
+	SMTPPassword string `koanf:"smtp_password"`
+	// InboundEmailDomain receives mail for personal addresses and replies; inbound
+	// email is off when it is empty. The webhook authenticates providers with HTTP
+	// basic auth using InboundEmailSecret as the password.
+	InboundEmailDomain string `koanf:"inbound_email_domain" validate:"required_with=InboundEmailSecret"`
+	InboundEmailSecret string `koanf:"inbound_email_secret" validate:"required_with=InboundEmailDomain"`
+}
+
+func (c *IntegrationConfig) InboundEmailEnabled() bool {
+	return c.InboundEmailDomain != "" && c.InboundEmailSecret != ""
+}
+
+// applyDefaults keeps existing deployments sending through Resend from its shared
+// onboarding address, which is what they did before these settings existed.
+func (c *IntegrationConfig) applyDefaults() {
+	if c.EmailTransport == "" {
+		c.EmailTransport = "resend"
+	}
+	if c.EmailFrom == "" {
+		c.EmailFrom = "Tasker <onboarding@resend.dev>"
+	}
+	if c.EmailTransport == "file" && c.EmailFileDir == "" {
+		c.EmailFileDir = "tmp/emails"
+	}
+	if c.EmailTransport == "smtp" && c.SMTPPort == 0 {
+		c.SMTPPort = 587
+	}
+}
+
+type StorageConfig struct {
+	// Backend selects where attachments are stored: s3, or local to keep them under
+	// LocalDir and serve them from the app through signed, expiring URLs
+	Backend  string `koanf:"backend" validate:"required,oneof=s3 local"`
+	LocalDir string `koanf:"local_dir" validate:"required_if=Backend local"`
+	// SigningKey signs local download URLs, which point at PublicURL, the
+	// externally reachable base URL of this server
+	SigningKey string `koanf:"signing_key" validate:"required_if=Backend local"`
+	PublicURL  string `koanf:"public_url" validate:"required_if=Backend local"`
+	// MaxFileSize caps a single attachment and MaxUserStorage the total a user may
+	// upload, both in bytes; MaxUserStorage 0 means unlimited
+	MaxFileSize    int64 `koanf:"max_file_size" validate:"min=1"`
+	MaxUserStorage int64 `koanf:"max_user_storage" validate:"min=0"`
+	// AllowedMimeTypes and DeniedMimeTypes take patterns such as "image/*". An empty
+	// allow list allows everything not denied; the deny list defaults to executables.
+	AllowedMimeTypes []string `koanf:"allowed_mime_types"`
+	DeniedMimeTypes  []string `koanf:"denied_mime_types"`
+	// ReconcileMode sets what the daily storage reconciliation does with objects no
+	// attachment references: off, dry-run to only report them, or repair to delete them
+	ReconcileMode string `koanf:"reconcile_mode" validate:"oneof=off dry-run repair"`
+	// PresignExpiry is how many seconds presigned download URLs stay valid, at most
+	// the seven days S3 allows
+	PresignExpiry int `koanf:"presign_expiry" validate:"min=0,max=604800"`
+	// MaxAttachmentVersions is how many versions of each attachment are kept,
+	// current one included; older ones are pruned as new versions arrive. 0 keeps all
+	MaxAttachmentVersions int `koanf:"max_attachment_versions" validate:"min=0"`
+}
+
+// applyDefaults keeps existing deployments on S3, which was the only backend
+// before this setting existed.
+func (c *StorageConfig) applyDefaults(server ServerConfig) {
+	if c.Backend == "" {
+		c.Backend = "s3"
+	}
+	if c.Backend == "local" && c.LocalDir == "" {
+		c.LocalDir = "tmp/storage"
+	}
+	if c.Backend == "local" && c.PublicURL == "" {
+		c.PublicURL = "http://localhost:" + server.Port
+	}
+	c.PublicURL = strings.TrimSuffix(c.PublicURL, "/")
+	if c.MaxFileSize == 0 {
+		c.MaxFileSize = 100 << 20
+	}
+	if c.ReconcileMode == "" {
+		c.ReconcileMode = "dry-run"
+	}
+	if c.PresignExpiry == 0 {
+		c.PresignExpiry = 900
+	}
+}
+
+type ScanConfig struct {
+	// Backend selects the malware scanner run on every upload: none to trust files
+	// as uploaded, or clamav to stream them to the clamd daemon at ClamAVAddress
+	Backend       string `koanf:"backend" validate:"required,oneof=none clamav"`
+	ClamAVAddress string `koanf:"clamav_address" validate:"required_if=Backend clamav"`
+	// Timeout is how many seconds a single scan may take
+	Timeout int `koanf:"timeout" validate:"min=1"`
+}
+
+// applyDefaults leaves scanning off, as it was before this setting existed.
+func (c *ScanConfig) applyDefaults() {
+	if c.Backend == "" {
+		c.Backend = "none"
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 120
+	}
+}
+
+type EventsConfig struct {
+	// PollInterval is how many milliseconds the outbox relay waits between looks for
+	// new events
+	PollInterval int `koanf:"poll_interval" validate:"min=1"`
+	// MaxAttempts is how often publishing an event is tried before it is set aside
+	// as failed, with backoff between attempts
+	MaxAttempts int `koanf:"max_attempts" validate:"min=1"`
+	// Retention is how many days published events are kept
+	Retention int `koanf:"retention" validate:"min=1"`
+}
+
+func (c *EventsConfig) applyDefaults() {
+	if c.PollInterval == 0 {
+		c.PollInterval = 1000
+	}
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = 20
+	}
+	if c.Retention == 0 {
+		c.Retention = 7
+	}
 }
 
 type AuthConfig struct {
@@ -85,6 +221,11 @@ func LoadConfig() (*Config, error) {
 		logger.Fatal().Err(err).Msg("could not unmarshal main config")
 	}
 
+	mainConfig.Integration.applyDefaults()
+	mainConfig.Storage.applyDefaults(mainConfig.Server)
+	mainConfig.Scan.applyDefaults()
+	mainConfig.Events.applyDefaults()
+
 	validate := validator.New()
 
 	err = validate.Struct(mainConfig)
@@ -92,6 +233,13 @@ func LoadConfig() (*Config, error) {
 		logger.Fatal().Err(err).Msg("config validation failed")
 	}
 
+	// AWS credentials are only needed when attachments are stored in S3
+	if mainConfig.Storage.Backend == "s3" {
+		if err := validate.Struct(mainConfig.AWS); err != nil {
+			logger.Fatal().Err(err).Msg("aws config validation failed")
+		}
+	}
+
 	// Set default observability config if not provided
 	if mainConfig.Observability == nil {
 		mainConfig.Observability = DefaultObservabilityConfig()
@@ -0,0 +1,112 @@
+CREATE TABLE todo_shares (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    owner_id TEXT NOT NULL,
+    resource_type TEXT NOT NULL,
+    todo_id UUID REFERENCES todos ON DELETE CASCADE,
+    category_id UUID REFERENCES todo_categories ON DELETE CASCADE,
+    user_id TEXT,
+    email TEXT NOT NULL,
+    role TEXT NOT NULL DEFAULT 'viewer',
+    status TEXT NOT NULL DEFAULT 'pending',
+    invite_token TEXT NOT NULL,
+    accepted_at TIMESTAMPTZ,
+
+    CONSTRAINT todo_shares_resource CHECK (
+        (resource_type = 'todo' AND todo_id IS NOT NULL AND category_id IS NULL)
+        OR (resource_type = 'category' AND category_id IS NOT NULL AND todo_id IS NULL)
+    ),
+    CONSTRAINT todo_shares_role CHECK (role IN ('viewer', 'commenter', 'editor')),
+    CONSTRAINT todo_shares_status CHECK (status IN ('pending', 'accepted'))
+);
+
+CREATE UNIQUE INDEX todo_shares_unique_token ON todo_shares(invite_token);
+CREATE UNIQUE INDEX todo_shares_unique_todo_email ON todo_shares(todo_id, email) WHERE todo_id IS NOT NULL;
+CREATE UNIQUE INDEX todo_shares_unique_category_email ON todo_shares(category_id, email) WHERE category_id IS NOT NULL;
+CREATE INDEX idx_todo_shares_user_id ON todo_shares(user_id) WHERE status = 'accepted';
+CREATE INDEX idx_todo_shares_owner_id ON todo_shares(owner_id);
+
+CREATE TRIGGER set_updated_at_todo_shares
+    BEFORE UPDATE ON todo_shares
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_set_updated_at();
+
+-- Orders roles so the strongest grant wins when several shares apply
+CREATE OR REPLACE FUNCTION share_role_rank(role TEXT)
+    RETURNS INT
+    LANGUAGE sql
+    IMMUTABLE
+    AS $$
+    SELECT
+        CASE role
+            WHEN 'owner' THEN 4
+            WHEN 'editor' THEN 3
+            WHEN 'commenter' THEN 2
+            WHEN 'viewer' THEN 1
+            ELSE 0
+        END;
+$$;
+
+-- Resolves a user's role on a todo: owners of the todo (or of its parent) are owners,
+-- everyone else gets the strongest accepted share on the todo tree or its category.
+CREATE OR REPLACE FUNCTION todo_access_role(p_todo_id UUID, p_user_id TEXT)
+    RETURNS TEXT
+    LANGUAGE sql
+    STABLE
+    AS $$
+    SELECT
+        CASE
+            WHEN t.user_id = p_user_id OR p.user_id = p_user_id THEN 'owner'
+            ELSE (
+                SELECT
+                    s.role
+                FROM
+                    todo_shares s
+                WHERE
+                    s.user_id = p_user_id
+                    AND s.status = 'accepted'
+                    AND (
+                        s.todo_id IN (t.id, t.parent_todo_id)
+                        OR s.category_id IN (t.category_id, p.category_id)
+                    )
+                ORDER BY
+                    share_role_rank(s.role) DESC
+                LIMIT 1
+            )
+        END
+    FROM
+        todos t
+        LEFT JOIN todos p ON p.id = t.parent_todo_id
+    WHERE
+        t.id = p_todo_id;
+$$;
+
+CREATE OR REPLACE FUNCTION category_access_role(p_category_id UUID, p_user_id TEXT)
+    RETURNS TEXT
+    LANGUAGE sql
+    STABLE
+    AS $$
+    SELECT
+        CASE
+            WHEN c.user_id = p_user_id THEN 'owner'
+            ELSE (
+                SELECT
+                    s.role
+                FROM
+                    todo_shares s
+                WHERE
+                    s.user_id = p_user_id
+                    AND s.status = 'accepted'
+                    AND s.category_id = c.id
+                ORDER BY
+                    share_role_rank(s.role) DESC
+                LIMIT 1
+            )
+        END
+    FROM
+        todo_categories c
+    WHERE
+        c.id = p_category_id;
+$$;
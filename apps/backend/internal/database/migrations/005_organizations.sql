@@ -0,0 +1,64 @@
+-- Todos and categories created in an organization session belong to that organization.
+-- A NULL organization_id keeps them in the creator's personal workspace.
+ALTER TABLE todo_categories ADD COLUMN organization_id TEXT;
+ALTER TABLE todos ADD COLUMN organization_id TEXT;
+
+CREATE INDEX idx_todo_categories_organization_id ON todo_categories(organization_id) WHERE organization_id IS NOT NULL;
+CREATE INDEX idx_todos_organization_id ON todos(organization_id) WHERE organization_id IS NOT NULL;
+CREATE INDEX idx_todos_org_status_priority ON todos(organization_id, status, priority) WHERE organization_id IS NOT NULL;
+
+-- Category names are unique per workspace rather than per user
+DROP INDEX todo_categories_unique_name;
+CREATE UNIQUE INDEX todo_categories_unique_name ON todo_categories(user_id, name) WHERE organization_id IS NULL;
+CREATE UNIQUE INDEX todo_categories_unique_org_name ON todo_categories(organization_id, name) WHERE organization_id IS NOT NULL;
+
+-- Resolves a user's role on a todo within the session's workspace. Personal sessions
+-- only see personal todos, resolved through ownership and shares. Organization
+-- sessions see every todo of the organization: creators and admins are owners,
+-- other members are editors and are further limited by their Clerk permissions.
+CREATE OR REPLACE FUNCTION todo_access_role(p_todo_id UUID, p_user_id TEXT, p_org_id TEXT, p_org_admin BOOLEAN)
+    RETURNS TEXT
+    LANGUAGE sql
+    STABLE
+    AS $$
+    SELECT
+        CASE
+            WHEN p_org_id IS NULL THEN
+                CASE
+                    WHEN t.organization_id IS NULL THEN todo_access_role(t.id, p_user_id)
+                END
+            WHEN t.organization_id = p_org_id THEN
+                CASE
+                    WHEN p_org_admin OR t.user_id = p_user_id OR p.user_id = p_user_id THEN 'owner'
+                    ELSE 'editor'
+                END
+        END
+    FROM
+        todos t
+        LEFT JOIN todos p ON p.id = t.parent_todo_id
+    WHERE
+        t.id = p_todo_id;
+$$;
+
+CREATE OR REPLACE FUNCTION category_access_role(p_category_id UUID, p_user_id TEXT, p_org_id TEXT, p_org_admin BOOLEAN)
+    RETURNS TEXT
+    LANGUAGE sql
+    STABLE
+    AS $$
+    SELECT
+        CASE
+            WHEN p_org_id IS NULL THEN
+                CASE
+                    WHEN c.organization_id IS NULL THEN category_access_role(c.id, p_user_id)
+                END
+            WHEN c.organization_id = p_org_id THEN
+                CASE
+                    WHEN p_org_admin OR c.user_id = p_user_id THEN 'owner'
+                    ELSE 'editor'
+                END
+        END
+    FROM
+        todo_categories c
+    WHERE
+        c.id = p_category_id;
+$$;
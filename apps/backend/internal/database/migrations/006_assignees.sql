@@ -0,0 +1,34 @@
+CREATE TABLE todo_assignees (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    todo_id UUID NOT NULL REFERENCES todos ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    assigned_by TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX todo_assignees_unique_user ON todo_assignees(todo_id, user_id);
+CREATE INDEX idx_todo_assignees_user_id ON todo_assignees(user_id);
+
+CREATE TRIGGER set_updated_at_todo_assignees
+    BEFORE UPDATE ON todo_assignees
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_set_updated_at();
+
+CREATE TABLE todo_watchers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    todo_id UUID NOT NULL REFERENCES todos ON DELETE CASCADE,
+    user_id TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX todo_watchers_unique_user ON todo_watchers(todo_id, user_id);
+CREATE INDEX idx_todo_watchers_user_id ON todo_watchers(user_id);
+
+CREATE TRIGGER set_updated_at_todo_watchers
+    BEFORE UPDATE ON todo_watchers
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_set_updated_at();
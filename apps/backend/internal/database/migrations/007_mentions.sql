@@ -0,0 +1,25 @@
+-- Users mentioned in a comment, resolved when the comment is written: [{"userId": ..., "handle": ...}]
+ALTER TABLE todo_comments ADD COLUMN mentions JSONB NOT NULL DEFAULT '[]'::JSONB;
+
+CREATE INDEX idx_todo_comments_mentions ON todo_comments USING GIN (mentions jsonb_path_ops);
+
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    user_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    actor_id TEXT,
+    todo_id UUID REFERENCES todos ON DELETE CASCADE,
+    comment_id UUID REFERENCES todo_comments ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    body TEXT NOT NULL
+);
+
+CREATE INDEX idx_notifications_user_id_created_at ON notifications(user_id, created_at DESC);
+
+CREATE TRIGGER set_updated_at_notifications
+    BEFORE UPDATE ON notifications
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_set_updated_at();
@@ -0,0 +1,29 @@
+-- Replies point at the comment they answer. Depth is stored so threads can be
+-- capped without walking the tree on every insert.
+ALTER TABLE todo_comments
+    ADD COLUMN parent_comment_id UUID REFERENCES todo_comments ON DELETE CASCADE,
+    ADD COLUMN depth INT NOT NULL DEFAULT 0,
+    ADD COLUMN deleted_at TIMESTAMPTZ;
+
+ALTER TABLE todo_comments
+ADD CONSTRAINT no_self_parent_comment
+CHECK (id != parent_comment_id);
+
+CREATE INDEX idx_todo_comments_parent_comment_id ON todo_comments(parent_comment_id);
+
+CREATE TABLE comment_reactions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    comment_id UUID NOT NULL REFERENCES todo_comments ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    emoji TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX comment_reactions_unique_user_emoji ON comment_reactions(comment_id, user_id, emoji);
+
+CREATE TRIGGER set_updated_at_comment_reactions
+    BEFORE UPDATE ON comment_reactions
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_set_updated_at();
@@ -0,0 +1,22 @@
+ALTER TABLE todo_comments
+    ADD COLUMN edited_at TIMESTAMPTZ,
+    ADD COLUMN edited BOOLEAN GENERATED ALWAYS AS (edited_at IS NOT NULL) STORED;
+
+-- Every version of an edited comment, oldest first. The original is recorded on the
+-- first edit, dated to when the comment was written.
+CREATE TABLE comment_revisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    comment_id UUID NOT NULL REFERENCES todo_comments ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    content TEXT NOT NULL
+);
+
+CREATE INDEX idx_comment_revisions_comment_id ON comment_revisions(comment_id, created_at);
+
+CREATE TRIGGER set_updated_at_comment_revisions
+    BEFORE UPDATE ON comment_revisions
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_set_updated_at();
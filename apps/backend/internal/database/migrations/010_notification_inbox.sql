@@ -0,0 +1,88 @@
+ALTER TABLE notifications
+    ADD COLUMN read_at TIMESTAMPTZ,
+    ADD COLUMN archived_at TIMESTAMPTZ;
+
+CREATE INDEX idx_notifications_user_id_unread ON notifications(user_id)
+WHERE
+    read_at IS NULL
+    AND archived_at IS NULL;
+
+-- Per-user, per-type channel choices. A missing row means every channel is on.
+CREATE TABLE notification_preferences (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    user_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    in_app BOOLEAN NOT NULL DEFAULT TRUE,
+    email BOOLEAN NOT NULL DEFAULT TRUE,
+    webhook BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE UNIQUE INDEX notification_preferences_unique_user_type ON notification_preferences(user_id, type);
+
+CREATE TRIGGER set_updated_at_notification_preferences
+    BEFORE UPDATE ON notification_preferences
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_set_updated_at();
+
+-- Settings shared by every notification type. Quiet hours are local "HH:MM" times
+-- in the user's timezone; a window whose end is before its start spans midnight.
+CREATE TABLE notification_settings (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    user_id TEXT NOT NULL UNIQUE,
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    quiet_hours_start TEXT CHECK (quiet_hours_start ~ '^([01][0-9]|2[0-3]):[0-5][0-9]$'),
+    quiet_hours_end TEXT CHECK (quiet_hours_end ~ '^([01][0-9]|2[0-3]):[0-5][0-9]$'),
+    webhook_url TEXT,
+    webhook_secret TEXT,
+
+    CONSTRAINT quiet_hours_complete CHECK ((quiet_hours_start IS NULL) = (quiet_hours_end IS NULL)),
+    CONSTRAINT webhook_has_secret CHECK (webhook_url IS NULL OR webhook_secret IS NOT NULL)
+);
+
+CREATE TRIGGER set_updated_at_notification_settings
+    BEFORE UPDATE ON notification_settings
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_set_updated_at();
+
+-- Time-based alerts already sent, keyed by the time they fired for so moving a due
+-- date or reminder produces a fresh alert.
+CREATE TABLE todo_alerts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    todo_id UUID NOT NULL REFERENCES todos ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    fires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX todo_alerts_unique_firing ON todo_alerts(todo_id, kind, fires_at);
+
+CREATE TRIGGER set_updated_at_todo_alerts
+    BEFORE UPDATE ON todo_alerts
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_set_updated_at();
+
+-- Reminders are free-form metadata; only values that parse as timestamps are used.
+CREATE OR REPLACE FUNCTION try_timestamptz(value TEXT)
+    RETURNS TIMESTAMPTZ
+    LANGUAGE plpgsql
+    STABLE
+    AS $$
+BEGIN
+    RETURN value::TIMESTAMPTZ;
+EXCEPTION
+    WHEN OTHERS THEN
+        RETURN NULL;
+END;
+$$;
+
+CREATE INDEX idx_todos_due_date_open ON todos(due_date)
+WHERE
+    status NOT IN ('completed', 'archived');
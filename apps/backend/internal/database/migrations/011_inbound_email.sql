@@ -0,0 +1,37 @@
+-- Secret personal addresses: mail sent to <token>@<inbound domain> becomes a todo
+-- for the user. Rotating the token retires the old address.
+CREATE TABLE inbound_email_addresses (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    user_id TEXT NOT NULL UNIQUE,
+    token TEXT NOT NULL UNIQUE
+);
+
+CREATE TRIGGER set_updated_at_inbound_email_addresses
+    BEFORE UPDATE ON inbound_email_addresses
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_set_updated_at();
+
+-- Reply-to tokens on notification emails. Each is issued to one recipient, so a
+-- reply becomes a comment by that user on the todo, threaded under the comment the
+-- email was about.
+CREATE TABLE email_reply_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    token TEXT NOT NULL UNIQUE,
+    user_id TEXT NOT NULL,
+    todo_id UUID NOT NULL REFERENCES todos ON DELETE CASCADE,
+    comment_id UUID REFERENCES comments ON DELETE SET NULL,
+    organization_id TEXT
+);
+
+CREATE INDEX idx_email_reply_tokens_todo_id ON email_reply_tokens(todo_id);
+
+CREATE TRIGGER set_updated_at_email_reply_tokens
+    BEFORE UPDATE ON email_reply_tokens
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_set_updated_at();
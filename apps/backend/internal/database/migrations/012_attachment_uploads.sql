@@ -0,0 +1,25 @@
+-- Direct-to-storage uploads. A row is created when the client asks for an upload
+-- URL and completed once the object has been verified; uploads still pending after
+-- expires_at are abandoned and cleaned up along with their objects.
+CREATE TABLE attachment_uploads (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    todo_id UUID NOT NULL REFERENCES todos ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    storage_key TEXT NOT NULL UNIQUE,
+    file_size BIGINT NOT NULL,
+    mime_type TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_attachment_uploads_todo_id ON attachment_uploads(todo_id);
+CREATE INDEX idx_attachment_uploads_pending ON attachment_uploads(expires_at) WHERE completed_at IS NULL;
+
+CREATE TRIGGER set_updated_at_attachment_uploads
+    BEFORE UPDATE ON attachment_uploads
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_set_updated_at();
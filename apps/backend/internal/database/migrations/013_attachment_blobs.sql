@@ -0,0 +1,26 @@
+-- Content-addressed attachment storage. Each distinct file a user uploads is stored
+-- once, under a key derived from its SHA-256, and counted by the attachments that
+-- reference it; the object is deleted when the last one goes.
+CREATE TABLE attachment_blobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    user_id TEXT NOT NULL,
+    checksum TEXT NOT NULL,
+    storage_key TEXT NOT NULL UNIQUE,
+    file_size BIGINT NOT NULL,
+    mime_type TEXT NOT NULL,
+    ref_count INTEGER NOT NULL DEFAULT 0 CHECK (ref_count >= 0),
+
+    UNIQUE (user_id, checksum)
+);
+
+CREATE TRIGGER set_updated_at_attachment_blobs
+    BEFORE UPDATE ON attachment_blobs
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_set_updated_at();
+
+-- Hex SHA-256 of the content; NULL for attachments stored before blobs existed,
+-- which own their object outright
+ALTER TABLE todo_attachments ADD COLUMN checksum TEXT;
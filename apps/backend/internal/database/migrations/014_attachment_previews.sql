@@ -0,0 +1,12 @@
+-- Thumbnails and previews generated in the background after an attachment is
+-- stored. Attachments that existed before previews are left unsupported rather
+-- than queued for a backfill.
+ALTER TABLE todo_attachments
+    ADD COLUMN preview_status TEXT NOT NULL DEFAULT 'unsupported'
+        CHECK (preview_status IN ('pending', 'ready', 'failed', 'unsupported')),
+    -- Derivative stored next to the original, NULL when there is no thumbnail
+    ADD COLUMN thumbnail_key TEXT,
+    -- Number of pages for documents such as PDFs
+    ADD COLUMN page_count INTEGER;
+
+ALTER TABLE todo_attachments ALTER COLUMN preview_status SET DEFAULT 'pending';
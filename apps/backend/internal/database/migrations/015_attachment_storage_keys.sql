@@ -0,0 +1,3 @@
+-- Lets the storage reconciliation job and deletions check whether an object is
+-- still referenced by an attachment
+CREATE INDEX idx_todo_attachments_download_key ON todo_attachments(download_key);
@@ -0,0 +1,131 @@
+-- Running totals of what each user stores, kept in step with attachments, blobs and
+-- uploads by triggers so quota checks read one row instead of summing, and so
+-- cascading deletes are counted too.
+CREATE TABLE user_storage_usage (
+    user_id TEXT PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    -- Bytes stored: deduplicated blobs plus attachments stored before blobs existed
+    used_bytes BIGINT NOT NULL DEFAULT 0,
+    -- Attachments uploaded, counting duplicates
+    file_count INTEGER NOT NULL DEFAULT 0,
+    -- Bytes announced by direct uploads that haven't completed yet
+    reserved_bytes BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TRIGGER set_updated_at_user_storage_usage
+    BEFORE UPDATE ON user_storage_usage
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_set_updated_at();
+
+CREATE OR REPLACE FUNCTION adjust_storage_usage(p_user_id TEXT, p_bytes BIGINT, p_files INTEGER, p_reserved BIGINT)
+    RETURNS VOID
+    LANGUAGE sql
+    AS $$
+    INSERT INTO
+        user_storage_usage (user_id, used_bytes, file_count, reserved_bytes)
+    VALUES
+        (p_user_id, p_bytes, p_files, p_reserved)
+    ON CONFLICT (user_id) DO UPDATE
+    SET
+        used_bytes=user_storage_usage.used_bytes + EXCLUDED.used_bytes,
+        file_count=user_storage_usage.file_count + EXCLUDED.file_count,
+        reserved_bytes=user_storage_usage.reserved_bytes + EXCLUDED.reserved_bytes;
+$$;
+
+CREATE OR REPLACE FUNCTION trigger_storage_usage_blobs()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM adjust_storage_usage(NEW.user_id, NEW.file_size, 0, 0);
+    ELSE
+        PERFORM adjust_storage_usage(OLD.user_id, -OLD.file_size, 0, 0);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER storage_usage_attachment_blobs
+    AFTER INSERT OR DELETE ON attachment_blobs
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_storage_usage_blobs();
+
+-- Attachments with a checksum take their bytes from their blob
+CREATE OR REPLACE FUNCTION trigger_storage_usage_attachments()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM adjust_storage_usage(NEW.uploaded_by,
+            CASE WHEN NEW.checksum IS NULL THEN COALESCE(NEW.file_size, 0) ELSE 0 END, 1, 0);
+    ELSE
+        PERFORM adjust_storage_usage(OLD.uploaded_by,
+            CASE WHEN OLD.checksum IS NULL THEN -COALESCE(OLD.file_size, 0) ELSE 0 END, -1, 0);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER storage_usage_todo_attachments
+    AFTER INSERT OR DELETE ON todo_attachments
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_storage_usage_attachments();
+
+-- A direct upload reserves its announced size until it completes or goes away
+CREATE OR REPLACE FUNCTION trigger_storage_usage_uploads()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' AND NEW.completed_at IS NULL THEN
+        PERFORM adjust_storage_usage(NEW.user_id, 0, 0, NEW.file_size);
+    ELSIF TG_OP = 'UPDATE' AND OLD.completed_at IS NULL AND NEW.completed_at IS NOT NULL THEN
+        PERFORM adjust_storage_usage(OLD.user_id, 0, 0, -OLD.file_size);
+    ELSIF TG_OP = 'DELETE' AND OLD.completed_at IS NULL THEN
+        PERFORM adjust_storage_usage(OLD.user_id, 0, 0, -OLD.file_size);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER storage_usage_attachment_uploads
+    AFTER INSERT OR UPDATE OF completed_at OR DELETE ON attachment_uploads
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_storage_usage_uploads();
+
+-- Backfill from what is stored today
+INSERT INTO
+    user_storage_usage (user_id, used_bytes, file_count, reserved_bytes)
+SELECT
+    user_id,
+    SUM(used_bytes),
+    SUM(file_count),
+    SUM(reserved_bytes)
+FROM
+    (
+        SELECT
+            user_id,
+            file_size AS used_bytes,
+            0 AS file_count,
+            0 AS reserved_bytes
+        FROM
+            attachment_blobs
+        UNION ALL
+        SELECT
+            uploaded_by,
+            CASE WHEN checksum IS NULL THEN COALESCE(file_size, 0) ELSE 0 END,
+            1,
+            0
+        FROM
+            todo_attachments
+        UNION ALL
+        SELECT
+            user_id,
+            0,
+            0,
+            file_size
+        FROM
+            attachment_uploads
+        WHERE
+            completed_at IS NULL
+    ) totals
+GROUP BY
+    user_id;
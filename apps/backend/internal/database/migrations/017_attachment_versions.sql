@@ -0,0 +1,90 @@
+-- Every version of an attachment's content. The attachment row mirrors its current
+-- version, the one with the highest number. Each version holds the blob reference
+-- for its content, so old versions keep their files until they are pruned.
+CREATE TABLE attachment_versions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    attachment_id UUID NOT NULL REFERENCES todo_attachments ON DELETE CASCADE,
+    version INTEGER NOT NULL CHECK (version > 0),
+    -- Owner of the content's blob, who uploaded it
+    uploaded_by TEXT NOT NULL,
+    name TEXT NOT NULL,
+    download_key TEXT NOT NULL,
+    file_size BIGINT,
+    mime_type TEXT,
+    checksum TEXT,
+    -- Set when the version brings back the content of an earlier one
+    restored_from INTEGER,
+    restored_by TEXT,
+
+    UNIQUE (attachment_id, version)
+);
+
+CREATE INDEX idx_attachment_versions_download_key ON attachment_versions(download_key);
+
+CREATE TRIGGER set_updated_at_attachment_versions
+    BEFORE UPDATE ON attachment_versions
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_set_updated_at();
+
+ALTER TABLE todo_attachments ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- Existing attachments become their first version, which takes over their blob
+-- reference
+INSERT INTO
+    attachment_versions (
+        created_at,
+        attachment_id,
+        version,
+        uploaded_by,
+        name,
+        download_key,
+        file_size,
+        mime_type,
+        checksum
+    )
+SELECT
+    created_at,
+    id,
+    1,
+    uploaded_by,
+    name,
+    download_key,
+    file_size,
+    mime_type,
+    checksum
+FROM
+    todo_attachments;
+
+-- Content stored before blobs existed now lives as long as its version rather than
+-- its attachment, so its bytes are counted on versions. Attachments only count files.
+CREATE OR REPLACE FUNCTION trigger_storage_usage_attachments()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM adjust_storage_usage(NEW.uploaded_by, 0, 1, 0);
+    ELSE
+        PERFORM adjust_storage_usage(OLD.uploaded_by, 0, -1, 0);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION trigger_storage_usage_versions()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' AND NEW.checksum IS NULL THEN
+        PERFORM adjust_storage_usage(NEW.uploaded_by, COALESCE(NEW.file_size, 0), 0, 0);
+    ELSIF TG_OP = 'DELETE' AND OLD.checksum IS NULL THEN
+        PERFORM adjust_storage_usage(OLD.uploaded_by, -COALESCE(OLD.file_size, 0), 0, 0);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER storage_usage_attachment_versions
+    AFTER INSERT OR DELETE ON attachment_versions
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_storage_usage_versions();
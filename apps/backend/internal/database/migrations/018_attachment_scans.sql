@@ -0,0 +1,15 @@
+-- Malware scan verdicts. Each version keeps the verdict on its content so earlier
+-- versions are checked before download too, and the attachment mirrors its current
+-- version. Content stored before scanning existed starts out pending and is picked
+-- up by the sweep for pending scans.
+ALTER TABLE todo_attachments
+    ADD COLUMN scan_status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (scan_status IN ('pending', 'clean', 'infected', 'error'));
+
+ALTER TABLE attachment_versions
+    ADD COLUMN scan_status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (scan_status IN ('pending', 'clean', 'infected', 'error'));
+
+CREATE INDEX idx_attachment_versions_pending_scan ON attachment_versions(created_at)
+WHERE
+    scan_status='pending';
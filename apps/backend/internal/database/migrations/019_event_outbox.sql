@@ -0,0 +1,59 @@
+-- Domain events, written in the same transaction as the change they describe and
+-- published afterwards by the relay. Events of one aggregate are published in
+-- sequence order; position orders events across aggregates.
+CREATE TABLE event_outbox (
+    -- Consumers deduplicate redeliveries by this ID
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    position BIGSERIAL NOT NULL UNIQUE,
+
+    aggregate_type TEXT NOT NULL,
+    aggregate_id UUID NOT NULL,
+    sequence BIGINT NOT NULL,
+    event_type TEXT NOT NULL,
+    actor_id TEXT,
+    organization_id TEXT,
+    payload JSONB NOT NULL,
+
+    -- When the relay may next try the event: after a failed attempt, or once its
+    -- claim on the event lapses
+    available_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    published_at TIMESTAMPTZ,
+    -- Set when the event ran out of attempts; it no longer holds up its aggregate
+    failed_at TIMESTAMPTZ,
+
+    UNIQUE (aggregate_type, aggregate_id, sequence)
+);
+
+CREATE INDEX idx_event_outbox_unpublished ON event_outbox(position)
+WHERE
+    published_at IS NULL
+    AND failed_at IS NULL;
+
+CREATE INDEX idx_event_outbox_published_at ON event_outbox(published_at)
+WHERE
+    published_at IS NOT NULL;
+
+-- Last sequence handed out per aggregate. Bumping it locks the row, so writers of
+-- one aggregate commit their events in sequence order, and sequences keep counting
+-- after published events are pruned.
+CREATE TABLE event_aggregates (
+    aggregate_type TEXT NOT NULL,
+    aggregate_id UUID NOT NULL,
+    sequence BIGINT NOT NULL,
+
+    PRIMARY KEY (aggregate_type, aggregate_id)
+);
+
+-- Events each subscriber has handled, so redeliveries are skipped
+CREATE TABLE event_deliveries (
+    subscriber TEXT NOT NULL,
+    event_id UUID NOT NULL,
+    delivered_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (subscriber, event_id)
+);
+
+CREATE INDEX idx_event_deliveries_delivered_at ON event_deliveries(delivered_at);
@@ -0,0 +1,16 @@
+-- Nested categories. Deleting a category moves its subcategories up to its parent,
+-- which the repository does before the delete; SET NULL only covers rows removed
+-- some other way. Archived categories keep their todos but are hidden from listings
+-- along with their subcategories unless archived ones are asked for.
+ALTER TABLE todo_categories
+    ADD COLUMN parent_id UUID REFERENCES todo_categories ON DELETE SET NULL,
+    ADD COLUMN archived_at TIMESTAMPTZ,
+    ADD CONSTRAINT no_self_parent CHECK (id != parent_id);
+
+CREATE INDEX idx_todo_categories_parent_id ON todo_categories(parent_id) WHERE parent_id IS NOT NULL;
+
+-- Names are unique among siblings, so Work › Clients and Personal › Clients can coexist
+DROP INDEX todo_categories_unique_name;
+DROP INDEX todo_categories_unique_org_name;
+CREATE UNIQUE INDEX todo_categories_unique_name ON todo_categories(user_id, parent_id, name) NULLS NOT DISTINCT WHERE organization_id IS NULL;
+CREATE UNIQUE INDEX todo_categories_unique_org_name ON todo_categories(organization_id, parent_id, name) NULLS NOT DISTINCT WHERE organization_id IS NOT NULL;
@@ -0,0 +1,102 @@
+-- Organization sessions resolve access from the member's default role, which the
+-- session derives from its Clerk role and permissions, instead of making every
+-- member an editor. Accepted shares take precedence over that default, so sharing
+-- a todo or category with a member narrows or widens their access to it. Personal
+-- sessions reach organization todos and categories through shares only, which
+-- lets outside collaborators work on them.
+DROP FUNCTION todo_access_role(UUID, TEXT, TEXT, BOOLEAN);
+DROP FUNCTION category_access_role(UUID, TEXT, TEXT, BOOLEAN);
+
+-- Resolves the strongest accepted share a user has on a todo tree or its category.
+CREATE OR REPLACE FUNCTION todo_share_role(p_todo_id UUID, p_user_id TEXT)
+    RETURNS TEXT
+    LANGUAGE sql
+    STABLE
+    AS $$
+    SELECT
+        s.role
+    FROM
+        todos t
+        LEFT JOIN todos p ON p.id = t.parent_todo_id
+        JOIN todo_shares s ON s.user_id = p_user_id
+        AND s.status = 'accepted'
+        AND (
+            s.todo_id IN (t.id, t.parent_todo_id)
+            OR s.category_id IN (t.category_id, p.category_id)
+        )
+    WHERE
+        t.id = p_todo_id
+    ORDER BY
+        share_role_rank(s.role) DESC
+    LIMIT 1;
+$$;
+
+CREATE OR REPLACE FUNCTION category_share_role(p_category_id UUID, p_user_id TEXT)
+    RETURNS TEXT
+    LANGUAGE sql
+    STABLE
+    AS $$
+    SELECT
+        s.role
+    FROM
+        todo_shares s
+    WHERE
+        s.user_id = p_user_id
+        AND s.status = 'accepted'
+        AND s.category_id = p_category_id
+    ORDER BY
+        share_role_rank(s.role) DESC
+    LIMIT 1;
+$$;
+
+-- Resolves a user's role on a todo within the session's workspace. p_org_role is the
+-- member's default role in the session's organization: 'owner' for admins, down to
+-- 'viewer' for members who may not write. Creators of a todo or its parent are owners.
+CREATE OR REPLACE FUNCTION todo_access_role(p_todo_id UUID, p_user_id TEXT, p_org_id TEXT, p_org_role TEXT)
+    RETURNS TEXT
+    LANGUAGE sql
+    STABLE
+    AS $$
+    SELECT
+        CASE
+            WHEN p_org_id IS NULL THEN
+                CASE
+                    WHEN t.organization_id IS NULL THEN todo_access_role(t.id, p_user_id)
+                    ELSE todo_share_role(t.id, p_user_id)
+                END
+            WHEN t.organization_id = p_org_id THEN
+                CASE
+                    WHEN p_org_role = 'owner' OR t.user_id = p_user_id OR p.user_id = p_user_id THEN 'owner'
+                    ELSE COALESCE(todo_share_role(t.id, p_user_id), p_org_role)
+                END
+        END
+    FROM
+        todos t
+        LEFT JOIN todos p ON p.id = t.parent_todo_id
+    WHERE
+        t.id = p_todo_id;
+$$;
+
+CREATE OR REPLACE FUNCTION category_access_role(p_category_id UUID, p_user_id TEXT, p_org_id TEXT, p_org_role TEXT)
+    RETURNS TEXT
+    LANGUAGE sql
+    STABLE
+    AS $$
+    SELECT
+        CASE
+            WHEN p_org_id IS NULL THEN
+                CASE
+                    WHEN c.organization_id IS NULL THEN category_access_role(c.id, p_user_id)
+                    ELSE category_share_role(c.id, p_user_id)
+                END
+            WHEN c.organization_id = p_org_id THEN
+                CASE
+                    WHEN p_org_role = 'owner' OR c.user_id = p_user_id THEN 'owner'
+                    ELSE COALESCE(category_share_role(c.id, p_user_id), p_org_role)
+                END
+        END
+    FROM
+        todo_categories c
+    WHERE
+        c.id = p_category_id;
+$$;
@@ -0,0 +1,138 @@
+-- Category shares cover the whole subtree: a share on a category grants the same role
+-- on its subcategories at any depth and on the todos filed under them. UNION rather
+-- than UNION ALL keeps the walks finite should parent_id ever form a cycle.
+
+-- The category and its ancestors, nearest first.
+CREATE OR REPLACE FUNCTION category_ancestor_ids(p_category_id UUID)
+    RETURNS SETOF UUID
+    LANGUAGE sql
+    STABLE
+    AS $$
+    WITH RECURSIVE ancestors AS (
+        SELECT
+            id,
+            parent_id
+        FROM
+            todo_categories
+        WHERE
+            id = p_category_id
+        UNION
+        SELECT
+            c.id,
+            c.parent_id
+        FROM
+            todo_categories c
+            JOIN ancestors a ON c.id = a.parent_id
+    )
+    SELECT
+        id
+    FROM
+        ancestors;
+$$;
+
+-- The categories a user reaches through accepted category shares, with their subtrees.
+CREATE OR REPLACE FUNCTION shared_category_ids(p_user_id TEXT)
+    RETURNS SETOF UUID
+    LANGUAGE sql
+    STABLE
+    AS $$
+    WITH RECURSIVE shared AS (
+        SELECT
+            category_id AS id
+        FROM
+            todo_shares
+        WHERE
+            user_id = p_user_id
+            AND status = 'accepted'
+            AND category_id IS NOT NULL
+        UNION
+        SELECT
+            c.id
+        FROM
+            todo_categories c
+            JOIN shared s ON c.parent_id = s.id
+    )
+    SELECT
+        id
+    FROM
+        shared;
+$$;
+
+CREATE OR REPLACE FUNCTION todo_share_role(p_todo_id UUID, p_user_id TEXT)
+    RETURNS TEXT
+    LANGUAGE sql
+    STABLE
+    AS $$
+    SELECT
+        s.role
+    FROM
+        todos t
+        LEFT JOIN todos p ON p.id = t.parent_todo_id
+        JOIN todo_shares s ON s.user_id = p_user_id
+        AND s.status = 'accepted'
+        AND (
+            s.todo_id IN (t.id, t.parent_todo_id)
+            OR s.category_id IN (
+                SELECT category_ancestor_ids(t.category_id)
+                UNION
+                SELECT category_ancestor_ids(p.category_id)
+            )
+        )
+    WHERE
+        t.id = p_todo_id
+    ORDER BY
+        share_role_rank(s.role) DESC
+    LIMIT 1;
+$$;
+
+CREATE OR REPLACE FUNCTION category_share_role(p_category_id UUID, p_user_id TEXT)
+    RETURNS TEXT
+    LANGUAGE sql
+    STABLE
+    AS $$
+    SELECT
+        s.role
+    FROM
+        todo_shares s
+    WHERE
+        s.user_id = p_user_id
+        AND s.status = 'accepted'
+        AND s.category_id IN (SELECT category_ancestor_ids(p_category_id))
+    ORDER BY
+        share_role_rank(s.role) DESC
+    LIMIT 1;
+$$;
+
+-- Personal sessions on personal todos and categories resolve shares the same way.
+CREATE OR REPLACE FUNCTION todo_access_role(p_todo_id UUID, p_user_id TEXT)
+    RETURNS TEXT
+    LANGUAGE sql
+    STABLE
+    AS $$
+    SELECT
+        CASE
+            WHEN t.user_id = p_user_id OR p.user_id = p_user_id THEN 'owner'
+            ELSE todo_share_role(t.id, p_user_id)
+        END
+    FROM
+        todos t
+        LEFT JOIN todos p ON p.id = t.parent_todo_id
+    WHERE
+        t.id = p_todo_id;
+$$;
+
+CREATE OR REPLACE FUNCTION category_access_role(p_category_id UUID, p_user_id TEXT)
+    RETURNS TEXT
+    LANGUAGE sql
+    STABLE
+    AS $$
+    SELECT
+        CASE
+            WHEN c.user_id = p_user_id THEN 'owner'
+            ELSE category_share_role(c.id, p_user_id)
+        END
+    FROM
+        todo_categories c
+    WHERE
+        c.id = p_category_id;
+$$;
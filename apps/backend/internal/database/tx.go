@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/sqlerr"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// txMaxAttempts is how often a unit of work runs before a serialization failure
+	// is returned to the caller
+	txMaxAttempts = 4
+	txRetryDelay  = 10 * time.Millisecond
+)
+
+// Querier runs queries on the pool or on a transaction, so repositories work the
+// same inside and outside a unit of work. Begin on a transaction starts a
+// savepoint.
+type Querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// TxFunc is the body of a unit of work. It may run more than once, so it must not
+// have effects outside the database; register those with AfterCommit.
+type TxFunc func(ctx context.Context) error
+
+type unitOfWorkKey struct{}
+
+// unitOfWork is the transaction carried in the context of a TxFunc, shared by
+// nested units of work.
+type unitOfWork struct {
+	tx          pgx.Tx
+	afterCommit *[]func()
+}
+
+// Querier returns the transaction of the unit of work ctx belongs to, or the pool
+// when there is none.
+func (db *Database) Querier(ctx context.Context) Querier {
+	if uow, ok := ctx.Value(unitOfWorkKey{}).(*unitOfWork); ok {
+		return uow.tx
+	}
+	return db.Pool
+}
+
+// WithTx runs fn as a unit of work: every repository call made with the context
+// fn gets runs in one transaction, committed when fn returns nil and rolled back
+// otherwise. Called inside another unit of work, fn runs in a savepoint of its
+// transaction instead, so its failure can be handled without losing the outer work.
+// Serialization failures and deadlocks roll back and run the outermost unit of
+// work again.
+func (db *Database) WithTx(ctx context.Context, fn TxFunc) error {
+	return db.WithTxOptions(ctx, pgx.TxOptions{}, fn)
+}
+
+// WithTxOptions is WithTx with transaction options such as the isolation level.
+// Nested units of work run with the options of the outermost one.
+func (db *Database) WithTxOptions(ctx context.Context, opts pgx.TxOptions, fn TxFunc) error {
+	if outer, ok := ctx.Value(unitOfWorkKey{}).(*unitOfWork); ok {
+		return runSavepoint(ctx, outer, fn)
+	}
+
+	for attempt := 1; ; attempt++ {
+		hooks, err := db.runTx(ctx, opts, fn)
+		if err == nil {
+			for _, hook := range hooks {
+				hook()
+			}
+			return nil
+		}
+
+		if !IsRetryable(err) || attempt == txMaxAttempts {
+			return err
+		}
+
+		// Back off with jitter so the transactions that collided don't collide again
+		delay := txRetryDelay<<(attempt-1) + rand.N(txRetryDelay)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (db *Database) runTx(ctx context.Context, opts pgx.TxOptions, fn TxFunc) ([]func(), error) {
+	tx, err := db.Pool.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	uow := &unitOfWork{tx: tx, afterCommit: &[]func(){}}
+	if err := fn(context.WithValue(ctx, unitOfWorkKey{}, uow)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return *uow.afterCommit, nil
+}
+
+func runSavepoint(ctx context.Context, outer *unitOfWork, fn TxFunc) error {
+	savepoint, err := outer.tx.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	defer savepoint.Rollback(ctx)
+
+	// Hooks registered by work that is rolled back are dropped with it
+	hooks := len(*outer.afterCommit)
+
+	uow := &unitOfWork{tx: savepoint, afterCommit: outer.afterCommit}
+	if err := fn(context.WithValue(ctx, unitOfWorkKey{}, uow)); err != nil {
+		*outer.afterCommit = (*outer.afterCommit)[:hooks]
+		return err
+	}
+
+	if err := savepoint.Commit(ctx); err != nil {
+		*outer.afterCommit = (*outer.afterCommit)[:hooks]
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+
+	return nil
+}
+
+// AfterCommit runs hook once the unit of work ctx belongs to commits, and drops it
+// if the work is rolled back. Outside a unit of work the hook runs right away.
+// Hooks are for effects outside the database, such as deleting files or enqueueing
+// tasks, that must only happen when the data they follow is saved.
+func AfterCommit(ctx context.Context, hook func()) {
+	uow, ok := ctx.Value(unitOfWorkKey{}).(*unitOfWork)
+	if !ok {
+		hook()
+		return
+	}
+	*uow.afterCommit = append(*uow.afterCommit, hook)
+}
+
+// IsRetryable reports whether err is a serialization failure or deadlock, after
+// which running the transaction again can succeed.
+func IsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	code := sqlerr.MapCode(pgErr.Code)
+	return code == sqlerr.SerializationFailure || code == sqlerr.DeadlockDetected
+}
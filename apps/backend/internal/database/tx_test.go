@@ -0,0 +1,264 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ApoorvYdv/go-tasker/internal/database"
+	tasktest "github.com/ApoorvYdv/go-tasker/internal/testing"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, database.IsRetryable(&pgconn.PgError{Code: "40001"}))
+	assert.True(t, database.IsRetryable(&pgconn.PgError{Code: "40P01"}))
+	assert.True(t, database.IsRetryable(errors.Join(errors.New("wrapped"), &pgconn.PgError{Code: "40001"})))
+	assert.False(t, database.IsRetryable(&pgconn.PgError{Code: "23505"}))
+	assert.False(t, database.IsRetryable(errors.New("not a database error")))
+}
+
+func TestWithTx(t *testing.T) {
+	testDB, srv, cleanup := tasktest.SetupTest(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	db := srv.DB
+
+	_, err := testDB.Pool.Exec(ctx, `CREATE TABLE tx_test (name TEXT PRIMARY KEY)`)
+	require.NoError(t, err)
+
+	reset := func(t *testing.T) {
+		t.Helper()
+		_, err := testDB.Pool.Exec(ctx, `DELETE FROM tx_test`)
+		require.NoError(t, err)
+	}
+
+	insert := func(ctx context.Context, name string) error {
+		_, err := db.Querier(ctx).Exec(ctx, `INSERT INTO tx_test (name) VALUES ($1)`, name)
+		return err
+	}
+
+	names := func(t *testing.T) []string {
+		t.Helper()
+
+		rows, err := testDB.Pool.Query(ctx, `SELECT name FROM tx_test ORDER BY name`)
+		require.NoError(t, err)
+		names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		require.NoError(t, err)
+		return names
+	}
+
+	serializationFailure := &pgconn.PgError{Code: "40001", Message: "could not serialize access"}
+
+	t.Run("commits the unit of work", func(t *testing.T) {
+		reset(t)
+
+		var hooks int
+		err := db.WithTx(ctx, func(ctx context.Context) error {
+			assert.NotEqual(t, database.Querier(testDB.Pool), db.Querier(ctx))
+
+			if err := insert(ctx, "a"); err != nil {
+				return err
+			}
+			database.AfterCommit(ctx, func() { hooks++ })
+
+			// Not visible outside the transaction before it commits
+			assert.Empty(t, names(t))
+			assert.Zero(t, hooks)
+			return insert(ctx, "b")
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"a", "b"}, names(t))
+		assert.Equal(t, 1, hooks)
+	})
+
+	t.Run("rolls back when the work fails", func(t *testing.T) {
+		reset(t)
+
+		var hooks int
+		failed := errors.New("failed")
+		err := db.WithTx(ctx, func(ctx context.Context) error {
+			require.NoError(t, insert(ctx, "a"))
+			database.AfterCommit(ctx, func() { hooks++ })
+			return failed
+		})
+		assert.ErrorIs(t, err, failed)
+
+		assert.Empty(t, names(t))
+		assert.Zero(t, hooks)
+	})
+
+	t.Run("runs hooks right away outside a unit of work", func(t *testing.T) {
+		var hooks int
+		database.AfterCommit(ctx, func() { hooks++ })
+		assert.Equal(t, 1, hooks)
+	})
+
+	t.Run("retries serialization failures", func(t *testing.T) {
+		reset(t)
+
+		var attempts, hooks int
+		err := db.WithTx(ctx, func(ctx context.Context) error {
+			attempts++
+			if err := insert(ctx, "a"); err != nil {
+				return err
+			}
+			database.AfterCommit(ctx, func() { hooks++ })
+
+			if attempts < 3 {
+				return serializationFailure
+			}
+			return nil
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, []string{"a"}, names(t))
+		// Hooks of the attempts that were rolled back are dropped
+		assert.Equal(t, 1, hooks)
+	})
+
+	t.Run("gives up after the last attempt", func(t *testing.T) {
+		reset(t)
+
+		var attempts int
+		err := db.WithTx(ctx, func(ctx context.Context) error {
+			attempts++
+			return serializationFailure
+		})
+		assert.True(t, database.IsRetryable(err))
+		assert.Equal(t, 4, attempts)
+	})
+
+	t.Run("doesn't retry other failures", func(t *testing.T) {
+		reset(t)
+
+		var attempts int
+		err := db.WithTx(ctx, func(ctx context.Context) error {
+			attempts++
+			if err := insert(ctx, "a"); err != nil {
+				return err
+			}
+			return insert(ctx, "a")
+		})
+		require.Error(t, err)
+		assert.False(t, database.IsRetryable(err))
+		assert.Equal(t, 1, attempts)
+		assert.Empty(t, names(t))
+	})
+
+	t.Run("retries a real serialization conflict", func(t *testing.T) {
+		reset(t)
+
+		opts := pgx.TxOptions{IsoLevel: pgx.Serializable}
+
+		// Both transactions read the whole table before writing to it, so they can't
+		// both commit
+		readAndInsert := func(ctx context.Context, name string, beforeInsert func()) error {
+			var count int
+			if err := db.Querier(ctx).QueryRow(ctx, `SELECT COUNT(*) FROM tx_test`).Scan(&count); err != nil {
+				return err
+			}
+			beforeInsert()
+			return insert(ctx, name)
+		}
+
+		var attempts int
+		err := db.WithTxOptions(ctx, opts, func(txCtx context.Context) error {
+			attempts++
+			return readAndInsert(txCtx, "a", func() {
+				if attempts > 1 {
+					return
+				}
+				// Another transaction read the table too, and commits first
+				err := db.WithTxOptions(ctx, opts, func(ctx context.Context) error {
+					return readAndInsert(ctx, "b", func() {})
+				})
+				require.NoError(t, err)
+			})
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, attempts)
+		assert.Equal(t, []string{"a", "b"}, names(t))
+	})
+
+	t.Run("nested unit of work rolls back to its savepoint", func(t *testing.T) {
+		reset(t)
+
+		var hooks []string
+		failed := errors.New("failed")
+		err := db.WithTx(ctx, func(ctx context.Context) error {
+			if err := insert(ctx, "outer"); err != nil {
+				return err
+			}
+			database.AfterCommit(ctx, func() { hooks = append(hooks, "outer") })
+
+			err := db.WithTx(ctx, func(ctx context.Context) error {
+				require.NoError(t, insert(ctx, "nested"))
+				database.AfterCommit(ctx, func() { hooks = append(hooks, "nested") })
+				return failed
+			})
+			assert.ErrorIs(t, err, failed)
+
+			// The transaction is still usable after the savepoint rolled back
+			return insert(ctx, "outer-after")
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"outer", "outer-after"}, names(t))
+		assert.Equal(t, []string{"outer"}, hooks)
+	})
+
+	t.Run("nested unit of work commits with the outer one", func(t *testing.T) {
+		reset(t)
+
+		var hooks []string
+		failed := errors.New("failed")
+		err := db.WithTx(ctx, func(ctx context.Context) error {
+			err := db.WithTx(ctx, func(ctx context.Context) error {
+				database.AfterCommit(ctx, func() { hooks = append(hooks, "nested") })
+				return insert(ctx, "nested")
+			})
+			require.NoError(t, err)
+
+			// Released but not committed until the outer unit of work is
+			assert.Empty(t, names(t))
+			return failed
+		})
+		assert.ErrorIs(t, err, failed)
+
+		assert.Empty(t, names(t))
+		assert.Empty(t, hooks)
+	})
+
+	t.Run("serialization failure in a nested unit of work retries the outer one", func(t *testing.T) {
+		reset(t)
+
+		var outer, nested int
+		err := db.WithTx(ctx, func(ctx context.Context) error {
+			outer++
+			if err := insert(ctx, "outer"); err != nil {
+				return err
+			}
+
+			return db.WithTx(ctx, func(ctx context.Context) error {
+				nested++
+				if nested == 1 {
+					return serializationFailure
+				}
+				return insert(ctx, "nested")
+			})
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, outer)
+		assert.Equal(t, 2, nested)
+		assert.Equal(t, []string{"nested", "outer"}, names(t))
+	})
+}
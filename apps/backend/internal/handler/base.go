@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"net/http"
 	"time"
 
+	"github.com/ApoorvYdv/go-tasker/internal/lib/storage"
 	"github.com/ApoorvYdv/go-tasker/internal/middleware"
+	"github.com/ApoorvYdv/go-tasker/internal/model"
 	"github.com/ApoorvYdv/go-tasker/internal/server"
 	"github.com/ApoorvYdv/go-tasker/internal/validation"
 	"github.com/labstack/echo/v4"
@@ -68,7 +71,8 @@ func (h NoContentResponseHandler) AddAttributes(txn *newrelic.Transaction, resul
 	// http.status_code is already set by tracing middleware
 }
 
-// FileResponseHandler handles file responses
+// FileResponseHandler handles file responses, either a full []byte or a
+// *model.FileStream that carries its own name and content type
 type FileResponseHandler struct {
 	status      int
 	filename    string
@@ -76,11 +80,30 @@ type FileResponseHandler struct {
 }
 
 func (h FileResponseHandler) Handle(c echo.Context, result interface{}) error {
+	if stream, ok := result.(*model.FileStream); ok {
+		return h.handleStream(c, stream)
+	}
+
 	data := result.([]byte)
-	c.Response().Header().Set("Content-Disposition", "attachment; filename="+h.filename)
+	c.Response().Header().Set(echo.HeaderContentDisposition, storage.ContentDisposition(h.filename))
 	return c.Blob(h.status, h.contentType, data)
 }
 
+func (h FileResponseHandler) handleStream(c echo.Context, stream *model.FileStream) error {
+	// The server-wide write timeout would otherwise cut large downloads off
+	if err := http.NewResponseController(c.Response()).SetWriteDeadline(time.Time{}); err != nil {
+		logger := middleware.GetLogger(c)
+		logger.Warn().Err(err).Msg("could not clear write deadline for file stream")
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, stream.ContentType)
+	res.Header().Set(echo.HeaderContentDisposition, storage.ContentDisposition(stream.Filename))
+	res.WriteHeader(h.status)
+
+	return stream.Write(res)
+}
+
 func (h FileResponseHandler) GetOperation() string {
 	return "handler_file"
 }
@@ -88,6 +111,11 @@ func (h FileResponseHandler) GetOperation() string {
 func (h FileResponseHandler) AddAttributes(txn *newrelic.Transaction, result interface{}) {
 	if txn != nil {
 		// http.status_code is already set by tracing middleware
+		if stream, ok := result.(*model.FileStream); ok {
+			txn.AddAttribute("file.name", stream.Filename)
+			txn.AddAttribute("file.content_type", stream.ContentType)
+			return
+		}
 		txn.AddAttribute("file.name", h.filename)
 		txn.AddAttribute("file.content_type", h.contentType)
 		if data, ok := result.([]byte); ok {
@@ -123,8 +151,8 @@ func handleRequest[Req validation.Validatable](
 		Str("path", path).
 		Str("route", route)
 
-	// Add file-specific fields to logger if it's a file handler
-	if fileHandler, ok := responseHandler.(FileResponseHandler); ok {
+	// Add file-specific fields to logger if it's a file handler with a fixed name
+	if fileHandler, ok := responseHandler.(FileResponseHandler); ok && fileHandler.filename != "" {
 		loggerBuilder = loggerBuilder.
 			Str("filename", fileHandler.filename).
 			Str("content_type", fileHandler.contentType)
@@ -239,6 +267,21 @@ func HandleFile[Req validation.Validatable](
 	}
 }
 
+// HandleFileStream is HandleFile for files streamed as they are produced, named by
+// the handler's result
+func HandleFileStream[Req validation.Validatable](
+	h Handler,
+	handler HandlerFunc[Req, *model.FileStream],
+	status int,
+	req Req,
+) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return handleRequest(c, req, func(c echo.Context, req Req) (interface{}, error) {
+			return handler(c, req)
+		}, FileResponseHandler{status: status})
+	}
+}
+
 // HandleNoContent wraps a handler with validation, error handling, logging, metrics, and tracing for endpoints that don't return content
 func HandleNoContent[Req validation.Validatable](
 	h Handler,
@@ -39,7 +39,7 @@ func (h *CategoryHandler) GetCategories(c echo.Context) error {
 	return Handle(
 		h.Handler,
 		func(c echo.Context, query *category.GetCategoriesQuery) (
-			*model.PaginatedResponse[category.Category], error,
+			*model.PaginatedResponse[category.PopulatedCategory], error,
 		) {
 			userID := middleware.GetUserID(c)
 			return h.categoryService.GetCategories(c, userID, query)
@@ -72,3 +72,27 @@ func (h *CategoryHandler) DeleteCategory(c echo.Context) error {
 		&category.DeleteCategoryPayload{},
 	)(c)
 }
+
+func (h *CategoryHandler) ArchiveCategory(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, payload *category.ArchiveCategoryPayload) (*category.Category, error) {
+			userID := middleware.GetUserID(c)
+			return h.categoryService.ArchiveCategory(c, userID, payload.ID)
+		},
+		http.StatusOK,
+		&category.ArchiveCategoryPayload{},
+	)(c)
+}
+
+func (h *CategoryHandler) UnarchiveCategory(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, payload *category.ArchiveCategoryPayload) (*category.Category, error) {
+			userID := middleware.GetUserID(c)
+			return h.categoryService.UnarchiveCategory(c, userID, payload.ID)
+		},
+		http.StatusOK,
+		&category.ArchiveCategoryPayload{},
+	)(c)
+}
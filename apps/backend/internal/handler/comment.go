@@ -58,6 +58,18 @@ func (h *CommentHandler) UpdateComment(c echo.Context) error {
 	)(c)
 }
 
+func (h *CommentHandler) GetCommentRevisions(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, payload *comment.GetCommentRevisionsPayload) ([]comment.Revision, error) {
+			userID := middleware.GetUserID(c)
+			return h.commentService.GetCommentRevisions(c, userID, payload.ID)
+		},
+		http.StatusOK,
+		&comment.GetCommentRevisionsPayload{},
+	)(c)
+}
+
 func (h *CommentHandler) DeleteComment(c echo.Context) error {
 	return HandleNoContent(
 		h.Handler,
@@ -69,3 +81,15 @@ func (h *CommentHandler) DeleteComment(c echo.Context) error {
 		&comment.DeleteCommentPayload{},
 	)(c)
 }
+
+func (h *CommentHandler) ToggleReaction(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, payload *comment.ToggleReactionPayload) ([]comment.Reaction, error) {
+			userID := middleware.GetUserID(c)
+			return h.commentService.ToggleReaction(c, userID, payload)
+		},
+		http.StatusOK,
+		&comment.ToggleReactionPayload{},
+	)(c)
+}
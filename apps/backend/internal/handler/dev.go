@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ApoorvYdv/go-tasker/internal/errs"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/email"
+	"github.com/ApoorvYdv/go-tasker/internal/server"
+	"github.com/labstack/echo/v4"
+)
+
+// DevHandler serves development tools. Its routes are only registered outside production.
+type DevHandler struct {
+	Handler
+}
+
+func NewDevHandler(s *server.Server) *DevHandler {
+	return &DevHandler{
+		Handler: NewHandler(s),
+	}
+}
+
+// PreviewEmail renders an email template with its preview data. Pass ?format=text
+// for the plain-text part. Templates are parsed on every request so edits show up
+// on reload.
+func (h *DevHandler) PreviewEmail(c echo.Context) error {
+	renderer, err := email.NewRenderer()
+	if err != nil {
+		return fmt.Errorf("failed to load email templates: %w", err)
+	}
+
+	rendered, err := renderer.Preview(email.Template(c.Param("template")))
+	if errors.Is(err, email.ErrUnknownTemplate) {
+		return errs.NewNotFoundError("Email template not found", false, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to render email preview: %w", err)
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	if c.QueryParam("format") == "text" {
+		return c.String(http.StatusOK, rendered.Text)
+	}
+
+	return c.HTML(http.StatusOK, rendered.HTML)
+}
@@ -6,19 +6,31 @@ import (
 )
 
 type Handlers struct {
-	Health   *HealthHandler
-	OpenAPI  *OpenAPIHandler
-	Todo     *TodoHandler
-	Category *CategoryHandler
-	Comment  *CommentHandler
+	Health       *HealthHandler
+	OpenAPI      *OpenAPIHandler
+	Todo         *TodoHandler
+	Category     *CategoryHandler
+	Comment      *CommentHandler
+	Stream       *StreamHandler
+	Share        *ShareHandler
+	Notification *NotificationHandler
+	Inbound      *InboundHandler
+	Storage      *StorageHandler
+	Dev          *DevHandler
 }
 
 func NewHandlers(s *server.Server, services *service.Services) *Handlers {
 	return &Handlers{
-		Health:   NewHealthHandler(s),
-		OpenAPI:  NewOpenAPIHandler(s),
-		Todo:     NewTodoHandler(s, services.Todo),
-		Category: NewCategoryHandler(s, services.Category),
-		Comment:  NewCommentHandler(s, services.Comment),
+		Health:       NewHealthHandler(s),
+		OpenAPI:      NewOpenAPIHandler(s),
+		Todo:         NewTodoHandler(s, services.Todo),
+		Category:     NewCategoryHandler(s, services.Category),
+		Comment:      NewCommentHandler(s, services.Comment),
+		Stream:       NewStreamHandler(s),
+		Share:        NewShareHandler(s, services.Share),
+		Notification: NewNotificationHandler(s, services.Notification),
+		Inbound:      NewInboundHandler(s, services.Inbound),
+		Storage:      NewStorageHandler(s, services.Storage),
+		Dev:          NewDevHandler(s),
 	}
 }
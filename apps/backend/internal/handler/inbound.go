@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/ApoorvYdv/go-tasker/internal/errs"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/inboundmail"
+	"github.com/ApoorvYdv/go-tasker/internal/middleware"
+	"github.com/ApoorvYdv/go-tasker/internal/model/inbound"
+	"github.com/ApoorvYdv/go-tasker/internal/server"
+	"github.com/ApoorvYdv/go-tasker/internal/service"
+	"github.com/labstack/echo/v4"
+)
+
+// maxInboundEmailSize bounds the raw webhook body, attachments included
+const maxInboundEmailSize = 25 << 20
+
+type InboundHandler struct {
+	Handler
+	inboundService *service.InboundService
+}
+
+func NewInboundHandler(s *server.Server, inboundService *service.InboundService) *InboundHandler {
+	return &InboundHandler{
+		Handler:        NewHandler(s),
+		inboundService: inboundService,
+	}
+}
+
+func (h *InboundHandler) GetInboundAddress(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, payload *inbound.GetInboundAddressPayload) (*inbound.Address, error) {
+			userID := middleware.GetUserID(c)
+			return h.inboundService.GetAddress(c, userID)
+		},
+		http.StatusOK,
+		&inbound.GetInboundAddressPayload{},
+	)(c)
+}
+
+func (h *InboundHandler) RotateInboundAddress(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, payload *inbound.RotateInboundAddressPayload) (*inbound.Address, error) {
+			userID := middleware.GetUserID(c)
+			return h.inboundService.RotateAddress(c, userID)
+		},
+		http.StatusOK,
+		&inbound.RotateInboundAddressPayload{},
+	)(c)
+}
+
+// ReceiveEmail is the webhook mail providers post inbound email to. It accepts either
+// the raw MIME message or the provider's JSON format, authenticated with the shared
+// secret as the basic auth password.
+func (h *InboundHandler) ReceiveEmail(c echo.Context) error {
+	logger := middleware.GetLogger(c)
+	cfg := h.server.Config.Integration
+
+	if !cfg.InboundEmailEnabled() {
+		return errs.NewNotFoundError("Inbound email is not enabled", false, nil)
+	}
+
+	_, password, _ := c.Request().BasicAuth()
+	if subtle.ConstantTimeCompare([]byte(password), []byte(cfg.InboundEmailSecret)) != 1 {
+		logger.Warn().Str("ip", c.RealIP()).Msg("inbound email webhook called with an invalid secret")
+		return errs.NewUnauthorizedError("Unauthorized", false)
+	}
+
+	body := http.MaxBytesReader(c.Response(), c.Request().Body, maxInboundEmailSize)
+	defer body.Close()
+
+	var msg *inboundmail.Message
+	var err error
+
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType == echo.MIMEApplicationJSON {
+		var data []byte
+		data, err = io.ReadAll(body)
+		if err == nil {
+			msg, err = inboundmail.ParseJSON(data)
+		}
+	} else {
+		msg, err = inboundmail.ParseMIME(body)
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "Inbound email is too large")
+	case errors.Is(err, inboundmail.ErrInvalidMessage):
+		return errs.NewBadRequestError(err.Error(), false, nil, nil, nil)
+	case err != nil:
+		logger.Error().Err(err).Msg("failed to read inbound email")
+		return err
+	}
+
+	result, err := h.inboundService.ProcessEmail(c, msg)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ApoorvYdv/go-tasker/internal/middleware"
+	"github.com/ApoorvYdv/go-tasker/internal/model/notification"
+	"github.com/ApoorvYdv/go-tasker/internal/server"
+	"github.com/ApoorvYdv/go-tasker/internal/service"
+	"github.com/labstack/echo/v4"
+)
+
+type NotificationHandler struct {
+	Handler
+	notificationService *service.NotificationService
+}
+
+func NewNotificationHandler(s *server.Server, notificationService *service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		Handler:             NewHandler(s),
+		notificationService: notificationService,
+	}
+}
+
+func (h *NotificationHandler) GetNotifications(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, query *notification.GetNotificationsQuery) (*notification.Inbox, error) {
+			userID := middleware.GetUserID(c)
+			return h.notificationService.GetNotifications(c, userID, query)
+		},
+		http.StatusOK,
+		&notification.GetNotificationsQuery{},
+	)(c)
+}
+
+func (h *NotificationHandler) GetUnreadCount(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, payload *notification.GetUnreadCountPayload) (*notification.UnreadCount, error) {
+			userID := middleware.GetUserID(c)
+			return h.notificationService.GetUnreadCount(c, userID)
+		},
+		http.StatusOK,
+		&notification.GetUnreadCountPayload{},
+	)(c)
+}
+
+func (h *NotificationHandler) MarkNotificationRead(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, payload *notification.MarkNotificationReadPayload) (*notification.Notification, error) {
+			userID := middleware.GetUserID(c)
+			return h.notificationService.MarkRead(c, userID, payload.ID)
+		},
+		http.StatusOK,
+		&notification.MarkNotificationReadPayload{},
+	)(c)
+}
+
+func (h *NotificationHandler) MarkAllNotificationsRead(c echo.Context) error {
+	return HandleNoContent(
+		h.Handler,
+		func(c echo.Context, payload *notification.MarkAllNotificationsReadPayload) error {
+			userID := middleware.GetUserID(c)
+			return h.notificationService.MarkAllRead(c, userID)
+		},
+		http.StatusNoContent,
+		&notification.MarkAllNotificationsReadPayload{},
+	)(c)
+}
+
+func (h *NotificationHandler) ArchiveNotification(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, payload *notification.ArchiveNotificationPayload) (*notification.Notification, error) {
+			userID := middleware.GetUserID(c)
+			return h.notificationService.Archive(c, userID, payload.ID)
+		},
+		http.StatusOK,
+		&notification.ArchiveNotificationPayload{},
+	)(c)
+}
+
+func (h *NotificationHandler) GetPreferences(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, payload *notification.GetPreferencesPayload) (*notification.Preferences, error) {
+			userID := middleware.GetUserID(c)
+			return h.notificationService.GetPreferences(c, userID)
+		},
+		http.StatusOK,
+		&notification.GetPreferencesPayload{},
+	)(c)
+}
+
+func (h *NotificationHandler) UpdatePreferences(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, payload *notification.UpdatePreferencesPayload) (*notification.Preferences, error) {
+			userID := middleware.GetUserID(c)
+			return h.notificationService.UpdatePreferences(c, userID, payload)
+		},
+		http.StatusOK,
+		&notification.UpdatePreferencesPayload{},
+	)(c)
+}
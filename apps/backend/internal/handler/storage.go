@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/ApoorvYdv/go-tasker/internal/errs"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/filetype"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/storage"
+	"github.com/ApoorvYdv/go-tasker/internal/middleware"
+	"github.com/ApoorvYdv/go-tasker/internal/server"
+	"github.com/labstack/echo/v4"
+)
+
+type StorageHandler struct {
+	Handler
+	storage storage.Storage
+}
+
+func NewStorageHandler(s *server.Server, storage storage.Storage) *StorageHandler {
+	return &StorageHandler{
+		Handler: NewHandler(s),
+		storage: storage,
+	}
+}
+
+// ServeObject serves the signed download URLs of the local storage backend. S3
+// URLs point at the bucket, so the route 404s with any other backend.
+func (h *StorageHandler) ServeObject(c echo.Context) error {
+	logger := middleware.GetLogger(c)
+
+	local, ok := h.storage.(*storage.LocalStorage)
+	if !ok {
+		return errs.NewNotFoundError("Not found", false, nil)
+	}
+
+	key, err := url.PathUnescape(c.Param("*"))
+	if err != nil {
+		return errs.NewNotFoundError("Not found", false, nil)
+	}
+
+	if err := local.VerifyDownload(key, c.QueryParams()); err != nil {
+		return errs.NewForbiddenError("Download link is invalid or has expired", false)
+	}
+
+	body, object, err := local.Get(c.Request().Context(), key)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		return errs.NewNotFoundError("File not found", false, nil)
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("storage_key", key).Msg("failed to open stored file")
+		return err
+	}
+	defer body.Close()
+
+	// Only types browsers render harmlessly may display inline; anything else, such
+	// as HTML, is downloaded so it can't run script on the app's origin
+	var disposition string
+	switch filename := c.QueryParam("filename"); {
+	case filename != "":
+		disposition = storage.ContentDisposition(filename)
+	case filetype.Inline(object.ContentType):
+		disposition = storage.InlineDisposition("")
+	default:
+		disposition = storage.ContentDisposition(path.Base(key))
+	}
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, object.ContentType)
+	header.Set(echo.HeaderContentDisposition, disposition)
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+	header.Set("ETag", `"`+object.ETag+`"`)
+	header.Set("Cache-Control", "private, max-age=0")
+
+	// Local objects are files, so ServeContent can answer range requests
+	http.ServeContent(c.Response(), c.Request(), path.Base(key), object.LastModified, body.(io.ReadSeeker))
+	return nil
+}
+
+// ReceiveObject accepts uploads to the presigned upload URLs of the local storage
+// backend, enforcing the signed content type and size the way S3 does.
+func (h *StorageHandler) ReceiveObject(c echo.Context) error {
+	logger := middleware.GetLogger(c)
+
+	local, ok := h.storage.(*storage.LocalStorage)
+	if !ok {
+		return errs.NewNotFoundError("Not found", false, nil)
+	}
+
+	key, err := url.PathUnescape(c.Param("*"))
+	if err != nil {
+		return errs.NewNotFoundError("Not found", false, nil)
+	}
+
+	size, err := local.VerifyUpload(key, c.Request().Header.Get(echo.HeaderContentType), c.QueryParams())
+	if err != nil {
+		return errs.NewForbiddenError("Upload link is invalid or has expired", false)
+	}
+
+	if c.Request().ContentLength != size {
+		return errs.NewBadRequestError("Upload size does not match the signed size", false, nil, nil, nil)
+	}
+
+	body := io.LimitReader(c.Request().Body, size)
+	if err := local.Put(c.Request().Context(), key, body, c.Request().Header.Get(echo.HeaderContentType)); err != nil {
+		logger.Error().Err(err).Str("storage_key", key).Msg("failed to store uploaded file")
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
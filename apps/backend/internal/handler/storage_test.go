@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/config"
+	"github.com/ApoorvYdv/go-tasker/internal/errs"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/storage"
+	"github.com/ApoorvYdv/go-tasker/internal/server"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageHandler_ServeObject(t *testing.T) {
+	ctx := context.Background()
+
+	local, err := storage.NewLocalStorage(&config.StorageConfig{
+		Backend:    "local",
+		LocalDir:   t.TempDir(),
+		SigningKey: "test-signing-key",
+		PublicURL:  "http://localhost:8080",
+	})
+	require.NoError(t, err)
+
+	objects := map[string]string{
+		"files/photo.png":  "image/png",
+		"files/page.html":  "text/html; charset=utf-8",
+		"files/image.svg":  "image/svg+xml",
+		"files/report.pdf": "application/pdf",
+	}
+	for key, contentType := range objects {
+		require.NoError(t, local.Put(ctx, key, strings.NewReader("content"), contentType))
+	}
+
+	h := NewStorageHandler(&server.Server{}, local)
+	e := echo.New()
+	e.GET("/api/v1/storage/*", h.ServeObject)
+
+	serve := func(t *testing.T, rawURL string) (*httptest.ResponseRecorder, error) {
+		t.Helper()
+
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, u.RequestURI(), nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/api/v1/storage/*")
+		c.SetParamNames("*")
+		c.SetParamValues(strings.TrimPrefix(u.EscapedPath(), storage.LocalPathPrefix))
+
+		return rec, h.ServeObject(c)
+	}
+
+	tests := []struct {
+		name        string
+		key         string
+		filename    string
+		disposition string
+	}{
+		{
+			name:        "allowlisted image displays inline",
+			key:         "files/photo.png",
+			disposition: "inline",
+		},
+		{
+			name:        "allowlisted pdf displays inline",
+			key:         "files/report.pdf",
+			disposition: "inline",
+		},
+		{
+			name:        "html downloads",
+			key:         "files/page.html",
+			disposition: "attachment; filename=page.html",
+		},
+		{
+			name:        "svg downloads",
+			key:         "files/image.svg",
+			disposition: "attachment; filename=image.svg",
+		},
+		{
+			name:        "signed filename downloads",
+			key:         "files/photo.png",
+			filename:    "holiday.png",
+			disposition: "attachment; filename=holiday.png",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawURL, err := local.Presign(ctx, tt.key, time.Minute, tt.filename)
+			require.NoError(t, err)
+
+			rec, err := serve(t, rawURL)
+			require.NoError(t, err)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "nosniff", rec.Header().Get(echo.HeaderXContentTypeOptions))
+			assert.Equal(t, tt.disposition, rec.Header().Get(echo.HeaderContentDisposition))
+			assert.Equal(t, objects[tt.key], rec.Header().Get(echo.HeaderContentType))
+			assert.Equal(t, "content", rec.Body.String())
+		})
+	}
+
+	t.Run("tampered signature is forbidden", func(t *testing.T) {
+		rawURL, err := local.Presign(ctx, "files/photo.png", time.Minute, "")
+		require.NoError(t, err)
+
+		_, err = serve(t, strings.Replace(rawURL, "files/photo.png", "files/page.html", 1))
+
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusForbidden, httpErr.Status)
+	})
+
+	t.Run("expired link is forbidden", func(t *testing.T) {
+		rawURL, err := local.Presign(ctx, "files/photo.png", -time.Minute, "")
+		require.NoError(t, err)
+
+		_, err = serve(t, rawURL)
+
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusForbidden, httpErr.Status)
+	})
+}
@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/lib/realtime"
+	"github.com/ApoorvYdv/go-tasker/internal/middleware"
+	"github.com/ApoorvYdv/go-tasker/internal/server"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	LastEventIDHeader = "Last-Event-ID"
+	streamWriteWait   = 10 * time.Second
+	streamPongWait    = 2 * realtime.HeartbeatInterval
+)
+
+type StreamHandler struct {
+	Handler
+	upgrader websocket.Upgrader
+}
+
+func NewStreamHandler(s *server.Server) *StreamHandler {
+	h := &StreamHandler{
+		Handler: NewHandler(s),
+	}
+
+	h.upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     h.checkOrigin,
+	}
+
+	return h
+}
+
+// streamWriter abstracts the wire format so SSE and WebSocket share one delivery loop
+type streamWriter interface {
+	WriteEvent(event realtime.Event) error
+	WriteHeartbeat() error
+}
+
+// Stream pushes the change events of the current workspace over WebSocket when the
+// request asks for an upgrade, and over Server-Sent Events otherwise.
+func (h *StreamHandler) Stream(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	if ws := middleware.GetWorkspace(c); ws.IsOrg() {
+		userID = realtime.OrgStreamKey(ws.OrgID)
+	}
+
+	lastEventID := c.Request().Header.Get(LastEventIDHeader)
+	if lastEventID == "" {
+		// Browsers can't set headers on WebSocket handshakes, so allow a query fallback
+		lastEventID = c.QueryParam("lastEventId")
+	}
+
+	if websocket.IsWebSocketUpgrade(c.Request()) {
+		return h.streamWebSocket(c, userID, lastEventID)
+	}
+
+	return h.streamSSE(c, userID, lastEventID)
+}
+
+func (h *StreamHandler) streamSSE(c echo.Context, userID, lastEventID string) error {
+	logger := middleware.GetLogger(c)
+	ctx := c.Request().Context()
+
+	sub, backlog, err := h.server.Realtime.Subscribe(ctx, userID, lastEventID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to subscribe to realtime stream")
+		return err
+	}
+	defer sub.Close()
+
+	// The server-wide write timeout would otherwise cut the stream off
+	if err := http.NewResponseController(c.Response()).SetWriteDeadline(time.Time{}); err != nil {
+		logger.Warn().Err(err).Msg("could not clear write deadline for event stream")
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	if _, err := fmt.Fprintf(res, "retry: %d\n\n", (3 * time.Second).Milliseconds()); err != nil {
+		return nil
+	}
+	res.Flush()
+
+	logger.Info().Str("transport", "sse").Int("backlog", len(backlog)).Msg("realtime stream opened")
+
+	h.pump(ctx, &sseWriter{res: res}, sub, backlog)
+
+	logger.Info().Str("transport", "sse").Msg("realtime stream closed")
+
+	return nil
+}
+
+func (h *StreamHandler) streamWebSocket(c echo.Context, userID, lastEventID string) error {
+	logger := middleware.GetLogger(c)
+
+	conn, err := h.upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		// The upgrader has already written an error response
+		logger.Warn().Err(err).Msg("websocket upgrade failed")
+		return nil
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(c.Request().Context())
+	defer cancel()
+
+	sub, backlog, err := h.server.Realtime.Subscribe(ctx, userID, lastEventID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to subscribe to realtime stream")
+		_ = conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "subscription failed"),
+			time.Now().Add(streamWriteWait))
+		return nil
+	}
+	defer sub.Close()
+
+	// The stream is server-to-client only; reading keeps control frames flowing
+	// and tells us when the client goes away.
+	conn.SetReadLimit(512)
+	_ = conn.SetReadDeadline(time.Now().Add(streamPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(streamPongWait))
+	})
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	logger.Info().Str("transport", "websocket").Int("backlog", len(backlog)).Msg("realtime stream opened")
+
+	h.pump(ctx, &wsWriter{conn: conn}, sub, backlog)
+
+	_ = conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseGoingAway, ""),
+		time.Now().Add(streamWriteWait))
+
+	logger.Info().Str("transport", "websocket").Msg("realtime stream closed")
+
+	return nil
+}
+
+// pump delivers the backlog followed by live events until the client disconnects,
+// a write fails, or the broker drops the subscription for falling behind.
+func (h *StreamHandler) pump(ctx context.Context, w streamWriter, sub *realtime.Subscription, backlog []realtime.Event) {
+	lastID := ""
+
+	for _, event := range backlog {
+		if err := w.WriteEvent(event); err != nil {
+			return
+		}
+		lastID = event.ID
+	}
+
+	heartbeat := time.NewTicker(realtime.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			// Skip events already delivered as part of the backlog
+			if !realtime.IsAfter(event.ID, lastID) {
+				continue
+			}
+			if err := w.WriteEvent(event); err != nil {
+				return
+			}
+			lastID = event.ID
+		case <-heartbeat.C:
+			if err := w.WriteHeartbeat(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (h *StreamHandler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get(echo.HeaderOrigin)
+	if origin == "" {
+		return true
+	}
+
+	allowed := h.server.Config.Server.CORSAllowedOrigins
+	return slices.Contains(allowed, "*") || slices.Contains(allowed, origin)
+}
+
+type sseWriter struct {
+	res *echo.Response
+}
+
+func (w *sseWriter) WriteEvent(event realtime.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w.res, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+		return err
+	}
+	w.res.Flush()
+
+	return nil
+}
+
+func (w *sseWriter) WriteHeartbeat() error {
+	if _, err := fmt.Fprint(w.res, ": heartbeat\n\n"); err != nil {
+		return err
+	}
+	w.res.Flush()
+
+	return nil
+}
+
+type wsWriter struct {
+	conn *websocket.Conn
+}
+
+func (w *wsWriter) WriteEvent(event realtime.Event) error {
+	if err := w.conn.SetWriteDeadline(time.Now().Add(streamWriteWait)); err != nil {
+		return err
+	}
+	return w.conn.WriteJSON(event)
+}
+
+func (w *wsWriter) WriteHeartbeat() error {
+	return w.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteWait))
+}
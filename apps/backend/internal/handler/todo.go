@@ -1,9 +1,15 @@
 package handler
 
 import (
+	"errors"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"time"
 
 	"github.com/ApoorvYdv/go-tasker/internal/errs"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/filetype"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/storage"
 	"github.com/ApoorvYdv/go-tasker/internal/middleware"
 	"github.com/ApoorvYdv/go-tasker/internal/model"
 	"github.com/ApoorvYdv/go-tasker/internal/model/todo"
@@ -96,30 +102,163 @@ func (h *TodoHandler) GetTodoStats(c echo.Context) error {
 	)(c)
 }
 
+// UploadTodoAttachment streams the first file part of a multipart request to
+// storage. It doesn't go through Handle, whose binding would spool the whole form
+// to disk before the service sees it.
 func (h *TodoHandler) UploadTodoAttachment(c echo.Context) error {
+	payload := &todo.UploadTodoAttachmentPayload{}
+	if err := (&echo.DefaultBinder{}).BindPathParams(c, payload); err != nil {
+		return errs.NewBadRequestError("invalid todo id", false, nil, nil, nil)
+	}
+	if err := payload.Validate(); err != nil {
+		return errs.ValidationError(err)
+	}
+
+	part, err := filePart(c)
+	if err != nil {
+		return err
+	}
+
+	userID := middleware.GetUserID(c)
+	attachment, err := h.todoService.UploadTodoAttachment(c, userID, payload.TodoID, part.FileName(), part)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, attachment)
+}
+
+// UploadAttachmentVersion streams a multipart "file" field as the new current
+// version of an attachment.
+func (h *TodoHandler) UploadAttachmentVersion(c echo.Context) error {
+	payload := &todo.AttachmentVersionsPayload{}
+	if err := (&echo.DefaultBinder{}).BindPathParams(c, payload); err != nil {
+		return errs.NewBadRequestError("invalid attachment id", false, nil, nil, nil)
+	}
+	if err := payload.Validate(); err != nil {
+		return errs.ValidationError(err)
+	}
+
+	part, err := filePart(c)
+	if err != nil {
+		return err
+	}
+
+	userID := middleware.GetUserID(c)
+	attachment, err := h.todoService.UploadAttachmentVersion(c, userID, payload.AttachmentID, part.FileName(), part)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, attachment)
+}
+
+// filePart finds the "file" field of a multipart upload, leaving the body unread
+// so the file can be streamed.
+func filePart(c echo.Context) (*multipart.Part, error) {
+	reader, err := c.Request().MultipartReader()
+	if err != nil {
+		return nil, errs.NewBadRequestError("multipart form not found", false, nil, nil, nil)
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, errs.NewBadRequestError("no file found", false, nil, nil, nil)
+		}
+		if err != nil {
+			return nil, errs.NewBadRequestError("malformed multipart form", false, nil, nil, nil)
+		}
+
+		if part.FormName() == "file" && part.FileName() != "" {
+			return part, nil
+		}
+	}
+}
+
+func (h *TodoHandler) GetAttachmentVersions(c echo.Context) error {
 	return Handle(
 		h.Handler,
-		func(c echo.Context, payload *todo.UploadTodoAttachmentPayload) (*todo.Attachment, error) {
+		func(c echo.Context, payload *todo.AttachmentVersionsPayload) ([]todo.AttachmentVersion, error) {
 			userID := middleware.GetUserID(c)
+			return h.todoService.GetAttachmentVersions(c, userID, payload.AttachmentID)
+		},
+		http.StatusOK,
+		&todo.AttachmentVersionsPayload{},
+	)(c)
+}
 
-			form, err := c.MultipartForm()
-			if err != nil {
-				return nil, errs.NewBadRequestError("multipart form not found", false, nil, nil, nil)
-			}
-
-			files := form.File["file"]
-			if len(files) == 0 {
-				return nil, errs.NewBadRequestError("no file found", false, nil, nil, nil)
-			}
+func (h *TodoHandler) RestoreAttachmentVersion(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, payload *todo.RestoreAttachmentVersionPayload) (*todo.Attachment, error) {
+			userID := middleware.GetUserID(c)
+			return h.todoService.RestoreAttachmentVersion(c, userID, payload)
+		},
+		http.StatusOK,
+		&todo.RestoreAttachmentVersionPayload{},
+	)(c)
+}
 
-			if len(files) > 1 {
-				return nil, errs.NewBadRequestError("only one file allowed per upload", false, nil, nil, nil)
-			}
+func (h *TodoHandler) CreateAttachmentUpload(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, payload *todo.CreateAttachmentUploadPayload) (*todo.UploadTicket, error) {
+			userID := middleware.GetUserID(c)
+			return h.todoService.CreateAttachmentUpload(c, userID, payload)
+		},
+		http.StatusCreated,
+		&todo.CreateAttachmentUploadPayload{},
+	)(c)
+}
 
-			return h.todoService.UploadTodoAttachment(c, userID, payload.TodoID, files[0])
+func (h *TodoHandler) CompleteAttachmentUpload(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, payload *todo.CompleteAttachmentUploadPayload) (*todo.Attachment, error) {
+			userID := middleware.GetUserID(c)
+			return h.todoService.CompleteAttachmentUpload(c, userID, payload.TodoID, payload.UploadID)
 		},
 		http.StatusCreated,
-		&todo.UploadTodoAttachmentPayload{},
+		&todo.CompleteAttachmentUploadPayload{},
+	)(c)
+}
+
+func (h *TodoHandler) GetTodoAttachments(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, payload *todo.GetTodoAttachmentsPayload) ([]todo.Attachment, error) {
+			userID := middleware.GetUserID(c)
+			return h.todoService.GetTodoAttachments(c, userID, payload.TodoID)
+		},
+		http.StatusOK,
+		&todo.GetTodoAttachmentsPayload{},
+	)(c)
+}
+
+// DownloadTodoAttachmentsArchive streams a ZIP of a todo's attachments.
+func (h *TodoHandler) DownloadTodoAttachmentsArchive(c echo.Context) error {
+	return HandleFileStream(
+		h.Handler,
+		func(c echo.Context, payload *todo.GetTodoAttachmentsArchivePayload) (*model.FileStream, error) {
+			userID := middleware.GetUserID(c)
+			includeSubtasks := payload.IncludeSubtasks != nil && *payload.IncludeSubtasks
+			return h.todoService.GetTodoAttachmentsArchive(c, userID, payload.TodoID, includeSubtasks)
+		},
+		http.StatusOK,
+		&todo.GetTodoAttachmentsArchivePayload{},
+	)(c)
+}
+
+func (h *TodoHandler) GetStorageUsage(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, payload *todo.GetStorageUsagePayload) (*todo.StorageReport, error) {
+			userID := middleware.GetUserID(c)
+			return h.todoService.GetStorageUsage(c, userID)
+		},
+		http.StatusOK,
+		&todo.GetStorageUsagePayload{},
 	)(c)
 }
 
@@ -143,7 +282,8 @@ func (h *TodoHandler) GetAttachmentPresignedURL(c echo.Context) error {
 		}, error,
 		) {
 			userID := middleware.GetUserID(c)
-			url, err := h.todoService.GetTodoAttachmentURL(c, userID, payload.TodoID, payload.AttachmentID)
+			url, err := h.todoService.GetTodoAttachmentURL(c, userID, payload.TodoID, payload.AttachmentID,
+				payload.Version)
 			if err != nil {
 				return nil, err
 			}
@@ -155,3 +295,127 @@ func (h *TodoHandler) GetAttachmentPresignedURL(c echo.Context) error {
 		&todo.GetTodoAttachmentPayload{},
 	)(c)
 }
+
+// GetAttachmentContent serves an attachment from the app rather than a presigned
+// URL, checking access on every request. Range and conditional requests are
+// answered by http.ServeContent, so media can seek and unchanged files revalidate
+// with a 304. Types browsers display safely are served inline.
+func (h *TodoHandler) GetAttachmentContent(c echo.Context) error {
+	payload := &todo.GetTodoAttachmentContentPayload{}
+	binder := &echo.DefaultBinder{}
+	if err := binder.BindPathParams(c, payload); err != nil {
+		return errs.NewBadRequestError("invalid attachment id", false, nil, nil, nil)
+	}
+	if err := binder.BindQueryParams(c, payload); err != nil {
+		return errs.NewBadRequestError("invalid query parameters", false, nil, nil, nil)
+	}
+	if err := payload.Validate(); err != nil {
+		return errs.ValidationError(err)
+	}
+
+	userID := middleware.GetUserID(c)
+	attachment, content, err := h.todoService.OpenTodoAttachment(c, userID, payload.TodoID, payload.AttachmentID,
+		payload.Version)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	object := content.Object()
+	contentType := object.ContentType
+	if attachment.MimeType != nil {
+		contentType = *attachment.MimeType
+	}
+	if contentType == "" {
+		contentType = echo.MIMEOctetStream
+	}
+
+	// Checksums identify content across deduplicated keys; older attachments fall
+	// back to the storage ETag
+	etag := object.ETag
+	if attachment.Checksum != nil {
+		etag = *attachment.Checksum
+	}
+
+	disposition := storage.ContentDisposition(attachment.Name)
+	if (payload.Download == nil || !*payload.Download) && filetype.Inline(contentType) {
+		disposition = storage.InlineDisposition(attachment.Name)
+	}
+
+	// The server-wide write timeout would otherwise cut long media streams off
+	if err := http.NewResponseController(c.Response()).SetWriteDeadline(time.Time{}); err != nil {
+		logger := middleware.GetLogger(c)
+		logger.Warn().Err(err).Msg("could not clear write deadline for attachment content")
+	}
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, contentType)
+	header.Set(echo.HeaderContentDisposition, disposition)
+	header.Set("ETag", `"`+etag+`"`)
+	// Revalidate every time so revoked access takes effect right away
+	header.Set("Cache-Control", "private, no-cache")
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+
+	http.ServeContent(c.Response(), c.Request(), attachment.Name, object.LastModified, content)
+	return nil
+}
+
+func (h *TodoHandler) AddTodoAssignees(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, payload *todo.AddTodoAssigneesPayload) ([]todo.Assignee, error) {
+			userID := middleware.GetUserID(c)
+			return h.todoService.AddTodoAssignees(c, userID, payload)
+		},
+		http.StatusOK,
+		&todo.AddTodoAssigneesPayload{},
+	)(c)
+}
+
+func (h *TodoHandler) GetTodoAssignees(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, payload *todo.GetTodoAssigneesPayload) ([]todo.Assignee, error) {
+			userID := middleware.GetUserID(c)
+			return h.todoService.GetTodoAssignees(c, userID, payload.TodoID)
+		},
+		http.StatusOK,
+		&todo.GetTodoAssigneesPayload{},
+	)(c)
+}
+
+func (h *TodoHandler) RemoveTodoAssignee(c echo.Context) error {
+	return HandleNoContent(
+		h.Handler,
+		func(c echo.Context, payload *todo.RemoveTodoAssigneePayload) error {
+			userID := middleware.GetUserID(c)
+			return h.todoService.RemoveTodoAssignee(c, userID, payload.TodoID, payload.UserID)
+		},
+		http.StatusNoContent,
+		&todo.RemoveTodoAssigneePayload{},
+	)(c)
+}
+
+func (h *TodoHandler) WatchTodo(c echo.Context) error {
+	return Handle(
+		h.Handler,
+		func(c echo.Context, payload *todo.WatchTodoPayload) (*todo.Watcher, error) {
+			userID := middleware.GetUserID(c)
+			return h.todoService.WatchTodo(c, userID, payload.TodoID)
+		},
+		http.StatusOK,
+		&todo.WatchTodoPayload{},
+	)(c)
+}
+
+func (h *TodoHandler) UnwatchTodo(c echo.Context) error {
+	return HandleNoContent(
+		h.Handler,
+		func(c echo.Context, payload *todo.WatchTodoPayload) error {
+			userID := middleware.GetUserID(c)
+			return h.todoService.UnwatchTodo(c, userID, payload.TodoID)
+		},
+		http.StatusNoContent,
+		&todo.WatchTodoPayload{},
+	)(c)
+}
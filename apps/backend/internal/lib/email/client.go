@@ -1,50 +1,69 @@
 package email
 
 import (
-	"bytes"
+	"context"
 	"fmt"
-	"html/template"
 
 	"github.com/ApoorvYdv/go-tasker/internal/config"
-	"github.com/pkg/errors"
-	"github.com/resend/resend-go/v2"
 	"github.com/rs/zerolog"
 )
 
 type Client struct {
-	client *resend.Client
-	logger *zerolog.Logger
+	transport Transport
+	renderer  *Renderer
+	from      string
+	replyTo   string
+	logger    *zerolog.Logger
 }
 
-func NewClient(cfg *config.Config, logger *zerolog.Logger) *Client {
-	return &Client{
-		client: resend.NewClient(cfg.Integration.ResendAPIKey),
-		logger: logger,
+func NewClient(cfg *config.Config, logger *zerolog.Logger) (*Client, error) {
+	transport, err := NewTransport(&cfg.Integration)
+	if err != nil {
+		return nil, err
+	}
+
+	renderer, err := NewRenderer()
+	if err != nil {
+		return nil, err
 	}
+
+	return &Client{
+		transport: transport,
+		renderer:  renderer,
+		from:      cfg.Integration.EmailFrom,
+		replyTo:   cfg.Integration.EmailReplyTo,
+		logger:    logger,
+	}, nil
 }
 
-func (c *Client) SendEmail(to, subject string, templateName Template, data map[string]string) error {
-	tmplPath := fmt.Sprintf("%s/%s.html", "templates/emails", templateName)
+func (c *Client) SendEmail(ctx context.Context, to, subject string, templateName Template, data map[string]string) error {
+	return c.SendEmailWithReplyTo(ctx, to, "", subject, templateName, data)
+}
 
-	tmpl, err := template.ParseFiles(tmplPath)
-	if err != nil {
-		return errors.Wrapf(err, "failed to parse email template %s", templateName)
+// SendEmailWithReplyTo sends with a per-message reply-to address, falling back to the
+// configured one when replyTo is empty.
+func (c *Client) SendEmailWithReplyTo(ctx context.Context, to, replyTo, subject string, templateName Template,
+	data map[string]string,
+) error {
+	if replyTo == "" {
+		replyTo = c.replyTo
 	}
 
-	var body bytes.Buffer
-	if err := tmpl.Execute(&body, data); err != nil {
-		return errors.Wrapf(err, "failed to execute email template %s", templateName)
+	body, err := c.renderer.Render(templateName, data)
+	if err != nil {
+		return err
 	}
 
-	params := &resend.SendEmailRequest{
-		From:    fmt.Sprintf("%s <%s>", "Tasker", "onboarding@resend.dev"),
+	msg := &Message{
+		From:    c.from,
+		ReplyTo: replyTo,
 		To:      []string{to},
 		Subject: subject,
-		Html:    body.String(),
+		HTML:    body.HTML,
+		Text:    body.Text,
 	}
 
-	_, err = c.client.Emails.Send(params)
-	if err != nil {
+	if err := c.transport.Send(ctx, msg); err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
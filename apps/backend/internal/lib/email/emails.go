@@ -1,14 +1,57 @@
 package email
 
-func (c *Client) SendWelcomeEmail(to, firstName string) error {
+import (
+	"context"
+	"fmt"
+)
+
+func (c *Client) SendWelcomeEmail(ctx context.Context, to, firstName string) error {
 	data := map[string]string{
 		"UserFirstName": firstName,
 	}
 
 	return c.SendEmail(
+		ctx,
 		to,
 		"Welcome to Tasker!",
 		TemplateWelcome,
 		data,
 	)
 }
+
+func (c *Client) SendShareInvitationEmail(ctx context.Context, to, inviterName, resourceType, resourceName, role, inviteURL string) error {
+	data := map[string]string{
+		"InviterName":  inviterName,
+		"ResourceType": resourceType,
+		"ResourceName": resourceName,
+		"Role":         role,
+		"InviteURL":    inviteURL,
+	}
+
+	return c.SendEmail(
+		ctx,
+		to,
+		fmt.Sprintf("%s shared \"%s\" with you", inviterName, resourceName),
+		TemplateShareInvitation,
+		data,
+	)
+}
+
+func (c *Client) SendTodoActivityEmail(ctx context.Context, to, replyTo, actorName, todoTitle, summary, reason, todoURL string) error {
+	data := map[string]string{
+		"ActorName": actorName,
+		"TodoTitle": todoTitle,
+		"Summary":   summary,
+		"Reason":    reason,
+		"TodoURL":   todoURL,
+	}
+
+	return c.SendEmailWithReplyTo(
+		ctx,
+		to,
+		replyTo,
+		fmt.Sprintf("Update on \"%s\"", todoTitle),
+		TemplateTodoActivity,
+		data,
+	)
+}
@@ -0,0 +1,110 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Bytes encodes the message as an RFC 5322 document for SMTP and .eml files.
+func (m *Message) Bytes() ([]byte, error) {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address %q: %w", m.From, err)
+	}
+
+	to := make([]string, 0, len(m.To))
+	for _, address := range m.To {
+		parsed, err := mail.ParseAddress(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient address %q: %w", address, err)
+		}
+		to = append(to, parsed.String())
+	}
+
+	messageID, err := newMessageID(from.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	writeHeader(&b, "From", from.String())
+	writeHeader(&b, "To", strings.Join(to, ", "))
+	if m.ReplyTo != "" {
+		replyTo, err := mail.ParseAddress(m.ReplyTo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reply-to address %q: %w", m.ReplyTo, err)
+		}
+		writeHeader(&b, "Reply-To", replyTo.String())
+	}
+	writeHeader(&b, "Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	writeHeader(&b, "Date", time.Now().Format(time.RFC1123Z))
+	writeHeader(&b, "Message-ID", messageID)
+	writeHeader(&b, "MIME-Version", "1.0")
+
+	// Clients show the last alternative they support, so HTML goes after plain text
+	mw := multipart.NewWriter(&b)
+	writeHeader(&b, "Content-Type", fmt.Sprintf(`multipart/alternative; boundary="%s"`, mw.Boundary()))
+	b.WriteString("\r\n")
+
+	if err := writePart(mw, "text/plain", m.Text); err != nil {
+		return nil, err
+	}
+	if err := writePart(mw, "text/html", m.HTML); err != nil {
+		return nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish email body: %w", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+func writePart(mw *multipart.Writer, contentType, body string) error {
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType + `; charset="utf-8"`},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start %s part: %w", contentType, err)
+	}
+
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return fmt.Errorf("failed to encode %s part: %w", contentType, err)
+	}
+	if err := qp.Close(); err != nil {
+		return fmt.Errorf("failed to encode %s part: %w", contentType, err)
+	}
+
+	return nil
+}
+
+func writeHeader(b *bytes.Buffer, name, value string) {
+	b.WriteString(name)
+	b.WriteString(": ")
+	b.WriteString(value)
+	b.WriteString("\r\n")
+}
+
+func newMessageID(from string) (string, error) {
+	domain := "localhost"
+	if at := strings.LastIndexByte(from, '@'); at >= 0 {
+		domain = from[at+1:]
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to read random bytes for message id: %w", err)
+	}
+
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain), nil
+}
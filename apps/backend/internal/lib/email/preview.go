@@ -1,7 +0,0 @@
-package email
-
-var PreviewData = map[string]map[string]string{
-	"welcome": {
-		"UserFirstName": "John",
-	},
-}
@@ -0,0 +1,122 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/templates"
+	"github.com/pkg/errors"
+)
+
+var ErrUnknownTemplate = errors.New("unknown email template")
+
+// Rendered is an email body in both of the formats a message carries.
+type Rendered struct {
+	HTML string
+	Text string
+}
+
+// Renderer renders registered templates inside the shared layout.
+type Renderer struct {
+	templates map[Template]*template.Template
+}
+
+var templateFuncs = template.FuncMap{
+	"year": func() int {
+		return time.Now().Year()
+	},
+	// dict builds the argument map for partials that take more than one value
+	"dict": func(pairs ...any) (map[string]any, error) {
+		if len(pairs)%2 != 0 {
+			return nil, errors.New("dict needs key and value pairs")
+		}
+		m := make(map[string]any, len(pairs)/2)
+		for i := 0; i < len(pairs); i += 2 {
+			key, ok := pairs[i].(string)
+			if !ok {
+				return nil, fmt.Errorf("dict key %v is not a string", pairs[i])
+			}
+			m[key] = pairs[i+1]
+		}
+		return m, nil
+	},
+}
+
+// NewRenderer parses the layout and partials once, then each registered template
+// on its own copy so their blocks don't collide.
+func NewRenderer() (*Renderer, error) {
+	base, err := template.New("email").
+		Funcs(templateFuncs).
+		Option("missingkey=error").
+		ParseFS(templates.Emails, "emails/layouts/*.html", "emails/partials/*.html")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse email layouts")
+	}
+
+	parsed := make(map[Template]*template.Template, len(Registry))
+	for name := range Registry {
+		clone, err := base.Clone()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to clone email layout for %s", name)
+		}
+
+		tmpl, err := clone.ParseFS(templates.Emails, fmt.Sprintf("emails/%s.html", name))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse email template %s", name)
+		}
+
+		parsed[name] = tmpl
+	}
+
+	return &Renderer{templates: parsed}, nil
+}
+
+// Render validates data against the template's required variables and renders the
+// HTML body and a plain-text alternative generated from it.
+func (r *Renderer) Render(name Template, data map[string]string) (*Rendered, error) {
+	definition, ok := Registry[name]
+	tmpl := r.templates[name]
+	if !ok || tmpl == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+
+	missing := make([]string, 0)
+	for _, key := range definition.Required {
+		if strings.TrimSpace(data[key]) == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("email template %s is missing required variables: %s", name, strings.Join(missing, ", "))
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&body, "layout", data); err != nil {
+		return nil, errors.Wrapf(err, "failed to execute email template %s", name)
+	}
+
+	text, err := HTMLToText(body.String())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to generate plain text for email template %s", name)
+	}
+
+	return &Rendered{
+		HTML: body.String(),
+		Text: text,
+	}, nil
+}
+
+// Preview renders a template with its registered preview data.
+func (r *Renderer) Preview(name Template) (*Rendered, error) {
+	definition, ok := Registry[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+
+	return r.Render(name, definition.Preview)
+}
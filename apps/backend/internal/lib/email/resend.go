@@ -0,0 +1,33 @@
+package email
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/resend/resend-go/v2"
+)
+
+type ResendTransport struct {
+	client *resend.Client
+}
+
+func NewResendTransport(apiKey string) *ResendTransport {
+	return &ResendTransport{client: resend.NewClient(apiKey)}
+}
+
+func (t *ResendTransport) Send(ctx context.Context, msg *Message) error {
+	params := &resend.SendEmailRequest{
+		From:    msg.From,
+		To:      msg.To,
+		ReplyTo: msg.ReplyTo,
+		Subject: msg.Subject,
+		Html:    msg.HTML,
+		Text:    msg.Text,
+	}
+
+	if _, err := t.client.Emails.SendWithContext(ctx, params); err != nil {
+		return fmt.Errorf("failed to send email via resend: %w", err)
+	}
+
+	return nil
+}
@@ -3,5 +3,51 @@ package email
 type Template string
 
 const (
-	TemplateWelcome Template = "welcome"
+	TemplateWelcome         Template = "welcome"
+	TemplateShareInvitation Template = "share_invitation"
+	TemplateTodoActivity    Template = "todo_activity"
 )
+
+// Definition declares an email template. Each template lives in
+// templates/emails/<name>.html and defines a "preheader" and a "content" block
+// that are rendered inside the shared layout.
+type Definition struct {
+	Name Template
+	// Required lists the variables the template needs; sending without them fails
+	Required []string
+	// Preview is sample data for the development preview route
+	Preview map[string]string
+}
+
+// Registry holds every email template the backend can send.
+var Registry = map[Template]Definition{
+	TemplateWelcome: {
+		Name:     TemplateWelcome,
+		Required: []string{"UserFirstName"},
+		Preview: map[string]string{
+			"UserFirstName": "John",
+		},
+	},
+	TemplateShareInvitation: {
+		Name:     TemplateShareInvitation,
+		Required: []string{"InviterName", "ResourceType", "ResourceName", "Role", "InviteURL"},
+		Preview: map[string]string{
+			"InviterName":  "Jane",
+			"ResourceType": "category",
+			"ResourceName": "Work",
+			"Role":         "editor",
+			"InviteURL":    "https://tasker.app/invitations/token",
+		},
+	},
+	TemplateTodoActivity: {
+		Name:     TemplateTodoActivity,
+		Required: []string{"ActorName", "TodoTitle", "Summary", "Reason", "TodoURL"},
+		Preview: map[string]string{
+			"ActorName": "Jane",
+			"TodoTitle": "Ship the Q3 report",
+			"Summary":   "changed the status to completed",
+			"Reason":    "You're receiving this because you are watching this todo.",
+			"TodoURL":   "https://tasker.app/todos/123",
+		},
+	},
+}
@@ -0,0 +1,124 @@
+package email
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	spacePattern      = regexp.MustCompile(`[ \t\r\f\v\x{00a0}]+`)
+	blankLinesPattern = regexp.MustCompile(`\n{3,}`)
+)
+
+// blockElements start on a new line in the plain-text rendering.
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Table: true, atom.Tr: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Ul: true, atom.Ol: true, atom.Li: true, atom.Blockquote: true, atom.Pre: true,
+}
+
+// HTMLToText derives the plain-text part of an email from its HTML. Links keep their
+// target in parentheses, and hidden content such as the preheader is dropped.
+func HTMLToText(source string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(source))
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	writeText(&b, doc)
+
+	lines := strings.Split(b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(spacePattern.ReplaceAllString(line, " "))
+	}
+
+	text := strings.TrimSpace(strings.Join(lines, "\n"))
+	return blankLinesPattern.ReplaceAllString(text, "\n\n") + "\n", nil
+}
+
+func writeText(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(strings.ReplaceAll(n.Data, "\n", " "))
+		return
+	case html.ElementNode:
+		switch n.DataAtom {
+		case atom.Head, atom.Style, atom.Script:
+			return
+		case atom.Br:
+			b.WriteString("\n")
+			return
+		case atom.Hr:
+			b.WriteString("\n\n---\n\n")
+			return
+		case atom.A:
+			writeLink(b, n)
+			return
+		}
+		if isHidden(n) {
+			return
+		}
+	}
+
+	block := n.Type == html.ElementNode && blockElements[n.DataAtom]
+	if block {
+		b.WriteString("\n\n")
+	}
+	if n.Type == html.ElementNode && n.DataAtom == atom.Li {
+		b.WriteString("- ")
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		writeText(b, child)
+	}
+
+	if block {
+		b.WriteString("\n\n")
+	}
+}
+
+func writeLink(b *strings.Builder, n *html.Node) {
+	var label strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		writeText(&label, child)
+	}
+
+	text := strings.TrimSpace(spacePattern.ReplaceAllString(label.String(), " "))
+	href := attr(n, "href")
+
+	switch {
+	case href == "" || href == text || strings.TrimPrefix(href, "mailto:") == text:
+		b.WriteString(text)
+	case text == "":
+		b.WriteString(href)
+	default:
+		b.WriteString(text + " (" + href + ")")
+	}
+}
+
+func isHidden(n *html.Node) bool {
+	style := strings.ReplaceAll(strings.ToLower(attr(n, "style")), " ", "")
+	return strings.Contains(style, "display:none") || hasAttr(n, "hidden")
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
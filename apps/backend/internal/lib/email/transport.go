@@ -0,0 +1,37 @@
+package email
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ApoorvYdv/go-tasker/internal/config"
+)
+
+// Message is a rendered email ready to hand to a transport.
+type Message struct {
+	From    string
+	ReplyTo string
+	To      []string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Transport delivers rendered messages.
+type Transport interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NewTransport builds the transport selected by cfg.EmailTransport.
+func NewTransport(cfg *config.IntegrationConfig) (Transport, error) {
+	switch cfg.EmailTransport {
+	case "resend":
+		return NewResendTransport(cfg.ResendAPIKey), nil
+	case "smtp":
+		return NewSMTPTransport(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword), nil
+	case "file":
+		return NewFileTransport(cfg.EmailFileDir)
+	default:
+		return nil, fmt.Errorf("unknown email transport %q", cfg.EmailTransport)
+	}
+}
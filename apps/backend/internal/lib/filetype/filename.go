@@ -0,0 +1,58 @@
+package filetype
+
+import (
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maxFilenameBytes = 255
+
+// SanitizeFilename turns a client-supplied filename into one that is safe to
+// display and to put in a Content-Disposition header. Directories, control and
+// reserved characters are dropped, and long names are shortened keeping the
+// extension. Filenames are display metadata only and never part of storage keys.
+func SanitizeFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == utf8.RuneError, unicode.IsControl(r), unicode.Is(unicode.Cf, r):
+			return -1
+		case strings.ContainsRune(`<>:"/\|?*`, r):
+			return '_'
+		case unicode.IsSpace(r):
+			return ' '
+		default:
+			return r
+		}
+	}, name)
+
+	name = strings.Join(strings.Fields(name), " ")
+	// Leading dots hide files and trailing ones are dropped by Windows
+	name = strings.Trim(name, ". ")
+	if name == "" {
+		return "attachment"
+	}
+
+	if len(name) > maxFilenameBytes {
+		ext := path.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		stem := strings.TrimSuffix(name, ext)
+		name = truncateUTF8(stem, maxFilenameBytes-len(ext)) + ext
+	}
+
+	return name
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character.
+func truncateUTF8(s string, n int) string {
+	for len(s) > n {
+		_, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+	}
+	return s
+}
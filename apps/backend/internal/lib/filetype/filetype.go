@@ -0,0 +1,218 @@
+package filetype
+
+import (
+	"bytes"
+	"errors"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// SniffLength is how many leading bytes Detect looks at.
+const SniffLength = 512
+
+var (
+	ErrTypeNotAllowed = errors.New("file type is not allowed")
+	ErrTypeMismatch   = errors.New("file content does not match its extension")
+)
+
+// DefaultDenied blocks executables and scripts that browsers or desktops might run.
+var DefaultDenied = []string{
+	"application/x-msdownload",
+	"application/x-executable",
+	"application/x-mach-binary",
+	"application/x-sh",
+	"application/x-msi",
+	"application/vnd.microsoft.portable-executable",
+}
+
+// executableSignatures covers what http.DetectContentType leaves as octet-stream.
+var executableSignatures = []struct {
+	prefix      []byte
+	contentType string
+}{
+	{[]byte("MZ"), "application/x-msdownload"},
+	{[]byte("\x7fELF"), "application/x-executable"},
+	{[]byte("\xfe\xed\xfa\xce"), "application/x-mach-binary"},
+	{[]byte("\xfe\xed\xfa\xcf"), "application/x-mach-binary"},
+	{[]byte("\xce\xfa\xed\xfe"), "application/x-mach-binary"},
+	{[]byte("\xcf\xfa\xed\xfe"), "application/x-mach-binary"},
+	{[]byte("#!"), "application/x-sh"},
+}
+
+// zipContainers are formats stored as zip archives, which sniff as application/zip.
+var zipContainers = []string{
+	"application/java-archive",
+	"application/epub+zip",
+	"application/vnd.openxmlformats-officedocument.",
+	"application/vnd.oasis.opendocument.",
+	"application/vnd.android.package-archive",
+}
+
+// Detect sniffs the media type of a file from its first SniffLength bytes.
+func Detect(head []byte) string {
+	for _, sig := range executableSignatures {
+		if bytes.HasPrefix(head, sig.prefix) {
+			return sig.contentType
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	return mediaType
+}
+
+// Policy decides which files may be stored, by media type patterns such as
+// "image/png" or "image/*". An empty allow list allows everything not denied.
+type Policy struct {
+	allowed []string
+	denied  []string
+}
+
+func NewPolicy(allowed, denied []string) *Policy {
+	return &Policy{
+		allowed: allowed,
+		denied:  denied,
+	}
+}
+
+// Check sniffs a file's content, makes sure it is allowed and agrees with the
+// filename's extension, and returns the media type to store it under.
+func (p *Policy) Check(filename string, head []byte) (string, error) {
+	detected := Detect(head)
+	if !p.allows(detected) {
+		return "", ErrTypeNotAllowed
+	}
+
+	declared := extensionType(filename)
+	if declared == "" {
+		return detected, nil
+	}
+
+	if !compatible(declared, detected) {
+		return "", ErrTypeMismatch
+	}
+
+	// Sniffing only knows broad families such as text or zip; the extension is
+	// more specific, and checked too so a denied type can't pass as another one
+	if !p.allows(declared) {
+		return "", ErrTypeNotAllowed
+	}
+
+	return declared, nil
+}
+
+// CheckDeclared validates the content type a client announces for a file it has
+// yet to upload. The content itself is checked with Check once it arrives.
+func (p *Policy) CheckDeclared(filename, contentType string) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !p.allows(mediaType) {
+		return ErrTypeNotAllowed
+	}
+
+	if declared := extensionType(filename); declared != "" && !p.allows(declared) {
+		return ErrTypeNotAllowed
+	}
+
+	return nil
+}
+
+func (p *Policy) allows(mediaType string) bool {
+	for _, pattern := range p.denied {
+		if matches(pattern, mediaType) {
+			return false
+		}
+	}
+
+	if len(p.allowed) == 0 {
+		return true
+	}
+
+	for _, pattern := range p.allowed {
+		if matches(pattern, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(pattern, mediaType string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(mediaType, prefix+"/")
+	}
+	return pattern == mediaType
+}
+
+// extensionType is the media type registered for the filename's extension, if any.
+func extensionType(filename string) string {
+	ext := strings.ToLower(path.Ext(filename))
+	if ext == "" {
+		return ""
+	}
+
+	mediaType, _, err := mime.ParseMediaType(mime.TypeByExtension(ext))
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// compatible reports whether content sniffed as detected may carry a filename
+// registered as declared.
+func compatible(declared, detected string) bool {
+	switch {
+	case declared == detected:
+		return true
+	case detected == "application/octet-stream":
+		// Unknown binary content; only text formats are known not to look like this
+		return !isText(declared)
+	case detected == "text/plain":
+		return isText(declared)
+	case detected == "text/xml":
+		return strings.Contains(declared, "xml")
+	case detected == "application/zip":
+		for _, container := range zipContainers {
+			if strings.HasPrefix(declared, container) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+func isText(mediaType string) bool {
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/x-yaml",
+		"application/yaml", "application/toml", "application/sql", "image/svg+xml":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+// Inline reports whether browsers may display content of the media type in the
+// page rather than download it. Anything that can run script, such as HTML or
+// SVG, is always downloaded.
+func Inline(mediaType string) bool {
+	media, _, _ := strings.Cut(strings.ToLower(mediaType), ";")
+	media = strings.TrimSpace(media)
+
+	switch {
+	case media == "image/svg+xml":
+		return false
+	case strings.HasPrefix(media, "image/"), strings.HasPrefix(media, "video/"), strings.HasPrefix(media, "audio/"):
+		return true
+	}
+
+	switch media {
+	case "application/pdf", "text/plain", "text/csv":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package inboundmail
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+)
+
+// postmarkPayload is the subset of Postmark's inbound webhook JSON the app reads.
+// Other providers can be pointed at the raw MIME endpoint instead.
+type postmarkPayload struct {
+	From              string `json:"From"`
+	To                string `json:"To"`
+	Cc                string `json:"Cc"`
+	OriginalRecipient string `json:"OriginalRecipient"`
+	Subject           string `json:"Subject"`
+	TextBody          string `json:"TextBody"`
+	HTMLBody          string `json:"HtmlBody"`
+	Attachments       []struct {
+		Name        string `json:"Name"`
+		Content     string `json:"Content"`
+		ContentType string `json:"ContentType"`
+	} `json:"Attachments"`
+}
+
+// ParseJSON parses a provider's parsed-email JSON, in Postmark's inbound format.
+func ParseJSON(data []byte) (*Message, error) {
+	var payload postmarkPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+
+	msg := &Message{
+		Subject: payload.Subject,
+		Text:    payload.TextBody,
+		HTML:    payload.HTMLBody,
+	}
+
+	if from, err := mail.ParseAddress(payload.From); err == nil {
+		msg.From = from.Address
+	}
+
+	for _, list := range []string{payload.OriginalRecipient, payload.To, payload.Cc} {
+		if list == "" {
+			continue
+		}
+		addresses, err := mail.ParseAddressList(list)
+		if err != nil {
+			continue
+		}
+		for _, address := range addresses {
+			msg.Recipients = append(msg.Recipients, address.Address)
+		}
+	}
+
+	for _, a := range payload.Attachments {
+		content, err := base64.StdEncoding.DecodeString(a.Content)
+		if err != nil {
+			return nil, fmt.Errorf("%w: attachment %s is not valid base64", ErrInvalidMessage, a.Name)
+		}
+		if len(content) == 0 {
+			continue
+		}
+		msg.Attachments = append(msg.Attachments, Attachment{
+			Filename:    a.Name,
+			ContentType: a.ContentType,
+			Data:        content,
+		})
+	}
+
+	return msg, nil
+}
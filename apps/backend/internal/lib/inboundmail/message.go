@@ -0,0 +1,35 @@
+// Package inboundmail parses emails delivered by an inbound email provider, either
+// as raw MIME or as the provider's parsed JSON.
+package inboundmail
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var ErrInvalidMessage = errors.New("invalid inbound email")
+
+// Message is an inbound email reduced to what the app uses.
+type Message struct {
+	From string
+	// Recipients are every address the message was delivered to
+	Recipients  []string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []Attachment
+}
+
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+var subjectPrefixPattern = regexp.MustCompile(`(?i)^\s*(?:(?:re|fw|fwd|aw|wg)\s*(?:\[\d+\])?\s*:\s*)+`)
+
+// CleanSubject removes reply and forward prefixes such as "Re:" and "Fwd:".
+func CleanSubject(subject string) string {
+	return strings.TrimSpace(subjectPrefixPattern.ReplaceAllString(subject, ""))
+}
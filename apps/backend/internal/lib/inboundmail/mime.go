@@ -0,0 +1,164 @@
+package inboundmail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// recipientHeaders are read for delivery addresses. Providers add Delivered-To or
+// X-Original-To when the envelope recipient isn't in To or Cc, such as for Bcc.
+var recipientHeaders = []string{"To", "Cc", "Delivered-To", "X-Original-To"}
+
+// maxMIMEDepth bounds nested multiparts so crafted messages can't recurse forever.
+const maxMIMEDepth = 10
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+// ParseMIME parses a raw RFC 5322 message.
+func ParseMIME(r io.Reader) (*Message, error) {
+	raw, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+
+	msg := &Message{}
+
+	if from, err := mail.ParseAddress(decodeHeader(raw.Header.Get("From"))); err == nil {
+		msg.From = from.Address
+	}
+
+	for _, header := range recipientHeaders {
+		for _, value := range raw.Header[header] {
+			addresses, err := mail.ParseAddressList(decodeHeader(value))
+			if err != nil {
+				continue
+			}
+			for _, address := range addresses {
+				msg.Recipients = append(msg.Recipients, address.Address)
+			}
+		}
+	}
+
+	msg.Subject = decodeHeader(raw.Header.Get("Subject"))
+
+	if err := readPart(msg, raw.Header.Get, raw.Body, 0); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// partHeader reads a header of the message or of one of its parts.
+type partHeader func(key string) string
+
+func readPart(msg *Message, header partHeader, body io.Reader, depth int) error {
+	if depth > maxMIMEDepth {
+		return fmt.Errorf("%w: multipart nesting is too deep", ErrInvalidMessage)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+			}
+			if err := readPart(msg, part.Header.Get, part, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(decodeTransfer(header("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return fmt.Errorf("%w: failed to decode body: %v", ErrInvalidMessage, err)
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(header("Content-Disposition"))
+	filename := decodeHeader(dispositionParams["filename"])
+	if filename == "" {
+		filename = decodeHeader(params["name"])
+	}
+
+	isText := mediaType == "text/plain" || mediaType == "text/html"
+	if disposition == "attachment" || filename != "" || !isText {
+		if len(data) > 0 {
+			msg.Attachments = append(msg.Attachments, Attachment{
+				Filename:    filename,
+				ContentType: mediaType,
+				Data:        data,
+			})
+		}
+		return nil
+	}
+
+	text, err := toUTF8(data, params["charset"])
+	if err != nil {
+		return err
+	}
+
+	// The first body of each kind wins; later ones are usually forwarded copies
+	switch {
+	case mediaType == "text/plain" && msg.Text == "":
+		msg.Text = text
+	case mediaType == "text/html" && msg.HTML == "":
+		msg.HTML = text
+	}
+
+	return nil
+}
+
+func decodeTransfer(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	default:
+		return body
+	}
+}
+
+func toUTF8(data []byte, label string) (string, error) {
+	if label == "" || strings.EqualFold(label, "utf-8") || strings.EqualFold(label, "us-ascii") {
+		return string(data), nil
+	}
+
+	reader, err := charset.NewReaderLabel(label, bytes.NewReader(data))
+	if err != nil {
+		// Unknown charsets are read as UTF-8 rather than dropping the body
+		return string(data), nil
+	}
+
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to decode charset %s: %v", ErrInvalidMessage, label, err)
+	}
+
+	return string(decoded), nil
+}
+
+func decodeHeader(value string) string {
+	decoded, err := wordDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
@@ -0,0 +1,52 @@
+package inboundmail
+
+import (
+	"regexp"
+	"strings"
+)
+
+// quoteHeaderPatterns match the line mail clients put above quoted text.
+var quoteHeaderPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^On .+ wrote:$`),
+	regexp.MustCompile(`(?i)^-+\s*Original Message\s*-+$`),
+	regexp.MustCompile(`(?i)^From: .+`),
+	regexp.MustCompile(`(?i)^_{10,}$`),
+	regexp.MustCompile(`(?i)^Sent from my .+$`),
+}
+
+// StripQuotedReply returns only the new text of a reply, dropping the quoted
+// message, the quote header and a trailing "-- " signature.
+func StripQuotedReply(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+
+	// Some clients wrap "On ... wrote:" over two lines
+	for i := 0; i+1 < len(lines); i++ {
+		joined := strings.TrimSpace(lines[i]) + " " + strings.TrimSpace(lines[i+1])
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), "On ") && quoteHeaderPatterns[0].MatchString(joined) &&
+			!quoteHeaderPatterns[0].MatchString(strings.TrimSpace(lines[i])) {
+			lines = lines[:i]
+			break
+		}
+	}
+
+	kept := make([]string, 0, len(lines))
+scan:
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, ">"):
+			break scan
+		case line == "-- " || trimmed == "--":
+			break scan
+		}
+		for _, pattern := range quoteHeaderPatterns {
+			if pattern.MatchString(trimmed) {
+				break scan
+			}
+		}
+		kept = append(kept, line)
+	}
+
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
@@ -0,0 +1,113 @@
+package job
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+)
+
+const (
+	TaskCleanupAttachmentUploads  = "attachment:cleanup_uploads"
+	TaskGenerateAttachmentPreview = "attachment:generate_preview"
+	TaskDeleteStorageObjects      = "attachment:delete_objects"
+	TaskReconcileStorage          = "attachment:reconcile_storage"
+	TaskScanAttachment            = "attachment:scan"
+	TaskScanPendingAttachments    = "attachment:scan_pending"
+)
+
+func NewCleanupAttachmentUploadsTask(interval time.Duration) *asynq.Task {
+	return asynq.NewTask(TaskCleanupAttachmentUploads, nil,
+		asynq.MaxRetry(0),
+		asynq.Queue("low"),
+		asynq.Timeout(5*time.Minute),
+		asynq.Unique(interval))
+}
+
+type GenerateAttachmentPreviewPayload struct {
+	AttachmentID string `json:"attachmentId"`
+}
+
+func NewGenerateAttachmentPreviewTask(attachmentID uuid.UUID) (*asynq.Task, error) {
+	data, err := json.Marshal(GenerateAttachmentPreviewPayload{
+		AttachmentID: attachmentID.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asynq.NewTask(TaskGenerateAttachmentPreview, data,
+		asynq.MaxRetry(3),
+		asynq.Queue("low"),
+		asynq.Timeout(2*time.Minute)), nil
+}
+
+type DeleteStorageObjectsPayload struct {
+	Keys []string `json:"keys"`
+}
+
+// NewDeleteStorageObjectsTask retries for about a day, since objects it gives up
+// on are only found again by the reconciliation job.
+func NewDeleteStorageObjectsTask(keys []string) (*asynq.Task, error) {
+	data, err := json.Marshal(DeleteStorageObjectsPayload{
+		Keys: keys,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asynq.NewTask(TaskDeleteStorageObjects, data,
+		asynq.MaxRetry(10),
+		asynq.Queue("low"),
+		asynq.Timeout(time.Minute)), nil
+}
+
+// ReconcileStoragePayload selects between only reporting inconsistencies and
+// repairing them.
+type ReconcileStoragePayload struct {
+	DryRun bool `json:"dryRun"`
+}
+
+func NewReconcileStorageTask(dryRun bool, interval time.Duration) (*asynq.Task, error) {
+	data, err := json.Marshal(ReconcileStoragePayload{
+		DryRun: dryRun,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asynq.NewTask(TaskReconcileStorage, data,
+		asynq.MaxRetry(0),
+		asynq.Queue("low"),
+		asynq.Timeout(time.Hour),
+		asynq.Unique(interval)), nil
+}
+
+// ScanAttachmentPayload names the stored content to scan, which every attachment
+// and version stored under the key shares.
+type ScanAttachmentPayload struct {
+	StorageKey string `json:"storageKey"`
+}
+
+func NewScanAttachmentTask(key string) (*asynq.Task, error) {
+	data, err := json.Marshal(ScanAttachmentPayload{
+		StorageKey: key,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asynq.NewTask(TaskScanAttachment, data,
+		asynq.MaxRetry(5),
+		asynq.Queue("default"),
+		asynq.Timeout(5*time.Minute)), nil
+}
+
+func NewScanPendingAttachmentsTask(interval time.Duration) *asynq.Task {
+	return asynq.NewTask(TaskScanPendingAttachments, nil,
+		asynq.MaxRetry(0),
+		asynq.Queue("low"),
+		asynq.Timeout(5*time.Minute),
+		asynq.Unique(interval))
+}
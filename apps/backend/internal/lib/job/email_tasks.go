@@ -8,7 +8,9 @@ import (
 )
 
 const (
-	TaskWelcome = "email:welcome"
+	TaskWelcome         = "email:welcome"
+	TaskShareInvitation = "email:share_invitation"
+	TaskTodoActivity    = "email:todo_activity"
 )
 
 type WelcomeEmailPayload struct {
@@ -30,3 +32,50 @@ func NewWelcomeEmailTask(to, firstName string) (*asynq.Task, error) {
 		asynq.Queue("default"),
 		asynq.Timeout(30*time.Second)), nil
 }
+
+type ShareInvitationEmailPayload struct {
+	To           string `json:"to"`
+	InviterID    string `json:"inviter_id"`
+	ResourceType string `json:"resource_type"`
+	ResourceName string `json:"resource_name"`
+	Role         string `json:"role"`
+	InviteURL    string `json:"invite_url"`
+}
+
+func NewShareInvitationEmailTask(payload ShareInvitationEmailPayload) (*asynq.Task, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return asynq.NewTask(TaskShareInvitation, data,
+		asynq.MaxRetry(3),
+		asynq.Queue("default"),
+		asynq.Timeout(30*time.Second)), nil
+}
+
+// TodoActivityEmailPayload identifies the recipient by user ID; the address is
+// resolved when the task runs so it reflects the user's current primary email.
+type TodoActivityEmailPayload struct {
+	RecipientID string `json:"recipient_id"`
+	ActorID     string `json:"actor_id"`
+	TodoID      string `json:"todo_id"`
+	TodoTitle   string `json:"todo_title"`
+	Summary     string `json:"summary"`
+	Reason      string `json:"reason"`
+	TodoURL     string `json:"todo_url"`
+	// ReplyTo lets the recipient comment by replying; empty uses the default reply-to
+	ReplyTo string `json:"reply_to,omitempty"`
+}
+
+func NewTodoActivityEmailTask(payload TodoActivityEmailPayload) (*asynq.Task, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return asynq.NewTask(TaskTodoActivity, data,
+		asynq.MaxRetry(3),
+		asynq.Queue("low"),
+		asynq.Timeout(30*time.Second)), nil
+}
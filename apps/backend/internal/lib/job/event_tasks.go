@@ -0,0 +1,32 @@
+package job
+
+import (
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+const (
+	TaskDeliverEvent = "event:deliver"
+	TaskPruneEvents  = "event:prune"
+)
+
+// NewDeliverEventTask hands one domain event, marshalled as JSON, to the
+// asynchronous subscribers. Enqueue it with the event ID as its task ID, so an
+// event published again while its task is queued or recently done isn't
+// delivered twice.
+func NewDeliverEventTask(event []byte) *asynq.Task {
+	return asynq.NewTask(TaskDeliverEvent, event,
+		asynq.MaxRetry(10),
+		asynq.Queue("default"),
+		asynq.Timeout(time.Minute),
+		asynq.Retention(time.Hour))
+}
+
+func NewPruneEventsTask(interval time.Duration) *asynq.Task {
+	return asynq.NewTask(TaskPruneEvents, nil,
+		asynq.MaxRetry(0),
+		asynq.Queue("low"),
+		asynq.Timeout(5*time.Minute),
+		asynq.Unique(interval))
+}
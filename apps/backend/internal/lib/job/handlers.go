@@ -7,14 +7,21 @@ import (
 
 	"github.com/ApoorvYdv/go-tasker/internal/config"
 	"github.com/ApoorvYdv/go-tasker/internal/lib/email"
+	"github.com/clerk/clerk-sdk-go/v2/user"
 	"github.com/hibiken/asynq"
 	"github.com/rs/zerolog"
 )
 
 var emailClient *email.Client
 
-func (j *JobService) InitHandlers(config *config.Config, logger *zerolog.Logger) {
-	emailClient = email.NewClient(config, logger)
+func (j *JobService) InitHandlers(config *config.Config, logger *zerolog.Logger) error {
+	client, err := email.NewClient(config, logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize email client: %w", err)
+	}
+
+	emailClient = client
+	return nil
 }
 
 func (j *JobService) handleWelcomeEmailTask(ctx context.Context, t *asynq.Task) error {
@@ -29,6 +36,7 @@ func (j *JobService) handleWelcomeEmailTask(ctx context.Context, t *asynq.Task)
 		Msg("Processing welcome email task")
 
 	err := emailClient.SendWelcomeEmail(
+		ctx,
 		p.To,
 		p.FirstName,
 	)
@@ -47,3 +55,118 @@ func (j *JobService) handleWelcomeEmailTask(ctx context.Context, t *asynq.Task)
 		Msg("Successfully sent welcome email")
 	return nil
 }
+
+func (j *JobService) handleShareInvitationEmailTask(ctx context.Context, t *asynq.Task) error {
+	var p ShareInvitationEmailPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("failed to unmarshal share invitation email payload: %w", err)
+	}
+
+	j.logger.Info().
+		Str("type", "share_invitation").
+		Str("to", p.To).
+		Msg("Processing share invitation email task")
+
+	err := emailClient.SendShareInvitationEmail(
+		ctx,
+		p.To,
+		displayName(ctx, p.InviterID),
+		p.ResourceType,
+		p.ResourceName,
+		p.Role,
+		p.InviteURL,
+	)
+	if err != nil {
+		j.logger.Error().
+			Str("type", "share_invitation").
+			Str("to", p.To).
+			Err(err).
+			Msg("Failed to send share invitation email")
+		return err
+	}
+
+	j.logger.Info().
+		Str("type", "share_invitation").
+		Str("to", p.To).
+		Msg("Successfully sent share invitation email")
+	return nil
+}
+
+func (j *JobService) handleTodoActivityEmailTask(ctx context.Context, t *asynq.Task) error {
+	var p TodoActivityEmailPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("failed to unmarshal todo activity email payload: %w", err)
+	}
+
+	j.logger.Info().
+		Str("type", "todo_activity").
+		Str("recipient_id", p.RecipientID).
+		Str("todo_id", p.TodoID).
+		Msg("Processing todo activity email task")
+
+	to, err := primaryEmail(ctx, p.RecipientID)
+	if err != nil {
+		j.logger.Error().
+			Str("type", "todo_activity").
+			Str("recipient_id", p.RecipientID).
+			Err(err).
+			Msg("Failed to resolve recipient email")
+		return err
+	}
+
+	// System events such as reminders have no actor
+	actorName := "Reminder:"
+	if p.ActorID != "" {
+		actorName = displayName(ctx, p.ActorID)
+	}
+
+	err = emailClient.SendTodoActivityEmail(
+		ctx,
+		to,
+		p.ReplyTo,
+		actorName,
+		p.TodoTitle,
+		p.Summary,
+		p.Reason,
+		p.TodoURL,
+	)
+	if err != nil {
+		j.logger.Error().
+			Str("type", "todo_activity").
+			Str("recipient_id", p.RecipientID).
+			Err(err).
+			Msg("Failed to send todo activity email")
+		return err
+	}
+
+	j.logger.Info().
+		Str("type", "todo_activity").
+		Str("recipient_id", p.RecipientID).
+		Msg("Successfully sent todo activity email")
+	return nil
+}
+
+// displayName resolves a Clerk user's first name for email copy, falling back to a generic label
+func displayName(ctx context.Context, userID string) string {
+	u, err := user.Get(ctx, userID)
+	if err != nil || u.FirstName == nil || *u.FirstName == "" {
+		return "Someone"
+	}
+	return *u.FirstName
+}
+
+// primaryEmail resolves a Clerk user's primary email address
+func primaryEmail(ctx context.Context, userID string) (string, error) {
+	u, err := user.Get(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user %s: %w", userID, err)
+	}
+
+	for _, address := range u.EmailAddresses {
+		if u.PrimaryEmailAddressID != nil && address.ID == *u.PrimaryEmailAddressID {
+			return address.EmailAddress, nil
+		}
+	}
+
+	return "", fmt.Errorf("user %s has no primary email address", userID)
+}
@@ -1,15 +1,19 @@
 package job
 
 import (
+	"fmt"
+
 	"github.com/ApoorvYdv/go-tasker/internal/config"
 	"github.com/hibiken/asynq"
 	"github.com/rs/zerolog"
 )
 
 type JobService struct {
-	Client *asynq.Client
-	server *asynq.Server
-	logger *zerolog.Logger
+	Client    *asynq.Client
+	server    *asynq.Server
+	scheduler *asynq.Scheduler
+	mux       *asynq.ServeMux
+	logger    *zerolog.Logger
 }
 
 func NewJobService(logger *zerolog.Logger, cfg *config.Config) *JobService {
@@ -34,19 +38,42 @@ func NewJobService(logger *zerolog.Logger, cfg *config.Config) *JobService {
 	)
 
 	return &JobService{
-		Client: client,
-		server: server,
-		logger: logger,
+		Client:    client,
+		server:    server,
+		scheduler: asynq.NewScheduler(redisOpt, nil),
+		mux:       asynq.NewServeMux(),
+		logger:    logger,
+	}
+}
+
+// Handle registers a handler for tasks that need more than this package provides,
+// such as database access. Register handlers before Start.
+func (j *JobService) Handle(taskType string, handler asynq.HandlerFunc) {
+	j.mux.HandleFunc(taskType, handler)
+}
+
+// Schedule enqueues task on a cron schedule. Every instance runs the scheduler, so
+// periodic tasks should be created with asynq.Unique to avoid duplicates.
+func (j *JobService) Schedule(cronspec string, task *asynq.Task) error {
+	if _, err := j.scheduler.Register(cronspec, task); err != nil {
+		return fmt.Errorf("failed to schedule task %s: %w", task.Type(), err)
 	}
+	return nil
 }
 
 func (j *JobService) Start() error {
 	// Register task handlers
-	mux := asynq.NewServeMux()
-	mux.HandleFunc(TaskWelcome, j.handleWelcomeEmailTask)
+	j.mux.HandleFunc(TaskWelcome, j.handleWelcomeEmailTask)
+	j.mux.HandleFunc(TaskShareInvitation, j.handleShareInvitationEmailTask)
+	j.mux.HandleFunc(TaskTodoActivity, j.handleTodoActivityEmailTask)
+	j.mux.HandleFunc(TaskNotificationWebhook, j.handleNotificationWebhookTask)
 
 	j.logger.Info().Msg("Starting background job server")
-	if err := j.server.Start(mux); err != nil {
+	if err := j.server.Start(j.mux); err != nil {
+		return err
+	}
+
+	if err := j.scheduler.Start(); err != nil {
 		return err
 	}
 
@@ -55,6 +82,7 @@ func (j *JobService) Start() error {
 
 func (j *JobService) Stop() {
 	j.logger.Info().Msg("Stopping background job server")
+	j.scheduler.Shutdown()
 	j.server.Shutdown()
 	j.Client.Close()
 }
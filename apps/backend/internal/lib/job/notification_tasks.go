@@ -0,0 +1,175 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+const (
+	TaskNotificationWebhook = "notification:webhook"
+	TaskScanDueTodos        = "notification:scan_due_todos"
+)
+
+// WebhookSignatureHeader carries the hex HMAC-SHA256 of the request body, keyed with
+// the user's webhook secret, so receivers can verify deliveries came from us.
+const WebhookSignatureHeader = "X-Tasker-Signature"
+
+// NotificationWebhookPayload is signed when enqueued so the secret never leaves the database.
+type NotificationWebhookPayload struct {
+	URL       string          `json:"url"`
+	Body      json.RawMessage `json:"body"`
+	Signature string          `json:"signature"`
+}
+
+func NewNotificationWebhookTask(url, secret string, body []byte, opts ...asynq.Option) (*asynq.Task, error) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	data, err := json.Marshal(NotificationWebhookPayload{
+		URL:       url,
+		Body:      body,
+		Signature: "sha256=" + hex.EncodeToString(mac.Sum(nil)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append([]asynq.Option{
+		asynq.MaxRetry(5),
+		asynq.Queue("low"),
+		asynq.Timeout(30 * time.Second),
+	}, opts...)
+
+	return asynq.NewTask(TaskNotificationWebhook, data, opts...), nil
+}
+
+// NewScanDueTodosTask is scheduled by every instance; Unique keeps one per interval.
+func NewScanDueTodosTask(interval time.Duration) *asynq.Task {
+	return asynq.NewTask(TaskScanDueTodos, nil,
+		asynq.MaxRetry(0),
+		asynq.Queue("default"),
+		asynq.Timeout(time.Minute),
+		asynq.Unique(interval))
+}
+
+// blockedWebhookPrefixes are the ranges webhooks may not be delivered to: anything
+// that isn't publicly routable, so user-supplied URLs can't reach internal services.
+var blockedWebhookPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "this" network
+	netip.MustParsePrefix("10.0.0.0/8"),     // private
+	netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT
+	netip.MustParsePrefix("127.0.0.0/8"),    // loopback
+	netip.MustParsePrefix("169.254.0.0/16"), // link-local, including cloud metadata
+	netip.MustParsePrefix("172.16.0.0/12"),  // private
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("192.168.0.0/16"), // private
+	netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+	netip.MustParsePrefix("224.0.0.0/4"),    // multicast
+	netip.MustParsePrefix("240.0.0.0/4"),    // reserved and broadcast
+	netip.MustParsePrefix("::/128"),         // unspecified
+	netip.MustParsePrefix("::1/128"),        // loopback
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, which embeds IPv4 addresses
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64
+	netip.MustParsePrefix("fc00::/7"),       // unique local
+	netip.MustParsePrefix("fe80::/10"),      // link-local
+	netip.MustParsePrefix("ff00::/8"),       // multicast
+}
+
+// isPublicWebhookAddr reports whether a webhook may connect to addr. IPv4-mapped
+// IPv6 addresses are checked as the IPv4 address they carry.
+func isPublicWebhookAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() {
+		return false
+	}
+	for _, prefix := range blockedWebhookPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// webhookClient checks the resolved address of every connection, so DNS names that
+// point at internal addresses are refused as well.
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				addrPort, err := netip.ParseAddrPort(address)
+				if err != nil {
+					return err
+				}
+				if !isPublicWebhookAddr(addrPort.Addr()) {
+					return errors.New("webhook address is not publicly routable")
+				}
+				return nil
+			},
+		}).DialContext,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+func (j *JobService) handleNotificationWebhookTask(ctx context.Context, t *asynq.Task) error {
+	var p NotificationWebhookPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("failed to unmarshal notification webhook payload: %w", err)
+	}
+
+	j.logger.Info().
+		Str("type", "notification_webhook").
+		Str("url", p.URL).
+		Msg("Processing notification webhook task")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(p.Body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Tasker-Webhooks/1.0")
+	req.Header.Set(WebhookSignatureHeader, p.Signature)
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		j.logger.Error().
+			Str("type", "notification_webhook").
+			Str("url", p.URL).
+			Err(err).
+			Msg("Failed to deliver notification webhook")
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+		j.logger.Error().
+			Str("type", "notification_webhook").
+			Str("url", p.URL).
+			Err(err).
+			Msg("Failed to deliver notification webhook")
+		return err
+	}
+
+	j.logger.Info().
+		Str("type", "notification_webhook").
+		Str("url", p.URL).
+		Msg("Successfully delivered notification webhook")
+	return nil
+}
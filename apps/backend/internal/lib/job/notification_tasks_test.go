@@ -0,0 +1,74 @@
+package job
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPublicWebhookAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"8.8.8.8", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"100.63.255.255", true},
+		{"100.128.0.0", true},
+		{"198.20.0.1", true},
+
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"10.1.2.3", false},
+		{"100.64.0.1", false},
+		{"100.127.255.255", false},
+		{"127.0.0.1", false},
+		{"127.8.8.8", false},
+		{"169.254.169.254", false},
+		{"172.16.0.1", false},
+		{"172.31.255.255", false},
+		{"192.0.0.8", false},
+		{"192.168.1.1", false},
+		{"198.18.0.1", false},
+		{"198.19.255.255", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"ff02::1", false},
+		{"64:ff9b::7f00:1", false},
+
+		// IPv4-mapped IPv6 addresses are checked as IPv4
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"::ffff:100.64.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:93.184.216.34", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			assert.Equal(t, tt.want, isPublicWebhookAddr(netip.MustParseAddr(tt.addr)))
+		})
+	}
+}
+
+func TestWebhookClientRefusesInternalAddresses(t *testing.T) {
+	for _, url := range []string{
+		"http://127.0.0.1:1/hook",
+		"http://[::ffff:127.0.0.1]:1/hook",
+		"http://[::1]:1/hook",
+	} {
+		t.Run(url, func(t *testing.T) {
+			resp, err := webhookClient.Post(url, "application/json", nil)
+			if resp != nil {
+				resp.Body.Close()
+			}
+			assert.ErrorContains(t, err, "not publicly routable")
+		})
+	}
+}
@@ -0,0 +1,460 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	uriAutolinkPattern   = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^\s<>]*)>`)
+	entityPattern        = regexp.MustCompile(`^&(?:[A-Za-z][A-Za-z0-9]{1,31}|#[0-9]{1,7}|#[xX][0-9A-Fa-f]{1,6});`)
+	emailAutolinkPattern = regexp.MustCompile(`^<([A-Za-z0-9.!#$%&'*+/=?^_{|}~-]+@[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?)*)>`)
+)
+
+// allowedSchemes are the URL schemes links may use. Relative URLs are always allowed.
+var allowedSchemes = map[string]struct{}{
+	"http":   {},
+	"https":  {},
+	"mailto": {},
+}
+
+// renderInline renders the inline content of a block, escaping all text.
+func renderInline(b *strings.Builder, s string) {
+	for i := 0; i < len(s); {
+		switch c := s[i]; c {
+		case '\\':
+			switch {
+			case i+1 < len(s) && s[i+1] == '\n':
+				b.WriteString("<br />\n")
+				i += 2
+			case i+1 < len(s) && isASCIIPunct(s[i+1]):
+				b.WriteString(escape(s[i+1 : i+2]))
+				i += 2
+			default:
+				b.WriteByte('\\')
+				i++
+			}
+
+		case ' ':
+			end := i
+			for end < len(s) && s[end] == ' ' {
+				end++
+			}
+			switch {
+			case end < len(s) && s[end] == '\n' && end-i >= 2:
+				b.WriteString("<br />\n")
+				i = end + 1
+			case end < len(s) && s[end] == '\n':
+				i = end
+			default:
+				b.WriteString(s[i:end])
+				i = end
+			}
+
+		case '`':
+			i = renderCodeSpan(b, s, i)
+
+		case '<':
+			i = renderAutolink(b, s, i)
+
+		case '[':
+			i = renderLink(b, s, i, i)
+
+		case '!':
+			if i+1 < len(s) && s[i+1] == '[' {
+				i = renderLink(b, s, i+1, i)
+			} else {
+				b.WriteByte('!')
+				i++
+			}
+
+		case '*', '_':
+			i = renderEmphasis(b, s, i)
+
+		case '~':
+			i = renderStrikethrough(b, s, i)
+
+		case '&':
+			// Entity references are valid text in HTML, so they pass through undecoded
+			if m := entityPattern.FindString(s[i:]); m != "" {
+				b.WriteString(m)
+				i += len(m)
+			} else {
+				b.WriteString("&amp;")
+				i++
+			}
+
+		default:
+			b.WriteString(escape(s[i : i+1]))
+			i++
+		}
+	}
+}
+
+func renderCodeSpan(b *strings.Builder, s string, start int) int {
+	n := runLength(s, start, '`')
+
+	closing := findBacktickRun(s, start+n, n)
+	if closing < 0 {
+		b.WriteString(s[start : start+n])
+		return start + n
+	}
+
+	code := strings.ReplaceAll(s[start+n:closing], "\n", " ")
+	if len(code) >= 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+		code = code[1 : len(code)-1]
+	}
+
+	b.WriteString("<code>")
+	b.WriteString(escape(code))
+	b.WriteString("</code>")
+	return closing + n
+}
+
+func renderAutolink(b *strings.Builder, s string, start int) int {
+	rest := s[start:]
+
+	if m := uriAutolinkPattern.FindStringSubmatch(rest); m != nil && isSafeURL(m[1]) {
+		writeLinkOpen(b, m[1])
+		b.WriteString(escape(m[1]))
+		b.WriteString("</a>")
+		return start + len(m[0])
+	}
+
+	if m := emailAutolinkPattern.FindStringSubmatch(rest); m != nil {
+		writeLinkOpen(b, "mailto:"+m[1])
+		b.WriteString(escape(m[1]))
+		b.WriteString("</a>")
+		return start + len(m[0])
+	}
+
+	b.WriteString("&lt;")
+	return start + 1
+}
+
+// renderLink renders [text](destination "title") starting at the opening bracket.
+// from is where the construct starts, which is the "!" for images.
+func renderLink(b *strings.Builder, s string, bracket int, from int) int {
+	textEnd := findClosingBracket(s, bracket)
+	if textEnd < 0 || textEnd+1 >= len(s) || s[textEnd+1] != '(' {
+		b.WriteString(escape(s[from : bracket+1]))
+		return bracket + 1
+	}
+
+	destination, end, ok := parseDestination(s, textEnd+2)
+	if !ok {
+		b.WriteString(escape(s[from : bracket+1]))
+		return bracket + 1
+	}
+
+	text := s[bracket+1 : textEnd]
+	if !isSafeURL(destination) {
+		renderInline(b, text)
+		return end
+	}
+
+	writeLinkOpen(b, destination)
+	renderInline(b, text)
+	b.WriteString("</a>")
+	return end
+}
+
+// parseDestination parses a link destination and optional title up to the closing
+// parenthesis that starts at or after i. It returns the unescaped destination and the
+// index after the parenthesis.
+func parseDestination(s string, i int) (string, int, bool) {
+	i = skipSpaces(s, i)
+
+	var destination string
+	if i < len(s) && s[i] == '<' {
+		end := strings.IndexAny(s[i+1:], ">\n")
+		if end < 0 || s[i+1+end] != '>' {
+			return "", 0, false
+		}
+		destination = s[i+1 : i+1+end]
+		i += end + 2
+	} else {
+		start := i
+		depth := 0
+	scan:
+		for ; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '(':
+				depth++
+			case ')':
+				if depth == 0 {
+					break scan
+				}
+				depth--
+			case ' ', '\n', '\t':
+				break scan
+			}
+		}
+		if i > len(s) {
+			return "", 0, false
+		}
+		destination = s[start:i]
+	}
+
+	i = skipSpaces(s, i)
+
+	// Titles are accepted for compatibility but not rendered
+	if i < len(s) && (s[i] == '"' || s[i] == '\'' || s[i] == '(') {
+		closer := s[i]
+		if closer == '(' {
+			closer = ')'
+		}
+		end := i + 1
+		for ; end < len(s) && s[end] != closer; end++ {
+			if s[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(s) {
+			return "", 0, false
+		}
+		i = skipSpaces(s, end+1)
+	}
+
+	if i >= len(s) || s[i] != ')' {
+		return "", 0, false
+	}
+
+	// Entities are decoded before the scheme is checked, so "javascript&#58;" is
+	// recognized as the scheme it spells
+	return html.UnescapeString(unescapePunct(destination)), i + 1, true
+}
+
+var emphasisTags = map[int][2]string{
+	1: {"<em>", "</em>"},
+	2: {"<strong>", "</strong>"},
+	3: {"<em><strong>", "</strong></em>"},
+}
+
+func renderEmphasis(b *strings.Builder, s string, start int) int {
+	c := s[start]
+	n := runLength(s, start, c)
+
+	if !canOpen(s, start, n) {
+		b.WriteString(s[start : start+n])
+		return start + n
+	}
+
+	// Try the widest pairing first so "***x***" becomes <em><strong>
+	for _, width := range []int{3, 2, 1} {
+		if n < width {
+			continue
+		}
+
+		closing := findClosingDelimiter(s, start+n, strings.Repeat(string(c), width))
+		if closing < 0 {
+			continue
+		}
+
+		open, close := emphasisTags[width][0], emphasisTags[width][1]
+
+		b.WriteString(s[start : start+n-width])
+		b.WriteString(open)
+		renderInline(b, s[start+n:closing])
+		b.WriteString(close)
+		return closing + width
+	}
+
+	b.WriteString(s[start : start+n])
+	return start + n
+}
+
+func renderStrikethrough(b *strings.Builder, s string, start int) int {
+	n := runLength(s, start, '~')
+	if n != 2 || !canOpen(s, start, n) {
+		b.WriteString(s[start : start+n])
+		return start + n
+	}
+
+	closing := findClosingDelimiter(s, start+2, "~~")
+	if closing >= 0 && runLength(s, closing, '~') != 2 {
+		closing = -1
+	}
+	if closing < 0 {
+		b.WriteString("~~")
+		return start + 2
+	}
+
+	b.WriteString("<del>")
+	renderInline(b, s[start+2:closing])
+	b.WriteString("</del>")
+	return closing + 2
+}
+
+// canOpen reports whether a delimiter run can open emphasis: it must be followed by
+// non-whitespace, and underscores may not open inside a word.
+func canOpen(s string, start, n int) bool {
+	next := start + n
+	if next >= len(s) || isSpace(s[next]) {
+		return false
+	}
+	if s[start] == '_' && start > 0 && isAlnum(s[start-1]) {
+		return false
+	}
+	return true
+}
+
+// findClosingDelimiter finds a delimiter run that closes emphasis whose content starts
+// at from, skipping code spans and escaped characters. The closer must follow
+// non-whitespace, and underscores may not close inside a word.
+func findClosingDelimiter(s string, from int, delimiter string) int {
+	c := delimiter[0]
+
+	for i := from; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+			continue
+		case '`':
+			n := runLength(s, i, '`')
+			if closing := findBacktickRun(s, i+n, n); closing >= 0 {
+				i = closing + n - 1
+			} else {
+				i += n - 1
+			}
+			continue
+		case c:
+		default:
+			continue
+		}
+
+		run := runLength(s, i, c)
+
+		// A single delimiter doesn't close inside a longer run, which pairs with another opener
+		if i == from || isSpace(s[i-1]) || run < len(delimiter) || len(delimiter) == 1 && run > 1 {
+			i += run - 1
+			continue
+		}
+
+		if c == '_' && i+run < len(s) && isAlnum(s[i+run]) {
+			i += run - 1
+			continue
+		}
+
+		return i
+	}
+
+	return -1
+}
+
+func findBacktickRun(s string, from, n int) int {
+	for i := from; i < len(s); {
+		if s[i] != '`' {
+			i++
+			continue
+		}
+		run := runLength(s, i, '`')
+		if run == n {
+			return i
+		}
+		i += run
+	}
+	return -1
+}
+
+func findClosingBracket(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '`':
+			n := runLength(s, i, '`')
+			if closing := findBacktickRun(s, i+n, n); closing >= 0 {
+				i = closing + n - 1
+			} else {
+				i += n - 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func writeLinkOpen(b *strings.Builder, destination string) {
+	b.WriteString(`<a href="`)
+	b.WriteString(escape(destination))
+	b.WriteString(`" rel="nofollow noopener noreferrer">`)
+}
+
+// isSafeURL allows relative URLs and absolute URLs with an allowed scheme.
+func isSafeURL(u string) bool {
+	for i := 0; i < len(u); i++ {
+		if u[i] < 0x20 || u[i] == 0x7f {
+			return false
+		}
+	}
+
+	colon := strings.IndexByte(u, ':')
+	if colon < 0 {
+		return true
+	}
+
+	// A colon after the path starts isn't a scheme separator
+	if slash := strings.IndexAny(u, "/?#"); slash >= 0 && slash < colon {
+		return true
+	}
+
+	_, ok := allowedSchemes[strings.ToLower(u[:colon])]
+	return ok
+}
+
+func unescapePunct(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func escape(s string) string {
+	return html.EscapeString(s)
+}
+
+func runLength(s string, start int, c byte) int {
+	n := 0
+	for start+n < len(s) && s[start+n] == c {
+		n++
+	}
+	return n
+}
+
+func skipSpaces(s string, i int) int {
+	for i < len(s) && isSpace(s[i]) {
+		i++
+	}
+	return i
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
@@ -0,0 +1,355 @@
+// Package markdown renders user-written CommonMark to HTML that is safe to embed in
+// a page without further sanitizing.
+//
+// The renderer covers the CommonMark constructs people use in comments: paragraphs,
+// hard line breaks, ATX and setext headings, thematic breaks, block quotes, bullet and
+// ordered lists, fenced and indented code blocks, code spans, emphasis, links and
+// autolinks, plus GFM strikethrough. Safety comes from construction rather than
+// filtering: all text is HTML-escaped, raw HTML is shown as text, only http, https
+// and mailto links are emitted, and images render as plain links so they can't be
+// used as tracking pixels.
+package markdown
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	fencePattern         = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})[ \t]*([^`\\s]*)[^`]*$")
+	atxHeadingPattern    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	thematicBreakPattern = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	setextPattern        = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	blockquotePattern    = regexp.MustCompile(`^ {0,3}> ?`)
+	listItemPattern      = regexp.MustCompile(`^( {0,3})([-+*]|(\d{1,9})([.)]))( {1,4}|$)(.*)$`)
+	languagePattern      = regexp.MustCompile(`^[A-Za-z0-9_+.#-]{1,32}$`)
+)
+
+// Render converts CommonMark source to sanitized HTML.
+func Render(source string) string {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	source = strings.ReplaceAll(source, "\r", "\n")
+
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		lines[i] = expandLeadingTabs(line)
+	}
+
+	var b strings.Builder
+	renderBlocks(&b, lines, false)
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// renderBlocks renders a sequence of lines as block elements. In tight lists
+// paragraphs are emitted without <p> wrappers.
+func renderBlocks(b *strings.Builder, lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		switch {
+		case isBlank(line):
+			i++
+
+		case fencePattern.MatchString(line):
+			i = renderFencedCode(b, lines, i)
+
+		case leadingSpaces(line) >= 4:
+			i = renderIndentedCode(b, lines, i)
+
+		case atxHeadingPattern.MatchString(line):
+			m := atxHeadingPattern.FindStringSubmatch(line)
+			level := len(m[1])
+			fmt.Fprintf(b, "<h%d>", level)
+			renderInline(b, strings.TrimSpace(m[2]))
+			fmt.Fprintf(b, "</h%d>\n", level)
+			i++
+
+		case thematicBreakPattern.MatchString(line):
+			b.WriteString("<hr />\n")
+			i++
+
+		case blockquotePattern.MatchString(line):
+			i = renderBlockquote(b, lines, i)
+
+		case listItemPattern.MatchString(line):
+			i = renderList(b, lines, i)
+
+		default:
+			i = renderParagraph(b, lines, i, tight)
+		}
+	}
+}
+
+func renderFencedCode(b *strings.Builder, lines []string, start int) int {
+	m := fencePattern.FindStringSubmatch(lines[start])
+	fence := m[1]
+	indent := leadingSpaces(lines[start])
+
+	b.WriteString("<pre><code")
+	if languagePattern.MatchString(m[2]) {
+		fmt.Fprintf(b, ` class="language-%s"`, escape(m[2]))
+	}
+	b.WriteString(">")
+
+	i := start + 1
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" && leadingSpaces(lines[i]) < 4 {
+			i++
+			break
+		}
+		b.WriteString(escape(trimIndent(lines[i], indent)))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("</code></pre>\n")
+	return i
+}
+
+func renderIndentedCode(b *strings.Builder, lines []string, start int) int {
+	end := start
+	for i := start; i < len(lines); i++ {
+		if isBlank(lines[i]) {
+			continue
+		}
+		if leadingSpaces(lines[i]) < 4 {
+			break
+		}
+		end = i + 1
+	}
+
+	b.WriteString("<pre><code>")
+	for _, line := range lines[start:end] {
+		b.WriteString(escape(trimIndent(line, 4)))
+		b.WriteString("\n")
+	}
+	b.WriteString("</code></pre>\n")
+	return end
+}
+
+func renderBlockquote(b *strings.Builder, lines []string, start int) int {
+	inner := make([]string, 0)
+
+	i := start
+	for ; i < len(lines); i++ {
+		loc := blockquotePattern.FindStringIndex(lines[i])
+		if loc != nil {
+			inner = append(inner, lines[i][loc[1]:])
+			continue
+		}
+		// Lazy continuation of a quoted paragraph
+		if !isBlank(lines[i]) && len(inner) > 0 && !isBlank(inner[len(inner)-1]) && !startsBlock(lines[i]) {
+			inner = append(inner, lines[i])
+			continue
+		}
+		break
+	}
+
+	b.WriteString("<blockquote>\n")
+	renderBlocks(b, inner, false)
+	b.WriteString("</blockquote>\n")
+	return i
+}
+
+// listContinuationIndent is the indentation that makes a line part of the list item
+// above it. Two spaces covers both "- " and "1. " items as people usually type them.
+const listContinuationIndent = 2
+
+type listItem struct {
+	lines []string
+}
+
+func renderList(b *strings.Builder, lines []string, start int) int {
+	first := listItemPattern.FindStringSubmatch(lines[start])
+	ordered := first[3] != ""
+	marker := first[2]
+	if ordered {
+		marker = first[4]
+	}
+
+	items := make([]listItem, 0)
+	loose := false
+	pendingBlank := false
+
+	i := start
+	for i < len(lines) {
+		line := lines[i]
+
+		nested := len(items) > 0 && leadingSpaces(line) >= len(first[1])+listContinuationIndent
+		if m := listItemPattern.FindStringSubmatch(line); m != nil && !nested && !thematicBreakPattern.MatchString(line) {
+			sameType := (m[3] != "") == ordered && (ordered && m[4] == marker || !ordered && m[2] == marker)
+			if !sameType {
+				break
+			}
+			if pendingBlank && len(items) > 0 {
+				loose = true
+			}
+			pendingBlank = false
+			items = append(items, listItem{lines: []string{m[6]}})
+			i++
+			continue
+		}
+
+		current := &items[len(items)-1]
+
+		switch {
+		case isBlank(line):
+			pendingBlank = true
+			current.lines = append(current.lines, "")
+		case nested:
+			if pendingBlank {
+				loose = true
+			}
+			pendingBlank = false
+			current.lines = append(current.lines, trimIndent(line, len(first[1])+listContinuationIndent))
+		case !pendingBlank && !startsBlock(line):
+			current.lines = append(current.lines, strings.TrimLeft(line, " "))
+		default:
+			return finishList(b, items, ordered, first[3], loose, i)
+		}
+		i++
+	}
+
+	return finishList(b, items, ordered, first[3], loose, i)
+}
+
+func finishList(b *strings.Builder, items []listItem, ordered bool, startNumber string, loose bool, next int) int {
+	if ordered {
+		start := strings.TrimLeft(startNumber, "0")
+		if start != "" && start != "1" {
+			fmt.Fprintf(b, "<ol start=\"%s\">\n", start)
+		} else {
+			b.WriteString("<ol>\n")
+		}
+	} else {
+		b.WriteString("<ul>\n")
+	}
+
+	for _, item := range items {
+		var inner strings.Builder
+		renderBlocks(&inner, item.lines, !loose)
+		html := inner.String()
+
+		b.WriteString("<li>")
+		switch {
+		case startsWithBlockTag(html):
+			b.WriteString("\n")
+			b.WriteString(html)
+		case strings.Count(html, "\n") > 1:
+			// Tight text followed by a nested block
+			b.WriteString(html)
+		default:
+			b.WriteString(strings.TrimSuffix(html, "\n"))
+		}
+		b.WriteString("</li>\n")
+	}
+
+	if ordered {
+		b.WriteString("</ol>\n")
+	} else {
+		b.WriteString("</ul>\n")
+	}
+
+	return next
+}
+
+func startsWithBlockTag(html string) bool {
+	for _, tag := range []string{"<p>", "<ul>", "<ol", "<pre>", "<blockquote>", "<h1>", "<h2>", "<h3>", "<h4>", "<h5>", "<h6>", "<hr />"} {
+		if strings.HasPrefix(html, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func renderParagraph(b *strings.Builder, lines []string, start int, tight bool) int {
+	text := []string{strings.TrimLeft(lines[start], " ")}
+
+	i := start + 1
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isBlank(line) {
+			break
+		}
+
+		if m := setextPattern.FindStringSubmatch(line); m != nil {
+			level := 2
+			if m[1][0] == '=' {
+				level = 1
+			}
+			fmt.Fprintf(b, "<h%d>", level)
+			renderInline(b, strings.TrimSpace(strings.Join(text, "\n")))
+			fmt.Fprintf(b, "</h%d>\n", level)
+			return i + 1
+		}
+
+		if startsBlock(line) {
+			break
+		}
+		text = append(text, strings.TrimLeft(line, " "))
+	}
+
+	content := strings.TrimRight(strings.Join(text, "\n"), " \t")
+	if tight {
+		renderInline(b, content)
+		b.WriteString("\n")
+		return i
+	}
+
+	b.WriteString("<p>")
+	renderInline(b, content)
+	b.WriteString("</p>\n")
+	return i
+}
+
+// startsBlock reports whether line interrupts a paragraph.
+func startsBlock(line string) bool {
+	if fencePattern.MatchString(line) || atxHeadingPattern.MatchString(line) ||
+		thematicBreakPattern.MatchString(line) || blockquotePattern.MatchString(line) {
+		return true
+	}
+
+	// Only bullet items and ordered items starting at 1 may interrupt a paragraph
+	if m := listItemPattern.FindStringSubmatch(line); m != nil && strings.TrimSpace(m[6]) != "" {
+		return m[3] == "" || m[3] == "1"
+	}
+
+	return false
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// trimIndent removes up to n leading spaces.
+func trimIndent(line string, n int) string {
+	if spaces := leadingSpaces(line); spaces < n {
+		n = spaces
+	}
+	return line[n:]
+}
+
+// expandLeadingTabs replaces tabs in a line's indentation with spaces to the next
+// multiple of four, which is how CommonMark measures indentation.
+func expandLeadingTabs(line string) string {
+	width := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+			width++
+		case '\t':
+			width += 4 - width%4
+		default:
+			if i == width {
+				return line
+			}
+			return strings.Repeat(" ", width) + line[i:]
+		}
+	}
+	return strings.Repeat(" ", width)
+}
@@ -0,0 +1,334 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender_XSS(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "javascript link",
+			source: "[click](javascript:alert(1))",
+			want:   "<p>click</p>",
+		},
+		{
+			name:   "javascript link with mixed case scheme",
+			source: "[click](JaVaScRiPt:alert(1))",
+			want:   "<p>click</p>",
+		},
+		{
+			name:   "data link",
+			source: "[click](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)",
+			want:   "<p>click</p>",
+		},
+		{
+			name:   "vbscript link",
+			source: "[click](vbscript:msgbox)",
+			want:   "<p>click</p>",
+		},
+		{
+			name:   "entity encoded colon",
+			source: "[click](javascript&#58;alert(1))",
+			want:   "<p>click</p>",
+		},
+		{
+			name:   "hex entity encoded scheme",
+			source: "[click](&#x6A;avascript:alert(1))",
+			want:   "<p>click</p>",
+		},
+		{
+			name:   "named entity encoded colon",
+			source: "[click](javascript&colon;alert(1))",
+			want:   "<p>click</p>",
+		},
+		{
+			name:   "entity encoded control character in scheme",
+			source: "[click](java&#9;script:alert(1))",
+			want:   "<p>click</p>",
+		},
+		{
+			name:   "backslash escaped colon",
+			source: `[click](javascript\:alert(1))`,
+			want:   "<p>click</p>",
+		},
+		{
+			name:   "angle bracket destination",
+			source: "[click](<javascript:alert(1)>)",
+			want:   "<p>click</p>",
+		},
+		{
+			name:   "javascript autolink",
+			source: "<javascript:alert(1)>",
+			want:   "<p>&lt;javascript:alert(1)&gt;</p>",
+		},
+		{
+			name:   "data image",
+			source: "![x](data:image/svg+xml;base64,PHN2Zz4=)",
+			want:   "<p>x</p>",
+		},
+		{
+			name:   "raw script tag",
+			source: "<script>alert(1)</script>",
+			want:   "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>",
+		},
+		{
+			name:   "raw html block",
+			source: "<div onclick=\"alert(1)\">\nhi\n</div>",
+			want:   "<p>&lt;div onclick=&#34;alert(1)&#34;&gt;\nhi\n&lt;/div&gt;</p>",
+		},
+		{
+			name:   "inline event handler",
+			source: "hello <img src=x onerror=alert(1)>",
+			want:   "<p>hello &lt;img src=x onerror=alert(1)&gt;</p>",
+		},
+		{
+			name:   "attribute breakout in destination",
+			source: `[x](https://example.com/"onmouseover="alert(1))`,
+			want:   `<p><a href="https://example.com/&#34;onmouseover=&#34;alert(1)" rel="nofollow noopener noreferrer">x</a></p>`,
+		},
+		{
+			name:   "attribute breakout through entity",
+			source: `[x](https://example.com/&quot;onmouseover=&quot;alert(1))`,
+			want:   `<p><a href="https://example.com/&#34;onmouseover=&#34;alert(1)" rel="nofollow noopener noreferrer">x</a></p>`,
+		},
+		{
+			name:   "attribute breakout in autolink",
+			source: `<https://example.com/'onmouseover='alert(1)>`,
+			want:   `<p><a href="https://example.com/&#39;onmouseover=&#39;alert(1)" rel="nofollow noopener noreferrer">https://example.com/&#39;onmouseover=&#39;alert(1)</a></p>`,
+		},
+		{
+			name:   "fence language breakout",
+			source: "```js\"><script>\nx\n```",
+			want:   "<pre><code>x\n</code></pre>",
+		},
+		{
+			name:   "html in code span",
+			source: "`<script>`",
+			want:   "<p><code>&lt;script&gt;</code></p>",
+		},
+		{
+			name:   "html in code block",
+			source: "    <script>alert(1)</script>",
+			want:   "<pre><code>&lt;script&gt;alert(1)&lt;/script&gt;\n</code></pre>",
+		},
+		{
+			name:   "html in link text",
+			source: "[<b>x</b>](https://example.com)",
+			want:   `<p><a href="https://example.com" rel="nofollow noopener noreferrer">&lt;b&gt;x&lt;/b&gt;</a></p>`,
+		},
+		{
+			name:   "entity passes through as text",
+			source: "&lt;script&gt;",
+			want:   "<p>&lt;script&gt;</p>",
+		},
+		{
+			name:   "bare ampersand",
+			source: "a & b",
+			want:   "<p>a &amp; b</p>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Render(tt.source)
+			assert.Equal(t, tt.want, got)
+
+			lower := strings.ToLower(got)
+			assert.NotContains(t, lower, "<script")
+			assert.NotContains(t, lower, "href=\"javascript")
+			assert.NotContains(t, lower, "href=\"data")
+		})
+	}
+}
+
+func TestRender_CommonMark(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "paragraphs",
+			source: "aaa\n\nbbb",
+			want:   "<p>aaa</p>\n<p>bbb</p>",
+		},
+		{
+			name:   "soft line break",
+			source: "aaa\nbbb",
+			want:   "<p>aaa\nbbb</p>",
+		},
+		{
+			name:   "hard line break with spaces",
+			source: "foo  \nbar",
+			want:   "<p>foo<br />\nbar</p>",
+		},
+		{
+			name:   "hard line break with backslash",
+			source: "foo\\\nbar",
+			want:   "<p>foo<br />\nbar</p>",
+		},
+		{
+			name:   "atx headings",
+			source: "# foo\n## foo ##\n###### foo",
+			want:   "<h1>foo</h1>\n<h2>foo</h2>\n<h6>foo</h6>",
+		},
+		{
+			name:   "seven hashes is not a heading",
+			source: "####### foo",
+			want:   "<p>####### foo</p>",
+		},
+		{
+			name:   "setext headings",
+			source: "Foo\n===\n\nBar\n---",
+			want:   "<h1>Foo</h1>\n<h2>Bar</h2>",
+		},
+		{
+			name:   "thematic breaks",
+			source: "***\n- - -\n___",
+			want:   "<hr />\n<hr />\n<hr />",
+		},
+		{
+			name:   "block quote",
+			source: "> # Foo\n> bar\nbaz",
+			want:   "<blockquote>\n<h1>Foo</h1>\n<p>bar\nbaz</p>\n</blockquote>",
+		},
+		{
+			name:   "tight bullet list",
+			source: "- foo\n- bar",
+			want:   "<ul>\n<li>foo</li>\n<li>bar</li>\n</ul>",
+		},
+		{
+			name:   "loose bullet list",
+			source: "- foo\n\n- bar",
+			want:   "<ul>\n<li>\n<p>foo</p>\n</li>\n<li>\n<p>bar</p>\n</li>\n</ul>",
+		},
+		{
+			name:   "ordered list with start",
+			source: "3. foo\n4. bar",
+			want:   "<ol start=\"3\">\n<li>foo</li>\n<li>bar</li>\n</ol>",
+		},
+		{
+			name:   "changing bullet starts a new list",
+			source: "- foo\n+ bar",
+			want:   "<ul>\n<li>foo</li>\n</ul>\n<ul>\n<li>bar</li>\n</ul>",
+		},
+		{
+			name:   "fenced code with info string",
+			source: "```go\nfmt.Println(\"<hi>\")\n```",
+			want:   "<pre><code class=\"language-go\">fmt.Println(&#34;&lt;hi&gt;&#34;)\n</code></pre>",
+		},
+		{
+			name:   "tilde fence",
+			source: "~~~\naaa\n~~~",
+			want:   "<pre><code>aaa\n</code></pre>",
+		},
+		{
+			name:   "indented code",
+			source: "    a simple\n      indented code block",
+			want:   "<pre><code>a simple\n  indented code block\n</code></pre>",
+		},
+		{
+			name:   "code span with backticks inside",
+			source: "`` foo ` bar ``",
+			want:   "<p><code>foo ` bar</code></p>",
+		},
+		{
+			name:   "emphasis and strong",
+			source: "*foo* __bar__ ***baz***",
+			want:   "<p><em>foo</em> <strong>bar</strong> <em><strong>baz</strong></em></p>",
+		},
+		{
+			name:   "intraword underscore is not emphasis",
+			source: "snake_case_name",
+			want:   "<p>snake_case_name</p>",
+		},
+		{
+			name:   "strikethrough",
+			source: "~~gone~~",
+			want:   "<p><del>gone</del></p>",
+		},
+		{
+			name:   "backslash escapes",
+			source: `\*not emphasized\*`,
+			want:   "<p>*not emphasized*</p>",
+		},
+		{
+			name:   "inline link",
+			source: "[link](/uri)",
+			want:   `<p><a href="/uri" rel="nofollow noopener noreferrer">link</a></p>`,
+		},
+		{
+			name:   "link with title",
+			source: `[link](/uri "title")`,
+			want:   `<p><a href="/uri" rel="nofollow noopener noreferrer">link</a></p>`,
+		},
+		{
+			name:   "link title with escaped quote",
+			source: `[link](/url "title \"and\" title")`,
+			want:   `<p><a href="/url" rel="nofollow noopener noreferrer">link</a></p>`,
+		},
+		{
+			name:   "link title in parentheses",
+			source: `[link](/url (title))`,
+			want:   `<p><a href="/url" rel="nofollow noopener noreferrer">link</a></p>`,
+		},
+		{
+			name:   "link destination with balanced parentheses",
+			source: "[link](foo(and(bar)))",
+			want:   `<p><a href="foo(and(bar))" rel="nofollow noopener noreferrer">link</a></p>`,
+		},
+		{
+			name:   "link destination with entity",
+			source: "[link](/f&ouml;&ouml;)",
+			want:   `<p><a href="/föö" rel="nofollow noopener noreferrer">link</a></p>`,
+		},
+		{
+			name:   "link destination in angle brackets with space",
+			source: "[link](</my uri>)",
+			want:   `<p><a href="/my uri" rel="nofollow noopener noreferrer">link</a></p>`,
+		},
+		{
+			name:   "uri autolink",
+			source: "<https://example.com>",
+			want:   `<p><a href="https://example.com" rel="nofollow noopener noreferrer">https://example.com</a></p>`,
+		},
+		{
+			name:   "email autolink",
+			source: "<foo@bar.example.com>",
+			want:   `<p><a href="mailto:foo@bar.example.com" rel="nofollow noopener noreferrer">foo@bar.example.com</a></p>`,
+		},
+		{
+			name:   "image renders as link",
+			source: "![alt text](https://example.com/a.png)",
+			want:   `<p><a href="https://example.com/a.png" rel="nofollow noopener noreferrer">alt text</a></p>`,
+		},
+		{
+			name:   "unclosed link text",
+			source: "[not a link",
+			want:   "<p>[not a link</p>",
+		},
+		{
+			name:   "tabs expand in indentation",
+			source: "\tfoo",
+			want:   "<pre><code>foo\n</code></pre>",
+		},
+		{
+			name:   "crlf line endings",
+			source: "a\r\nb",
+			want:   "<p>a\nb</p>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Render(tt.source))
+		})
+	}
+}
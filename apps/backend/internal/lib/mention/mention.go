@@ -0,0 +1,117 @@
+package mention
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MaxHandles caps how many distinct handles a single text can mention.
+const MaxHandles = 20
+
+// handlePattern matches @handle when it starts the text or follows a character that
+// can't be part of an email address or another handle, so "jane@example.com" is ignored.
+var handlePattern = regexp.MustCompile(`(?:^|[^\w@.])@([A-Za-z0-9_][A-Za-z0-9_.-]{0,63})`)
+
+// fencePattern matches the line opening a fenced code block, as the markdown renderer does.
+var fencePattern = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})[ \t]*[^`]*$")
+
+// ParseHandles returns the distinct lowercase handles mentioned in text, in order
+// of first appearance. Handles inside code spans and fenced code blocks are quoted
+// code, not mentions.
+func ParseHandles(text string) []string {
+	matches := handlePattern.FindAllStringSubmatch(stripCode(text), -1)
+
+	handles := make([]string, 0, len(matches))
+	seen := make(map[string]struct{}, len(matches))
+
+	for _, match := range matches {
+		// Sentence punctuation right after a handle isn't part of it
+		handle := strings.ToLower(strings.TrimRight(match[1], ".-"))
+		if handle == "" {
+			continue
+		}
+		if _, ok := seen[handle]; ok {
+			continue
+		}
+
+		seen[handle] = struct{}{}
+		handles = append(handles, handle)
+
+		if len(handles) == MaxHandles {
+			break
+		}
+	}
+
+	return handles
+}
+
+// stripCode blanks out fenced code blocks and code spans, leaving a space in their
+// place so the text around them stays apart.
+func stripCode(text string) string {
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		m := fencePattern.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+
+		// An unclosed fence runs to the end of the text
+		fence := m[1]
+		lines[i] = ""
+		for i++; i < len(lines); i++ {
+			trimmed := strings.TrimSpace(lines[i])
+			closing := strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == ""
+			lines[i] = ""
+			if closing {
+				break
+			}
+		}
+	}
+	text = strings.Join(lines, "\n")
+
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		if text[i] != '`' {
+			b.WriteByte(text[i])
+			i++
+			continue
+		}
+
+		n := backtickRun(text, i)
+		closing := findBacktickRun(text, i+n, n)
+		if closing < 0 {
+			// A run with no matching closer is literal backticks
+			b.WriteString(text[i : i+n])
+			i += n
+			continue
+		}
+
+		b.WriteByte(' ')
+		i = closing + n
+	}
+
+	return b.String()
+}
+
+func backtickRun(s string, start int) int {
+	i := start
+	for i < len(s) && s[i] == '`' {
+		i++
+	}
+	return i - start
+}
+
+func findBacktickRun(s string, from, n int) int {
+	for i := from; i < len(s); {
+		if s[i] != '`' {
+			i++
+			continue
+		}
+		run := backtickRun(s, i)
+		if run == n {
+			return i
+		}
+		i += run
+	}
+	return -1
+}
@@ -0,0 +1,135 @@
+package mention
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHandles(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "no mentions",
+			source: "Nothing to see here",
+			want:   []string{},
+		},
+		{
+			name:   "single mention",
+			source: "@jane please review",
+			want:   []string{"jane"},
+		},
+		{
+			name:   "mentions in order of appearance",
+			source: "cc @bob and @alice",
+			want:   []string{"bob", "alice"},
+		},
+		{
+			name:   "handles are lowercased",
+			source: "thanks @Jane.Doe",
+			want:   []string{"jane.doe"},
+		},
+		{
+			name:   "email address",
+			source: "send it to jane@example.com",
+			want:   []string{},
+		},
+		{
+			name:   "email address next to a mention",
+			source: "@bob mailed jane@example.com and bob.smith@example.org",
+			want:   []string{"bob"},
+		},
+		{
+			name:   "double at sign",
+			source: "@@jane",
+			want:   []string{},
+		},
+		{
+			name:   "trailing sentence punctuation",
+			source: "Ask @jane. Or @bob-, or @carol!",
+			want:   []string{"jane", "bob", "carol"},
+		},
+		{
+			name:   "surrounding punctuation",
+			source: "(@jane), \"@bob\" and [@carol]?",
+			want:   []string{"jane", "bob", "carol"},
+		},
+		{
+			name:   "dots and dashes inside a handle",
+			source: "@jane.doe-2 is back",
+			want:   []string{"jane.doe-2"},
+		},
+		{
+			name:   "duplicates",
+			source: "@jane @bob @jane",
+			want:   []string{"jane", "bob"},
+		},
+		{
+			name:   "duplicates differing in case",
+			source: "@Jane and @JANE",
+			want:   []string{"jane"},
+		},
+		{
+			name:   "duplicates differing in trailing punctuation",
+			source: "@jane, then @jane.",
+			want:   []string{"jane"},
+		},
+		{
+			name:   "code span",
+			source: "run `@jane` but tell @bob",
+			want:   []string{"bob"},
+		},
+		{
+			name:   "code span with double backticks",
+			source: "``echo `@jane` `` for @bob",
+			want:   []string{"bob"},
+		},
+		{
+			name:   "unclosed backtick",
+			source: "a ` before @jane",
+			want:   []string{"jane"},
+		},
+		{
+			name:   "code span right before a mention",
+			source: "`x`@jane",
+			want:   []string{"jane"},
+		},
+		{
+			name:   "fenced code block",
+			source: "@bob see:\n```go\n// @jane wrote this\n```\nthanks @carol",
+			want:   []string{"bob", "carol"},
+		},
+		{
+			name:   "tilde fence",
+			source: "~~~\n@jane\n~~~\n@bob",
+			want:   []string{"bob"},
+		},
+		{
+			name:   "unclosed fence",
+			source: "@bob\n```\n@jane",
+			want:   []string{"bob"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseHandles(tt.source))
+		})
+	}
+}
+
+func TestParseHandles_Limit(t *testing.T) {
+	handles := make([]string, 0, MaxHandles+5)
+	for i := range MaxHandles + 5 {
+		handles = append(handles, fmt.Sprintf("@user%d", i))
+	}
+
+	got := ParseHandles(strings.Join(handles, " "))
+	assert.Len(t, got, MaxHandles)
+	assert.Equal(t, "user0", got[0])
+}
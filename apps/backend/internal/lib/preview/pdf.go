@@ -0,0 +1,123 @@
+package preview
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+var (
+	pagesTypePattern  = regexp.MustCompile(`/Type\s*/Pages\b`)
+	countPattern      = regexp.MustCompile(`/Count\s+(\d+)`)
+	objStmTypePattern = regexp.MustCompile(`/Type\s*/ObjStm\b`)
+	flatePattern      = regexp.MustCompile(`/Filter\s*/FlateDecode\b`)
+)
+
+// PageCount reads the number of pages of a PDF from its page tree. It doesn't
+// render or fully parse the document: the root of the page tree holds the total,
+// so the largest /Count of any /Pages dictionary is the page count. Dictionaries
+// packed into compressed object streams are inflated and searched as well.
+func PageCount(r io.Reader) (int, error) {
+	data, err := readSource(r)
+	if err != nil {
+		return 0, err
+	}
+
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\n\f\r "), []byte("%PDF-")) {
+		return 0, fmt.Errorf("%w: not a PDF", ErrUnsupported)
+	}
+
+	count := 0
+	for _, dict := range dictionaries(data) {
+		count = max(count, pagesCount(data[dict[0]:dict[1]]))
+
+		if !isObjectStream(data[dict[0]:dict[1]]) {
+			continue
+		}
+		stream, ok := inflateStream(data[dict[1]:])
+		if !ok {
+			continue
+		}
+		for _, inner := range dictionaries(stream) {
+			count = max(count, pagesCount(stream[inner[0]:inner[1]]))
+		}
+	}
+
+	if count == 0 {
+		return 0, fmt.Errorf("%w: page tree not found", ErrUnsupported)
+	}
+
+	return count, nil
+}
+
+// dictionaries returns the start and end offsets of every "<< ... >>" dictionary in
+// data, nested ones included. Unbalanced delimiters in binary streams are skipped.
+func dictionaries(data []byte) [][2]int {
+	var found [][2]int
+	var open []int
+
+	for i := 0; i+1 < len(data); i++ {
+		switch {
+		case data[i] == '<' && data[i+1] == '<':
+			open = append(open, i)
+			i++
+		case data[i] == '>' && data[i+1] == '>':
+			if len(open) > 0 {
+				found = append(found, [2]int{open[len(open)-1], i + 2})
+				open = open[:len(open)-1]
+			}
+			i++
+		}
+	}
+
+	return found
+}
+
+func pagesCount(dict []byte) int {
+	if !pagesTypePattern.Match(dict) {
+		return 0
+	}
+
+	match := countPattern.FindSubmatch(dict)
+	if match == nil {
+		return 0
+	}
+
+	n, err := strconv.Atoi(string(match[1]))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func isObjectStream(dict []byte) bool {
+	return objStmTypePattern.Match(dict) && flatePattern.Match(dict)
+}
+
+// inflateStream decompresses the stream that follows a dictionary. Whatever
+// inflates before an error is kept, which is enough to find the page tree in
+// slightly damaged files.
+func inflateStream(rest []byte) ([]byte, bool) {
+	rest = bytes.TrimLeft(rest, "\x00\t\n\f\r ")
+	if !bytes.HasPrefix(rest, []byte("stream")) {
+		return nil, false
+	}
+	rest = bytes.TrimPrefix(rest[len("stream"):], []byte("\r"))
+	rest = bytes.TrimPrefix(rest, []byte("\n"))
+
+	if end := bytes.Index(rest, []byte("endstream")); end >= 0 {
+		rest = rest[:end]
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(rest))
+	if err != nil {
+		return nil, false
+	}
+	defer zr.Close()
+
+	out, _ := io.ReadAll(io.LimitReader(zr, MaxSourceSize))
+	return out, len(out) > 0
+}
@@ -0,0 +1,111 @@
+// Package preview generates thumbnails and document details for attachments.
+package preview
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// ThumbnailSize bounds both sides of a thumbnail
+	ThumbnailSize = 320
+	// ThumbnailContentType is what Thumbnail encodes to
+	ThumbnailContentType = "image/jpeg"
+
+	// MaxSourceSize is the largest file read to build a preview
+	MaxSourceSize = 50 << 20
+	// MaxPixels keeps decoding a small image with huge dimensions from
+	// exhausting memory
+	MaxPixels = 50_000_000
+
+	thumbnailQuality = 80
+)
+
+var (
+	ErrUnsupported = errors.New("preview is not supported for this file")
+	ErrTooLarge    = errors.New("file is too large to preview")
+)
+
+var imageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/bmp":  true,
+	"image/tiff": true,
+	"image/webp": true,
+}
+
+// IsImage reports whether Thumbnail can decode files of this content type.
+func IsImage(contentType string) bool {
+	return imageTypes[mediaType(contentType)]
+}
+
+// IsPDF reports whether PageCount applies to files of this content type.
+func IsPDF(contentType string) bool {
+	return mediaType(contentType) == "application/pdf"
+}
+
+// Supported reports whether any preview can be generated for the content type.
+func Supported(contentType string) bool {
+	return IsImage(contentType) || IsPDF(contentType)
+}
+
+// Thumbnail decodes an image, applies its EXIF orientation and scales it to fit
+// within ThumbnailSize, returning it as a JPEG. Transparent areas are flattened
+// onto white since JPEG has no alpha channel.
+func Thumbnail(r io.Reader) ([]byte, error) {
+	data, err := readSource(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+
+	thumb := imaging.Fit(img, ThumbnailSize, ThumbnailSize, imaging.Lanczos)
+	bounds := thumb.Bounds()
+	flat := imaging.Overlay(imaging.New(bounds.Dx(), bounds.Dy(), color.White), thumb, image.Pt(0, 0), 1)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// readSource reads a whole file up to MaxSourceSize.
+func readSource(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxSourceSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxSourceSize {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+func mediaType(contentType string) string {
+	media, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(media))
+}
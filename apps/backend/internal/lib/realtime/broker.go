@@ -0,0 +1,297 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+)
+
+const (
+	historyKeyPrefix = "realtime:history:"
+	channelPrefix    = "realtime:user:"
+
+	// HistoryMaxLen bounds the per-user replay log kept in Redis for Last-Event-ID resume.
+	HistoryMaxLen = 1000
+	// BufferSize is the number of undelivered events a single connection may hold
+	// before it is considered a slow consumer and disconnected.
+	BufferSize = 64
+	// HeartbeatInterval is how often idle connections receive a keep-alive frame.
+	HeartbeatInterval = 15 * time.Second
+)
+
+// Broker fans out change events to connected clients. Events are appended to a
+// capped Redis stream per user (for resume) and published over Redis pub/sub so
+// every server instance can deliver them to its own local subscribers.
+type Broker struct {
+	redis  *redis.Client
+	logger *zerolog.Logger
+
+	mu          sync.RWMutex
+	subscribers map[string]map[*Subscription]struct{}
+
+	pubsub    *redis.PubSub
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Subscription is a single client connection's view of a user's event stream.
+type Subscription struct {
+	UserID string
+
+	broker *Broker
+	events chan Event
+}
+
+func NewBroker(redisClient *redis.Client, logger *zerolog.Logger) *Broker {
+	return &Broker{
+		redis:       redisClient,
+		logger:      logger,
+		subscribers: make(map[string]map[*Subscription]struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Start subscribes to the user channels of every instance and begins dispatching
+// incoming events to local subscribers.
+func (b *Broker) Start() {
+	b.pubsub = b.redis.PSubscribe(context.Background(), channelPrefix+"*")
+
+	go func() {
+		ch := b.pubsub.Channel()
+		for {
+			select {
+			case <-b.done:
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+
+				var event Event
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					b.logger.Error().Err(err).Str("channel", msg.Channel).Msg("failed to decode realtime event")
+					continue
+				}
+
+				b.dispatch(event)
+			}
+		}
+	}()
+
+	b.logger.Info().Msg("realtime broker started")
+}
+
+// Close stops dispatching and disconnects every local subscriber. Only the first
+// call does anything, so shutdown paths may each close the broker.
+func (b *Broker) Close() error {
+	var err error
+	b.closeOnce.Do(func() {
+		close(b.done)
+
+		b.mu.Lock()
+		for userID, subs := range b.subscribers {
+			for sub := range subs {
+				close(sub.events)
+			}
+			delete(b.subscribers, userID)
+		}
+		b.mu.Unlock()
+
+		if b.pubsub != nil {
+			err = b.pubsub.Close()
+		}
+	})
+
+	return err
+}
+
+// Publish records an event in the user's replay log and broadcasts it to all instances.
+func (b *Broker) Publish(ctx context.Context, userID string, eventType EventType, resourceID string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal realtime event data for type=%s: %w", eventType, err)
+	}
+
+	event := Event{
+		Type:       eventType,
+		UserID:     userID,
+		ResourceID: resourceID,
+		Data:       payload,
+		OccurredAt: time.Now().UTC(),
+	}
+
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal realtime event for type=%s: %w", eventType, err)
+	}
+
+	id, err := b.redis.XAdd(ctx, &redis.XAddArgs{
+		Stream: historyKeyPrefix + userID,
+		MaxLen: HistoryMaxLen,
+		Approx: true,
+		Values: map[string]any{"event": encoded},
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to append realtime event to history for user_id=%s: %w", userID, err)
+	}
+
+	event.ID = id
+	encoded, err = json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal realtime event for type=%s: %w", eventType, err)
+	}
+
+	if err := b.redis.Publish(ctx, channelPrefix+userID, encoded).Err(); err != nil {
+		return fmt.Errorf("failed to publish realtime event for user_id=%s: %w", userID, err)
+	}
+
+	return nil
+}
+
+// Subscribe registers a new local subscriber for userID. When lastEventID is set, the
+// events recorded after it are returned as a backlog to be delivered before live events.
+func (b *Broker) Subscribe(ctx context.Context, userID string, lastEventID string) (*Subscription, []Event, error) {
+	sub := &Subscription{
+		UserID: userID,
+		broker: b,
+		events: make(chan Event, BufferSize),
+	}
+
+	// Register before reading the backlog so nothing published in between is lost;
+	// consumers drop duplicates with IsAfter.
+	b.mu.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[*Subscription]struct{})
+	}
+	b.subscribers[userID][sub] = struct{}{}
+	b.mu.Unlock()
+
+	if lastEventID == "" || !isValidID(lastEventID) {
+		return sub, nil, nil
+	}
+
+	messages, err := b.redis.XRangeN(ctx, historyKeyPrefix+userID, "("+lastEventID, "+", HistoryMaxLen).Result()
+	if err != nil {
+		sub.Close()
+		return nil, nil, fmt.Errorf("failed to read realtime history for user_id=%s: %w", userID, err)
+	}
+
+	backlog := make([]Event, 0, len(messages))
+	for _, msg := range messages {
+		raw, ok := msg.Values["event"].(string)
+		if !ok {
+			continue
+		}
+
+		var event Event
+		if err := json.Unmarshal([]byte(raw), &event); err != nil {
+			b.logger.Error().Err(err).Str("event_id", msg.ID).Msg("failed to decode realtime history entry")
+			continue
+		}
+		event.ID = msg.ID
+		backlog = append(backlog, event)
+	}
+
+	return sub, backlog, nil
+}
+
+// Events returns the live event channel. It is closed when the subscription is
+// dropped, either by Close or because the consumer fell too far behind.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close unregisters the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.broker.remove(s)
+}
+
+func (b *Broker) remove(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subs, ok := b.subscribers[sub.UserID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.subscribers, sub.UserID)
+	}
+	close(sub.events)
+}
+
+func (b *Broker) dispatch(event Event) {
+	var slow []*Subscription
+
+	b.mu.RLock()
+	for sub := range b.subscribers[event.UserID] {
+		select {
+		case sub.events <- event:
+		default:
+			slow = append(slow, sub)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, sub := range slow {
+		b.logger.Warn().
+			Str("user_id", sub.UserID).
+			Str("event_id", event.ID).
+			Msg("disconnecting slow realtime consumer")
+		b.remove(sub)
+	}
+}
+
+// IsAfter reports whether stream entry ID a was recorded after b.
+func IsAfter(a, b string) bool {
+	if b == "" {
+		return true
+	}
+
+	aMs, aSeq, okA := parseID(a)
+	bMs, bSeq, okB := parseID(b)
+	if !okA || !okB {
+		return true
+	}
+
+	if aMs != bMs {
+		return aMs > bMs
+	}
+	return aSeq > bSeq
+}
+
+func isValidID(id string) bool {
+	_, _, ok := parseID(id)
+	return ok
+}
+
+func parseID(id string) (uint64, uint64, bool) {
+	msPart, seqPart, found := strings.Cut(id, "-")
+	if !found {
+		return 0, 0, false
+	}
+
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return ms, seq, true
+}
@@ -0,0 +1,45 @@
+package realtime
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventTodoCreated          EventType = "todo.created"
+	EventTodoUpdated          EventType = "todo.updated"
+	EventTodoDeleted          EventType = "todo.deleted"
+	EventCategoryCreated      EventType = "category.created"
+	EventCategoryUpdated      EventType = "category.updated"
+	EventCategoryDeleted      EventType = "category.deleted"
+	EventCommentAdded         EventType = "comment.added"
+	EventCommentUpdated       EventType = "comment.updated"
+	EventCommentDeleted       EventType = "comment.deleted"
+	EventCommentReacted       EventType = "comment.reacted"
+	EventTodoAssigneesChanged EventType = "todo.assignees_changed"
+	EventAttachmentAdded      EventType = "attachment.added"
+	EventAttachmentDeleted    EventType = "attachment.deleted"
+	EventAttachmentUpdated    EventType = "attachment.updated"
+	EventNotificationCreated  EventType = "notification.created"
+	EventNotificationUpdated  EventType = "notification.updated"
+	EventNotificationsReadAll EventType = "notifications.read_all"
+)
+
+// Event is a single change notification delivered to a user's stream.
+// ID is the Redis stream entry ID and doubles as the SSE event id used for resume.
+type Event struct {
+	ID         string          `json:"id"`
+	Type       EventType       `json:"type"`
+	UserID     string          `json:"userId"`
+	ResourceID string          `json:"resourceId"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	OccurredAt time.Time       `json:"occurredAt"`
+}
+
+// OrgStreamKey is the stream key shared by every member of an organization workspace.
+// Clerk user IDs are prefixed with "user_", so it can't collide with a user's own key.
+func OrgStreamKey(orgID string) string {
+	return "org:" + orgID
+}
@@ -0,0 +1,116 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamavChunkSize is how much of the file goes into each INSTREAM chunk.
+const clamavChunkSize = 64 << 10
+
+// ClamAVScanner streams files to a clamd daemon over TCP with the INSTREAM
+// command, so files never need to be on a disk clamd can read. clamd rejects
+// streams over its StreamMaxLength, which such files report as ErrScanFailed.
+type ClamAVScanner struct {
+	address string
+	timeout time.Duration
+}
+
+func NewClamAVScanner(address string, timeout time.Duration) *ClamAVScanner {
+	return &ClamAVScanner{
+		address: address,
+		timeout: timeout,
+	}
+}
+
+func (s *ClamAVScanner) Enabled() bool {
+	return true
+}
+
+func (s *ClamAVScanner) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(s.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("failed to set clamd deadline: %w", err)
+	}
+
+	// clamd answers as soon as it gives up on a stream, closing the connection, so
+	// a failed write still leaves its reason to read
+	writeErr := s.stream(conn, r)
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		if writeErr != nil {
+			return nil, writeErr
+		}
+		return nil, fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+
+	return parseClamAVReply(strings.TrimRight(reply, "\x00"))
+}
+
+// stream sends r as INSTREAM chunks, each prefixed with its length, ending with a
+// zero-length chunk.
+func (s *ClamAVScanner) stream(conn net.Conn, r io.Reader) error {
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return fmt.Errorf("failed to send clamd command: %w", err)
+	}
+
+	buf := make([]byte, 4+clamavChunkSize)
+	for {
+		n, err := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				return fmt.Errorf("failed to stream file to clamd: %w", err)
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return fmt.Errorf("failed to end clamd stream: %w", err)
+	}
+	return nil
+}
+
+// parseClamAVReply reads "stream: OK", "stream: <signature> FOUND" or
+// "<reason> ERROR".
+func parseClamAVReply(reply string) (*Result, error) {
+	reply = strings.TrimSpace(reply)
+
+	switch {
+	case strings.HasSuffix(reply, "ERROR"):
+		return nil, fmt.Errorf("%w: clamd replied %q", ErrScanFailed, reply)
+	case strings.HasSuffix(reply, " OK"):
+		return &Result{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		signature := strings.TrimSuffix(reply, " FOUND")
+		if _, after, ok := strings.Cut(signature, ": "); ok {
+			signature = after
+		}
+		return &Result{Infected: true, Signature: signature}, nil
+	default:
+		return nil, fmt.Errorf("unexpected clamd reply %q", reply)
+	}
+}
@@ -0,0 +1,135 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/lib/scan/scantest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClamAVScanner_Scan(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("clean", func(t *testing.T) {
+		d := scantest.NewClamd(t, 1<<20)
+		scanner := NewClamAVScanner(d.Address(), 5*time.Second)
+
+		// Larger than a chunk, so the file goes over in several
+		content := strings.Repeat("harmless content ", 10_000)
+		result, err := scanner.Scan(ctx, strings.NewReader(content))
+		require.NoError(t, err)
+
+		assert.False(t, result.Infected)
+		assert.Empty(t, result.Signature)
+		require.Len(t, d.Streams(), 1)
+		assert.Equal(t, content, string(d.Streams()[0]))
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		d := scantest.NewClamd(t, 1<<20)
+		scanner := NewClamAVScanner(d.Address(), 5*time.Second)
+
+		result, err := scanner.Scan(ctx, strings.NewReader(""))
+		require.NoError(t, err)
+		assert.False(t, result.Infected)
+	})
+
+	t.Run("infected", func(t *testing.T) {
+		d := scantest.NewClamd(t, 1<<20)
+		scanner := NewClamAVScanner(d.Address(), 5*time.Second)
+
+		result, err := scanner.Scan(ctx, strings.NewReader("prefix "+scantest.EICARMarker+" suffix"))
+		require.NoError(t, err)
+
+		assert.True(t, result.Infected)
+		assert.Equal(t, "Eicar", result.Signature)
+	})
+
+	t.Run("oversize", func(t *testing.T) {
+		d := scantest.NewClamd(t, 1024)
+		scanner := NewClamAVScanner(d.Address(), 5*time.Second)
+
+		result, err := scanner.Scan(ctx, bytes.NewReader(make([]byte, 4*clamavChunkSize)))
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, ErrScanFailed)
+		assert.ErrorContains(t, err, "size limit exceeded")
+	})
+
+	t.Run("daemon down", func(t *testing.T) {
+		scanner := NewClamAVScanner(scantest.DownAddress(t), time.Second)
+
+		result, err := scanner.Scan(ctx, strings.NewReader("content"))
+		assert.Nil(t, result)
+		require.Error(t, err)
+		// An outage is retried, unlike a file the scanner can't handle
+		assert.False(t, errors.Is(err, ErrScanFailed))
+		assert.ErrorContains(t, err, "failed to connect to clamd")
+	})
+
+	t.Run("daemon hangs", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { listener.Close() })
+
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			io.Copy(io.Discard, conn)
+		}()
+
+		scanner := NewClamAVScanner(listener.Addr().String(), 200*time.Millisecond)
+
+		result, err := scanner.Scan(ctx, strings.NewReader("content"))
+		assert.Nil(t, result)
+		require.Error(t, err)
+		assert.False(t, errors.Is(err, ErrScanFailed))
+	})
+
+	t.Run("unexpected reply", func(t *testing.T) {
+		d := scantest.NewClamd(t, 1<<20)
+		d.Reply = "PONG"
+		scanner := NewClamAVScanner(d.Address(), 5*time.Second)
+
+		result, err := scanner.Scan(ctx, strings.NewReader("content"))
+		assert.Nil(t, result)
+		assert.ErrorContains(t, err, "unexpected clamd reply")
+	})
+}
+
+func TestParseClamAVReply(t *testing.T) {
+	tests := []struct {
+		reply     string
+		want      *Result
+		scanError bool
+	}{
+		{reply: "stream: OK", want: &Result{}},
+		{reply: "stream: OK\n", want: &Result{}},
+		{reply: "stream: Eicar-Signature FOUND", want: &Result{Infected: true, Signature: "Eicar-Signature"}},
+		{reply: "stream: Win.Test.EICAR_HDB-1 FOUND", want: &Result{Infected: true, Signature: "Win.Test.EICAR_HDB-1"}},
+		{reply: "INSTREAM size limit exceeded. ERROR", scanError: true},
+		{reply: "stream: Can't allocate memory ERROR", scanError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.reply, func(t *testing.T) {
+			result, err := parseClamAVReply(tt.reply)
+			if tt.scanError {
+				assert.ErrorIs(t, err, ErrScanFailed)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, result)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// Package scantest provides a stand-in clamd daemon for tests of code that scans
+// files with the ClamAV scanner.
+package scantest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// EICARMarker stands in for the EICAR test file: the daemon reports streams
+// containing it the way clamd reports the real one.
+const EICARMarker = "EICAR-STANDARD-ANTIVIRUS-TEST-FILE"
+
+// Clamd answers INSTREAM commands on a local port like clamd does: "stream: OK",
+// "stream: Eicar FOUND", or a size limit error once a stream exceeds MaxLength.
+type Clamd struct {
+	listener net.Listener
+	// MaxLength is clamd's StreamMaxLength
+	MaxLength int
+	// Reply, when set, is sent instead of a verdict
+	Reply string
+
+	mu      sync.Mutex
+	streams [][]byte
+}
+
+// NewClamd starts a daemon that stops when the test ends.
+func NewClamd(t *testing.T, maxLength int) *Clamd {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	d := &Clamd{listener: listener, MaxLength: maxLength}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn)
+		}
+	}()
+
+	return d
+}
+
+// DownAddress returns an address no daemon listens on.
+func DownAddress(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	return address
+}
+
+func (d *Clamd) Address() string {
+	return d.listener.Addr().String()
+}
+
+// Streams returns the content of every complete stream the daemon received.
+func (d *Clamd) Streams() [][]byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.streams
+}
+
+func (d *Clamd) serve(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	r := bufio.NewReader(conn)
+	command, err := r.ReadString(0)
+	if err != nil || command != "zINSTREAM\x00" {
+		conn.Write([]byte("UNKNOWN COMMAND\x00"))
+		return
+	}
+
+	var stream bytes.Buffer
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+
+		if stream.Len()+int(size) > d.MaxLength {
+			conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+			// Drain the rest so closing doesn't reset the connection before the
+			// client reads the reply
+			conn.(*net.TCPConn).CloseWrite()
+			io.Copy(io.Discard, r)
+			return
+		}
+
+		if _, err := io.CopyN(&stream, r, int64(size)); err != nil {
+			return
+		}
+	}
+
+	d.mu.Lock()
+	d.streams = append(d.streams, stream.Bytes())
+	d.mu.Unlock()
+
+	switch {
+	case d.Reply != "":
+		conn.Write([]byte(d.Reply + "\x00"))
+	case bytes.Contains(stream.Bytes(), []byte(EICARMarker)):
+		conn.Write([]byte("stream: Eicar FOUND\x00"))
+	default:
+		conn.Write([]byte("stream: OK\x00"))
+	}
+}
@@ -0,0 +1,399 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/config"
+)
+
+// LocalPathPrefix is where the app serves signed local download URLs.
+const LocalPathPrefix = "/api/v1/storage/"
+
+var (
+	ErrInvalidKey       = errors.New("invalid storage key")
+	ErrInvalidSignature = errors.New("invalid storage URL signature")
+	ErrURLExpired       = errors.New("storage URL has expired")
+)
+
+// LocalStorage keeps objects on the local filesystem, for development and tests.
+// Content lives under <dir>/objects and metadata S3 would keep, such as the content
+// type, under <dir>/meta.
+type LocalStorage struct {
+	dir        string
+	signingKey []byte
+	publicURL  string
+}
+
+type localMeta struct {
+	ContentType string `json:"contentType"`
+	ETag        string `json:"etag"`
+}
+
+func NewLocalStorage(cfg *config.StorageConfig) (*LocalStorage, error) {
+	dir, err := filepath.Abs(cfg.LocalDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve storage directory: %w", err)
+	}
+
+	for _, sub := range []string{"objects", "meta"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o750); err != nil {
+			return nil, fmt.Errorf("failed to create storage directory: %w", err)
+		}
+	}
+
+	return &LocalStorage{
+		dir:        dir,
+		signingKey: []byte(cfg.SigningKey),
+		publicURL:  cfg.PublicURL,
+	}, nil
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, body io.Reader, contentType string) error {
+	objectPath, metaPath, err := s.paths(key)
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(body)
+	if contentType == "" {
+		// Peek returns what it could read along with io.EOF for short files
+		head, _ := reader.Peek(512)
+		contentType = http.DetectContentType(head)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0o750); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	// Write to a temporary file first so readers never see a partial object
+	tmp, err := os.CreateTemp(filepath.Dir(objectPath), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), reader); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if err := s.writeMeta(metaPath, localMeta{
+		ContentType: contentType,
+		ETag:        hex.EncodeToString(hash.Sum(nil)),
+	}); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), objectPath); err != nil {
+		return fmt.Errorf("failed to store file: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns an *os.File, so callers can seek to serve ranges.
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	objectPath, _, err := s.paths(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(objectPath)
+	if err != nil {
+		return nil, nil, s.wrapError(err, "failed to open file")
+	}
+
+	object, err := s.Head(ctx, key)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return file, object, nil
+}
+
+func (s *LocalStorage) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	objectPath, _, err := s.paths(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(objectPath)
+	if err != nil {
+		return nil, s.wrapError(err, "failed to open file")
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to seek file: %w", err)
+	}
+
+	if length < 0 {
+		return file, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, length), file}, nil
+}
+
+func (s *LocalStorage) Head(ctx context.Context, key string) (*Object, error) {
+	objectPath, metaPath, err := s.paths(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(objectPath)
+	if err != nil {
+		return nil, s.wrapError(err, "failed to stat file")
+	}
+
+	meta, err := s.readMeta(metaPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Object{
+		Key:          key,
+		Size:         info.Size(),
+		ContentType:  meta.ContentType,
+		ETag:         meta.ETag,
+		LastModified: info.ModTime(),
+	}, nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	objectPath, metaPath, err := s.paths(key)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range []string{objectPath, metaPath} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to delete file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *LocalStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	body, object, err := s.Get(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return s.Put(ctx, dstKey, body, object.ContentType)
+}
+
+// List walks the objects directory in lexical order, skipping files that are
+// still being written.
+func (s *LocalStorage) List(ctx context.Context, prefix string, fn func(*Object) error) error {
+	root := filepath.Join(s.dir, "objects")
+
+	// Start from the deepest directory the prefix names
+	start := root
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		objectPath, _, err := s.paths(prefix[:i])
+		if err != nil {
+			return err
+		}
+		start = objectPath
+	}
+
+	return filepath.WalkDir(start, func(objectPath string, entry fs.DirEntry, err error) error {
+		// Objects deleted while listing, or a prefix with no objects, are skipped
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, objectPath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		return fn(&Object{
+			Key:          key,
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+		})
+	})
+}
+
+func (s *LocalStorage) Presign(ctx context.Context, key string, expires time.Duration, filename string) (string, error) {
+	if _, _, err := s.paths(key); err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(time.Now().Add(expires).Unix(), 10))
+	if filename != "" {
+		query.Set("filename", filename)
+	}
+	query.Set("signature", s.sign(http.MethodGet, key, query.Get("expires"), query.Get("filename")))
+
+	return s.publicURL + LocalPathPrefix + escapeKey(key) + "?" + query.Encode(), nil
+}
+
+func (s *LocalStorage) PresignUpload(ctx context.Context, key, contentType string, size int64,
+	expires time.Duration,
+) (*PresignedUpload, error) {
+	if _, _, err := s.paths(key); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(time.Now().Add(expires).Unix(), 10))
+	query.Set("size", strconv.FormatInt(size, 10))
+	query.Set("signature", s.sign(http.MethodPut, key, query.Get("expires"), contentType, query.Get("size")))
+
+	return &PresignedUpload{
+		URL:    s.publicURL + LocalPathPrefix + escapeKey(key) + "?" + query.Encode(),
+		Method: http.MethodPut,
+		Headers: map[string]string{
+			"Content-Type": contentType,
+		},
+	}, nil
+}
+
+// VerifyDownload checks the query of a URL from Presign.
+func (s *LocalStorage) VerifyDownload(key string, query url.Values) error {
+	return s.verify(query, http.MethodGet, key, query.Get("expires"), query.Get("filename"))
+}
+
+// VerifyUpload checks the query of a URL from PresignUpload against the request's
+// content type and returns the size the upload must have.
+func (s *LocalStorage) VerifyUpload(key, contentType string, query url.Values) (int64, error) {
+	if err := s.verify(query, http.MethodPut, key, query.Get("expires"), contentType, query.Get("size")); err != nil {
+		return 0, err
+	}
+
+	size, err := strconv.ParseInt(query.Get("size"), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidSignature
+	}
+
+	return size, nil
+}
+
+func (s *LocalStorage) verify(query url.Values, parts ...string) error {
+	if !hmac.Equal([]byte(query.Get("signature")), []byte(s.sign(parts...))) {
+		return ErrInvalidSignature
+	}
+
+	expiresAt, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if time.Now().Unix() > expiresAt {
+		return ErrURLExpired
+	}
+
+	return nil
+}
+
+// sign covers the method so a download URL can't be replayed as an upload.
+func (s *LocalStorage) sign(parts ...string) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// paths maps a key to its content and metadata files, rejecting keys that would
+// escape the storage directory.
+func (s *LocalStorage) paths(key string) (string, string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || path.Clean(key) != key {
+		return "", "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." {
+			return "", "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+	}
+
+	rel := filepath.FromSlash(key)
+	return filepath.Join(s.dir, "objects", rel), filepath.Join(s.dir, "meta", rel+".json"), nil
+}
+
+func (s *LocalStorage) readMeta(metaPath string) (*localMeta, error) {
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, s.wrapError(err, "failed to read file metadata")
+	}
+
+	var meta localMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to decode file metadata: %w", err)
+	}
+
+	return &meta, nil
+}
+
+func (s *LocalStorage) writeMeta(metaPath string, meta localMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to encode file metadata: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0o750); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	if err := os.WriteFile(metaPath, data, 0o640); err != nil {
+		return fmt.Errorf("failed to write file metadata: %w", err)
+	}
+
+	return nil
+}
+
+func (s *LocalStorage) wrapError(err error, message string) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s: %w", message, ErrNotFound)
+	}
+
+	return fmt.Errorf("%s: %w", message, err)
+}
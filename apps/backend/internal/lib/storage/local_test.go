@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLocalStorage(t *testing.T) *LocalStorage {
+	t.Helper()
+
+	s, err := NewLocalStorage(&config.StorageConfig{
+		Backend:    "local",
+		LocalDir:   t.TempDir(),
+		SigningKey: "test-signing-key",
+		PublicURL:  "http://localhost:8080",
+	})
+	require.NoError(t, err)
+	return s
+}
+
+// signedQuery returns the key and query of a URL from Presign or PresignUpload.
+func signedQuery(t *testing.T, rawURL string) (string, url.Values) {
+	t.Helper()
+
+	u, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(u.Path, LocalPathPrefix))
+
+	return strings.TrimPrefix(u.Path, LocalPathPrefix), u.Query()
+}
+
+func TestLocalStorage_VerifyDownload(t *testing.T) {
+	ctx := context.Background()
+	s := newTestLocalStorage(t)
+
+	rawURL, err := s.Presign(ctx, "todos/1/report.pdf", time.Minute, "report.pdf")
+	require.NoError(t, err)
+	key, query := signedQuery(t, rawURL)
+	require.Equal(t, "todos/1/report.pdf", key)
+
+	t.Run("valid", func(t *testing.T) {
+		assert.NoError(t, s.VerifyDownload(key, query))
+	})
+
+	t.Run("other key", func(t *testing.T) {
+		assert.ErrorIs(t, s.VerifyDownload("todos/2/report.pdf", query), ErrInvalidSignature)
+	})
+
+	t.Run("tampered filename", func(t *testing.T) {
+		tampered := cloneQuery(query)
+		tampered.Set("filename", "report.html")
+		assert.ErrorIs(t, s.VerifyDownload(key, tampered), ErrInvalidSignature)
+	})
+
+	t.Run("extended expiry", func(t *testing.T) {
+		tampered := cloneQuery(query)
+		tampered.Set("expires", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		assert.ErrorIs(t, s.VerifyDownload(key, tampered), ErrInvalidSignature)
+	})
+
+	t.Run("missing signature", func(t *testing.T) {
+		tampered := cloneQuery(query)
+		tampered.Del("signature")
+		assert.ErrorIs(t, s.VerifyDownload(key, tampered), ErrInvalidSignature)
+	})
+
+	t.Run("other signing key", func(t *testing.T) {
+		other := newTestLocalStorage(t)
+		other.signingKey = []byte("another-key")
+		assert.ErrorIs(t, other.VerifyDownload(key, query), ErrInvalidSignature)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		expiredURL, err := s.Presign(ctx, key, -time.Minute, "")
+		require.NoError(t, err)
+		_, expired := signedQuery(t, expiredURL)
+		assert.ErrorIs(t, s.VerifyDownload(key, expired), ErrURLExpired)
+	})
+
+	t.Run("upload URL", func(t *testing.T) {
+		upload, err := s.PresignUpload(ctx, key, "application/pdf", 10, time.Minute)
+		require.NoError(t, err)
+		_, uploadQuery := signedQuery(t, upload.URL)
+		assert.ErrorIs(t, s.VerifyDownload(key, uploadQuery), ErrInvalidSignature)
+	})
+}
+
+func TestLocalStorage_VerifyUpload(t *testing.T) {
+	ctx := context.Background()
+	s := newTestLocalStorage(t)
+
+	upload, err := s.PresignUpload(ctx, "todos/1/photo.png", "image/png", 1024, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPut, upload.Method)
+	key, query := signedQuery(t, upload.URL)
+
+	t.Run("valid", func(t *testing.T) {
+		size, err := s.VerifyUpload(key, "image/png", query)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1024), size)
+	})
+
+	t.Run("other content type", func(t *testing.T) {
+		_, err := s.VerifyUpload(key, "text/html", query)
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("larger size", func(t *testing.T) {
+		tampered := cloneQuery(query)
+		tampered.Set("size", "1048576")
+		_, err := s.VerifyUpload(key, "image/png", tampered)
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("download URL", func(t *testing.T) {
+		rawURL, err := s.Presign(ctx, key, time.Minute, "")
+		require.NoError(t, err)
+		_, downloadQuery := signedQuery(t, rawURL)
+		_, err = s.VerifyUpload(key, "image/png", downloadQuery)
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+}
+
+func TestLocalStorage_InvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	s := newTestLocalStorage(t)
+
+	for _, key := range []string{"", "/etc/passwd", "../secret", "a/../../secret", `a\b`, "a//b"} {
+		t.Run(key, func(t *testing.T) {
+			_, err := s.Presign(ctx, key, time.Minute, "")
+			assert.ErrorIs(t, err, ErrInvalidKey)
+		})
+	}
+}
+
+func cloneQuery(query url.Values) url.Values {
+	clone := url.Values{}
+	for k, v := range query {
+		clone[k] = append([]string(nil), v...)
+	}
+	return clone
+}
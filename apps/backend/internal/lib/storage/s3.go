@@ -0,0 +1,309 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+type S3Storage struct {
+	client *s3.Client
+	bucket string
+}
+
+func NewS3Storage(cfg *config.AWSConfig) (*S3Storage, error) {
+	configOptions := []func(*awsConfig.LoadOptions) error{
+		awsConfig.WithRegion(cfg.Region),
+		awsConfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			cfg.AccessKeyID,
+			cfg.SecretAccessKey,
+			"",
+		)),
+	}
+
+	sdkConfig, err := awsConfig.LoadDefaultConfig(context.TODO(), configOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	return &S3Storage{
+		client: s3.NewFromConfig(sdkConfig),
+		bucket: cfg.Bucket,
+	}, nil
+}
+
+// multipartPartSize is the part size of multipart uploads, and so the most a Put
+// holds in memory. S3 requires at least 5 MiB for every part but the last.
+const multipartPartSize = 8 << 20
+
+// Put uploads files of up to one part in a single request and streams larger ones
+// as a multipart upload.
+func (s *S3Storage) Put(ctx context.Context, key string, body io.Reader, contentType string) error {
+	part := make([]byte, multipartPartSize)
+
+	n, err := io.ReadFull(body, part)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if contentType == "" {
+		contentType = http.DetectContentType(part[:n])
+	}
+
+	if n < multipartPartSize {
+		_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(s.bucket),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(part[:n]),
+			ContentType: aws.String(contentType),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upload file to S3: %w", err)
+		}
+		return nil
+	}
+
+	return s.putMultipart(ctx, key, body, contentType, part)
+}
+
+// putMultipart uploads first and then the rest of body part by part, reusing the
+// buffer. Failed uploads are aborted so S3 doesn't keep billing for their parts.
+func (s *S3Storage) putMultipart(ctx context.Context, key string, body io.Reader, contentType string,
+	first []byte,
+) error {
+	upload, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start multipart upload to S3: %w", err)
+	}
+
+	abort := func(cause error) error {
+		_, abortErr := s.client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(s.bucket),
+			Key:      aws.String(key),
+			UploadId: upload.UploadId,
+		})
+		return errors.Join(cause, abortErr)
+	}
+
+	var parts []types.CompletedPart
+	part := first
+	for partNumber := int32(1); len(part) > 0; partNumber++ {
+		output, err := s.client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String(s.bucket),
+			Key:        aws.String(key),
+			UploadId:   upload.UploadId,
+			PartNumber: aws.Int32(partNumber),
+			Body:       bytes.NewReader(part),
+		})
+		if err != nil {
+			return abort(fmt.Errorf("failed to upload part %d to S3: %w", partNumber, err))
+		}
+
+		parts = append(parts, types.CompletedPart{
+			ETag:       output.ETag,
+			PartNumber: aws.Int32(partNumber),
+		})
+
+		n, err := io.ReadFull(body, first)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			return abort(fmt.Errorf("failed to read file: %w", err))
+		}
+		part = first[:n]
+	}
+
+	_, err = s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(key),
+		UploadId:        upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return abort(fmt.Errorf("failed to complete multipart upload to S3: %w", err))
+	}
+
+	return nil
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, nil, s.wrapError(err, "failed to get file from S3")
+	}
+
+	return output.Body, &Object{
+		Key:          key,
+		Size:         aws.ToInt64(output.ContentLength),
+		ContentType:  aws.ToString(output.ContentType),
+		ETag:         strings.Trim(aws.ToString(output.ETag), `"`),
+		LastModified: aws.ToTime(output.LastModified),
+	}, nil
+}
+
+func (s *S3Storage) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	byteRange := fmt.Sprintf("bytes=%d-", offset)
+	if length >= 0 {
+		byteRange = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	}
+
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(byteRange),
+	})
+	if err != nil {
+		return nil, s.wrapError(err, "failed to get file range from S3")
+	}
+
+	return output.Body, nil
+}
+
+func (s *S3Storage) Head(ctx context.Context, key string) (*Object, error) {
+	output, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, s.wrapError(err, "failed to head file in S3")
+	}
+
+	return &Object{
+		Key:          key,
+		Size:         aws.ToInt64(output.ContentLength),
+		ContentType:  aws.ToString(output.ContentType),
+		ETag:         strings.Trim(aws.ToString(output.ETag), `"`),
+		LastModified: aws.ToTime(output.LastModified),
+	}, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete file from S3: %w", err)
+	}
+
+	return nil
+}
+
+func (s *S3Storage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(dstKey),
+		CopySource: aws.String(s.bucket + "/" + escapeKey(srcKey)),
+	})
+	if err != nil {
+		return s.wrapError(err, "failed to copy file in S3")
+	}
+
+	return nil
+}
+
+func (s *S3Storage) List(ctx context.Context, prefix string, fn func(*Object) error) error {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list files in S3: %w", err)
+		}
+
+		for _, item := range page.Contents {
+			if err := fn(&Object{
+				Key:          aws.ToString(item.Key),
+				Size:         aws.ToInt64(item.Size),
+				ETag:         strings.Trim(aws.ToString(item.ETag), `"`),
+				LastModified: aws.ToTime(item.LastModified),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *S3Storage) Presign(ctx context.Context, key string, expires time.Duration, filename string) (string, error) {
+	presignedClient := s3.NewPresignClient(s.client)
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	if disposition := ContentDisposition(filename); disposition != "" {
+		input.ResponseContentDisposition = aws.String(disposition)
+	}
+
+	presignedUrl, err := presignedClient.PresignGetObject(ctx, input, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
+	}
+
+	return presignedUrl.URL, nil
+}
+
+func (s *S3Storage) PresignUpload(ctx context.Context, key, contentType string, size int64,
+	expires time.Duration,
+) (*PresignedUpload, error) {
+	presignedClient := s3.NewPresignClient(s.client)
+
+	// Content type and length are signed, so S3 rejects any other file
+	request, err := presignedClient.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate presigned upload URL: %w", err)
+	}
+
+	headers := make(map[string]string, len(request.SignedHeader))
+	for name, values := range request.SignedHeader {
+		// Clients set these themselves and browsers refuse to
+		if strings.EqualFold(name, "Host") || strings.EqualFold(name, "Content-Length") {
+			continue
+		}
+		headers[name] = strings.Join(values, ",")
+	}
+
+	return &PresignedUpload{
+		URL:     request.URL,
+		Method:  request.Method,
+		Headers: headers,
+	}, nil
+}
+
+// wrapError maps S3's missing-object errors to ErrNotFound.
+func (s *S3Storage) wrapError(err error, message string) error {
+	var noSuchKey *types.NoSuchKey
+	var notFound *types.NotFound
+	if errors.As(err, &noSuchKey) || errors.As(err, &notFound) {
+		return fmt.Errorf("%s: %w", message, ErrNotFound)
+	}
+
+	return fmt.Errorf("%s: %w", message, err)
+}
@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/config"
+)
+
+var (
+	ErrNotFound = errors.New("storage object not found")
+	ErrTooLarge = errors.New("storage object exceeds the size limit")
+)
+
+// Object describes a stored blob without its content.
+type Object struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// PresignedUpload lets a client upload an object straight to storage by sending a
+// request with Method to URL, carrying Headers.
+type PresignedUpload struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+}
+
+// Storage is a flat blob store addressed by slash-separated keys such as
+// "todos/attachments/<todo-id>/report.pdf".
+type Storage interface {
+	// Put stores body under key, sniffing the content type when it is empty
+	Put(ctx context.Context, key string, body io.Reader, contentType string) error
+	// Get opens the object for reading; the caller closes the reader
+	Get(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+	// GetRange opens length bytes of the object starting at offset, or the rest of
+	// the object when length is negative
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	Head(ctx context.Context, key string) (*Object, error)
+	// Delete succeeds when the object doesn't exist
+	Delete(ctx context.Context, key string) error
+	Copy(ctx context.Context, srcKey, dstKey string) error
+	// List calls fn for every object whose key starts with prefix, stopping at the
+	// first error fn returns. Listed objects carry no content type.
+	List(ctx context.Context, prefix string, fn func(*Object) error) error
+	// Presign returns a URL anyone can download the object from until it expires;
+	// filename, when set, is the name browsers save the download under
+	Presign(ctx context.Context, key string, expires time.Duration, filename string) (string, error)
+	// PresignUpload returns a URL that accepts exactly one object of the given
+	// content type and size until it expires
+	PresignUpload(ctx context.Context, key, contentType string, size int64, expires time.Duration) (*PresignedUpload, error)
+}
+
+func New(cfg *config.Config) (Storage, error) {
+	switch cfg.Storage.Backend {
+	case "local":
+		return NewLocalStorage(&cfg.Storage)
+	case "s3":
+		return NewS3Storage(&cfg.AWS)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage.Backend)
+	}
+}
+
+// escapeKey URL-escapes each segment of a key while keeping the slashes.
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// LimitedReader fails with ErrTooLarge once more than its limit has been read, so
+// a Put of an oversized stream aborts instead of storing a truncated object.
+type LimitedReader struct {
+	r     io.Reader
+	limit int64
+	n     int64
+}
+
+func NewLimitedReader(r io.Reader, limit int64) *LimitedReader {
+	return &LimitedReader{r: r, limit: limit}
+}
+
+func (l *LimitedReader) Read(p []byte) (int, error) {
+	if l.n > l.limit {
+		return 0, ErrTooLarge
+	}
+
+	// Read one byte past the limit so an exactly-full stream still ends cleanly
+	if remaining := l.limit - l.n + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.limit {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
+
+// N is the number of bytes read so far.
+func (l *LimitedReader) N() int64 {
+	return l.n
+}
+
+// ObjectReader reads an object through range requests, so it can be seeked without
+// downloading what is skipped. http.ServeContent uses it to answer Range requests
+// for objects of any backend.
+type ObjectReader struct {
+	ctx     context.Context
+	storage Storage
+	object  *Object
+	offset  int64
+	body    io.ReadCloser
+}
+
+func NewObjectReader(ctx context.Context, storage Storage, object *Object) *ObjectReader {
+	return &ObjectReader{ctx: ctx, storage: storage, object: object}
+}
+
+// Object describes what is being read.
+func (r *ObjectReader) Object() *Object {
+	return r.object
+}
+
+func (r *ObjectReader) Read(p []byte) (int, error) {
+	if r.offset >= r.object.Size {
+		return 0, io.EOF
+	}
+
+	// Content is only requested once read, so seeking costs nothing
+	if r.body == nil {
+		body, err := r.storage.GetRange(r.ctx, r.object.Key, r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *ObjectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.object.Size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek before the start of the object")
+	}
+
+	if offset != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *ObjectReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
+
+// ContentDisposition is the Content-Disposition header that makes browsers save a
+// download as filename, or "" without a filename.
+func ContentDisposition(filename string) string {
+	if filename == "" {
+		return ""
+	}
+	return mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+}
+
+// InlineDisposition lets browsers display the content themselves, still naming it
+// filename should it be saved.
+func InlineDisposition(filename string) string {
+	if filename == "" {
+		return "inline"
+	}
+	return mime.FormatMediaType("inline", map[string]string{"filename": filename})
+}
@@ -0,0 +1,100 @@
+package workspace
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+type contextKey struct{}
+
+// AdminRole is the Clerk organization role that may manage everything in its organization.
+const AdminRole = "org:admin"
+
+// Permissions checked by RequirePermission. Clerk prefixes custom organization
+// permissions with "org:", so both forms are accepted.
+const (
+	PermissionTodosWrite      = "todos:write"
+	PermissionCategoriesWrite = "categories:write"
+	PermissionCommentsWrite   = "comments:write"
+)
+
+// Workspace is the tenant a request operates in. An empty OrgID means the
+// caller's personal workspace.
+type Workspace struct {
+	OrgID       string
+	Role        string
+	Permissions []string
+}
+
+func (w Workspace) IsOrg() bool {
+	return w.OrgID != ""
+}
+
+func (w Workspace) IsAdmin() bool {
+	return w.IsOrg() && w.Role == AdminRole
+}
+
+// HasPermission reports whether the caller holds permission in this workspace.
+// Personal workspaces and organization admins hold every permission.
+func (w Workspace) HasPermission(permission string) bool {
+	if !w.IsOrg() || w.IsAdmin() {
+		return true
+	}
+
+	return slices.Contains(w.Permissions, permission) ||
+		slices.Contains(w.Permissions, "org:"+strings.TrimPrefix(permission, "org:"))
+}
+
+// DefaultTodoRole is the role a member has on the organization's todos unless a
+// share says otherwise: admins own them, and other members edit, comment on or
+// view them according to their permissions. Personal workspaces have no default.
+func (w Workspace) DefaultTodoRole() string {
+	switch {
+	case !w.IsOrg():
+		return ""
+	case w.IsAdmin():
+		return "owner"
+	case w.HasPermission(PermissionTodosWrite):
+		return "editor"
+	case w.HasPermission(PermissionCommentsWrite):
+		return "commenter"
+	default:
+		return "viewer"
+	}
+}
+
+// DefaultCategoryRole is DefaultTodoRole for the organization's categories.
+func (w Workspace) DefaultCategoryRole() string {
+	switch {
+	case !w.IsOrg():
+		return ""
+	case w.IsAdmin():
+		return "owner"
+	case w.HasPermission(PermissionCategoriesWrite):
+		return "editor"
+	default:
+		return "viewer"
+	}
+}
+
+// OrgIDArg returns the organization ID as a nullable query argument.
+func (w Workspace) OrgIDArg() *string {
+	if !w.IsOrg() {
+		return nil
+	}
+	return &w.OrgID
+}
+
+func WithContext(ctx context.Context, w Workspace) context.Context {
+	return context.WithValue(ctx, contextKey{}, w)
+}
+
+// FromContext returns the workspace stored by the auth middleware, or the
+// personal workspace when none was stored.
+func FromContext(ctx context.Context) Workspace {
+	if w, ok := ctx.Value(contextKey{}).(Workspace); ok {
+		return w
+	}
+	return Workspace{}
+}
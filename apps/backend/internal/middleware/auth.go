@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/ApoorvYdv/go-tasker/internal/errs"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/workspace"
 	"github.com/ApoorvYdv/go-tasker/internal/server"
 	"github.com/clerk/clerk-sdk-go/v2"
 	clerkhttp "github.com/clerk/clerk-sdk-go/v2/http"
@@ -61,10 +62,20 @@ func (auth *AuthMiddleware) RequireAuth(next echo.HandlerFunc) echo.HandlerFunc
 		c.Set("user_id", claims.Subject)
 		c.Set("user_role", claims.ActiveOrganizationRole)
 		c.Set("permissions", claims.Claims.ActiveOrganizationPermissions)
+		c.Set("org_id", claims.ActiveOrganizationID)
+
+		// Repositories scope their queries by the workspace carried on the request context
+		ws := workspace.Workspace{
+			OrgID:       claims.ActiveOrganizationID,
+			Role:        claims.ActiveOrganizationRole,
+			Permissions: claims.Claims.ActiveOrganizationPermissions,
+		}
+		c.SetRequest(c.Request().WithContext(workspace.WithContext(c.Request().Context(), ws)))
 
 		auth.server.Logger.Info().
 			Str("function", "RequireAuth").
 			Str("user_id", claims.Subject).
+			Str("org_id", claims.ActiveOrganizationID).
 			Str("request_id", GetRequestID(c)).
 			Dur("duration", time.Since(start)).
 			Msg("user authenticated successfully")
@@ -72,3 +83,28 @@ func (auth *AuthMiddleware) RequireAuth(next echo.HandlerFunc) echo.HandlerFunc
 		return next(c)
 	})
 }
+
+// RequirePermission rejects requests whose workspace lacks any of the given permissions.
+// It must run after RequireAuth. Personal sessions and organization admins always pass.
+func (auth *AuthMiddleware) RequirePermission(permissions ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ws := GetWorkspace(c)
+
+			for _, permission := range permissions {
+				if !ws.HasPermission(permission) {
+					auth.server.Logger.Warn().
+						Str("function", "RequirePermission").
+						Str("user_id", GetUserID(c)).
+						Str("org_id", ws.OrgID).
+						Str("permission", permission).
+						Str("request_id", GetRequestID(c)).
+						Msg("permission denied")
+					return errs.NewForbiddenError("You don't have permission to perform this action", false)
+				}
+			}
+
+			return next(c)
+		}
+	}
+}
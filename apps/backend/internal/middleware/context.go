@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 
+	"github.com/ApoorvYdv/go-tasker/internal/lib/workspace"
 	"github.com/ApoorvYdv/go-tasker/internal/logger"
 	"github.com/ApoorvYdv/go-tasker/internal/server"
 	"github.com/labstack/echo/v4"
@@ -11,9 +12,11 @@ import (
 )
 
 const (
-	UserIDKey   = "user_id"
-	UserRoleKey = "user_role"
-	LoggerKey   = "logger"
+	UserIDKey      = "user_id"
+	UserRoleKey    = "user_role"
+	OrgIDKey       = "org_id"
+	PermissionsKey = "permissions"
+	LoggerKey      = "logger"
 )
 
 type ContextEnhancer struct {
@@ -87,6 +90,12 @@ func GetUserID(c echo.Context) string {
 	return ""
 }
 
+// GetWorkspace returns the organization workspace of the session, or the personal
+// workspace when no organization is active.
+func GetWorkspace(c echo.Context) workspace.Workspace {
+	return workspace.FromContext(c.Request().Context())
+}
+
 func GetLogger(c echo.Context) *zerolog.Logger {
 	if logger, ok := c.Get(LoggerKey).(*zerolog.Logger); ok {
 		return logger
@@ -1,11 +1,80 @@
 package category
 
-import "github.com/ApoorvYdv/go-tasker/internal/model"
+import (
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/model"
+	"github.com/google/uuid"
+)
 
 type Category struct {
 	model.Base
-	UserID      string  `json:"userId" db:"user_id"`
-	Name        string  `json:"name" db:"name"`
-	Color       *string `json:"color" db:"color"`
-	Description *string `json:"description" db:"description"`
+	UserID         string     `json:"userId" db:"user_id"`
+	OrganizationID *string    `json:"organizationId" db:"organization_id"`
+	ParentID       *uuid.UUID `json:"parentId" db:"parent_id"`
+	Name           string     `json:"name" db:"name"`
+	Color          *string    `json:"color" db:"color"`
+	Description    *string    `json:"description" db:"description"`
+	// ArchivedAt is set on archived categories, which are hidden from listings with
+	// their subcategories but keep their todos
+	ArchivedAt *time.Time `json:"archivedAt" db:"archived_at"`
+}
+
+func (c *Category) IsArchived() bool {
+	return c.ArchivedAt != nil
+}
+
+// TodoCounts counts the todos filed under a category by status.
+type TodoCounts struct {
+	Draft     int `json:"draft"`
+	Active    int `json:"active"`
+	Completed int `json:"completed"`
+	Archived  int `json:"archived"`
+	Total     int `json:"total"`
+}
+
+type PopulatedCategory struct {
+	Category
+	TodoCounts TodoCounts `json:"todoCounts" db:"todo_counts"`
+	// Children is only filled in when categories are listed as a tree
+	Children []PopulatedCategory `json:"children,omitempty" db:"-"`
+}
+
+// Nest arranges a flat list of categories into trees, keeping the order of the
+// list among siblings. Categories whose parent is missing from the list are
+// returned as roots.
+func Nest(categories []PopulatedCategory) []PopulatedCategory {
+	present := make(map[uuid.UUID]struct{}, len(categories))
+	for _, c := range categories {
+		present[c.ID] = struct{}{}
+	}
+
+	childrenOf := make(map[uuid.UUID][]PopulatedCategory)
+	roots := make([]PopulatedCategory, 0)
+	for _, c := range categories {
+		if c.ParentID != nil {
+			if _, ok := present[*c.ParentID]; ok {
+				childrenOf[*c.ParentID] = append(childrenOf[*c.ParentID], c)
+				continue
+			}
+		}
+		roots = append(roots, c)
+	}
+
+	var attach func(c *PopulatedCategory)
+	attach = func(c *PopulatedCategory) {
+		c.Children = childrenOf[c.ID]
+		if c.Children == nil {
+			c.Children = []PopulatedCategory{}
+		}
+		for i := range c.Children {
+			attach(&c.Children[i])
+		}
+	}
+
+	for i := range roots {
+		attach(&roots[i])
+	}
+
+	return roots
 }
@@ -7,9 +7,10 @@ import (
 
 // --- Create Category ---
 type CreateCategoryPayload struct {
-	Name        string  `json:"name" validate:"required,min=3,max=100"`
-	Description *string `json:"description" validate:"omitempty,max=1000"`
-	Color       *string `json:"color" validate:"omitempty,hexcolor"`
+	Name        string     `json:"name" validate:"required,min=3,max=100"`
+	Description *string    `json:"description" validate:"omitempty,max=1000"`
+	Color       *string    `json:"color" validate:"omitempty,hexcolor"`
+	ParentID    *uuid.UUID `json:"parentId" validate:"omitempty,uuid"`
 }
 
 func (r *CreateCategoryPayload) Validate() error {
@@ -24,6 +25,11 @@ type GetCategoriesQuery struct {
 	Sort   *string `query:"sort" validate:"omitempty,oneof=created_at updated_at name"`
 	Order  *string `query:"order" validate:"omitempty,oneof=asc desc"`
 	Search *string `query:"search" validate:"omitempty,min=1"`
+	// View "tree" nests subcategories under their parents and paginates the roots
+	View            *string `query:"view" validate:"omitempty,oneof=list tree"`
+	IncludeArchived *bool   `query:"includeArchived"`
+	// IncludeSubcategories adds the todos of subcategories to each category's counts
+	IncludeSubcategories *bool `query:"includeSubcategories"`
 }
 
 func (r *GetCategoriesQuery) Validate() error {
@@ -54,6 +60,11 @@ func (r *GetCategoriesQuery) Validate() error {
 		r.Order = &defaultOrder
 	}
 
+	if r.View == nil {
+		defaultView := "list"
+		r.View = &defaultView
+	}
+
 	return nil
 }
 
@@ -69,10 +80,13 @@ func (r *GetCategoryByIDRequest) Validate() error {
 
 // --- Update Category ---
 type UpdateCategoryPayload struct {
-	ID          uuid.UUID `param:"id" validate:"required,uuid"`
-	Name        *string   `json:"name" validate:"omitempty,min=3,max=100"`
-	Description *string   `json:"description" validate:"omitempty,max=1000"`
-	Color       *string   `json:"color" validate:"omitempty,hexcolor"`
+	ID          uuid.UUID  `param:"id" validate:"required,uuid"`
+	Name        *string    `json:"name" validate:"omitempty,min=3,max=100"`
+	Description *string    `json:"description" validate:"omitempty,max=1000"`
+	Color       *string    `json:"color" validate:"omitempty,hexcolor"`
+	ParentID    *uuid.UUID `json:"parentId" validate:"omitempty,uuid"`
+	// MoveToRoot detaches the category from its parent
+	MoveToRoot bool `json:"moveToRoot" validate:"excluded_with=ParentID"`
 }
 
 func (r *UpdateCategoryPayload) Validate() error {
@@ -89,3 +103,13 @@ func (r *DeleteCategoryPayload) Validate() error {
 	validate := validator.New()
 	return validate.Struct(r)
 }
+
+// --- Archive / Unarchive Category ---
+type ArchiveCategoryPayload struct {
+	ID uuid.UUID `param:"id" validate:"required,uuid"`
+}
+
+func (r *ArchiveCategoryPayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
@@ -1,13 +1,124 @@
 package comment
 
 import (
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/lib/markdown"
 	"github.com/ApoorvYdv/go-tasker/internal/model"
 	"github.com/google/uuid"
 )
 
+// MaxThreadDepth caps reply nesting. A reply to a comment at the deepest level is
+// attached to that comment's parent instead, so threads never grow deeper.
+const MaxThreadDepth = 3
+
 type Comment struct {
 	model.Base
-	TodoID  uuid.UUID `json:"todoId" db:"todo_id"`
-	UserID  string    `json:"userId" db:"user_id"`
-	Content string    `json:"content" db:"content"`
+	TodoID uuid.UUID `json:"todoId" db:"todo_id"`
+	UserID string    `json:"userId" db:"user_id"`
+	// Content is the CommonMark source; ContentHTML is its sanitized rendering
+	Content         string     `json:"content" db:"content"`
+	ContentHTML     string     `json:"contentHtml" db:"-"`
+	Mentions        []Mention  `json:"mentions" db:"mentions"`
+	ParentCommentID *uuid.UUID `json:"parentCommentId" db:"parent_comment_id"`
+	Depth           int        `json:"depth" db:"depth"`
+	Edited          bool       `json:"edited" db:"edited"`
+	EditedAt        *time.Time `json:"editedAt" db:"edited_at"`
+	// DeletedAt is set on tombstones: deleted comments kept so their replies stay in place
+	DeletedAt *time.Time `json:"deletedAt" db:"deleted_at"`
+	Reactions []Reaction `json:"reactions" db:"reactions"`
+	Replies   []Comment  `json:"replies" db:"-"`
+}
+
+// Revision is one version of an edited comment.
+type Revision struct {
+	model.Base
+	CommentID   uuid.UUID `json:"commentId" db:"comment_id"`
+	UserID      string    `json:"userId" db:"user_id"`
+	Content     string    `json:"content" db:"content"`
+	ContentHTML string    `json:"contentHtml" db:"-"`
+}
+
+// Reaction aggregates one emoji on a comment.
+type Reaction struct {
+	Emoji       string `json:"emoji"`
+	Count       int    `json:"count"`
+	ReactedByMe bool   `json:"reactedByMe"`
+}
+
+// Mention is an @handle in the comment content resolved to a user who can access the todo.
+type Mention struct {
+	UserID string `json:"userId"`
+	Handle string `json:"handle"`
+}
+
+// MentionedUserIDs returns the IDs of every user mentioned in the comment.
+func (c *Comment) MentionedUserIDs() []string {
+	ids := make([]string, 0, len(c.Mentions))
+	for _, m := range c.Mentions {
+		ids = append(ids, m.UserID)
+	}
+	return ids
+}
+
+// RenderContent fills ContentHTML from the CommonMark source.
+func (c *Comment) RenderContent() {
+	c.ContentHTML = markdown.Render(c.Content)
+}
+
+// RenderContent fills ContentHTML from the CommonMark source.
+func (r *Revision) RenderContent() {
+	r.ContentHTML = markdown.Render(r.Content)
+}
+
+func (c *Comment) IsDeleted() bool {
+	return c.DeletedAt != nil
+}
+
+// ReplyParent returns the comment a reply to c attaches to and the reply's depth.
+// Replies at the depth limit become siblings of c rather than its children.
+func (c *Comment) ReplyParent() (uuid.UUID, int) {
+	if c.Depth+1 >= MaxThreadDepth && c.ParentCommentID != nil {
+		return *c.ParentCommentID, c.Depth
+	}
+	return c.ID, c.Depth + 1
+}
+
+// Nest arranges a flat, chronologically ordered list of comments into threads.
+// Nesting is bounded by MaxThreadDepth, which is enforced when replies are written.
+// Replies whose parent is missing from the list are returned as top-level comments.
+func Nest(comments []Comment) []Comment {
+	present := make(map[uuid.UUID]struct{}, len(comments))
+	for _, c := range comments {
+		present[c.ID] = struct{}{}
+	}
+
+	childrenOf := make(map[uuid.UUID][]Comment)
+	roots := make([]Comment, 0)
+	for _, c := range comments {
+		if c.ParentCommentID != nil {
+			if _, ok := present[*c.ParentCommentID]; ok {
+				childrenOf[*c.ParentCommentID] = append(childrenOf[*c.ParentCommentID], c)
+				continue
+			}
+		}
+		roots = append(roots, c)
+	}
+
+	var attach func(c *Comment)
+	attach = func(c *Comment) {
+		c.Replies = childrenOf[c.ID]
+		if c.Replies == nil {
+			c.Replies = []Comment{}
+		}
+		for i := range c.Replies {
+			attach(&c.Replies[i])
+		}
+	}
+
+	for i := range roots {
+		attach(&roots[i])
+	}
+
+	return roots
 }
@@ -1,14 +1,16 @@
 package comment
 
 import (
+	"github.com/ApoorvYdv/go-tasker/internal/validation"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 )
 
 // --- Add Comment ---
 type AddCommentPayload struct {
-	TodoID  uuid.UUID `json:"todoId" validate:"required,uuid"`
-	Content string    `json:"content" validate:"required,min=1,max=1000"`
+	TodoID          uuid.UUID  `param:"id" validate:"required,uuid"`
+	Content         string     `json:"content" validate:"required,min=1,max=1000"`
+	ParentCommentID *uuid.UUID `json:"parentCommentId" validate:"omitempty,uuid"`
 }
 
 func (r *AddCommentPayload) Validate() error {
@@ -18,7 +20,7 @@ func (r *AddCommentPayload) Validate() error {
 
 // --- Get Comments ---
 type GetCommentsByTodoIDPayload struct {
-	TodoID uuid.UUID `param:"todoId" validate:"required,uuid"`
+	TodoID uuid.UUID `param:"id" validate:"required,uuid"`
 }
 
 func (r *GetCommentsByTodoIDPayload) Validate() error {
@@ -46,3 +48,35 @@ func (r *DeleteCommentPayload) Validate() error {
 	validate := validator.New()
 	return validate.Struct(r)
 }
+
+// --- Get Comment Revisions ---
+type GetCommentRevisionsPayload struct {
+	ID uuid.UUID `param:"id" validate:"required,uuid"`
+}
+
+func (r *GetCommentRevisionsPayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
+
+// --- Toggle Reaction ---
+type ToggleReactionPayload struct {
+	ID    uuid.UUID `param:"id" validate:"required,uuid"`
+	Emoji string    `json:"emoji" validate:"required,max=32"`
+}
+
+func (r *ToggleReactionPayload) Validate() error {
+	validate := validator.New()
+
+	if err := validate.Struct(r); err != nil {
+		return err
+	}
+
+	if !IsEmoji(r.Emoji) {
+		return validation.CustomValidationErrors{
+			{Field: "emoji", Message: "must be a single emoji"},
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package comment
+
+import (
+	"unicode"
+)
+
+const (
+	zeroWidthJoiner = '‍'
+	keycapCombiner  = '⃣'
+)
+
+// IsEmoji reports whether s looks like a single emoji, including skin tone, keycap
+// and ZWJ sequences. It is deliberately permissive about which symbols count.
+func IsEmoji(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	hasSymbol := false
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.So, r), unicode.Is(unicode.Sk, r):
+			hasSymbol = true
+		case r == keycapCombiner:
+			hasSymbol = true
+		case r == zeroWidthJoiner, unicode.Is(unicode.Variation_Selector, r):
+		case r == '#' || r == '*' || (r >= '0' && r <= '9'):
+			// Keycap bases such as 1️⃣
+		case r >= 0xE0020 && r <= 0xE007F:
+			// Tag characters used by subdivision flags
+		default:
+			return false
+		}
+	}
+
+	return hasSymbol
+}
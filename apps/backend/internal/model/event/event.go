@@ -0,0 +1,77 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Type string
+
+const (
+	TypeTodoCreated            Type = "todo.created"
+	TypeTodoUpdated            Type = "todo.updated"
+	TypeTodoDeleted            Type = "todo.deleted"
+	TypeTodoAssigneesChanged   Type = "todo.assignees_changed"
+	TypeCommentAdded           Type = "comment.added"
+	TypeCommentUpdated         Type = "comment.updated"
+	TypeCommentDeleted         Type = "comment.deleted"
+	TypeCommentReactionToggled Type = "comment.reaction_toggled"
+	TypeAttachmentAdded        Type = "attachment.added"
+	TypeAttachmentVersionAdded Type = "attachment.version_added"
+	TypeAttachmentDeleted      Type = "attachment.deleted"
+	TypeCategoryCreated        Type = "category.created"
+	TypeCategoryUpdated        Type = "category.updated"
+	TypeCategoryDeleted        Type = "category.deleted"
+)
+
+// AggregateType names the entity whose events are kept in order. Comments and
+// attachments belong to their todo's aggregate, so a todo's history is one ordered
+// stream.
+type AggregateType string
+
+const (
+	AggregateTodo     AggregateType = "todo"
+	AggregateCategory AggregateType = "category"
+)
+
+// Event is a domain event recorded in the outbox. ID is unique per event and stays
+// the same across redeliveries, so consumers use it as their idempotency key.
+// Sequence counts the events of the aggregate from 1, without gaps.
+type Event struct {
+	ID             uuid.UUID       `json:"id" db:"id"`
+	CreatedAt      time.Time       `json:"createdAt" db:"created_at"`
+	Position       int64           `json:"position" db:"position"`
+	AggregateType  AggregateType   `json:"aggregateType" db:"aggregate_type"`
+	AggregateID    uuid.UUID       `json:"aggregateId" db:"aggregate_id"`
+	Sequence       int64           `json:"sequence" db:"sequence"`
+	Type           Type            `json:"type" db:"event_type"`
+	ActorID        *string         `json:"actorId" db:"actor_id"`
+	OrganizationID *string         `json:"organizationId" db:"organization_id"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	AvailableAt    time.Time       `json:"-" db:"available_at"`
+	Attempts       int             `json:"-" db:"attempts"`
+	LastError      *string         `json:"-" db:"last_error"`
+	PublishedAt    *time.Time      `json:"-" db:"published_at"`
+	FailedAt       *time.Time      `json:"-" db:"failed_at"`
+}
+
+// Payload is the typed body of an event, which knows its event type and aggregate.
+type Payload interface {
+	EventType() Type
+	Aggregate() (AggregateType, uuid.UUID)
+}
+
+// Decode unmarshals the event's payload into the payload type of its event type.
+func Decode[P Payload](e *Event) (*P, error) {
+	var payload P
+	if payload.EventType() != e.Type {
+		return nil, fmt.Errorf("cannot decode %s event as %s", e.Type, payload.EventType())
+	}
+	if err := json.Unmarshal(e.Payload, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode %s event %s: %w", e.Type, e.ID, err)
+	}
+	return &payload, nil
+}
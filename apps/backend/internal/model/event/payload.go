@@ -0,0 +1,193 @@
+package event
+
+import (
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/model/category"
+	"github.com/ApoorvYdv/go-tasker/internal/model/comment"
+	"github.com/ApoorvYdv/go-tasker/internal/model/todo"
+	"github.com/google/uuid"
+)
+
+type TodoCreated struct {
+	Todo todo.Todo `json:"todo"`
+}
+
+func (TodoCreated) EventType() Type { return TypeTodoCreated }
+
+func (p TodoCreated) Aggregate() (AggregateType, uuid.UUID) {
+	return AggregateTodo, p.Todo.ID
+}
+
+// TodoUpdated carries the todo after the update. Changed lists the fields that were
+// set, by their JSON names. PreviousStatus and PreviousDueDate are their values
+// before the update, for consumers that react to them changing.
+type TodoUpdated struct {
+	Todo            todo.Todo   `json:"todo"`
+	Changed         []string    `json:"changed"`
+	PreviousStatus  todo.Status `json:"previousStatus"`
+	PreviousDueDate *time.Time  `json:"previousDueDate"`
+}
+
+func (TodoUpdated) EventType() Type { return TypeTodoUpdated }
+
+func (p TodoUpdated) Aggregate() (AggregateType, uuid.UUID) {
+	return AggregateTodo, p.Todo.ID
+}
+
+// TodoDeleted is recorded for the deleted todo only; its subtasks go with it.
+// MemberIDs are the users who could see the todo, resolved before its shares were
+// deleted with it.
+type TodoDeleted struct {
+	TodoID       uuid.UUID  `json:"todoId"`
+	ParentTodoID *uuid.UUID `json:"parentTodoId"`
+	Title        string     `json:"title"`
+	MemberIDs    []string   `json:"memberIds"`
+}
+
+func (TodoDeleted) EventType() Type { return TypeTodoDeleted }
+
+func (p TodoDeleted) Aggregate() (AggregateType, uuid.UUID) {
+	return AggregateTodo, p.TodoID
+}
+
+// TodoAssigneesChanged carries every assignee of the todo after the change, and
+// the users who were assigned or unassigned by it.
+type TodoAssigneesChanged struct {
+	TodoID    uuid.UUID       `json:"todoId"`
+	Assignees []todo.Assignee `json:"assignees"`
+	Added     []string        `json:"added"`
+	Removed   []string        `json:"removed"`
+}
+
+func (TodoAssigneesChanged) EventType() Type { return TypeTodoAssigneesChanged }
+
+func (p TodoAssigneesChanged) Aggregate() (AggregateType, uuid.UUID) {
+	return AggregateTodo, p.TodoID
+}
+
+// CommentAdded names the author of the comment replied to, if any.
+type CommentAdded struct {
+	Comment      comment.Comment `json:"comment"`
+	ParentUserID *string         `json:"parentUserId"`
+}
+
+func (CommentAdded) EventType() Type { return TypeCommentAdded }
+
+func (p CommentAdded) Aggregate() (AggregateType, uuid.UUID) {
+	return AggregateTodo, p.Comment.TodoID
+}
+
+// CommentUpdated carries the comment after the edit and who it mentioned before,
+// so only users newly mentioned by the edit are told.
+type CommentUpdated struct {
+	Comment            comment.Comment `json:"comment"`
+	PreviousMentionIDs []string        `json:"previousMentionIds"`
+}
+
+func (CommentUpdated) EventType() Type { return TypeCommentUpdated }
+
+func (p CommentUpdated) Aggregate() (AggregateType, uuid.UUID) {
+	return AggregateTodo, p.Comment.TodoID
+}
+
+// CommentDeleted reports whether the comment was kept as a tombstone because it
+// has replies.
+type CommentDeleted struct {
+	CommentID  uuid.UUID `json:"commentId"`
+	TodoID     uuid.UUID `json:"todoId"`
+	Tombstoned bool      `json:"tombstoned"`
+}
+
+func (CommentDeleted) EventType() Type { return TypeCommentDeleted }
+
+func (p CommentDeleted) Aggregate() (AggregateType, uuid.UUID) {
+	return AggregateTodo, p.TodoID
+}
+
+type CommentReactionToggled struct {
+	CommentID uuid.UUID `json:"commentId"`
+	TodoID    uuid.UUID `json:"todoId"`
+	UserID    string    `json:"userId"`
+	Emoji     string    `json:"emoji"`
+	Added     bool      `json:"added"`
+}
+
+func (CommentReactionToggled) EventType() Type { return TypeCommentReactionToggled }
+
+func (p CommentReactionToggled) Aggregate() (AggregateType, uuid.UUID) {
+	return AggregateTodo, p.TodoID
+}
+
+type AttachmentAdded struct {
+	TodoID     uuid.UUID       `json:"todoId"`
+	Attachment todo.Attachment `json:"attachment"`
+}
+
+func (AttachmentAdded) EventType() Type { return TypeAttachmentAdded }
+
+func (p AttachmentAdded) Aggregate() (AggregateType, uuid.UUID) {
+	return AggregateTodo, p.TodoID
+}
+
+// AttachmentVersionAdded carries the attachment at its new version. RestoredFrom is
+// set when the version brings back the content of an earlier one.
+type AttachmentVersionAdded struct {
+	TodoID       uuid.UUID       `json:"todoId"`
+	Attachment   todo.Attachment `json:"attachment"`
+	RestoredFrom *int            `json:"restoredFrom"`
+}
+
+func (AttachmentVersionAdded) EventType() Type { return TypeAttachmentVersionAdded }
+
+func (p AttachmentVersionAdded) Aggregate() (AggregateType, uuid.UUID) {
+	return AggregateTodo, p.TodoID
+}
+
+type AttachmentDeleted struct {
+	AttachmentID uuid.UUID `json:"attachmentId"`
+	TodoID       uuid.UUID `json:"todoId"`
+	Name         string    `json:"name"`
+}
+
+func (AttachmentDeleted) EventType() Type { return TypeAttachmentDeleted }
+
+func (p AttachmentDeleted) Aggregate() (AggregateType, uuid.UUID) {
+	return AggregateTodo, p.TodoID
+}
+
+type CategoryCreated struct {
+	Category category.Category `json:"category"`
+}
+
+func (CategoryCreated) EventType() Type { return TypeCategoryCreated }
+
+func (p CategoryCreated) Aggregate() (AggregateType, uuid.UUID) {
+	return AggregateCategory, p.Category.ID
+}
+
+// CategoryUpdated carries the category after the update. Changed lists the fields
+// that were set, by their JSON names.
+type CategoryUpdated struct {
+	Category category.Category `json:"category"`
+	Changed  []string          `json:"changed"`
+}
+
+func (CategoryUpdated) EventType() Type { return TypeCategoryUpdated }
+
+func (p CategoryUpdated) Aggregate() (AggregateType, uuid.UUID) {
+	return AggregateCategory, p.Category.ID
+}
+
+// CategoryDeleted names the users who could see the category, resolved before its
+// shares were deleted with it.
+type CategoryDeleted struct {
+	CategoryID uuid.UUID `json:"categoryId"`
+	MemberIDs  []string  `json:"memberIds"`
+}
+
+func (CategoryDeleted) EventType() Type { return TypeCategoryDeleted }
+
+func (p CategoryDeleted) Aggregate() (AggregateType, uuid.UUID) {
+	return AggregateCategory, p.CategoryID
+}
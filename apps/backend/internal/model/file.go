@@ -0,0 +1,13 @@
+package model
+
+import "io"
+
+// FileStream is a file response written to the client as it is produced, for
+// content too large to hold in memory.
+type FileStream struct {
+	Filename    string
+	ContentType string
+	// Write produces the content. It runs after the headers are sent, so an error
+	// can only cut the response short.
+	Write func(w io.Writer) error
+}
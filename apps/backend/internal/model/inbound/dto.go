@@ -0,0 +1,17 @@
+package inbound
+
+// --- Get Inbound Address ---
+type GetInboundAddressPayload struct {
+}
+
+func (p *GetInboundAddressPayload) Validate() error {
+	return nil
+}
+
+// --- Rotate Inbound Address ---
+type RotateInboundAddressPayload struct {
+}
+
+func (p *RotateInboundAddressPayload) Validate() error {
+	return nil
+}
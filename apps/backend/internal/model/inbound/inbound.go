@@ -0,0 +1,79 @@
+package inbound
+
+import (
+	"strings"
+
+	"github.com/ApoorvYdv/go-tasker/internal/model"
+	"github.com/google/uuid"
+)
+
+// replyPrefix marks reply-to addresses on notification emails, which use the plus
+// subaddress so they route to the same mailbox as personal addresses.
+const replyPrefix = "reply+"
+
+// Address is a user's secret personal address for emailing in todos.
+type Address struct {
+	model.Base
+	UserID string `json:"userId" db:"user_id"`
+	Token  string `json:"-" db:"token"`
+	Email  string `json:"email" db:"-"`
+}
+
+// ReplyToken ties a notification email's reply-to address to its recipient and todo.
+type ReplyToken struct {
+	model.Base
+	Token          string     `json:"-" db:"token"`
+	UserID         string     `json:"userId" db:"user_id"`
+	TodoID         uuid.UUID  `json:"todoId" db:"todo_id"`
+	CommentID      *uuid.UUID `json:"commentId" db:"comment_id"`
+	OrganizationID *string    `json:"organizationId" db:"organization_id"`
+}
+
+type RecipientKind string
+
+const (
+	RecipientPersonal RecipientKind = "personal"
+	RecipientReply    RecipientKind = "reply"
+)
+
+func PersonalAddress(token, domain string) string {
+	return token + "@" + domain
+}
+
+func ReplyAddress(token, domain string) string {
+	return replyPrefix + token + "@" + domain
+}
+
+// ParseRecipient recognizes personal and reply addresses on the inbound domain.
+func ParseRecipient(address, domain string) (RecipientKind, string, bool) {
+	at := strings.LastIndexByte(address, '@')
+	if at <= 0 || !strings.EqualFold(address[at+1:], domain) {
+		return "", "", false
+	}
+
+	local := strings.ToLower(address[:at])
+	if token, ok := strings.CutPrefix(local, replyPrefix); ok {
+		return RecipientReply, token, token != ""
+	}
+
+	// Other subaddresses are ignored so "token+anything@" still reaches the user
+	token, _, _ := strings.Cut(local, "+")
+	return RecipientPersonal, token, token != ""
+}
+
+type ResultStatus string
+
+const (
+	ResultTodoCreated  ResultStatus = "todo_created"
+	ResultCommentAdded ResultStatus = "comment_added"
+	ResultIgnored      ResultStatus = "ignored"
+)
+
+// Result reports what an inbound email turned into. Ignored emails are still
+// acknowledged so providers don't retry them.
+type Result struct {
+	Status    ResultStatus `json:"status"`
+	Reason    string       `json:"reason,omitempty"`
+	TodoID    *uuid.UUID   `json:"todoId,omitempty"`
+	CommentID *uuid.UUID   `json:"commentId,omitempty"`
+}
@@ -0,0 +1,144 @@
+package notification
+
+import (
+	"fmt"
+
+	"github.com/ApoorvYdv/go-tasker/internal/validation"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+// --- Get Notifications ---
+type GetNotificationsQuery struct {
+	Page   *int    `query:"page" validate:"omitempty,min=1"`
+	Limit  *int    `query:"limit" validate:"omitempty,min=1,max=100"`
+	Status *string `query:"status" validate:"omitempty,oneof=all unread read"`
+	// Archived selects the archive instead of the inbox
+	Archived *bool `query:"archived"`
+}
+
+func (q *GetNotificationsQuery) Validate() error {
+	validate := validator.New()
+
+	if err := validate.Struct(q); err != nil {
+		return err
+	}
+
+	// Set defaults for pagination
+	if q.Page == nil {
+		defaultPage := 1
+		q.Page = &defaultPage
+	}
+
+	if q.Limit == nil {
+		defaultLimit := 20
+		q.Limit = &defaultLimit
+	}
+
+	if q.Status == nil {
+		defaultStatus := "all"
+		q.Status = &defaultStatus
+	}
+
+	if q.Archived == nil {
+		defaultArchived := false
+		q.Archived = &defaultArchived
+	}
+
+	return nil
+}
+
+// --- Get Unread Count ---
+type GetUnreadCountPayload struct {
+}
+
+func (p *GetUnreadCountPayload) Validate() error {
+	return nil
+}
+
+// --- Mark Notification Read ---
+type MarkNotificationReadPayload struct {
+	ID uuid.UUID `param:"id" validate:"required,uuid"`
+}
+
+func (p *MarkNotificationReadPayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(p)
+}
+
+// --- Mark All Notifications Read ---
+type MarkAllNotificationsReadPayload struct {
+}
+
+func (p *MarkAllNotificationsReadPayload) Validate() error {
+	return nil
+}
+
+// --- Archive Notification ---
+type ArchiveNotificationPayload struct {
+	ID uuid.UUID `param:"id" validate:"required,uuid"`
+}
+
+func (p *ArchiveNotificationPayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(p)
+}
+
+// --- Get Preferences ---
+type GetPreferencesPayload struct {
+}
+
+func (p *GetPreferencesPayload) Validate() error {
+	return nil
+}
+
+// --- Update Preferences ---
+type UpdatePreferencesPayload struct {
+	Timezone *string `json:"timezone" validate:"omitempty,timezone"`
+	// Quiet hours are "HH:MM"; set both to an empty string to turn them off
+	QuietHoursStart *string `json:"quietHoursStart" validate:"omitempty,datetime=15:04"`
+	QuietHoursEnd   *string `json:"quietHoursEnd" validate:"omitempty,datetime=15:04"`
+	// WebhookURL must be https; an empty string removes the webhook
+	WebhookURL *string                   `json:"webhookUrl" validate:"omitempty,url,startswith=https://,max=2048"`
+	Types      []UpdatePreferencePayload `json:"types" validate:"omitempty,dive"`
+}
+
+type UpdatePreferencePayload struct {
+	Type    Type  `json:"type" validate:"required"`
+	InApp   *bool `json:"inApp"`
+	Email   *bool `json:"email"`
+	Webhook *bool `json:"webhook"`
+}
+
+func (p *UpdatePreferencesPayload) Validate() error {
+	validate := validator.New()
+
+	if err := validate.Struct(p); err != nil {
+		return err
+	}
+
+	var errs validation.CustomValidationErrors
+
+	if (p.QuietHoursStart == nil) != (p.QuietHoursEnd == nil) ||
+		p.QuietHoursStart != nil && (*p.QuietHoursStart == "") != (*p.QuietHoursEnd == "") {
+		errs = append(errs, validation.CustomValidationError{
+			Field:   "quietHoursEnd",
+			Message: "quiet hours need both a start and an end",
+		})
+	}
+
+	for i, t := range p.Types {
+		if !IsValidType(t.Type) {
+			errs = append(errs, validation.CustomValidationError{
+				Field:   fmt.Sprintf("types[%d].type", i),
+				Message: "is not a known notification type",
+			})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
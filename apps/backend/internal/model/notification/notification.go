@@ -0,0 +1,164 @@
+package notification
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/model"
+	"github.com/google/uuid"
+)
+
+type Type string
+
+const (
+	TypeTodoAssigned       Type = "todo_assigned"
+	TypeTodoStatusChanged  Type = "todo_status_changed"
+	TypeTodoDueDateChanged Type = "todo_due_date_changed"
+	TypeTodoCommented      Type = "todo_commented"
+	TypeTodoDueSoon        Type = "todo_due_soon"
+	TypeTodoReminder       Type = "todo_reminder"
+	TypeCommentMention     Type = "comment_mention"
+	TypeCommentReply       Type = "comment_reply"
+)
+
+// Types lists every notification type users can set preferences for.
+var Types = []Type{
+	TypeTodoAssigned,
+	TypeTodoStatusChanged,
+	TypeTodoDueDateChanged,
+	TypeTodoCommented,
+	TypeTodoDueSoon,
+	TypeTodoReminder,
+	TypeCommentMention,
+	TypeCommentReply,
+}
+
+func IsValidType(t Type) bool {
+	for _, known := range Types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+type Channel string
+
+const (
+	ChannelInApp   Channel = "in_app"
+	ChannelEmail   Channel = "email"
+	ChannelWebhook Channel = "webhook"
+)
+
+type Notification struct {
+	model.Base
+	UserID     string     `json:"userId" db:"user_id"`
+	Type       Type       `json:"type" db:"type"`
+	ActorID    *string    `json:"actorId" db:"actor_id"`
+	TodoID     *uuid.UUID `json:"todoId" db:"todo_id"`
+	CommentID  *uuid.UUID `json:"commentId" db:"comment_id"`
+	Title      string     `json:"title" db:"title"`
+	Body       string     `json:"body" db:"body"`
+	ReadAt     *time.Time `json:"readAt" db:"read_at"`
+	ArchivedAt *time.Time `json:"archivedAt" db:"archived_at"`
+}
+
+// Inbox is a page of notifications together with the user's total unread count.
+type Inbox struct {
+	model.PaginatedResponse[Notification]
+	UnreadCount int `json:"unreadCount"`
+}
+
+type UnreadCount struct {
+	Count int `json:"count"`
+}
+
+// Preference is a user's channel choice for one notification type.
+type Preference struct {
+	Type    Type `json:"type" db:"type"`
+	InApp   bool `json:"inApp" db:"in_app"`
+	Email   bool `json:"email" db:"email"`
+	Webhook bool `json:"webhook" db:"webhook"`
+}
+
+// DefaultPreference is used for types a user hasn't configured: every channel is on.
+// Webhooks are only delivered once the user has set a webhook URL.
+func DefaultPreference(t Type) Preference {
+	return Preference{Type: t, InApp: true, Email: true, Webhook: true}
+}
+
+// QuietHours is a daily window during which email and webhook delivery is held back.
+// Start and End are "HH:MM" in Timezone; an End before Start spans midnight.
+type QuietHours struct {
+	Timezone        string  `json:"timezone" db:"timezone"`
+	QuietHoursStart *string `json:"quietHoursStart" db:"quiet_hours_start"`
+	QuietHoursEnd   *string `json:"quietHoursEnd" db:"quiet_hours_end"`
+}
+
+// QuietUntil reports whether now falls within quiet hours and, if so, when they end.
+func (q QuietHours) QuietUntil(now time.Time) (time.Time, bool) {
+	if q.QuietHoursStart == nil || q.QuietHoursEnd == nil {
+		return time.Time{}, false
+	}
+
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+
+	start, err := clockOn(local, *q.QuietHoursStart)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := clockOn(local, *q.QuietHoursEnd)
+	if err != nil || start.Equal(end) {
+		return time.Time{}, false
+	}
+
+	if start.Before(end) {
+		if !local.Before(start) && local.Before(end) {
+			return end, true
+		}
+		return time.Time{}, false
+	}
+
+	// The window spans midnight
+	switch {
+	case !local.Before(start):
+		return end.AddDate(0, 0, 1), true
+	case local.Before(end):
+		return end, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// clockOn returns the given "HH:MM" time on the same local day as day.
+func clockOn(day time.Time, clock string) (time.Time, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid clock time %q: %w", clock, err)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location()), nil
+}
+
+// Settings are a user's notification settings shared by every type.
+type Settings struct {
+	QuietHours
+	WebhookURL    *string `json:"webhookUrl" db:"webhook_url"`
+	WebhookSecret *string `json:"webhookSecret" db:"webhook_secret"`
+}
+
+// Preferences is everything a user can configure about notifications.
+type Preferences struct {
+	Settings
+	Types []Preference `json:"types"`
+}
+
+// Delivery is the resolved channel configuration for one recipient of one type.
+type Delivery struct {
+	UserID string `db:"user_id"`
+	Preference
+	Settings
+}
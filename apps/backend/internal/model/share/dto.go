@@ -0,0 +1,81 @@
+package share
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+// --- Share Todo ---
+type CreateTodoSharePayload struct {
+	TodoID uuid.UUID `param:"id" validate:"required,uuid"`
+	Email  string    `json:"email" validate:"required,email"`
+	Role   Role      `json:"role" validate:"required,oneof=viewer commenter editor"`
+}
+
+func (r *CreateTodoSharePayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
+
+// --- Share Category ---
+type CreateCategorySharePayload struct {
+	CategoryID uuid.UUID `param:"id" validate:"required,uuid"`
+	Email      string    `json:"email" validate:"required,email"`
+	Role       Role      `json:"role" validate:"required,oneof=viewer commenter editor"`
+}
+
+func (r *CreateCategorySharePayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
+
+// --- Get Todo Shares ---
+type GetTodoSharesPayload struct {
+	TodoID uuid.UUID `param:"id" validate:"required,uuid"`
+}
+
+func (r *GetTodoSharesPayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
+
+// --- Get Category Shares ---
+type GetCategorySharesPayload struct {
+	CategoryID uuid.UUID `param:"id" validate:"required,uuid"`
+}
+
+func (r *GetCategorySharesPayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
+
+// --- Update Share ---
+type UpdateSharePayload struct {
+	ID   uuid.UUID `param:"id" validate:"required,uuid"`
+	Role Role      `json:"role" validate:"required,oneof=viewer commenter editor"`
+}
+
+func (r *UpdateSharePayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
+
+// --- Delete Share ---
+type DeleteSharePayload struct {
+	ID uuid.UUID `param:"id" validate:"required,uuid"`
+}
+
+func (r *DeleteSharePayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
+
+// --- Accept Invitation ---
+type AcceptInvitationPayload struct {
+	Token string `param:"token" validate:"required,min=32,max=128"`
+}
+
+func (r *AcceptInvitationPayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
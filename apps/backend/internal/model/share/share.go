@@ -0,0 +1,65 @@
+package share
+
+import (
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/model"
+	"github.com/google/uuid"
+)
+
+type ResourceType string
+
+const (
+	ResourceTypeTodo     ResourceType = "todo"
+	ResourceTypeCategory ResourceType = "category"
+)
+
+type Role string
+
+const (
+	RoleOwner     Role = "owner"
+	RoleEditor    Role = "editor"
+	RoleCommenter Role = "commenter"
+	RoleViewer    Role = "viewer"
+)
+
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusAccepted Status = "accepted"
+)
+
+type Share struct {
+	model.Base
+	OwnerID      string       `json:"ownerId" db:"owner_id"`
+	ResourceType ResourceType `json:"resourceType" db:"resource_type"`
+	TodoID       *uuid.UUID   `json:"todoId" db:"todo_id"`
+	CategoryID   *uuid.UUID   `json:"categoryId" db:"category_id"`
+	UserID       *string      `json:"userId" db:"user_id"`
+	Email        string       `json:"email" db:"email"`
+	Role         Role         `json:"role" db:"role"`
+	Status       Status       `json:"status" db:"status"`
+	InviteToken  string       `json:"-" db:"invite_token"`
+	AcceptedAt   *time.Time   `json:"acceptedAt" db:"accepted_at"`
+}
+
+// Rank orders roles by the permissions they grant; keep in sync with share_role_rank in SQL.
+func (r Role) Rank() int {
+	switch r {
+	case RoleOwner:
+		return 4
+	case RoleEditor:
+		return 3
+	case RoleCommenter:
+		return 2
+	case RoleViewer:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (r Role) AtLeast(min Role) bool {
+	return r.Rank() >= min.Rank()
+}
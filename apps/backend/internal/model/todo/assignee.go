@@ -0,0 +1,19 @@
+package todo
+
+import (
+	"github.com/ApoorvYdv/go-tasker/internal/model"
+	"github.com/google/uuid"
+)
+
+type Assignee struct {
+	model.Base
+	TodoID     uuid.UUID `json:"todoId" db:"todo_id"`
+	UserID     string    `json:"userId" db:"user_id"`
+	AssignedBy string    `json:"assignedBy" db:"assigned_by"`
+}
+
+type Watcher struct {
+	model.Base
+	TodoID uuid.UUID `json:"todoId" db:"todo_id"`
+	UserID string    `json:"userId" db:"user_id"`
+}
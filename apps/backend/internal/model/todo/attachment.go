@@ -1,6 +1,53 @@
 package todo
 
-import "github.com/ApoorvYdv/go-tasker/internal/model"
+import (
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/lib/preview"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/storage"
+	"github.com/ApoorvYdv/go-tasker/internal/model"
+	"github.com/google/uuid"
+)
+
+type PreviewStatus string
+
+const (
+	PreviewPending     PreviewStatus = "pending"
+	PreviewReady       PreviewStatus = "ready"
+	PreviewFailed      PreviewStatus = "failed"
+	PreviewUnsupported PreviewStatus = "unsupported"
+)
+
+// InitialPreviewStatus is the preview status of a new attachment of the given type.
+func InitialPreviewStatus(mimeType string) PreviewStatus {
+	if preview.Supported(mimeType) {
+		return PreviewPending
+	}
+	return PreviewUnsupported
+}
+
+// ScanStatus is the malware scan verdict on an attachment's content.
+type ScanStatus string
+
+const (
+	ScanPending  ScanStatus = "pending"
+	ScanClean    ScanStatus = "clean"
+	ScanInfected ScanStatus = "infected"
+	ScanError    ScanStatus = "error"
+)
+
+// QuarantinePrefix is prepended to the storage key of infected content, keeping it
+// apart from everything that is served.
+const QuarantinePrefix = "quarantine/"
+
+// ThumbnailSuffix is appended to a content key to get the key of its thumbnail.
+const ThumbnailSuffix = ".thumb.jpg"
+
+// ThumbnailKey is where the thumbnail of the content stored under key goes, next
+// to the original so it shares its lifetime.
+func ThumbnailKey(key string) string {
+	return key + ThumbnailSuffix
+}
 
 type Attachment struct {
 	model.Base
@@ -10,4 +57,118 @@ type Attachment struct {
 	DownloadKey string  `json:"downloadKey" db:"download_key"`
 	FileSize    *int64  `json:"fileSize" db:"file_size"`
 	MimeType    *string `json:"mimeType" db:"mime_type"`
+	// Checksum is the hex SHA-256 of the content, nil for attachments stored
+	// before uploads were content-addressed
+	Checksum      *string       `json:"checksum" db:"checksum"`
+	PreviewStatus PreviewStatus `json:"previewStatus" db:"preview_status"`
+	ThumbnailKey  *string       `json:"thumbnailKey" db:"thumbnail_key"`
+	PageCount     *int          `json:"pageCount" db:"page_count"`
+	// Version is the number of the current version, whose content the attachment holds
+	Version    int        `json:"version" db:"version"`
+	ScanStatus ScanStatus `json:"scanStatus" db:"scan_status"`
+	// ThumbnailURL is a presigned URL for the thumbnail, set when one is ready
+	ThumbnailURL *string `json:"thumbnailUrl" db:"-"`
+}
+
+// AttachmentVersion is one version of an attachment's content, kept after newer
+// versions are uploaded until retention prunes it.
+type AttachmentVersion struct {
+	model.Base
+	AttachmentID uuid.UUID  `json:"attachmentId" db:"attachment_id"`
+	Version      int        `json:"version" db:"version"`
+	UploadedBy   string     `json:"uploadedBy" db:"uploaded_by"`
+	Name         string     `json:"name" db:"name"`
+	DownloadKey  string     `json:"-" db:"download_key"`
+	FileSize     *int64     `json:"fileSize" db:"file_size"`
+	MimeType     *string    `json:"mimeType" db:"mime_type"`
+	Checksum     *string    `json:"checksum" db:"checksum"`
+	ScanStatus   ScanStatus `json:"scanStatus" db:"scan_status"`
+	// RestoredFrom is the earlier version this one brought back, and RestoredBy who
+	// did it
+	RestoredFrom *int    `json:"restoredFrom" db:"restored_from"`
+	RestoredBy   *string `json:"restoredBy" db:"restored_by"`
+}
+
+// AtVersion is the attachment as it was at the given version. Previews describe the
+// current content only, so they are left out.
+func (a *Attachment) AtVersion(v *AttachmentVersion) *Attachment {
+	attachment := *a
+	attachment.Version = v.Version
+	attachment.Name = v.Name
+	attachment.DownloadKey = v.DownloadKey
+	attachment.FileSize = v.FileSize
+	attachment.MimeType = v.MimeType
+	attachment.Checksum = v.Checksum
+	attachment.ScanStatus = v.ScanStatus
+	attachment.ThumbnailKey = nil
+	attachment.ThumbnailURL = nil
+	attachment.PageCount = nil
+	return &attachment
+}
+
+// Blob is a file stored once per user under a key derived from its checksum and
+// shared by every attachment with the same content.
+type Blob struct {
+	model.Base
+	UserID     string `json:"userId" db:"user_id"`
+	Checksum   string `json:"checksum" db:"checksum"`
+	StorageKey string `json:"-" db:"storage_key"`
+	FileSize   int64  `json:"fileSize" db:"file_size"`
+	MimeType   string `json:"mimeType" db:"mime_type"`
+	RefCount   int    `json:"refCount" db:"ref_count"`
+}
+
+// AttachmentUpload is a pending direct-to-storage upload that becomes an
+// Attachment once completed.
+type AttachmentUpload struct {
+	model.Base
+	TodoID      uuid.UUID  `json:"todoId" db:"todo_id"`
+	UserID      string     `json:"userId" db:"user_id"`
+	Name        string     `json:"name" db:"name"`
+	StorageKey  string     `json:"-" db:"storage_key"`
+	FileSize    int64      `json:"fileSize" db:"file_size"`
+	MimeType    string     `json:"mimeType" db:"mime_type"`
+	ExpiresAt   time.Time  `json:"expiresAt" db:"expires_at"`
+	CompletedAt *time.Time `json:"completedAt" db:"completed_at"`
+}
+
+// UploadTicket tells the client where to send the file for an upload.
+type UploadTicket struct {
+	Upload *AttachmentUpload        `json:"upload"`
+	Target *storage.PresignedUpload `json:"target"`
+}
+
+// StorageUsage is what a user stores, from running totals kept up to date with
+// every upload and delete.
+type StorageUsage struct {
+	// UsedBytes counts deduplicated content once
+	UsedBytes int64 `json:"usedBytes" db:"used_bytes"`
+	FileCount int   `json:"fileCount" db:"file_count"`
+	// ReservedBytes is held by direct uploads that haven't completed yet
+	ReservedBytes int64 `json:"reservedBytes" db:"reserved_bytes"`
+}
+
+// StorageReport breaks a user's storage down by todo and content type. The
+// breakdowns count every attachment, so with deduplicated files they can add up to
+// more than UsedBytes.
+type StorageReport struct {
+	StorageUsage
+	// QuotaBytes is nil when storage is unlimited
+	QuotaBytes  *int64                 `json:"quotaBytes"`
+	MaxFileSize int64                  `json:"maxFileSize"`
+	ByTodo      []TodoStorageUsage     `json:"byTodo"`
+	ByMimeType  []MimeTypeStorageUsage `json:"byMimeType"`
+}
+
+type TodoStorageUsage struct {
+	TodoID    uuid.UUID `json:"todoId" db:"todo_id"`
+	Title     string    `json:"title" db:"title"`
+	Bytes     int64     `json:"bytes" db:"bytes"`
+	FileCount int       `json:"fileCount" db:"file_count"`
+}
+
+type MimeTypeStorageUsage struct {
+	MimeType  string `json:"mimeType" db:"mime_type"`
+	Bytes     int64  `json:"bytes" db:"bytes"`
+	FileCount int    `json:"fileCount" db:"file_count"`
 }
@@ -56,6 +56,11 @@ type GetTodosQuery struct {
 	DueTo        *time.Time `query:"dueTo"`
 	Overdue      *bool      `query:"overdue"`
 	Completed    *bool      `query:"completed"`
+	Ownership    *string    `query:"ownership" validate:"omitempty,oneof=all owned shared"`
+	// Assignee filters by assigned user; "me" selects the requesting user
+	Assignee *string `query:"assignee" validate:"omitempty,min=1,max=255"`
+	// IncludeSubcategories widens the categoryId filter to the category's subcategories
+	IncludeSubcategories *bool `query:"includeSubcategories"`
 }
 
 func (q *GetTodosQuery) Validate() error {
@@ -127,6 +132,30 @@ func (r *UploadTodoAttachmentPayload) Validate() error {
 	return validate.Struct(r)
 }
 
+// --- Create Attachment Upload ---
+type CreateAttachmentUploadPayload struct {
+	TodoID      uuid.UUID `param:"id" validate:"required,uuid"`
+	Filename    string    `json:"filename" validate:"required,min=1,max=255"`
+	ContentType string    `json:"contentType" validate:"required,max=255"`
+	Size        int64     `json:"size" validate:"required,min=1"`
+}
+
+func (r *CreateAttachmentUploadPayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
+
+// --- Complete Attachment Upload ---
+type CompleteAttachmentUploadPayload struct {
+	TodoID   uuid.UUID `param:"id" validate:"required,uuid"`
+	UploadID uuid.UUID `param:"uploadId" validate:"required,uuid"`
+}
+
+func (r *CompleteAttachmentUploadPayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
+
 // --- Delete Todo Attachment ---
 type DeleteTodoAttachmentPayload struct {
 	TodoID       uuid.UUID `param:"id" validate:"required,uuid"`
@@ -142,9 +171,120 @@ func (r *DeleteTodoAttachmentPayload) Validate() error {
 type GetTodoAttachmentPayload struct {
 	TodoID       uuid.UUID `param:"id" validate:"required,uuid"`
 	AttachmentID uuid.UUID `param:"attachmentId" validate:"required,uuid"`
+	// Version picks an earlier version instead of the current one
+	Version *int `query:"version" validate:"omitempty,min=1"`
 }
 
 func (r *GetTodoAttachmentPayload) Validate() error {
 	validate := validator.New()
 	return validate.Struct(r)
 }
+
+// --- Get Todo Attachment Content ---
+type GetTodoAttachmentContentPayload struct {
+	TodoID       uuid.UUID `param:"id" validate:"required,uuid"`
+	AttachmentID uuid.UUID `param:"attachmentId" validate:"required,uuid"`
+	// Version picks an earlier version instead of the current one
+	Version *int `query:"version" validate:"omitempty,min=1"`
+	// Download asks for a download even when browsers could display the file
+	Download *bool `query:"download"`
+}
+
+// --- Attachment Versions ---
+// AttachmentVersionsPayload names an attachment without its todo, for
+// /attachments/:id/versions.
+type AttachmentVersionsPayload struct {
+	AttachmentID uuid.UUID `param:"id" validate:"required,uuid"`
+}
+
+func (r *AttachmentVersionsPayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
+
+type RestoreAttachmentVersionPayload struct {
+	AttachmentID uuid.UUID `param:"id" validate:"required,uuid"`
+	Version      int       `param:"version" validate:"required,min=1"`
+}
+
+func (r *RestoreAttachmentVersionPayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
+
+func (r *GetTodoAttachmentContentPayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
+
+// --- Get Todo Attachments ---
+type GetTodoAttachmentsPayload struct {
+	TodoID uuid.UUID `param:"id" validate:"required,uuid"`
+}
+
+func (r *GetTodoAttachmentsPayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
+
+// --- Download Todo Attachments Archive ---
+type GetTodoAttachmentsArchivePayload struct {
+	TodoID uuid.UUID `param:"id" validate:"required,uuid"`
+	// IncludeSubtasks adds each subtask's attachments in a folder named after it
+	IncludeSubtasks *bool `query:"includeSubtasks"`
+}
+
+func (r *GetTodoAttachmentsArchivePayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
+
+// --- Get Storage Usage ---
+type GetStorageUsagePayload struct {
+}
+
+func (r *GetStorageUsagePayload) Validate() error {
+	return nil
+}
+
+// --- Add Todo Assignees ---
+type AddTodoAssigneesPayload struct {
+	TodoID  uuid.UUID `param:"id" validate:"required,uuid"`
+	UserIDs []string  `json:"userIds" validate:"required,min=1,max=20,dive,required,max=255"`
+}
+
+func (r *AddTodoAssigneesPayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
+
+// --- Remove Todo Assignee ---
+type RemoveTodoAssigneePayload struct {
+	TodoID uuid.UUID `param:"id" validate:"required,uuid"`
+	UserID string    `param:"userId" validate:"required,max=255"`
+}
+
+func (r *RemoveTodoAssigneePayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
+
+// --- Get Todo Assignees ---
+type GetTodoAssigneesPayload struct {
+	TodoID uuid.UUID `param:"id" validate:"required,uuid"`
+}
+
+func (r *GetTodoAssigneesPayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
+
+// --- Watch / Unwatch Todo ---
+type WatchTodoPayload struct {
+	TodoID uuid.UUID `param:"id" validate:"required,uuid"`
+}
+
+func (r *WatchTodoPayload) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/ApoorvYdv/go-tasker/internal/model"
 	"github.com/ApoorvYdv/go-tasker/internal/model/category"
 	"github.com/ApoorvYdv/go-tasker/internal/model/comment"
+	"github.com/ApoorvYdv/go-tasker/internal/model/share"
 	"github.com/google/uuid"
 )
 
@@ -28,17 +29,18 @@ const (
 
 type Todo struct {
 	model.Base
-	UserID       string     `json:"userId" db:"user_id"`
-	Title        string     `json:"title" db:"title"`
-	Description  *string    `json:"description" db:"description"`
-	Status       Status     `json:"status" db:"status"`
-	Priority     Priority   `json:"priority" db:"priority"`
-	DueDate      *time.Time `json:"dueDate" db:"due_date"`
-	CompletedAt  *time.Time `json:"completedAt" db:"completed_at"`
-	ParentTodoID *uuid.UUID `json:"parentTodoId" db:"parent_todo_id"`
-	CategoryID   *uuid.UUID `json:"categoryId" db:"category_id"`
-	Metadata     *Metadata  `json:"metadata" db:"metadata"`
-	SortOrder    int        `json:"sortOrder" db:"sort_order"`
+	UserID         string     `json:"userId" db:"user_id"`
+	OrganizationID *string    `json:"organizationId" db:"organization_id"`
+	Title          string     `json:"title" db:"title"`
+	Description    *string    `json:"description" db:"description"`
+	Status         Status     `json:"status" db:"status"`
+	Priority       Priority   `json:"priority" db:"priority"`
+	DueDate        *time.Time `json:"dueDate" db:"due_date"`
+	CompletedAt    *time.Time `json:"completedAt" db:"completed_at"`
+	ParentTodoID   *uuid.UUID `json:"parentTodoId" db:"parent_todo_id"`
+	CategoryID     *uuid.UUID `json:"categoryId" db:"category_id"`
+	Metadata       *Metadata  `json:"metadata" db:"metadata"`
+	SortOrder      int        `json:"sortOrder" db:"sort_order"`
 }
 
 type Metadata struct {
@@ -50,10 +52,14 @@ type Metadata struct {
 
 type PopulatedTodo struct {
 	Todo
+	// AccessRole is the requesting user's role on the todo; "owner" for their own todos
+	AccessRole  share.Role         `json:"accessRole" db:"access_role"`
 	Category    *category.Category `json:"category" db:"category"`
 	Children    []*Todo            `json:"children" db:"children"`
 	Comments    []comment.Comment  `json:"comments" db:"comments"`
 	Attachments []Attachment       `json:"attachments" db:"attachments"`
+	AssigneeIDs []string           `json:"assigneeIds" db:"assignee_ids"`
+	WatcherIDs  []string           `json:"watcherIds" db:"watcher_ids"`
 }
 
 type TodoStats struct {
@@ -63,6 +69,11 @@ type TodoStats struct {
 	Completed int `json:"completed" db:"completed"`
 	Archived  int `json:"archived" db:"archived"`
 	Overdue   int `json:"overdue" db:"overdue"`
+
+	// AssignedToMe counts open todos assigned to the requesting user, including
+	// todos owned by others; AssignedToMeOverdue is the overdue subset.
+	AssignedToMe        int `json:"assignedToMe" db:"assigned_to_me"`
+	AssignedToMeOverdue int `json:"assignedToMeOverdue" db:"assigned_to_me_overdue"`
 }
 
 func (t *Todo) IsOverdue() bool {
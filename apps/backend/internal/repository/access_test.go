@@ -0,0 +1,400 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/ApoorvYdv/go-tasker/internal/errs"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/workspace"
+	"github.com/ApoorvYdv/go-tasker/internal/model/category"
+	"github.com/ApoorvYdv/go-tasker/internal/model/share"
+	"github.com/ApoorvYdv/go-tasker/internal/model/todo"
+	tasktest "github.com/ApoorvYdv/go-tasker/internal/testing"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// accessFixture creates todos, categories and accepted shares for the access tests.
+type accessFixture struct {
+	todoRepo     *TodoRepository
+	categoryRepo *CategoryRepository
+	shareRepo    *ShareRepository
+}
+
+func (f *accessFixture) createTodo(t *testing.T, ctx context.Context, userID string,
+	payload *todo.CreateTodoPayload,
+) *todo.Todo {
+	t.Helper()
+
+	todoItem, err := f.todoRepo.CreateTodo(ctx, userID, payload)
+	require.NoError(t, err)
+	return todoItem
+}
+
+func (f *accessFixture) createCategory(t *testing.T, ctx context.Context, userID string) *category.Category {
+	t.Helper()
+
+	categoryItem, err := f.categoryRepo.CreateCategory(ctx, userID, &category.CreateCategoryPayload{Name: "Shared"})
+	require.NoError(t, err)
+	return categoryItem
+}
+
+// share invites the user by email and accepts the invitation as them.
+func (f *accessFixture) share(t *testing.T, ownerID string, resourceType share.ResourceType,
+	resourceID uuid.UUID, userID string, role share.Role,
+) {
+	t.Helper()
+
+	ctx := context.Background()
+	email := userID + "@example.com"
+	token := uuid.NewString()
+
+	_, err := f.shareRepo.CreateShare(ctx, ownerID, resourceType, resourceID, email, role, token)
+	require.NoError(t, err)
+	_, err = f.shareRepo.AcceptInvitation(ctx, userID, token, []string{email})
+	require.NoError(t, err)
+}
+
+func assertNoAccess(t *testing.T, err error) {
+	t.Helper()
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func assertForbidden(t *testing.T, err error) {
+	t.Helper()
+
+	var httpErr *errs.HTTPError
+	require.True(t, errors.As(err, &httpErr), "expected an HTTP error, got %v", err)
+	assert.Equal(t, http.StatusForbidden, httpErr.Status)
+}
+
+func newUserID() string {
+	return "user_" + uuid.NewString()
+}
+
+func TestTodoRepository_CheckTodoAccess(t *testing.T) {
+	_, srv, cleanup := tasktest.SetupTest(t)
+	defer cleanup()
+
+	f := &accessFixture{
+		todoRepo:     NewTodoRepository(srv),
+		categoryRepo: NewCategoryRepository(srv),
+		shareRepo:    NewShareRepository(srv),
+	}
+	ctx := context.Background()
+
+	t.Run("personal todos", func(t *testing.T) {
+		ownerID := newUserID()
+		item := f.createTodo(t, ctx, ownerID, &todo.CreateTodoPayload{Title: "Personal"})
+		subtask := f.createTodo(t, ctx, newUserID(), &todo.CreateTodoPayload{Title: "Subtask", ParentTodoID: &item.ID})
+
+		_, err := f.todoRepo.CheckTodoAccess(ctx, ownerID, item.ID, share.RoleOwner)
+		require.NoError(t, err)
+
+		// The parent's creator owns its subtasks
+		_, err = f.todoRepo.CheckTodoAccess(ctx, ownerID, subtask.ID, share.RoleOwner)
+		require.NoError(t, err)
+
+		_, err = f.todoRepo.CheckTodoAccess(ctx, newUserID(), item.ID, share.RoleViewer)
+		assertNoAccess(t, err)
+	})
+
+	t.Run("shared todos", func(t *testing.T) {
+		ownerID := newUserID()
+		categoryItem := f.createCategory(t, ctx, ownerID)
+		item := f.createTodo(t, ctx, ownerID, &todo.CreateTodoPayload{Title: "Shared", CategoryID: &categoryItem.ID})
+		subtask := f.createTodo(t, ctx, ownerID, &todo.CreateTodoPayload{Title: "Subtask", ParentTodoID: &item.ID})
+
+		commenterID := newUserID()
+		f.share(t, ownerID, share.ResourceTypeTodo, item.ID, commenterID, share.RoleCommenter)
+
+		_, err := f.todoRepo.CheckTodoAccess(ctx, commenterID, item.ID, share.RoleCommenter)
+		require.NoError(t, err)
+		_, err = f.todoRepo.CheckTodoAccess(ctx, commenterID, item.ID, share.RoleEditor)
+		assertForbidden(t, err)
+
+		// Subtasks are shared with their parent
+		_, err = f.todoRepo.CheckTodoAccess(ctx, commenterID, subtask.ID, share.RoleCommenter)
+		require.NoError(t, err)
+
+		// The strongest of the todo's and its category's shares wins
+		f.share(t, ownerID, share.ResourceTypeCategory, categoryItem.ID, commenterID, share.RoleEditor)
+		_, err = f.todoRepo.CheckTodoAccess(ctx, commenterID, item.ID, share.RoleEditor)
+		require.NoError(t, err)
+	})
+
+	t.Run("pending shares give no access", func(t *testing.T) {
+		ownerID := newUserID()
+		item := f.createTodo(t, ctx, ownerID, &todo.CreateTodoPayload{Title: "Invited"})
+
+		_, err := f.shareRepo.CreateShare(ctx, ownerID, share.ResourceTypeTodo, item.ID, "invited@example.com",
+			share.RoleEditor, uuid.NewString())
+		require.NoError(t, err)
+
+		_, err = f.todoRepo.CheckTodoAccess(ctx, newUserID(), item.ID, share.RoleViewer)
+		assertNoAccess(t, err)
+	})
+
+	t.Run("category shares reach the category's todos", func(t *testing.T) {
+		ownerID := newUserID()
+		categoryItem := f.createCategory(t, ctx, ownerID)
+		item := f.createTodo(t, ctx, ownerID, &todo.CreateTodoPayload{Title: "Categorized", CategoryID: &categoryItem.ID})
+		subtask := f.createTodo(t, ctx, ownerID, &todo.CreateTodoPayload{Title: "Subtask", ParentTodoID: &item.ID})
+
+		viewerID := newUserID()
+		f.share(t, ownerID, share.ResourceTypeCategory, categoryItem.ID, viewerID, share.RoleViewer)
+
+		_, err := f.todoRepo.CheckTodoAccess(ctx, viewerID, item.ID, share.RoleViewer)
+		require.NoError(t, err)
+		_, err = f.todoRepo.CheckTodoAccess(ctx, viewerID, subtask.ID, share.RoleViewer)
+		require.NoError(t, err)
+		_, err = f.todoRepo.CheckTodoAccess(ctx, viewerID, item.ID, share.RoleCommenter)
+		assertForbidden(t, err)
+	})
+
+	t.Run("organization todos", func(t *testing.T) {
+		orgID := "org_" + uuid.NewString()
+		creatorID := newUserID()
+
+		writer := workspace.WithContext(ctx, workspace.Workspace{
+			OrgID:       orgID,
+			Role:        "org:member",
+			Permissions: []string{"org:" + workspace.PermissionTodosWrite},
+		})
+		reader := workspace.WithContext(ctx, workspace.Workspace{OrgID: orgID, Role: "org:member"})
+		admin := workspace.WithContext(ctx, workspace.Workspace{OrgID: orgID, Role: workspace.AdminRole})
+		otherOrg := workspace.WithContext(ctx, workspace.Workspace{OrgID: "org_" + uuid.NewString(), Role: workspace.AdminRole})
+
+		item := f.createTodo(t, writer, creatorID, &todo.CreateTodoPayload{Title: "Organization"})
+		require.NotNil(t, item.OrganizationID)
+		assert.Equal(t, orgID, *item.OrganizationID)
+
+		// Creators own their todos, members get their default role
+		_, err := f.todoRepo.CheckTodoAccess(writer, creatorID, item.ID, share.RoleOwner)
+		require.NoError(t, err)
+		_, err = f.todoRepo.CheckTodoAccess(writer, newUserID(), item.ID, share.RoleEditor)
+		require.NoError(t, err)
+		_, err = f.todoRepo.CheckTodoAccess(writer, newUserID(), item.ID, share.RoleOwner)
+		assertForbidden(t, err)
+		_, err = f.todoRepo.CheckTodoAccess(reader, newUserID(), item.ID, share.RoleViewer)
+		require.NoError(t, err)
+		_, err = f.todoRepo.CheckTodoAccess(reader, newUserID(), item.ID, share.RoleCommenter)
+		assertForbidden(t, err)
+		_, err = f.todoRepo.CheckTodoAccess(admin, newUserID(), item.ID, share.RoleOwner)
+		require.NoError(t, err)
+
+		// Shares take precedence over the default role, in either direction
+		narrowedID := newUserID()
+		f.share(t, creatorID, share.ResourceTypeTodo, item.ID, narrowedID, share.RoleViewer)
+		_, err = f.todoRepo.CheckTodoAccess(writer, narrowedID, item.ID, share.RoleEditor)
+		assertForbidden(t, err)
+
+		widenedID := newUserID()
+		f.share(t, creatorID, share.ResourceTypeTodo, item.ID, widenedID, share.RoleEditor)
+		_, err = f.todoRepo.CheckTodoAccess(reader, widenedID, item.ID, share.RoleEditor)
+		require.NoError(t, err)
+
+		// Personal sessions reach organization todos through shares only
+		_, err = f.todoRepo.CheckTodoAccess(ctx, creatorID, item.ID, share.RoleViewer)
+		assertNoAccess(t, err)
+		_, err = f.todoRepo.CheckTodoAccess(ctx, widenedID, item.ID, share.RoleEditor)
+		require.NoError(t, err)
+
+		// Other organizations don't see the todo at all
+		_, err = f.todoRepo.CheckTodoAccess(otherOrg, creatorID, item.ID, share.RoleViewer)
+		assertNoAccess(t, err)
+
+		// Organization sessions don't reach personal todos
+		personal := f.createTodo(t, ctx, creatorID, &todo.CreateTodoPayload{Title: "Personal"})
+		_, err = f.todoRepo.CheckTodoAccess(admin, creatorID, personal.ID, share.RoleViewer)
+		assertNoAccess(t, err)
+	})
+}
+
+func TestCategoryRepository_CheckCategoryAccess(t *testing.T) {
+	_, srv, cleanup := tasktest.SetupTest(t)
+	defer cleanup()
+
+	f := &accessFixture{
+		todoRepo:     NewTodoRepository(srv),
+		categoryRepo: NewCategoryRepository(srv),
+		shareRepo:    NewShareRepository(srv),
+	}
+	ctx := context.Background()
+
+	t.Run("personal categories", func(t *testing.T) {
+		ownerID := newUserID()
+		categoryItem := f.createCategory(t, ctx, ownerID)
+
+		_, err := f.categoryRepo.CheckCategoryAccess(ctx, ownerID, categoryItem.ID, share.RoleOwner)
+		require.NoError(t, err)
+
+		_, err = f.categoryRepo.CheckCategoryAccess(ctx, newUserID(), categoryItem.ID, share.RoleViewer)
+		assertNoAccess(t, err)
+
+		editorID := newUserID()
+		f.share(t, ownerID, share.ResourceTypeCategory, categoryItem.ID, editorID, share.RoleEditor)
+		_, err = f.categoryRepo.CheckCategoryAccess(ctx, editorID, categoryItem.ID, share.RoleEditor)
+		require.NoError(t, err)
+		_, err = f.categoryRepo.CheckCategoryAccess(ctx, editorID, categoryItem.ID, share.RoleOwner)
+		assertForbidden(t, err)
+	})
+
+	t.Run("todo shares don't reach the category", func(t *testing.T) {
+		ownerID := newUserID()
+		categoryItem := f.createCategory(t, ctx, ownerID)
+		item := f.createTodo(t, ctx, ownerID, &todo.CreateTodoPayload{Title: "Categorized", CategoryID: &categoryItem.ID})
+
+		editorID := newUserID()
+		f.share(t, ownerID, share.ResourceTypeTodo, item.ID, editorID, share.RoleEditor)
+
+		_, err := f.categoryRepo.CheckCategoryAccess(ctx, editorID, categoryItem.ID, share.RoleViewer)
+		assertNoAccess(t, err)
+	})
+
+	t.Run("organization categories", func(t *testing.T) {
+		orgID := "org_" + uuid.NewString()
+		creatorID := newUserID()
+
+		writer := workspace.WithContext(ctx, workspace.Workspace{
+			OrgID:       orgID,
+			Role:        "org:member",
+			Permissions: []string{workspace.PermissionCategoriesWrite},
+		})
+		reader := workspace.WithContext(ctx, workspace.Workspace{OrgID: orgID, Role: "org:member"})
+		admin := workspace.WithContext(ctx, workspace.Workspace{OrgID: orgID, Role: workspace.AdminRole})
+
+		categoryItem := f.createCategory(t, writer, creatorID)
+
+		_, err := f.categoryRepo.CheckCategoryAccess(writer, creatorID, categoryItem.ID, share.RoleOwner)
+		require.NoError(t, err)
+		_, err = f.categoryRepo.CheckCategoryAccess(writer, newUserID(), categoryItem.ID, share.RoleEditor)
+		require.NoError(t, err)
+		_, err = f.categoryRepo.CheckCategoryAccess(reader, newUserID(), categoryItem.ID, share.RoleEditor)
+		assertForbidden(t, err)
+		_, err = f.categoryRepo.CheckCategoryAccess(admin, newUserID(), categoryItem.ID, share.RoleOwner)
+		require.NoError(t, err)
+
+		// A share narrows a member's default role
+		narrowedID := newUserID()
+		f.share(t, creatorID, share.ResourceTypeCategory, categoryItem.ID, narrowedID, share.RoleViewer)
+		_, err = f.categoryRepo.CheckCategoryAccess(writer, narrowedID, categoryItem.ID, share.RoleEditor)
+		assertForbidden(t, err)
+
+		// Personal sessions reach it through shares only
+		_, err = f.categoryRepo.CheckCategoryAccess(ctx, creatorID, categoryItem.ID, share.RoleViewer)
+		assertNoAccess(t, err)
+		_, err = f.categoryRepo.CheckCategoryAccess(ctx, narrowedID, categoryItem.ID, share.RoleViewer)
+		require.NoError(t, err)
+	})
+}
+
+func TestTodoRepository_GetUsersWithoutTodoAccess(t *testing.T) {
+	_, srv, cleanup := tasktest.SetupTest(t)
+	defer cleanup()
+
+	f := &accessFixture{
+		todoRepo:     NewTodoRepository(srv),
+		categoryRepo: NewCategoryRepository(srv),
+		shareRepo:    NewShareRepository(srv),
+	}
+	ctx := context.Background()
+
+	t.Run("personal todos", func(t *testing.T) {
+		ownerID := newUserID()
+		item := f.createTodo(t, ctx, ownerID, &todo.CreateTodoPayload{Title: "Assigned"})
+
+		collaboratorID := newUserID()
+		f.share(t, ownerID, share.ResourceTypeTodo, item.ID, collaboratorID, share.RoleViewer)
+		strangerID := newUserID()
+
+		without, err := f.todoRepo.GetUsersWithoutTodoAccess(ctx, item.ID, []string{ownerID, collaboratorID, strangerID}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{strangerID}, without)
+	})
+
+	t.Run("organization todos", func(t *testing.T) {
+		member := workspace.WithContext(ctx, workspace.Workspace{OrgID: "org_" + uuid.NewString(), Role: "org:member"})
+		creatorID := newUserID()
+		item := f.createTodo(t, member, creatorID, &todo.CreateTodoPayload{Title: "Assigned"})
+
+		memberIDs := []string{newUserID(), newUserID()}
+		outsiderID := newUserID()
+		collaboratorID := newUserID()
+		f.share(t, creatorID, share.ResourceTypeTodo, item.ID, collaboratorID, share.RoleViewer)
+
+		// Members of the organization can see its todos, outsiders only through a share
+		without, err := f.todoRepo.GetUsersWithoutTodoAccess(member, item.ID,
+			append([]string{outsiderID, collaboratorID}, memberIDs...), memberIDs)
+		require.NoError(t, err)
+		assert.Equal(t, []string{outsiderID}, without)
+	})
+}
+
+func TestShareRepository_AcceptInvitation(t *testing.T) {
+	_, srv, cleanup := tasktest.SetupTest(t)
+	defer cleanup()
+
+	todoRepo := NewTodoRepository(srv)
+	shareRepo := NewShareRepository(srv)
+	ctx := context.Background()
+
+	ownerID := newUserID()
+	item, err := todoRepo.CreateTodo(ctx, ownerID, &todo.CreateTodoPayload{Title: "Invitation"})
+	require.NoError(t, err)
+
+	invite := func(t *testing.T, email string) string {
+		t.Helper()
+
+		token := uuid.NewString()
+		_, err := shareRepo.CreateShare(ctx, ownerID, share.ResourceTypeTodo, item.ID, email, share.RoleEditor, token)
+		require.NoError(t, err)
+		return token
+	}
+
+	t.Run("binds the invitation to its email", func(t *testing.T) {
+		token := invite(t, "invited@example.com")
+		userID := newUserID()
+
+		_, err := shareRepo.AcceptInvitation(ctx, userID, token, []string{"someone-else@example.com"})
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+		_, err = shareRepo.AcceptInvitation(ctx, userID, token, nil)
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+		// Still pending after the failed attempts
+		_, err = shareRepo.GetPendingInvitation(ctx, token)
+		require.NoError(t, err)
+
+		accepted, err := shareRepo.AcceptInvitation(ctx, userID, token, []string{"other@example.com", "invited@example.com"})
+		require.NoError(t, err)
+		assert.Equal(t, share.StatusAccepted, accepted.Status)
+		require.NotNil(t, accepted.UserID)
+		assert.Equal(t, userID, *accepted.UserID)
+
+		_, err = todoRepo.CheckTodoAccess(ctx, userID, item.ID, share.RoleEditor)
+		require.NoError(t, err)
+	})
+
+	t.Run("is accepted once", func(t *testing.T) {
+		token := invite(t, "once@example.com")
+
+		_, err := shareRepo.AcceptInvitation(ctx, newUserID(), token, []string{"once@example.com"})
+		require.NoError(t, err)
+
+		_, err = shareRepo.AcceptInvitation(ctx, newUserID(), token, []string{"once@example.com"})
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+		_, err = shareRepo.GetPendingInvitation(ctx, token)
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+	})
+
+	t.Run("owners can't accept their own invitations", func(t *testing.T) {
+		token := invite(t, "owner@example.com")
+
+		_, err := shareRepo.AcceptInvitation(ctx, ownerID, token, []string{"owner@example.com"})
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+	})
+}
@@ -6,8 +6,12 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/ApoorvYdv/go-tasker/internal/database"
+	"github.com/ApoorvYdv/go-tasker/internal/errs"
 	"github.com/ApoorvYdv/go-tasker/internal/model"
 	"github.com/ApoorvYdv/go-tasker/internal/model/category"
+	"github.com/ApoorvYdv/go-tasker/internal/model/event"
+	"github.com/ApoorvYdv/go-tasker/internal/model/share"
 	"github.com/ApoorvYdv/go-tasker/internal/server"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -28,6 +32,8 @@ func (r *CategoryRepository) CreateCategory(ctx context.Context, userID string,
 		INSERT INTO
 			todo_categories (
 				user_id,
+				organization_id,
+				parent_id,
 				name,
 				color,
 				description
@@ -35,6 +41,8 @@ func (r *CategoryRepository) CreateCategory(ctx context.Context, userID string,
 		VALUES
 			(
 				@user_id,
+				@org_id,
+				@parent_id,
 				@name,
 				@color,
 				@description
@@ -43,19 +51,30 @@ func (r *CategoryRepository) CreateCategory(ctx context.Context, userID string,
 		*
 	`
 
-	rows, err := r.server.DB.Pool.Query(ctx, stmt, pgx.NamedArgs{
-		"user_id":     userID,
-		"name":        payload.Name,
-		"color":       payload.Color,
-		"description": payload.Description,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute create category query for user_id=%s name=%s: %w", userID, payload.Name, err)
-	}
+	var categoryItem category.Category
+	err := r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
+
+		rows, err := q.Query(ctx, stmt, withScope(ctx, pgx.NamedArgs{
+			"user_id":     userID,
+			"parent_id":   payload.ParentID,
+			"name":        payload.Name,
+			"color":       payload.Color,
+			"description": payload.Description,
+		}))
+		if err != nil {
+			return fmt.Errorf("failed to execute create category query for user_id=%s name=%s: %w", userID, payload.Name, err)
+		}
 
-	categoryItem, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[category.Category])
+		categoryItem, err = pgx.CollectOneRow(rows, pgx.RowToStructByName[category.Category])
+		if err != nil {
+			return fmt.Errorf("failed to collect row from table:todo_categories for user_id=%s name=%s: %w", userID, payload.Name, err)
+		}
+
+		return recordEvents(ctx, q, userID, event.CategoryCreated{Category: categoryItem})
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to collect row from table:todo_categories for user_id=%s name=%s: %w", userID, payload.Name, err)
+		return nil, err
 	}
 
 	return &categoryItem, nil
@@ -69,13 +88,13 @@ func (r *CategoryRepository) GetCategoryByID(ctx context.Context, userID string,
 			todo_categories
 		WHERE
 			id=@id
-			AND user_id=@user_id
+			AND category_access_role(id, @user_id, @org_id, @category_role) IS NOT NULL
 	`
 
-	rows, err := r.server.DB.Pool.Query(ctx, stmt, pgx.NamedArgs{
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, withScope(ctx, pgx.NamedArgs{
 		"id":      categoryID,
 		"user_id": userID,
-	})
+	}))
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute get category by id query for category_id=%s user_id=%s: %w", categoryID.String(), userID, err)
 	}
@@ -88,29 +107,212 @@ func (r *CategoryRepository) GetCategoryByID(ctx context.Context, userID string,
 	return &categoryItem, nil
 }
 
-func (r *CategoryRepository) GetCategories(ctx context.Context, userID string,
-	query *category.GetCategoriesQuery,
-) (*model.PaginatedResponse[category.Category], error) {
+type categoryWithAccess struct {
+	category.Category
+	AccessRole share.Role `db:"access_role"`
+}
+
+// CheckCategoryAccess loads a category the user owns or collaborates on and
+// verifies their role is at least minRole.
+func (r *CategoryRepository) CheckCategoryAccess(ctx context.Context, userID string, categoryID uuid.UUID,
+	minRole share.Role,
+) (*category.Category, error) {
 	stmt := `
 		SELECT
-			*
+			c.*,
+			category_access_role(c.id, @user_id, @org_id, @category_role) AS access_role
 		FROM
-			todo_categories
+			todo_categories c
 		WHERE
-			user_id=@user_id
+			c.id=@id
+			AND category_access_role(c.id, @user_id, @org_id, @category_role) IS NOT NULL
 	`
 
-	args := pgx.NamedArgs{
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, withScope(ctx, pgx.NamedArgs{
+		"id":      categoryID,
 		"user_id": userID,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check category access for category_id=%s user_id=%s: %w", categoryID.String(), userID, err)
+	}
+
+	item, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[categoryWithAccess])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect row from table:todo_categories for category_id=%s user_id=%s: %w", categoryID.String(), userID, err)
+	}
+
+	if !item.AccessRole.AtLeast(minRole) {
+		return nil, errs.NewForbiddenError(fmt.Sprintf("this action requires %s access to the category", minRole), false)
+	}
+
+	return &item.Category, nil
+}
+
+// GetCategoryMemberIDs returns the owner and every accepted collaborator of a
+// category, counting shares on its ancestors.
+func (r *CategoryRepository) GetCategoryMemberIDs(ctx context.Context, categoryID uuid.UUID) ([]string, error) {
+	return queryCategoryMemberIDs(ctx, r.server.DB.Querier(ctx), categoryID)
+}
+
+func queryCategoryMemberIDs(ctx context.Context, q database.Querier, categoryID uuid.UUID) ([]string, error) {
+	stmt := `
+		SELECT
+			user_id
+		FROM
+			todo_categories
+		WHERE
+			id=@category_id
+		UNION
+		SELECT
+			user_id
+		FROM
+			todo_shares
+		WHERE
+			category_id IN (
+				SELECT
+					category_ancestor_ids(@category_id)
+			)
+			AND status='accepted'
+	`
+
+	rows, err := q.Query(ctx, stmt, pgx.NamedArgs{
+		"category_id": categoryID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get members for category_id=%s: %w", categoryID.String(), err)
+	}
+
+	memberIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:todo_shares for category_id=%s: %w", categoryID.String(), err)
+	}
+
+	return memberIDs, nil
+}
+
+// categoryScope selects the categories the user can access as visible, and as
+// hidden those that are archived or under an archived category.
+var categoryScope = `
+	visible AS (
+		SELECT
+			*
+		FROM
+			todo_categories c
+		WHERE
+			` + categoryReach("c") + `
+			AND category_access_role(c.id, @user_id, @org_id, @category_role) IS NOT NULL
+	),
+	hidden AS (
+		SELECT
+			id
+		FROM
+			visible
+		WHERE
+			archived_at IS NOT NULL
+		UNION
+		SELECT
+			v.id
+		FROM
+			visible v
+			JOIN hidden h ON v.parent_id=h.id
+	)
+`
+
+// GetCategories lists the categories the user can access with their todo counts,
+// as a page of categories or, in the tree view, a page of root categories with
+// their subcategories nested under them.
+func (r *CategoryRepository) GetCategories(ctx context.Context, userID string,
+	query *category.GetCategoriesQuery,
+) (*model.PaginatedResponse[category.PopulatedCategory], error) {
+	// subtree pairs every category with itself and, if asked for, with each of its
+	// subcategories, whose todos then count towards it
+	stmt := `
+		WITH RECURSIVE
+	` + categoryScope + `,
+			subtree (category_id, member_id) AS (
+				SELECT
+					id,
+					id
+				FROM
+					visible
+				UNION
+				SELECT
+					s.category_id,
+					v.id
+				FROM
+					subtree s
+					JOIN visible v ON v.parent_id=s.member_id
+				WHERE
+					@include_subcategories
+			),
+			counts AS (
+				SELECT
+					s.category_id,
+					COUNT(*) FILTER (
+						WHERE
+							t.status='draft'
+					) AS draft,
+					COUNT(*) FILTER (
+						WHERE
+							t.status='active'
+					) AS active,
+					COUNT(*) FILTER (
+						WHERE
+							t.status='completed'
+					) AS completed,
+					COUNT(*) FILTER (
+						WHERE
+							t.status='archived'
+					) AS archived,
+					COUNT(*) AS total
+				FROM
+					subtree s
+					JOIN todos t ON t.category_id=s.member_id
+				WHERE
+					todo_access_role(t.id, @user_id, @org_id, @todo_role) IS NOT NULL
+				GROUP BY
+					s.category_id
+			)
+		SELECT
+			c.*,
+			jsonb_build_object(
+				'draft',
+				COALESCE(n.draft, 0),
+				'active',
+				COALESCE(n.active, 0),
+				'completed',
+				COALESCE(n.completed, 0),
+				'archived',
+				COALESCE(n.archived, 0),
+				'total',
+				COALESCE(n.total, 0)
+			) AS todo_counts
+		FROM
+			visible c
+			LEFT JOIN counts n ON n.category_id=c.id
+	`
+
+	args := withScope(ctx, pgx.NamedArgs{
+		"user_id":               userID,
+		"include_subcategories": query.IncludeSubcategories != nil && *query.IncludeSubcategories,
+	})
+	conditions := []string{}
+
+	if query.IncludeArchived == nil || !*query.IncludeArchived {
+		conditions = append(conditions, "c.id NOT IN (SELECT id FROM hidden)")
 	}
 
 	// Add search filter if provided
 	if query.Search != nil {
-		stmt += ` AND name ILIKE '%' || @search || '%'`
+		conditions = append(conditions, "c.name ILIKE '%' || @search || '%'")
 		args["search"] = *query.Search
 	}
 
-	// Add sorting
+	if len(conditions) > 0 {
+		stmt += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// Add sorting; in the tree view it orders siblings
 	sortColumn := "name"
 	if query.Sort != nil {
 		sortColumn = *query.Sort
@@ -119,23 +321,27 @@ func (r *CategoryRepository) GetCategories(ctx context.Context, userID string,
 	if query.Order != nil {
 		sortOrder = *query.Order
 	}
-	stmt += fmt.Sprintf(" ORDER BY %s %s", sortColumn, sortOrder)
+	stmt += fmt.Sprintf(" ORDER BY c.%s %s", sortColumn, sortOrder)
 
-	// Add pagination
-	stmt += ` LIMIT @limit OFFSET @offset`
-	args["limit"] = *query.Limit
-	args["offset"] = (*query.Page - 1) * (*query.Limit)
+	tree := query.View != nil && *query.View == "tree"
 
-	rows, err := r.server.DB.Pool.Query(ctx, stmt, args)
+	// Add pagination; the tree view paginates its roots once it is built
+	if !tree {
+		stmt += ` LIMIT @limit OFFSET @offset`
+		args["limit"] = *query.Limit
+		args["offset"] = (*query.Page - 1) * (*query.Limit)
+	}
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, args)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute get categories query for user_id=%s: %w", userID, err)
 	}
 
-	categories, err := pgx.CollectRows(rows, pgx.RowToStructByName[category.Category])
+	categories, err := pgx.CollectRows(rows, pgx.RowToStructByName[category.PopulatedCategory])
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return &model.PaginatedResponse[category.Category]{
-				Data:       []category.Category{},
+			return &model.PaginatedResponse[category.PopulatedCategory]{
+				Data:       []category.PopulatedCategory{},
 				Page:       *query.Page,
 				Limit:      *query.Limit,
 				Total:      0,
@@ -145,32 +351,34 @@ func (r *CategoryRepository) GetCategories(ctx context.Context, userID string,
 		return nil, fmt.Errorf("failed to collect rows from table:todo_categories for user_id=%s: %w", userID, err)
 	}
 
-	// Get total count
-	countStmt := `
-		SELECT
-			COUNT(*)
-		FROM
-			todo_categories
-		WHERE
-			user_id=@user_id
-	`
-
-	countArgs := pgx.NamedArgs{
-		"user_id": userID,
-	}
-
-	if query.Search != nil {
-		countStmt += ` AND name ILIKE '%' || @search || '%'`
-		countArgs["search"] = *query.Search
-	}
-
 	var total int
-	err = r.server.DB.Pool.QueryRow(ctx, countStmt, countArgs).Scan(&total)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get total count of categories for user_id=%s: %w", userID, err)
+	if tree {
+		roots := category.Nest(categories)
+		total = len(roots)
+
+		offset := min((*query.Page-1)*(*query.Limit), total)
+		categories = roots[offset:min(offset+*query.Limit, total)]
+	} else {
+		// Get total count
+		countStmt := `
+			WITH RECURSIVE
+		` + categoryScope + `
+			SELECT
+				COUNT(*)
+			FROM
+				visible c
+		`
+		if len(conditions) > 0 {
+			countStmt += " WHERE " + strings.Join(conditions, " AND ")
+		}
+
+		err = r.server.DB.Querier(ctx).QueryRow(ctx, countStmt, args).Scan(&total)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get total count of categories for user_id=%s: %w", userID, err)
+		}
 	}
 
-	return &model.PaginatedResponse[category.Category]{
+	return &model.PaginatedResponse[category.PopulatedCategory]{
 		Data:       categories,
 		Page:       *query.Page,
 		Limit:      *query.Limit,
@@ -179,27 +387,77 @@ func (r *CategoryRepository) GetCategories(ctx context.Context, userID string,
 	}, nil
 }
 
+// IsInCategorySubtree reports whether categoryID is rootID or one of its
+// subcategories at any depth.
+func (r *CategoryRepository) IsInCategorySubtree(ctx context.Context, rootID uuid.UUID, categoryID uuid.UUID) (bool, error) {
+	var found bool
+	err := r.server.DB.Querier(ctx).QueryRow(ctx, `
+		WITH RECURSIVE
+			subtree AS (
+				SELECT
+					@root_id::UUID AS id
+				UNION
+				SELECT
+					c.id
+				FROM
+					todo_categories c
+					JOIN subtree s ON c.parent_id=s.id
+			)
+		SELECT
+			EXISTS (
+				SELECT
+					1
+				FROM
+					subtree
+				WHERE
+					id=@category_id
+			)
+	`, pgx.NamedArgs{
+		"root_id":     rootID,
+		"category_id": categoryID,
+	}).Scan(&found)
+	if err != nil {
+		return false, fmt.Errorf("failed to check subtree of category_id=%s for category_id=%s: %w", rootID.String(),
+			categoryID.String(), err)
+	}
+
+	return found, nil
+}
+
 func (r *CategoryRepository) UpdateCategory(ctx context.Context, userID string,
 	categoryID uuid.UUID, payload *category.UpdateCategoryPayload,
 ) (*category.Category, error) {
 	stmt := `UPDATE todo_categories SET `
-	args := pgx.NamedArgs{
+	args := withScope(ctx, pgx.NamedArgs{
 		"id":      categoryID,
 		"user_id": userID,
-	}
+	})
 	setClauses := []string{}
+	changed := []string{}
 
 	if payload.Name != nil {
 		setClauses = append(setClauses, "name = @name")
 		args["name"] = *payload.Name
+		changed = append(changed, "name")
 	}
 	if payload.Color != nil {
 		setClauses = append(setClauses, "color = @color")
 		args["color"] = *payload.Color
+		changed = append(changed, "color")
 	}
 	if payload.Description != nil {
 		setClauses = append(setClauses, "description = @description")
 		args["description"] = *payload.Description
+		changed = append(changed, "description")
+	}
+	if payload.ParentID != nil {
+		setClauses = append(setClauses, "parent_id = @parent_id")
+		args["parent_id"] = *payload.ParentID
+		changed = append(changed, "parentId")
+	}
+	if payload.MoveToRoot {
+		setClauses = append(setClauses, "parent_id = NULL")
+		changed = append(changed, "parentId")
 	}
 
 	if len(setClauses) == 0 {
@@ -207,36 +465,131 @@ func (r *CategoryRepository) UpdateCategory(ctx context.Context, userID string,
 	}
 
 	stmt += strings.Join(setClauses, ", ")
-	stmt += ` WHERE id = @id AND user_id = @user_id RETURNING *`
+	stmt += ` WHERE id = @id AND category_access_role(id, @user_id, @org_id, @category_role) = 'owner' RETURNING *`
 
-	rows, err := r.server.DB.Pool.Query(ctx, stmt, args)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute update category query for category_id=%s user_id=%s: %w", categoryID.String(), userID, err)
-	}
+	var categoryItem category.Category
+	err := r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
 
-	categoryItem, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[category.Category])
+		rows, err := q.Query(ctx, stmt, args)
+		if err != nil {
+			return fmt.Errorf("failed to execute update category query for category_id=%s user_id=%s: %w", categoryID.String(), userID, err)
+		}
+
+		categoryItem, err = pgx.CollectOneRow(rows, pgx.RowToStructByName[category.Category])
+		if err != nil {
+			return fmt.Errorf("failed to collect row from table:todo_categories for category_id=%s user_id=%s: %w", categoryID.String(), userID, err)
+		}
+
+		return recordEvents(ctx, q, userID, event.CategoryUpdated{Category: categoryItem, Changed: changed})
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to collect row from table:todo_categories for category_id=%s user_id=%s: %w", categoryID.String(), userID, err)
+		return nil, err
 	}
 
 	return &categoryItem, nil
 }
 
 func (r *CategoryRepository) DeleteCategory(ctx context.Context, userID string, categoryID uuid.UUID) error {
-	result, err := r.server.DB.Pool.Exec(ctx, `
-		DELETE FROM todo_categories
-		WHERE id = @id AND user_id = @user_id
-	`, pgx.NamedArgs{
-		"id":      categoryID,
-		"user_id": userID,
+	return r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
+
+		// Resolve collaborators before the shares are deleted along with the category
+		memberIDs, err := queryCategoryMemberIDs(ctx, q, categoryID)
+		if err != nil {
+			return err
+		}
+
+		// Move subcategories up to the parent of the category being deleted
+		rows, err := q.Query(ctx, `
+			UPDATE todo_categories child
+			SET
+				parent_id=c.parent_id
+			FROM
+				todo_categories c
+			WHERE
+				child.parent_id=c.id
+				AND c.id=@id
+				AND category_access_role(c.id, @user_id, @org_id, @category_role)='owner'
+			RETURNING
+				child.*
+		`, withScope(ctx, pgx.NamedArgs{
+			"id":      categoryID,
+			"user_id": userID,
+		}))
+		if err != nil {
+			return fmt.Errorf("failed to move subcategories of category_id=%s: %w", categoryID.String(), err)
+		}
+
+		children, err := pgx.CollectRows(rows, pgx.RowToStructByName[category.Category])
+		if err != nil {
+			return fmt.Errorf("failed to collect rows from table:todo_categories for parent category_id=%s: %w", categoryID.String(), err)
+		}
+
+		result, err := q.Exec(ctx, `
+			DELETE FROM todo_categories
+			WHERE id = @id AND category_access_role(id, @user_id, @org_id, @category_role) = 'owner'
+		`, withScope(ctx, pgx.NamedArgs{
+			"id":      categoryID,
+			"user_id": userID,
+		}))
+		if err != nil {
+			return fmt.Errorf("failed to delete category: %w", err)
+		}
+
+		if result.RowsAffected() == 0 {
+			return fmt.Errorf("category not found")
+		}
+
+		payloads := make([]event.Payload, 0, len(children)+1)
+		for _, child := range children {
+			payloads = append(payloads, event.CategoryUpdated{Category: child, Changed: []string{"parentId"}})
+		}
+		payloads = append(payloads, event.CategoryDeleted{CategoryID: categoryID, MemberIDs: memberIDs})
+
+		return recordEvents(ctx, q, userID, payloads...)
 	})
-	if err != nil {
-		return fmt.Errorf("failed to delete category: %w", err)
-	}
+}
 
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("category not found")
+// SetCategoryArchived archives or unarchives a category the user owns. Its todos
+// and subcategories are left as they are.
+func (r *CategoryRepository) SetCategoryArchived(ctx context.Context, userID string, categoryID uuid.UUID,
+	archived bool,
+) (*category.Category, error) {
+	var categoryItem category.Category
+	err := r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
+
+		rows, err := q.Query(ctx, `
+			UPDATE todo_categories
+			SET
+				archived_at=CASE
+					WHEN @archived THEN COALESCE(archived_at, CURRENT_TIMESTAMP)
+				END
+			WHERE
+				id=@id
+				AND category_access_role(id, @user_id, @org_id, @category_role)='owner'
+			RETURNING
+				*
+		`, withScope(ctx, pgx.NamedArgs{
+			"id":       categoryID,
+			"user_id":  userID,
+			"archived": archived,
+		}))
+		if err != nil {
+			return fmt.Errorf("failed to execute archive category query for category_id=%s user_id=%s: %w", categoryID.String(), userID, err)
+		}
+
+		categoryItem, err = pgx.CollectOneRow(rows, pgx.RowToStructByName[category.Category])
+		if err != nil {
+			return fmt.Errorf("failed to collect row from table:todo_categories for category_id=%s user_id=%s: %w", categoryID.String(), userID, err)
+		}
+
+		return recordEvents(ctx, q, userID, event.CategoryUpdated{Category: categoryItem, Changed: []string{"archivedAt"}})
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return &categoryItem, nil
 }
@@ -0,0 +1,311 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ApoorvYdv/go-tasker/internal/model"
+	"github.com/ApoorvYdv/go-tasker/internal/model/category"
+	"github.com/ApoorvYdv/go-tasker/internal/model/share"
+	"github.com/ApoorvYdv/go-tasker/internal/model/todo"
+	tasktest "github.com/ApoorvYdv/go-tasker/internal/testing"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCategoryRepository_Tree(t *testing.T) {
+	_, srv, cleanup := tasktest.SetupTest(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	todoRepo := NewTodoRepository(srv)
+	categoryRepo := NewCategoryRepository(srv)
+	shareRepo := NewShareRepository(srv)
+
+	createCategory := func(t *testing.T, userID string, name string, parentID *uuid.UUID) *category.Category {
+		t.Helper()
+
+		categoryItem, err := categoryRepo.CreateCategory(ctx, userID, &category.CreateCategoryPayload{
+			Name:     name,
+			ParentID: parentID,
+		})
+		require.NoError(t, err)
+		return categoryItem
+	}
+
+	// createTodos files a todo in the category for each given status
+	createTodos := func(t *testing.T, userID string, categoryID uuid.UUID, statuses ...todo.Status) {
+		t.Helper()
+
+		for _, status := range statuses {
+			todoItem, err := todoRepo.CreateTodo(ctx, userID, &todo.CreateTodoPayload{Title: "Counted", CategoryID: &categoryID})
+			require.NoError(t, err)
+
+			if status != todo.StatusDraft {
+				_, err := todoRepo.UpdateTodo(ctx, userID, &todo.UpdateTodoPayload{ID: todoItem.ID, Status: &status})
+				require.NoError(t, err)
+			}
+		}
+	}
+
+	// shareCategory shares the category with a new collaborator, who accepts
+	shareCategory := func(t *testing.T, ownerID string, categoryID uuid.UUID, role share.Role) string {
+		t.Helper()
+
+		collaboratorID := newUserID()
+		email := collaboratorID + "@example.com"
+		token := uuid.NewString()
+		_, err := shareRepo.CreateShare(ctx, ownerID, share.ResourceTypeCategory, categoryID, email, role, token)
+		require.NoError(t, err)
+		_, err = shareRepo.AcceptInvitation(ctx, collaboratorID, token, []string{email})
+		require.NoError(t, err)
+		return collaboratorID
+	}
+
+	// get lists categories sorted by name
+	get := func(t *testing.T, userID string, query category.GetCategoriesQuery) *model.PaginatedResponse[category.PopulatedCategory] {
+		t.Helper()
+
+		sort, order := "name", "asc"
+		query.Sort, query.Order = &sort, &order
+		require.NoError(t, query.Validate())
+
+		result, err := categoryRepo.GetCategories(ctx, userID, &query)
+		require.NoError(t, err)
+		return result
+	}
+
+	list := func(t *testing.T, userID string, query category.GetCategoriesQuery) []category.PopulatedCategory {
+		t.Helper()
+		return get(t, userID, query).Data
+	}
+
+	byName := func(categories []category.PopulatedCategory) map[string]category.PopulatedCategory {
+		named := make(map[string]category.PopulatedCategory, len(categories))
+		for _, c := range categories {
+			named[c.Name] = c
+		}
+		return named
+	}
+
+	names := func(categories []category.PopulatedCategory) []string {
+		result := make([]string, 0, len(categories))
+		for _, c := range categories {
+			result = append(result, c.Name)
+		}
+		return result
+	}
+
+	boolPtr := func(b bool) *bool { return &b }
+	stringPtr := func(s string) *string { return &s }
+
+	// newTree creates Alpha > Beta > Gamma and a separate root Delta
+	type tree struct {
+		alpha, beta, gamma, delta *category.Category
+	}
+	newTree := func(t *testing.T, userID string) tree {
+		t.Helper()
+
+		alpha := createCategory(t, userID, "Alpha", nil)
+		beta := createCategory(t, userID, "Beta", &alpha.ID)
+		gamma := createCategory(t, userID, "Gamma", &beta.ID)
+		delta := createCategory(t, userID, "Delta", nil)
+		return tree{alpha: alpha, beta: beta, gamma: gamma, delta: delta}
+	}
+
+	t.Run("tree view nests subcategories and paginates roots", func(t *testing.T) {
+		userID := newUserID()
+		newTree(t, userID)
+
+		roots := list(t, userID, category.GetCategoriesQuery{View: stringPtr("tree")})
+		require.Equal(t, []string{"Alpha", "Delta"}, names(roots))
+		require.Equal(t, []string{"Beta"}, names(roots[0].Children))
+		require.Equal(t, []string{"Gamma"}, names(roots[0].Children[0].Children))
+		assert.Empty(t, roots[0].Children[0].Children[0].Children)
+		assert.Empty(t, roots[1].Children)
+
+		page, limit := 2, 1
+		result := get(t, userID, category.GetCategoriesQuery{View: stringPtr("tree"), Page: &page, Limit: &limit})
+		assert.Equal(t, 2, result.Total)
+		assert.Equal(t, 2, result.TotalPages)
+		assert.Equal(t, []string{"Delta"}, names(result.Data))
+
+		// The list view keeps categories flat
+		assert.Equal(t, []string{"Alpha", "Beta", "Delta", "Gamma"}, names(list(t, userID, category.GetCategoriesQuery{})))
+	})
+
+	t.Run("counts todos by status", func(t *testing.T) {
+		userID := newUserID()
+		c := newTree(t, userID)
+		createTodos(t, userID, c.alpha.ID, todo.StatusDraft)
+		createTodos(t, userID, c.beta.ID, todo.StatusActive, todo.StatusCompleted)
+		createTodos(t, userID, c.gamma.ID, todo.StatusDraft, todo.StatusArchived)
+
+		own := byName(list(t, userID, category.GetCategoriesQuery{}))
+		assert.Equal(t, category.TodoCounts{Draft: 1, Total: 1}, own["Alpha"].TodoCounts)
+		assert.Equal(t, category.TodoCounts{Active: 1, Completed: 1, Total: 2}, own["Beta"].TodoCounts)
+		assert.Equal(t, category.TodoCounts{Draft: 1, Archived: 1, Total: 2}, own["Gamma"].TodoCounts)
+		assert.Equal(t, category.TodoCounts{}, own["Delta"].TodoCounts)
+
+		// Subcategories at any depth count towards their ancestors
+		withSubcategories := byName(list(t, userID, category.GetCategoriesQuery{IncludeSubcategories: boolPtr(true)}))
+		assert.Equal(t, category.TodoCounts{Draft: 2, Active: 1, Completed: 1, Archived: 1, Total: 5},
+			withSubcategories["Alpha"].TodoCounts)
+		assert.Equal(t, category.TodoCounts{Draft: 1, Active: 1, Completed: 1, Archived: 1, Total: 4},
+			withSubcategories["Beta"].TodoCounts)
+		assert.Equal(t, own["Gamma"].TodoCounts, withSubcategories["Gamma"].TodoCounts)
+	})
+
+	t.Run("counts only the todos the user can access", func(t *testing.T) {
+		ownerID := newUserID()
+		parent := createCategory(t, ownerID, "Parent", nil)
+		child := createCategory(t, ownerID, "Child", &parent.ID)
+		createTodos(t, ownerID, parent.ID, todo.StatusDraft)
+		createTodos(t, ownerID, child.ID, todo.StatusActive, todo.StatusActive)
+
+		// The collaborator sees the child category but not its parent
+		collaboratorID := shareCategory(t, ownerID, child.ID, share.RoleViewer)
+
+		shared := list(t, collaboratorID, category.GetCategoriesQuery{IncludeSubcategories: boolPtr(true)})
+		require.Equal(t, []string{"Child"}, names(shared))
+		assert.Equal(t, category.TodoCounts{Active: 2, Total: 2}, shared[0].TodoCounts)
+
+		// Its parent missing, the tree view lists it as a root
+		roots := list(t, collaboratorID, category.GetCategoriesQuery{View: stringPtr("tree")})
+		assert.Equal(t, []string{"Child"}, names(roots))
+	})
+
+	t.Run("a share on a parent category covers its subtree", func(t *testing.T) {
+		ownerID := newUserID()
+		c := newTree(t, ownerID)
+		createTodos(t, ownerID, c.beta.ID, todo.StatusActive)
+		createTodos(t, ownerID, c.gamma.ID, todo.StatusDraft, todo.StatusCompleted)
+		createTodos(t, ownerID, c.delta.ID, todo.StatusActive)
+
+		collaboratorID := shareCategory(t, ownerID, c.beta.ID, share.RoleCommenter)
+
+		// Subcategories at any depth inherit the share's role, and nothing above it
+		for _, tc := range []struct {
+			name     string
+			category *category.Category
+		}{
+			{"shared", c.beta},
+			{"subcategory", c.gamma},
+		} {
+			_, err := categoryRepo.CheckCategoryAccess(ctx, collaboratorID, tc.category.ID, share.RoleCommenter)
+			require.NoError(t, err, tc.name)
+			_, err = categoryRepo.CheckCategoryAccess(ctx, collaboratorID, tc.category.ID, share.RoleEditor)
+			assertForbidden(t, err)
+		}
+		_, err := categoryRepo.CheckCategoryAccess(ctx, collaboratorID, c.alpha.ID, share.RoleViewer)
+		assertNoAccess(t, err)
+
+		// So do the todos filed under them, and their subtasks
+		item, err := todoRepo.CreateTodo(ctx, ownerID, &todo.CreateTodoPayload{Title: "Nested", CategoryID: &c.gamma.ID})
+		require.NoError(t, err)
+		subtask, err := todoRepo.CreateTodo(ctx, ownerID, &todo.CreateTodoPayload{Title: "Subtask", ParentTodoID: &item.ID})
+		require.NoError(t, err)
+		for _, id := range []uuid.UUID{item.ID, subtask.ID} {
+			_, err = todoRepo.CheckTodoAccess(ctx, collaboratorID, id, share.RoleCommenter)
+			require.NoError(t, err)
+		}
+
+		members, err := todoRepo.GetTodoMemberIDs(ctx, item.ID)
+		require.NoError(t, err)
+		assert.Contains(t, members, collaboratorID)
+		members, err = categoryRepo.GetCategoryMemberIDs(ctx, c.gamma.ID)
+		require.NoError(t, err)
+		assert.Contains(t, members, collaboratorID)
+
+		query := &todo.GetTodosQuery{}
+		require.NoError(t, query.Validate())
+		todos, err := todoRepo.GetTodos(ctx, collaboratorID, query)
+		require.NoError(t, err)
+		assert.Equal(t, 5, todos.Total)
+
+		// Listings and counts take in the whole subtree
+		shared := byName(list(t, collaboratorID, category.GetCategoriesQuery{IncludeSubcategories: boolPtr(true)}))
+		require.Len(t, shared, 2)
+		assert.Equal(t, category.TodoCounts{Draft: 2, Active: 1, Completed: 1, Total: 4}, shared["Beta"].TodoCounts)
+		assert.Equal(t, category.TodoCounts{Draft: 2, Completed: 1, Total: 3}, shared["Gamma"].TodoCounts)
+
+		roots := list(t, collaboratorID, category.GetCategoriesQuery{View: stringPtr("tree")})
+		require.Equal(t, []string{"Beta"}, names(roots))
+		assert.Equal(t, []string{"Gamma"}, names(roots[0].Children))
+	})
+
+	t.Run("archived categories hide their subtree", func(t *testing.T) {
+		userID := newUserID()
+		c := newTree(t, userID)
+		createTodos(t, userID, c.gamma.ID, todo.StatusActive)
+
+		archived, err := categoryRepo.SetCategoryArchived(ctx, userID, c.beta.ID, true)
+		require.NoError(t, err)
+		assert.True(t, archived.IsArchived())
+
+		assert.Equal(t, []string{"Alpha", "Delta"}, names(list(t, userID, category.GetCategoriesQuery{})))
+
+		// Archiving leaves the todos alone, so they still count towards the ancestors
+		visible := byName(list(t, userID, category.GetCategoriesQuery{IncludeSubcategories: boolPtr(true)}))
+		assert.Equal(t, category.TodoCounts{Active: 1, Total: 1}, visible["Alpha"].TodoCounts)
+
+		// They're listed when asked for, with their todos kept
+		all := byName(list(t, userID, category.GetCategoriesQuery{IncludeArchived: boolPtr(true)}))
+		require.Len(t, all, 4)
+		assert.NotNil(t, all["Beta"].ArchivedAt)
+		assert.Nil(t, all["Gamma"].ArchivedAt)
+		assert.Equal(t, category.TodoCounts{Active: 1, Total: 1}, all["Gamma"].TodoCounts)
+
+		unarchived, err := categoryRepo.SetCategoryArchived(ctx, userID, c.beta.ID, false)
+		require.NoError(t, err)
+		assert.False(t, unarchived.IsArchived())
+		assert.Equal(t, []string{"Alpha", "Beta", "Delta", "Gamma"}, names(list(t, userID, category.GetCategoriesQuery{})))
+	})
+
+	t.Run("subtree membership", func(t *testing.T) {
+		c := newTree(t, newUserID())
+
+		for _, tc := range []struct {
+			name     string
+			root     *category.Category
+			member   *category.Category
+			expected bool
+		}{
+			{"itself", c.alpha, c.alpha, true},
+			{"child", c.alpha, c.beta, true},
+			{"grandchild", c.alpha, c.gamma, true},
+			{"ancestor", c.gamma, c.alpha, false},
+			{"other tree", c.delta, c.beta, false},
+		} {
+			found, err := categoryRepo.IsInCategorySubtree(ctx, tc.root.ID, tc.member.ID)
+			require.NoError(t, err, tc.name)
+			assert.Equal(t, tc.expected, found, tc.name)
+		}
+	})
+
+	t.Run("deleting a category moves its subcategories up", func(t *testing.T) {
+		userID := newUserID()
+		c := newTree(t, userID)
+
+		// Only owners delete categories
+		err := categoryRepo.DeleteCategory(ctx, newUserID(), c.beta.ID)
+		require.Error(t, err)
+
+		require.NoError(t, categoryRepo.DeleteCategory(ctx, userID, c.beta.ID))
+
+		gamma, err := categoryRepo.CheckCategoryAccess(ctx, userID, c.gamma.ID, share.RoleOwner)
+		require.NoError(t, err)
+		require.NotNil(t, gamma.ParentID)
+		assert.Equal(t, c.alpha.ID, *gamma.ParentID)
+
+		roots := list(t, userID, category.GetCategoriesQuery{View: stringPtr("tree")})
+		require.Equal(t, []string{"Alpha", "Delta"}, names(roots))
+		assert.Equal(t, []string{"Gamma"}, names(roots[0].Children))
+
+		// Deleting a root makes its subcategories roots
+		require.NoError(t, categoryRepo.DeleteCategory(ctx, userID, c.alpha.ID))
+		roots = list(t, userID, category.GetCategoriesQuery{View: stringPtr("tree")})
+		assert.Equal(t, []string{"Delta", "Gamma"}, names(roots))
+	})
+}
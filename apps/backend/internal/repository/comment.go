@@ -2,14 +2,61 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/ApoorvYdv/go-tasker/internal/model/comment"
+	"github.com/ApoorvYdv/go-tasker/internal/model/event"
 	"github.com/ApoorvYdv/go-tasker/internal/server"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
+// commentReactionsSelect aggregates the reactions on comment "com" per emoji, in the
+// order each emoji was first used, flagging the ones the requesting user added.
+const commentReactionsSelect = `
+	COALESCE(
+		(
+			SELECT
+				jsonb_agg(
+					jsonb_build_object(
+						'emoji',
+						r.emoji,
+						'count',
+						r.count,
+						'reactedByMe',
+						r.reacted_by_me
+					)
+					ORDER BY
+						r.first_reacted_at ASC
+				)
+			FROM
+				(
+					SELECT
+						emoji,
+						COUNT(*) AS count,
+						BOOL_OR(user_id=@user_id) AS reacted_by_me,
+						MIN(created_at) AS first_reacted_at
+					FROM
+						comment_reactions
+					WHERE
+						comment_id=com.id
+					GROUP BY
+						emoji
+				) r
+		),
+		'[]'::JSONB
+	)
+`
+
+// threadComments renders a flat list of comments and nests it into threads.
+func threadComments(comments []comment.Comment) []comment.Comment {
+	for i := range comments {
+		comments[i].RenderContent()
+	}
+	return comment.Nest(comments)
+}
+
 type CommentRepository struct {
 	server *server.Server
 }
@@ -18,57 +65,94 @@ func NewCommentRepository(server *server.Server) *CommentRepository {
 	return &CommentRepository{server: server}
 }
 
+// AddComment inserts a comment. parent is the comment being replied to, if any.
 func (r *CommentRepository) AddComment(ctx context.Context, userID string, todoID uuid.UUID,
-	payload *comment.AddCommentPayload,
+	payload *comment.AddCommentPayload, parent *comment.Comment, mentions []comment.Mention,
 ) (*comment.Comment, error) {
 	stmt := `
 		INSERT INTO
-			todo_comments (
+			todo_comments AS com (
 				todo_id,
 				user_id,
-				content
+				content,
+				mentions,
+				parent_comment_id,
+				depth
 			)
 		VALUES
 			(
 				@todo_id,
 				@user_id,
-				@content
+				@content,
+				@mentions,
+				@parent_comment_id,
+				@depth
 			)
 		RETURNING
-		*
+			com.*,
+			'[]'::JSONB AS reactions
 	`
 
-	rows, err := r.server.DB.Pool.Query(ctx, stmt, pgx.NamedArgs{
-		"todo_id": todoID,
-		"user_id": userID,
-		"content": payload.Content,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute add comment query for todo_id=%s user_id=%s: %w", todoID.String(), userID, err)
+	args := pgx.NamedArgs{
+		"todo_id":           todoID,
+		"user_id":           userID,
+		"content":           payload.Content,
+		"mentions":          mentions,
+		"parent_comment_id": nil,
+		"depth":             0,
+	}
+	if parent != nil {
+		parentID, depth := parent.ReplyParent()
+		args["parent_comment_id"] = parentID
+		args["depth"] = depth
 	}
 
-	commentItem, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[comment.Comment])
+	var commentItem comment.Comment
+	err := r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
+
+		rows, err := q.Query(ctx, stmt, args)
+		if err != nil {
+			return fmt.Errorf("failed to execute add comment query for todo_id=%s user_id=%s: %w", todoID.String(), userID, err)
+		}
+
+		commentItem, err = pgx.CollectOneRow(rows, pgx.RowToStructByName[comment.Comment])
+		if err != nil {
+			return fmt.Errorf("failed to collect row from table:todo_comments for todo_id=%s user_id=%s: %w", todoID.String(), userID, err)
+		}
+
+		commentItem.RenderContent()
+
+		added := event.CommentAdded{Comment: commentItem}
+		if parent != nil {
+			added.ParentUserID = &parent.UserID
+		}
+
+		return recordEvents(ctx, q, userID, added)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to collect row from table:todo_comments for todo_id=%s user_id=%s: %w", todoID.String(), userID, err)
+		return nil, err
 	}
 
 	return &commentItem, nil
 }
 
+// GetCommentsByTodoID returns the comments of a todo as nested threads.
 func (r *CommentRepository) GetCommentsByTodoID(ctx context.Context, userID string, todoID uuid.UUID) ([]comment.Comment, error) {
 	stmt := `
 		SELECT
-			*
+			com.*,
+			` + commentReactionsSelect + ` AS reactions
 		FROM
-			todo_comments
+			todo_comments com
 		WHERE
-			todo_id=@todo_id
-			AND user_id=@user_id
+			com.todo_id=@todo_id
 		ORDER BY
-			created_at ASC
+			com.created_at ASC
 	`
 
-	rows, err := r.server.DB.Pool.Query(ctx, stmt, pgx.NamedArgs{
+	// Access to the todo is checked by the caller; every collaborator's comments are returned
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
 		"todo_id": todoID,
 		"user_id": userID,
 	})
@@ -81,21 +165,24 @@ func (r *CommentRepository) GetCommentsByTodoID(ctx context.Context, userID stri
 		return nil, fmt.Errorf("failed to collect rows from table:todo_comments for todo_id=%s user_id=%s: %w", todoID.String(), userID, err)
 	}
 
-	return comments, nil
+	return threadComments(comments), nil
 }
 
+// GetCommentByID returns a live comment written by the user.
 func (r *CommentRepository) GetCommentByID(ctx context.Context, userID string, commentID uuid.UUID) (*comment.Comment, error) {
 	stmt := `
 		SELECT
-			*
+			com.*,
+			` + commentReactionsSelect + ` AS reactions
 		FROM
-			todo_comments
+			todo_comments com
 		WHERE
-			id=@id
-			AND user_id=@user_id
+			com.id=@id
+			AND com.user_id=@user_id
+			AND com.deleted_at IS NULL
 	`
 
-	rows, err := r.server.DB.Pool.Query(ctx, stmt, pgx.NamedArgs{
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
 		"id":      commentID,
 		"user_id": userID,
 	})
@@ -108,54 +195,374 @@ func (r *CommentRepository) GetCommentByID(ctx context.Context, userID string, c
 		return nil, fmt.Errorf("failed to collect row from table:todo_comments for comment_id=%s user_id=%s: %w", commentID.String(), userID, err)
 	}
 
+	commentItem.RenderContent()
+
 	return &commentItem, nil
 }
 
-func (r *CommentRepository) UpdateComment(ctx context.Context, userID string, commentID uuid.UUID, content string) (*comment.Comment, error) {
+// GetComment returns a live comment by any author. Callers check access to its todo.
+func (r *CommentRepository) GetComment(ctx context.Context, userID string, commentID uuid.UUID) (*comment.Comment, error) {
 	stmt := `
-		UPDATE
-			todo_comments
-		SET
-			content=@content
+		SELECT
+			com.*,
+			` + commentReactionsSelect + ` AS reactions
+		FROM
+			todo_comments com
 		WHERE
-			id=@id
-			AND user_id=@user_id
-		RETURNING
-		*
+			com.id=@id
+			AND com.deleted_at IS NULL
 	`
 
-	rows, err := r.server.DB.Pool.Query(ctx, stmt, pgx.NamedArgs{
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
 		"id":      commentID,
 		"user_id": userID,
-		"content": content,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute update comment query for comment_id=%s user_id=%s: %w", commentID.String(), userID, err)
+		return nil, fmt.Errorf("failed to execute get comment query for comment_id=%s: %w", commentID.String(), err)
 	}
 
 	commentItem, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[comment.Comment])
 	if err != nil {
-		return nil, fmt.Errorf("failed to collect row from table:todo_comments for comment_id=%s user_id=%s: %w", commentID.String(), userID, err)
+		return nil, fmt.Errorf("failed to collect row from table:todo_comments for comment_id=%s: %w", commentID.String(), err)
+	}
+
+	commentItem.RenderContent()
+
+	return &commentItem, nil
+}
+
+// UpdateComment replaces a comment's content and records the edit as a revision. The
+// original content is recorded as the first revision on the first edit.
+func (r *CommentRepository) UpdateComment(ctx context.Context, userID string, commentID uuid.UUID, content string,
+	mentions []comment.Mention,
+) (*comment.Comment, error) {
+	args := pgx.NamedArgs{
+		"id":       commentID,
+		"user_id":  userID,
+		"content":  content,
+		"mentions": mentions,
+	}
+
+	var commentItem comment.Comment
+	err := r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
+
+		// Lock the comment while reading who it mentioned before the edit
+		var previousMentions []comment.Mention
+		err := q.QueryRow(ctx, `
+			SELECT
+				mentions
+			FROM
+				todo_comments
+			WHERE
+				id=@id
+				AND user_id=@user_id
+				AND deleted_at IS NULL
+			FOR UPDATE
+		`, args).Scan(&previousMentions)
+		if err != nil {
+			return fmt.Errorf("failed to get mentions for comment_id=%s: %w", commentID.String(), err)
+		}
+
+		_, err = q.Exec(ctx, `
+			INSERT INTO
+				comment_revisions (comment_id, user_id, content, created_at)
+			SELECT
+				id,
+				user_id,
+				content,
+				created_at
+			FROM
+				todo_comments
+			WHERE
+				id=@id
+				AND user_id=@user_id
+				AND deleted_at IS NULL
+				AND NOT EXISTS (
+					SELECT
+						1
+					FROM
+						comment_revisions
+					WHERE
+						comment_id=@id
+				)
+		`, args)
+		if err != nil {
+			return fmt.Errorf("failed to record original revision for comment_id=%s: %w", commentID.String(), err)
+		}
+
+		stmt := `
+			UPDATE
+				todo_comments com
+			SET
+				content=@content,
+				mentions=@mentions,
+				edited_at=CURRENT_TIMESTAMP
+			WHERE
+				com.id=@id
+				AND com.user_id=@user_id
+				AND com.deleted_at IS NULL
+			RETURNING
+				com.*,
+				` + commentReactionsSelect + ` AS reactions
+		`
+
+		rows, err := q.Query(ctx, stmt, args)
+		if err != nil {
+			return fmt.Errorf("failed to execute update comment query for comment_id=%s user_id=%s: %w", commentID.String(), userID, err)
+		}
+
+		commentItem, err = pgx.CollectOneRow(rows, pgx.RowToStructByName[comment.Comment])
+		if err != nil {
+			return fmt.Errorf("failed to collect row from table:todo_comments for comment_id=%s user_id=%s: %w", commentID.String(), userID, err)
+		}
+
+		_, err = q.Exec(ctx, `
+			INSERT INTO
+				comment_revisions (comment_id, user_id, content, created_at)
+			VALUES
+				(@id, @user_id, @content, @edited_at)
+		`, pgx.NamedArgs{
+			"id":        commentItem.ID,
+			"user_id":   userID,
+			"content":   content,
+			"edited_at": commentItem.EditedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record revision for comment_id=%s: %w", commentID.String(), err)
+		}
+
+		commentItem.RenderContent()
+
+		updated := event.CommentUpdated{
+			Comment:            commentItem,
+			PreviousMentionIDs: make([]string, 0, len(previousMentions)),
+		}
+		for _, m := range previousMentions {
+			updated.PreviousMentionIDs = append(updated.PreviousMentionIDs, m.UserID)
+		}
+
+		return recordEvents(ctx, q, userID, updated)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &commentItem, nil
 }
 
-func (r *CommentRepository) DeleteComment(ctx context.Context, userID string, commentID uuid.UUID) error {
-	result, err := r.server.DB.Pool.Exec(ctx, `
-		DELETE FROM todo_comments
-		WHERE id = @id AND user_id = @user_id
-	`, pgx.NamedArgs{
+// GetCommentRevisions returns every recorded version of a comment, oldest first.
+// Comments that were never edited have no revisions.
+func (r *CommentRepository) GetCommentRevisions(ctx context.Context, commentID uuid.UUID) ([]comment.Revision, error) {
+	stmt := `
+		SELECT
+			*
+		FROM
+			comment_revisions
+		WHERE
+			comment_id=@comment_id
+		ORDER BY
+			created_at ASC
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"comment_id": commentID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get comment revisions query for comment_id=%s: %w", commentID.String(), err)
+	}
+
+	revisions, err := pgx.CollectRows(rows, pgx.RowToStructByName[comment.Revision])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:comment_revisions for comment_id=%s: %w", commentID.String(), err)
+	}
+
+	for i := range revisions {
+		revisions[i].RenderContent()
+	}
+
+	return revisions, nil
+}
+
+// DeleteComment removes a comment. A comment with replies becomes a tombstone so the
+// thread keeps its shape; a tombstone whose last reply is deleted is removed as well.
+// It reports whether the comment was kept as a tombstone.
+func (r *CommentRepository) DeleteComment(ctx context.Context, userID string, commentID uuid.UUID) (bool, error) {
+	args := pgx.NamedArgs{
 		"id":      commentID,
 		"user_id": userID,
+	}
+
+	deleted := event.CommentDeleted{CommentID: commentID}
+	err := r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
+
+		deleted.Tombstoned = false
+		err := q.QueryRow(ctx, `
+			UPDATE todo_comments
+			SET
+				content='',
+				mentions='[]'::JSONB,
+				deleted_at=CURRENT_TIMESTAMP
+			WHERE
+				id=@id
+				AND user_id=@user_id
+				AND deleted_at IS NULL
+				AND EXISTS (
+					SELECT
+						1
+					FROM
+						todo_comments reply
+					WHERE
+						reply.parent_comment_id=@id
+				)
+			RETURNING
+				todo_id
+		`, args).Scan(&deleted.TodoID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("failed to tombstone comment: %w", err)
+		}
+
+		if err == nil {
+			if _, err := q.Exec(ctx, `DELETE FROM comment_reactions WHERE comment_id=@id`, args); err != nil {
+				return fmt.Errorf("failed to delete reactions of tombstoned comment: %w", err)
+			}
+
+			// Earlier versions would otherwise keep the deleted content readable
+			if _, err := q.Exec(ctx, `DELETE FROM comment_revisions WHERE comment_id=@id`, args); err != nil {
+				return fmt.Errorf("failed to delete revisions of tombstoned comment: %w", err)
+			}
+
+			deleted.Tombstoned = true
+			return recordEvents(ctx, q, userID, deleted)
+		}
+
+		var parentID *uuid.UUID
+		err = q.QueryRow(ctx, `
+			DELETE FROM todo_comments
+			WHERE
+				id=@id
+				AND user_id=@user_id
+				AND deleted_at IS NULL
+			RETURNING
+				todo_id,
+				parent_comment_id
+		`, args).Scan(&deleted.TodoID, &parentID)
+		if err != nil {
+			return fmt.Errorf("failed to collect row from table:todo_comments for comment_id=%s user_id=%s: %w", commentID.String(), userID, err)
+		}
+
+		// Walk up the thread removing tombstones that no longer have replies
+		for parentID != nil {
+			var grandparentID *uuid.UUID
+			err = q.QueryRow(ctx, `
+				DELETE FROM todo_comments t
+				WHERE
+					t.id=@id
+					AND t.deleted_at IS NOT NULL
+					AND NOT EXISTS (
+						SELECT
+							1
+						FROM
+							todo_comments reply
+						WHERE
+							reply.parent_comment_id=t.id
+					)
+				RETURNING
+					t.parent_comment_id
+			`, pgx.NamedArgs{"id": *parentID}).Scan(&grandparentID)
+			if errors.Is(err, pgx.ErrNoRows) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("failed to remove tombstone comment_id=%s: %w", parentID.String(), err)
+			}
+			parentID = grandparentID
+		}
+
+		return recordEvents(ctx, q, userID, deleted)
 	})
 	if err != nil {
-		return fmt.Errorf("failed to delete comment: %w", err)
+		return false, err
 	}
 
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("comment not found")
+	return deleted.Tombstoned, nil
+}
+
+// ToggleReaction adds the user's emoji reaction to a comment, or removes it if the user
+// already reacted with that emoji. It returns the comment's updated reactions and
+// whether the reaction was added.
+func (r *CommentRepository) ToggleReaction(ctx context.Context, userID string, commentID uuid.UUID,
+	emoji string,
+) ([]comment.Reaction, bool, error) {
+	args := pgx.NamedArgs{
+		"comment_id": commentID,
+		"user_id":    userID,
+		"emoji":      emoji,
+	}
+
+	var (
+		toggled   event.CommentReactionToggled
+		reactions []comment.Reaction
+	)
+	err := r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
+
+		result, err := q.Exec(ctx, `
+			WITH
+				removed AS (
+					DELETE FROM comment_reactions
+					WHERE
+						comment_id=@comment_id
+						AND user_id=@user_id
+						AND emoji=@emoji
+					RETURNING
+						id
+				)
+			INSERT INTO
+				comment_reactions (comment_id, user_id, emoji)
+			SELECT
+				@comment_id,
+				@user_id,
+				@emoji
+			WHERE
+				NOT EXISTS (
+					SELECT
+						1
+					FROM
+						removed
+				)
+			ON CONFLICT (comment_id, user_id, emoji) DO NOTHING
+		`, args)
+		if err != nil {
+			return fmt.Errorf("failed to execute toggle reaction query for comment_id=%s user_id=%s: %w", commentID.String(), userID, err)
+		}
+
+		toggled = event.CommentReactionToggled{
+			CommentID: commentID,
+			UserID:    userID,
+			Emoji:     emoji,
+			Added:     result.RowsAffected() > 0,
+		}
+
+		err = q.QueryRow(ctx, `
+			SELECT
+				com.todo_id,
+				`+commentReactionsSelect+`
+			FROM
+				todo_comments com
+			WHERE
+				com.id=@comment_id
+		`, args).Scan(&toggled.TodoID, &reactions)
+		if err != nil {
+			return fmt.Errorf("failed to get reactions for comment_id=%s: %w", commentID.String(), err)
+		}
+
+		return recordEvents(ctx, q, userID, toggled)
+	})
+	if err != nil {
+		return nil, false, err
 	}
 
-	return nil
+	return reactions, toggled.Added, nil
 }
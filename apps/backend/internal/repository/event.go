@@ -0,0 +1,286 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/database"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/workspace"
+	"github.com/ApoorvYdv/go-tasker/internal/model/event"
+	"github.com/ApoorvYdv/go-tasker/internal/server"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type EventRepository struct {
+	server *server.Server
+}
+
+func NewEventRepository(server *server.Server) *EventRepository {
+	return &EventRepository{server: server}
+}
+
+// recordEvents writes domain events to the outbox as part of the unit of work ctx
+// belongs to, so they are published if and only if the change they describe
+// commits. Each event takes the next sequence of its aggregate, which locks the
+// aggregate's counter until the transaction ends; call it last in the unit of work
+// so it waits on nothing while holding that lock.
+func recordEvents(ctx context.Context, q database.Querier, actorID string, payloads ...event.Payload) error {
+	for _, payload := range payloads {
+		aggregateType, aggregateID := payload.Aggregate()
+
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s event for %s=%s: %w", payload.EventType(), aggregateType,
+				aggregateID.String(), err)
+		}
+
+		_, err = q.Exec(ctx, `
+			WITH
+				aggregate AS (
+					INSERT INTO
+						event_aggregates (aggregate_type, aggregate_id, sequence)
+					VALUES
+						(@aggregate_type, @aggregate_id, 1)
+					ON CONFLICT (aggregate_type, aggregate_id) DO UPDATE
+					SET
+						sequence=event_aggregates.sequence + 1
+					RETURNING
+						sequence
+				)
+			INSERT INTO
+				event_outbox (
+					aggregate_type,
+					aggregate_id,
+					sequence,
+					event_type,
+					actor_id,
+					organization_id,
+					payload
+				)
+			SELECT
+				@aggregate_type,
+				@aggregate_id,
+				aggregate.sequence,
+				@event_type,
+				@actor_id,
+				@org_id,
+				@payload::JSONB
+			FROM
+				aggregate
+		`, pgx.NamedArgs{
+			"aggregate_type": aggregateType,
+			"aggregate_id":   aggregateID,
+			"event_type":     payload.EventType(),
+			"actor_id":       actorID,
+			"org_id":         workspace.FromContext(ctx).OrgIDArg(),
+			"payload":        data,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record %s event for %s=%s: %w", payload.EventType(), aggregateType,
+				aggregateID.String(), err)
+		}
+	}
+
+	return nil
+}
+
+// ClaimEvents hands out up to limit unpublished events for the caller to publish,
+// oldest first and at most one per aggregate: an aggregate's next event is only
+// handed out once the one before it is published. Claimed events are held for the
+// lease and handed out again if they aren't marked by then.
+func (r *EventRepository) ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]event.Event, error) {
+	stmt := `
+		UPDATE event_outbox o
+		SET
+			available_at=CURRENT_TIMESTAMP + @lease::INTERVAL,
+			attempts=o.attempts + 1
+		WHERE
+			o.id IN (
+				SELECT
+					e.id
+				FROM
+					event_outbox e
+				WHERE
+					e.published_at IS NULL
+					AND e.failed_at IS NULL
+					AND e.available_at<=CURRENT_TIMESTAMP
+					AND NOT EXISTS (
+						SELECT
+							1
+						FROM
+							event_outbox prev
+						WHERE
+							prev.aggregate_type=e.aggregate_type
+							AND prev.aggregate_id=e.aggregate_id
+							AND prev.sequence<e.sequence
+							AND prev.published_at IS NULL
+							AND prev.failed_at IS NULL
+					)
+				ORDER BY
+					e.position ASC
+				LIMIT
+					@limit
+				FOR UPDATE
+					SKIP LOCKED
+			)
+		RETURNING
+			o.*
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"lease": lease,
+		"limit": limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute claim events query: %w", err)
+	}
+
+	events, err := pgx.CollectRows(rows, pgx.RowToStructByName[event.Event])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:event_outbox: %w", err)
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Position < events[j].Position
+	})
+
+	return events, nil
+}
+
+// MarkEventPublished records that every subscriber has the event.
+func (r *EventRepository) MarkEventPublished(ctx context.Context, eventID uuid.UUID) error {
+	_, err := r.server.DB.Querier(ctx).Exec(ctx, `
+		UPDATE event_outbox
+		SET
+			published_at=CURRENT_TIMESTAMP,
+			last_error=NULL
+		WHERE
+			id=@id
+	`, pgx.NamedArgs{
+		"id": eventID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark event_id=%s published: %w", eventID.String(), err)
+	}
+
+	return nil
+}
+
+// RetryEvent records a failed attempt at publishing the event and holds it back
+// until retryAt.
+func (r *EventRepository) RetryEvent(ctx context.Context, eventID uuid.UUID, lastError string, retryAt time.Time) error {
+	_, err := r.server.DB.Querier(ctx).Exec(ctx, `
+		UPDATE event_outbox
+		SET
+			available_at=@retry_at,
+			last_error=@last_error
+		WHERE
+			id=@id
+	`, pgx.NamedArgs{
+		"id":         eventID,
+		"retry_at":   retryAt,
+		"last_error": lastError,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to schedule retry of event_id=%s: %w", eventID.String(), err)
+	}
+
+	return nil
+}
+
+// FailEvent gives up on publishing the event, letting its aggregate's later events
+// through. Failed events are kept until they are dealt with by hand.
+func (r *EventRepository) FailEvent(ctx context.Context, eventID uuid.UUID, lastError string) error {
+	_, err := r.server.DB.Querier(ctx).Exec(ctx, `
+		UPDATE event_outbox
+		SET
+			failed_at=CURRENT_TIMESTAMP,
+			last_error=@last_error
+		WHERE
+			id=@id
+	`, pgx.NamedArgs{
+		"id":         eventID,
+		"last_error": lastError,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark event_id=%s failed: %w", eventID.String(), err)
+	}
+
+	return nil
+}
+
+// IsEventDelivered reports whether the subscriber already handled the event.
+func (r *EventRepository) IsEventDelivered(ctx context.Context, subscriber string, eventID uuid.UUID) (bool, error) {
+	var delivered bool
+	err := r.server.DB.Querier(ctx).QueryRow(ctx, `
+		SELECT
+			EXISTS (
+				SELECT
+					1
+				FROM
+					event_deliveries
+				WHERE
+					subscriber=@subscriber
+					AND event_id=@event_id
+			)
+	`, pgx.NamedArgs{
+		"subscriber": subscriber,
+		"event_id":   eventID,
+	}).Scan(&delivered)
+	if err != nil {
+		return false, fmt.Errorf("failed to check delivery of event_id=%s to subscriber=%s: %w", eventID.String(), subscriber, err)
+	}
+
+	return delivered, nil
+}
+
+// RecordEventDelivery records that the subscriber handled the event.
+func (r *EventRepository) RecordEventDelivery(ctx context.Context, subscriber string, eventID uuid.UUID) error {
+	_, err := r.server.DB.Querier(ctx).Exec(ctx, `
+		INSERT INTO
+			event_deliveries (subscriber, event_id)
+		VALUES
+			(@subscriber, @event_id)
+		ON CONFLICT (subscriber, event_id) DO NOTHING
+	`, pgx.NamedArgs{
+		"subscriber": subscriber,
+		"event_id":   eventID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record delivery of event_id=%s to subscriber=%s: %w", eventID.String(), subscriber, err)
+	}
+
+	return nil
+}
+
+// PruneEvents deletes events published before the cutoff along with the delivery
+// records of that age. It returns how many events were deleted.
+func (r *EventRepository) PruneEvents(ctx context.Context, before time.Time) (int64, error) {
+	args := pgx.NamedArgs{
+		"before": before,
+	}
+
+	result, err := r.server.DB.Querier(ctx).Exec(ctx, `
+		DELETE FROM event_outbox
+		WHERE
+			published_at<@before
+	`, args)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune published events: %w", err)
+	}
+
+	_, err = r.server.DB.Querier(ctx).Exec(ctx, `
+		DELETE FROM event_deliveries
+		WHERE
+			delivered_at<@before
+	`, args)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune event deliveries: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/model/comment"
+	"github.com/ApoorvYdv/go-tasker/internal/model/event"
+	"github.com/ApoorvYdv/go-tasker/internal/model/todo"
+	tasktest "github.com/ApoorvYdv/go-tasker/internal/testing"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventRepository_ClaimEvents(t *testing.T) {
+	_, srv, cleanup := tasktest.SetupTest(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID := "user_" + uuid.NewString()
+	todoRepo := NewTodoRepository(srv)
+	eventRepo := NewEventRepository(srv)
+
+	// createTodo records todo.created, then todo.updated for every given title
+	createTodo := func(t *testing.T, titles ...string) *todo.Todo {
+		t.Helper()
+
+		todoItem, err := todoRepo.CreateTodo(ctx, userID, &todo.CreateTodoPayload{Title: "Outbox"})
+		require.NoError(t, err)
+
+		for _, title := range titles {
+			_, err := todoRepo.UpdateTodo(ctx, userID, &todo.UpdateTodoPayload{ID: todoItem.ID, Title: &title})
+			require.NoError(t, err)
+		}
+
+		return todoItem
+	}
+
+	claim := func(t *testing.T) []event.Event {
+		t.Helper()
+
+		events, err := eventRepo.ClaimEvents(ctx, 100, time.Minute)
+		require.NoError(t, err)
+		return events
+	}
+
+	t.Run("one event per aggregate in order", func(t *testing.T) {
+		first := createTodo(t, "second", "third")
+		other := createTodo(t)
+
+		events := claim(t)
+		require.Len(t, events, 2)
+		assert.Equal(t, first.ID, events[0].AggregateID)
+		assert.Equal(t, event.TypeTodoCreated, events[0].Type)
+		assert.Equal(t, int64(1), events[0].Sequence)
+		assert.Equal(t, other.ID, events[1].AggregateID)
+		assert.Equal(t, 1, events[0].Attempts)
+
+		// Claimed events are held, and block the rest of their aggregate
+		assert.Empty(t, claim(t))
+
+		require.NoError(t, eventRepo.MarkEventPublished(ctx, events[0].ID))
+		require.NoError(t, eventRepo.MarkEventPublished(ctx, events[1].ID))
+
+		events = claim(t)
+		require.Len(t, events, 1)
+		assert.Equal(t, first.ID, events[0].AggregateID)
+		assert.Equal(t, event.TypeTodoUpdated, events[0].Type)
+		assert.Equal(t, int64(2), events[0].Sequence)
+
+		updated, err := event.Decode[event.TodoUpdated](&events[0])
+		require.NoError(t, err)
+		assert.Equal(t, "second", updated.Todo.Title)
+		assert.Equal(t, []string{"title"}, updated.Changed)
+		assert.Equal(t, todo.StatusDraft, updated.PreviousStatus)
+
+		require.NoError(t, eventRepo.MarkEventPublished(ctx, events[0].ID))
+
+		events = claim(t)
+		require.Len(t, events, 1)
+		assert.Equal(t, int64(3), events[0].Sequence)
+		require.NoError(t, eventRepo.MarkEventPublished(ctx, events[0].ID))
+
+		assert.Empty(t, claim(t))
+	})
+
+	t.Run("retry holds the aggregate back", func(t *testing.T) {
+		item := createTodo(t, "second")
+
+		events := claim(t)
+		require.Len(t, events, 1)
+
+		require.NoError(t, eventRepo.RetryEvent(ctx, events[0].ID, "subscriber failed", time.Now().Add(time.Hour)))
+		assert.Empty(t, claim(t))
+
+		require.NoError(t, eventRepo.RetryEvent(ctx, events[0].ID, "subscriber failed", time.Now().Add(-time.Minute)))
+		retried := claim(t)
+		require.Len(t, retried, 1)
+		assert.Equal(t, events[0].ID, retried[0].ID)
+		assert.Equal(t, 2, retried[0].Attempts)
+		require.NotNil(t, retried[0].LastError)
+		assert.Equal(t, "subscriber failed", *retried[0].LastError)
+
+		// Giving up on an event lets the aggregate's next one through
+		require.NoError(t, eventRepo.FailEvent(ctx, retried[0].ID, "subscriber failed"))
+		events = claim(t)
+		require.Len(t, events, 1)
+		assert.Equal(t, item.ID, events[0].AggregateID)
+		assert.Equal(t, int64(2), events[0].Sequence)
+		require.NoError(t, eventRepo.MarkEventPublished(ctx, events[0].ID))
+	})
+
+	t.Run("lapsed claim is handed out again", func(t *testing.T) {
+		createTodo(t)
+
+		events, err := eventRepo.ClaimEvents(ctx, 100, 50*time.Millisecond)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+
+		time.Sleep(100 * time.Millisecond)
+
+		reclaimed := claim(t)
+		require.Len(t, reclaimed, 1)
+		assert.Equal(t, events[0].ID, reclaimed[0].ID)
+		assert.Equal(t, 2, reclaimed[0].Attempts)
+		require.NoError(t, eventRepo.MarkEventPublished(ctx, reclaimed[0].ID))
+	})
+
+	t.Run("concurrent claims don't overlap", func(t *testing.T) {
+		for range 20 {
+			createTodo(t)
+		}
+
+		var (
+			mu      sync.Mutex
+			wg      sync.WaitGroup
+			claimed = map[uuid.UUID]int{}
+		)
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				events, err := eventRepo.ClaimEvents(ctx, 10, time.Minute)
+				assert.NoError(t, err)
+
+				mu.Lock()
+				defer mu.Unlock()
+				for _, e := range events {
+					claimed[e.ID]++
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Len(t, claimed, 20)
+		for id, count := range claimed {
+			assert.Equal(t, 1, count, "event %s claimed more than once", id)
+		}
+	})
+}
+
+func TestRecordEvents_UnitOfWork(t *testing.T) {
+	_, srv, cleanup := tasktest.SetupTest(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID := "user_" + uuid.NewString()
+	todoRepo := NewTodoRepository(srv)
+	commentRepo := NewCommentRepository(srv)
+	eventRepo := NewEventRepository(srv)
+
+	// A todo and a comment on it, written by two repositories in one unit of work
+	write := func(ctx context.Context) (*todo.Todo, error) {
+		todoItem, err := todoRepo.CreateTodo(ctx, userID, &todo.CreateTodoPayload{Title: "Unit of work"})
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = commentRepo.AddComment(ctx, userID, todoItem.ID, &comment.AddCommentPayload{Content: "First"},
+			nil, []comment.Mention{})
+		return todoItem, err
+	}
+
+	t.Run("rolled back work records no events", func(t *testing.T) {
+		failed := errors.New("failed")
+		var todoItem *todo.Todo
+		err := srv.DB.WithTx(ctx, func(ctx context.Context) error {
+			var err error
+			todoItem, err = write(ctx)
+			require.NoError(t, err)
+			return failed
+		})
+		assert.ErrorIs(t, err, failed)
+
+		_, err = todoRepo.GetTodo(ctx, todoItem.ID)
+		assert.Error(t, err)
+		events, err := eventRepo.ClaimEvents(ctx, 100, time.Minute)
+		require.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("committed work records every repository's events", func(t *testing.T) {
+		var todoItem *todo.Todo
+		err := srv.DB.WithTx(ctx, func(ctx context.Context) error {
+			var err error
+			todoItem, err = write(ctx)
+			return err
+		})
+		require.NoError(t, err)
+
+		events, err := eventRepo.ClaimEvents(ctx, 100, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, event.TypeTodoCreated, events[0].Type)
+		require.NoError(t, eventRepo.MarkEventPublished(ctx, events[0].ID))
+
+		// The comment belongs to the todo's aggregate and follows its creation
+		events, err = eventRepo.ClaimEvents(ctx, 100, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, event.TypeCommentAdded, events[0].Type)
+		assert.Equal(t, todoItem.ID, events[0].AggregateID)
+		assert.Equal(t, int64(2), events[0].Sequence)
+	})
+}
+
+func TestEventRepository_Deliveries(t *testing.T) {
+	_, srv, cleanup := tasktest.SetupTest(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	eventRepo := NewEventRepository(srv)
+	eventID := uuid.New()
+
+	delivered, err := eventRepo.IsEventDelivered(ctx, "realtime", eventID)
+	require.NoError(t, err)
+	assert.False(t, delivered)
+
+	require.NoError(t, eventRepo.RecordEventDelivery(ctx, "realtime", eventID))
+	// Recording again is a no-op
+	require.NoError(t, eventRepo.RecordEventDelivery(ctx, "realtime", eventID))
+
+	delivered, err = eventRepo.IsEventDelivered(ctx, "realtime", eventID)
+	require.NoError(t, err)
+	assert.True(t, delivered)
+
+	delivered, err = eventRepo.IsEventDelivered(ctx, "notifications", eventID)
+	require.NoError(t, err)
+	assert.False(t, delivered)
+}
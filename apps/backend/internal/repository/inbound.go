@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ApoorvYdv/go-tasker/internal/model/inbound"
+	"github.com/ApoorvYdv/go-tasker/internal/server"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type InboundRepository struct {
+	server *server.Server
+}
+
+func NewInboundRepository(server *server.Server) *InboundRepository {
+	return &InboundRepository{server: server}
+}
+
+// GetOrCreateAddress returns the user's personal address, creating it with token on
+// first use.
+func (r *InboundRepository) GetOrCreateAddress(ctx context.Context, userID string, token string) (*inbound.Address, error) {
+	stmt := `
+		WITH
+			created AS (
+				INSERT INTO
+					inbound_email_addresses (user_id, token)
+				VALUES
+					(@user_id, @token)
+				ON CONFLICT (user_id) DO NOTHING
+				RETURNING
+					*
+			)
+		SELECT
+			*
+		FROM
+			created
+		UNION ALL
+		SELECT
+			*
+		FROM
+			inbound_email_addresses
+		WHERE
+			user_id=@user_id
+		LIMIT
+			1
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"user_id": userID,
+		"token":   token,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get or create inbound address query for user_id=%s: %w", userID, err)
+	}
+
+	address, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[inbound.Address])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect row from table:inbound_email_addresses for user_id=%s: %w", userID, err)
+	}
+
+	return &address, nil
+}
+
+// RotateAddress replaces the user's token so the previous address stops working.
+func (r *InboundRepository) RotateAddress(ctx context.Context, userID string, token string) (*inbound.Address, error) {
+	stmt := `
+		INSERT INTO
+			inbound_email_addresses (user_id, token)
+		VALUES
+			(@user_id, @token)
+		ON CONFLICT (user_id) DO UPDATE
+		SET
+			token=EXCLUDED.token
+		RETURNING
+			*
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"user_id": userID,
+		"token":   token,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute rotate inbound address query for user_id=%s: %w", userID, err)
+	}
+
+	address, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[inbound.Address])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect row from table:inbound_email_addresses for user_id=%s: %w", userID, err)
+	}
+
+	return &address, nil
+}
+
+func (r *InboundRepository) GetAddressByToken(ctx context.Context, token string) (*inbound.Address, error) {
+	stmt := `
+		SELECT
+			*
+		FROM
+			inbound_email_addresses
+		WHERE
+			token=@token
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"token": token,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get inbound address by token query: %w", err)
+	}
+
+	address, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[inbound.Address])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect row from table:inbound_email_addresses for token: %w", err)
+	}
+
+	return &address, nil
+}
+
+func (r *InboundRepository) CreateReplyToken(ctx context.Context, token string, userID string, todoID uuid.UUID,
+	commentID *uuid.UUID, organizationID *string,
+) error {
+	_, err := r.server.DB.Querier(ctx).Exec(ctx, `
+		INSERT INTO
+			email_reply_tokens (token, user_id, todo_id, comment_id, organization_id)
+		VALUES
+			(@token, @user_id, @todo_id, @comment_id, @organization_id)
+	`, pgx.NamedArgs{
+		"token":           token,
+		"user_id":         userID,
+		"todo_id":         todoID,
+		"comment_id":      commentID,
+		"organization_id": organizationID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create email reply token for user_id=%s todo_id=%s: %w", userID, todoID.String(), err)
+	}
+
+	return nil
+}
+
+func (r *InboundRepository) GetReplyToken(ctx context.Context, token string) (*inbound.ReplyToken, error) {
+	stmt := `
+		SELECT
+			*
+		FROM
+			email_reply_tokens
+		WHERE
+			token=@token
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"token": token,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get email reply token query: %w", err)
+	}
+
+	replyToken, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[inbound.ReplyToken])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect row from table:email_reply_tokens for token: %w", err)
+	}
+
+	return &replyToken, nil
+}
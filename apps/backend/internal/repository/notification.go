@@ -0,0 +1,434 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ApoorvYdv/go-tasker/internal/model"
+	"github.com/ApoorvYdv/go-tasker/internal/model/notification"
+	"github.com/ApoorvYdv/go-tasker/internal/server"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type NotificationRepository struct {
+	server *server.Server
+}
+
+func NewNotificationRepository(server *server.Server) *NotificationRepository {
+	return &NotificationRepository{server: server}
+}
+
+// CreateNotifications fans a notification out to every recipient in one statement.
+// UserID on the template is ignored.
+func (r *NotificationRepository) CreateNotifications(ctx context.Context, userIDs []string,
+	template *notification.Notification,
+) ([]notification.Notification, error) {
+	stmt := `
+		INSERT INTO
+			notifications (
+				user_id,
+				type,
+				actor_id,
+				todo_id,
+				comment_id,
+				title,
+				body
+			)
+		SELECT
+			u,
+			@type,
+			@actor_id,
+			@todo_id,
+			@comment_id,
+			@title,
+			@body
+		FROM
+			UNNEST(@user_ids::TEXT[]) AS u
+		RETURNING
+		*
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"user_ids":   userIDs,
+		"type":       template.Type,
+		"actor_id":   template.ActorID,
+		"todo_id":    template.TodoID,
+		"comment_id": template.CommentID,
+		"title":      template.Title,
+		"body":       template.Body,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute create notifications query for type=%s: %w", template.Type, err)
+	}
+
+	notifications, err := pgx.CollectRows(rows, pgx.RowToStructByName[notification.Notification])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:notifications for type=%s: %w", template.Type, err)
+	}
+
+	return notifications, nil
+}
+
+func (r *NotificationRepository) GetNotifications(ctx context.Context, userID string,
+	query *notification.GetNotificationsQuery,
+) (*notification.Inbox, error) {
+	conditions := `
+		WHERE
+			user_id=@user_id
+	`
+	args := pgx.NamedArgs{
+		"user_id": userID,
+	}
+
+	if *query.Archived {
+		conditions += " AND archived_at IS NOT NULL"
+	} else {
+		conditions += " AND archived_at IS NULL"
+	}
+
+	switch *query.Status {
+	case "unread":
+		conditions += " AND read_at IS NULL"
+	case "read":
+		conditions += " AND read_at IS NOT NULL"
+	}
+
+	stmt := `
+		SELECT
+			*
+		FROM
+			notifications
+	` + conditions + `
+		ORDER BY
+			created_at DESC
+		LIMIT
+			@limit
+		OFFSET
+			@offset
+	`
+	args["limit"] = *query.Limit
+	args["offset"] = (*query.Page - 1) * *query.Limit
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get notifications query for user_id=%s: %w", userID, err)
+	}
+
+	notifications, err := pgx.CollectRows(rows, pgx.RowToStructByName[notification.Notification])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:notifications for user_id=%s: %w", userID, err)
+	}
+
+	var total int
+	err = r.server.DB.Querier(ctx).QueryRow(ctx, `SELECT COUNT(*) FROM notifications `+conditions, args).Scan(&total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get total count of notifications for user_id=%s: %w", userID, err)
+	}
+
+	unreadCount, err := r.GetUnreadCount(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &notification.Inbox{
+		PaginatedResponse: model.PaginatedResponse[notification.Notification]{
+			Data:       notifications,
+			Page:       *query.Page,
+			Limit:      *query.Limit,
+			Total:      total,
+			TotalPages: (total + *query.Limit - 1) / *query.Limit,
+		},
+		UnreadCount: unreadCount,
+	}, nil
+}
+
+// GetUnreadCount counts unread notifications in the user's inbox; archived ones don't count.
+func (r *NotificationRepository) GetUnreadCount(ctx context.Context, userID string) (int, error) {
+	stmt := `
+		SELECT
+			COUNT(*)
+		FROM
+			notifications
+		WHERE
+			user_id=@user_id
+			AND read_at IS NULL
+			AND archived_at IS NULL
+	`
+
+	var count int
+	err := r.server.DB.Querier(ctx).QueryRow(ctx, stmt, pgx.NamedArgs{
+		"user_id": userID,
+	}).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get unread notification count for user_id=%s: %w", userID, err)
+	}
+
+	return count, nil
+}
+
+func (r *NotificationRepository) MarkRead(ctx context.Context, userID string, notificationID uuid.UUID) (*notification.Notification, error) {
+	stmt := `
+		UPDATE
+			notifications
+		SET
+			read_at=COALESCE(read_at, CURRENT_TIMESTAMP)
+		WHERE
+			id=@id
+			AND user_id=@user_id
+		RETURNING
+		*
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"id":      notificationID,
+		"user_id": userID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute mark notification read query for notification_id=%s user_id=%s: %w", notificationID.String(), userID, err)
+	}
+
+	notificationItem, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[notification.Notification])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect row from table:notifications for notification_id=%s user_id=%s: %w", notificationID.String(), userID, err)
+	}
+
+	return &notificationItem, nil
+}
+
+// MarkAllRead marks every unread notification in the user's inbox as read and returns
+// how many changed.
+func (r *NotificationRepository) MarkAllRead(ctx context.Context, userID string) (int64, error) {
+	result, err := r.server.DB.Querier(ctx).Exec(ctx, `
+		UPDATE notifications
+		SET
+			read_at=CURRENT_TIMESTAMP
+		WHERE
+			user_id=@user_id
+			AND read_at IS NULL
+			AND archived_at IS NULL
+	`, pgx.NamedArgs{
+		"user_id": userID,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark all notifications read for user_id=%s: %w", userID, err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// Archive moves a notification out of the inbox. Archived notifications count as read.
+func (r *NotificationRepository) Archive(ctx context.Context, userID string, notificationID uuid.UUID) (*notification.Notification, error) {
+	stmt := `
+		UPDATE
+			notifications
+		SET
+			read_at=COALESCE(read_at, CURRENT_TIMESTAMP),
+			archived_at=COALESCE(archived_at, CURRENT_TIMESTAMP)
+		WHERE
+			id=@id
+			AND user_id=@user_id
+		RETURNING
+		*
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"id":      notificationID,
+		"user_id": userID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute archive notification query for notification_id=%s user_id=%s: %w", notificationID.String(), userID, err)
+	}
+
+	notificationItem, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[notification.Notification])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect row from table:notifications for notification_id=%s user_id=%s: %w", notificationID.String(), userID, err)
+	}
+
+	return &notificationItem, nil
+}
+
+// GetSettings returns the user's notification settings, or the defaults if the user
+// never changed them.
+func (r *NotificationRepository) GetSettings(ctx context.Context, userID string) (*notification.Settings, error) {
+	stmt := `
+		SELECT
+			COALESCE(s.timezone, 'UTC') AS timezone,
+			s.quiet_hours_start,
+			s.quiet_hours_end,
+			s.webhook_url,
+			s.webhook_secret
+		FROM
+			(
+				SELECT
+					@user_id::TEXT AS user_id
+			) u
+			LEFT JOIN notification_settings s ON s.user_id=u.user_id
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"user_id": userID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get notification settings query for user_id=%s: %w", userID, err)
+	}
+
+	settings, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[notification.Settings])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect row from table:notification_settings for user_id=%s: %w", userID, err)
+	}
+
+	return &settings, nil
+}
+
+func (r *NotificationRepository) SaveSettings(ctx context.Context, userID string, settings *notification.Settings) error {
+	_, err := r.server.DB.Querier(ctx).Exec(ctx, `
+		INSERT INTO
+			notification_settings (
+				user_id,
+				timezone,
+				quiet_hours_start,
+				quiet_hours_end,
+				webhook_url,
+				webhook_secret
+			)
+		VALUES
+			(
+				@user_id,
+				@timezone,
+				@quiet_hours_start,
+				@quiet_hours_end,
+				@webhook_url,
+				@webhook_secret
+			)
+		ON CONFLICT (user_id) DO UPDATE
+		SET
+			timezone=EXCLUDED.timezone,
+			quiet_hours_start=EXCLUDED.quiet_hours_start,
+			quiet_hours_end=EXCLUDED.quiet_hours_end,
+			webhook_url=EXCLUDED.webhook_url,
+			webhook_secret=EXCLUDED.webhook_secret
+	`, pgx.NamedArgs{
+		"user_id":           userID,
+		"timezone":          settings.Timezone,
+		"quiet_hours_start": settings.QuietHoursStart,
+		"quiet_hours_end":   settings.QuietHoursEnd,
+		"webhook_url":       settings.WebhookURL,
+		"webhook_secret":    settings.WebhookSecret,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save notification settings for user_id=%s: %w", userID, err)
+	}
+
+	return nil
+}
+
+// GetPreferences returns the user's configured preferences; unconfigured types are absent.
+func (r *NotificationRepository) GetPreferences(ctx context.Context, userID string) ([]notification.Preference, error) {
+	stmt := `
+		SELECT
+			type,
+			in_app,
+			email,
+			webhook
+		FROM
+			notification_preferences
+		WHERE
+			user_id=@user_id
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"user_id": userID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get notification preferences query for user_id=%s: %w", userID, err)
+	}
+
+	preferences, err := pgx.CollectRows(rows, pgx.RowToStructByName[notification.Preference])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:notification_preferences for user_id=%s: %w", userID, err)
+	}
+
+	return preferences, nil
+}
+
+// SavePreferences updates the given per-type preferences; nil channels keep their
+// current value, which for new rows is on.
+func (r *NotificationRepository) SavePreferences(ctx context.Context, userID string,
+	preferences []notification.UpdatePreferencePayload,
+) error {
+	return r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
+
+		for _, p := range preferences {
+			_, err := q.Exec(ctx, `
+				INSERT INTO
+					notification_preferences (user_id, type, in_app, email, webhook)
+				VALUES
+					(
+						@user_id,
+						@type,
+						COALESCE(@in_app, TRUE),
+						COALESCE(@email, TRUE),
+						COALESCE(@webhook, TRUE)
+					)
+				ON CONFLICT (user_id, type) DO UPDATE
+				SET
+					in_app=COALESCE(@in_app, notification_preferences.in_app),
+					email=COALESCE(@email, notification_preferences.email),
+					webhook=COALESCE(@webhook, notification_preferences.webhook)
+			`, pgx.NamedArgs{
+				"user_id": userID,
+				"type":    p.Type,
+				"in_app":  p.InApp,
+				"email":   p.Email,
+				"webhook": p.Webhook,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to save notification preference type=%s for user_id=%s: %w", p.Type, userID, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetDeliveries resolves the channels and settings of each recipient for one
+// notification type, applying defaults where nothing is configured.
+func (r *NotificationRepository) GetDeliveries(ctx context.Context, userIDs []string,
+	notificationType notification.Type,
+) ([]notification.Delivery, error) {
+	stmt := `
+		SELECT
+			u.user_id,
+			@type::TEXT AS type,
+			COALESCE(p.in_app, TRUE) AS in_app,
+			COALESCE(p.email, TRUE) AS email,
+			COALESCE(p.webhook, TRUE) AS webhook,
+			COALESCE(s.timezone, 'UTC') AS timezone,
+			s.quiet_hours_start,
+			s.quiet_hours_end,
+			s.webhook_url,
+			s.webhook_secret
+		FROM
+			UNNEST(@user_ids::TEXT[]) AS u (user_id)
+			LEFT JOIN notification_preferences p ON p.user_id=u.user_id
+			AND p.type=@type
+			LEFT JOIN notification_settings s ON s.user_id=u.user_id
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"user_ids": userIDs,
+		"type":     notificationType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get notification deliveries query for type=%s: %w", notificationType, err)
+	}
+
+	deliveries, err := pgx.CollectRows(rows, pgx.RowToStructByName[notification.Delivery])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:notification_preferences for type=%s: %w", notificationType, err)
+	}
+
+	return deliveries, nil
+}
@@ -3,15 +3,23 @@ package repository
 import "github.com/ApoorvYdv/go-tasker/internal/server"
 
 type Repositories struct {
-	Todo     *TodoRepository
-	Comment  *CommentRepository
-	Category *CategoryRepository
+	Todo         *TodoRepository
+	Comment      *CommentRepository
+	Category     *CategoryRepository
+	Share        *ShareRepository
+	Notification *NotificationRepository
+	Inbound      *InboundRepository
+	Event        *EventRepository
 }
 
 func NewRepositories(s *server.Server) *Repositories {
 	return &Repositories{
-		Todo:     NewTodoRepository(s),
-		Comment:  NewCommentRepository(s),
-		Category: NewCategoryRepository(s),
+		Todo:         NewTodoRepository(s),
+		Comment:      NewCommentRepository(s),
+		Category:     NewCategoryRepository(s),
+		Share:        NewShareRepository(s),
+		Notification: NewNotificationRepository(s),
+		Inbound:      NewInboundRepository(s),
+		Event:        NewEventRepository(s),
 	}
 }
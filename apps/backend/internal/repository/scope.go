@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ApoorvYdv/go-tasker/internal/lib/workspace"
+	"github.com/jackc/pgx/v5"
+)
+
+// withScope adds the session's workspace to the query arguments as @org_id, and as
+// @todo_role and @category_role the member's default roles in the organization, so
+// the access functions can tell organization sessions from personal ones.
+func withScope(ctx context.Context, args pgx.NamedArgs) pgx.NamedArgs {
+	ws := workspace.FromContext(ctx)
+	args["org_id"] = ws.OrgIDArg()
+	args["todo_role"] = ws.DefaultTodoRole()
+	args["category_role"] = ws.DefaultCategoryRole()
+	return args
+}
+
+// todoReach narrows the todos under alias to those the user could reach: their
+// own, their organization's, subtasks of their own, and those under an accepted
+// share, including todos anywhere below a shared category. Its predicates are indexed, so it goes before todo_access_role in list
+// queries; it must let through every todo that todo_access_role grants.
+func todoReach(alias string) string {
+	return fmt.Sprintf(`(
+		%[1]s.user_id=@user_id
+		OR %[1]s.organization_id=@org_id
+		OR %[1]s.parent_todo_id IN (
+			SELECT
+				id
+			FROM
+				todos
+			WHERE
+				user_id=@user_id
+		)
+		OR %[1]s.id IN (
+			SELECT
+				todo_id
+			FROM
+				todo_shares
+			WHERE
+				user_id=@user_id
+				AND status='accepted'
+		)
+		OR %[1]s.parent_todo_id IN (
+			SELECT
+				todo_id
+			FROM
+				todo_shares
+			WHERE
+				user_id=@user_id
+				AND status='accepted'
+		)
+		OR %[1]s.category_id IN (
+			SELECT
+				shared_category_ids(@user_id)
+		)
+		OR %[1]s.parent_todo_id IN (
+			SELECT
+				id
+			FROM
+				todos
+			WHERE
+				category_id IN (
+					SELECT
+						shared_category_ids(@user_id)
+				)
+		)
+	)`, alias)
+}
+
+// categoryReach is todoReach for categories under alias, ahead of
+// category_access_role.
+func categoryReach(alias string) string {
+	return fmt.Sprintf(`(
+		%[1]s.user_id=@user_id
+		OR %[1]s.organization_id=@org_id
+		OR %[1]s.id IN (
+			SELECT
+				shared_category_ids(@user_id)
+		)
+	)`, alias)
+}
@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ApoorvYdv/go-tasker/internal/model/share"
+	"github.com/ApoorvYdv/go-tasker/internal/server"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type ShareRepository struct {
+	server *server.Server
+}
+
+func NewShareRepository(server *server.Server) *ShareRepository {
+	return &ShareRepository{server: server}
+}
+
+func (r *ShareRepository) CreateShare(ctx context.Context, ownerID string, resourceType share.ResourceType,
+	resourceID uuid.UUID, email string, role share.Role, inviteToken string,
+) (*share.Share, error) {
+	stmt := `
+		INSERT INTO
+			todo_shares (
+				owner_id,
+				resource_type,
+				todo_id,
+				category_id,
+				email,
+				role,
+				invite_token
+			)
+		VALUES
+			(
+				@owner_id,
+				@resource_type,
+				@todo_id,
+				@category_id,
+				@email,
+				@role,
+				@invite_token
+			)
+		RETURNING
+		*
+	`
+
+	args := pgx.NamedArgs{
+		"owner_id":      ownerID,
+		"resource_type": resourceType,
+		"todo_id":       nil,
+		"category_id":   nil,
+		"email":         email,
+		"role":          role,
+		"invite_token":  inviteToken,
+	}
+
+	if resourceType == share.ResourceTypeTodo {
+		args["todo_id"] = resourceID
+	} else {
+		args["category_id"] = resourceID
+	}
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute create share query for %s_id=%s email=%s: %w", resourceType, resourceID.String(), email, err)
+	}
+
+	shareItem, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[share.Share])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect row from table:todo_shares for %s_id=%s email=%s: %w", resourceType, resourceID.String(), email, err)
+	}
+
+	return &shareItem, nil
+}
+
+// GetShares lists the shares of a resource. Owners and editors of the resource see
+// every share; other collaborators only see their own.
+func (r *ShareRepository) GetShares(ctx context.Context, userID string, resourceType share.ResourceType,
+	resourceID uuid.UUID,
+) ([]share.Share, error) {
+	stmt := `
+		SELECT
+			*
+		FROM
+			todo_shares
+		WHERE
+			resource_type=@resource_type
+			AND (
+				todo_id=@resource_id
+				OR category_id=@resource_id
+			)
+			AND (
+				user_id=@user_id
+				OR CASE
+					WHEN @resource_type='todo' THEN todo_access_role(@resource_id, @user_id, @org_id, @todo_role)
+					ELSE category_access_role(@resource_id, @user_id, @org_id, @category_role)
+				END IN ('owner', 'editor')
+			)
+		ORDER BY
+			created_at ASC
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, withScope(ctx, pgx.NamedArgs{
+		"user_id":       userID,
+		"resource_type": resourceType,
+		"resource_id":   resourceID,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get shares query for %s_id=%s: %w", resourceType, resourceID.String(), err)
+	}
+
+	shares, err := pgx.CollectRows(rows, pgx.RowToStructByName[share.Share])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:todo_shares for %s_id=%s: %w", resourceType, resourceID.String(), err)
+	}
+
+	return shares, nil
+}
+
+func (r *ShareRepository) UpdateShareRole(ctx context.Context, ownerID string, shareID uuid.UUID, role share.Role) (*share.Share, error) {
+	stmt := `
+		UPDATE
+			todo_shares
+		SET
+			role=@role
+		WHERE
+			id=@id
+			AND owner_id=@owner_id
+		RETURNING
+		*
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"id":       shareID,
+		"owner_id": ownerID,
+		"role":     role,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute update share query for share_id=%s owner_id=%s: %w", shareID.String(), ownerID, err)
+	}
+
+	shareItem, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[share.Share])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect row from table:todo_shares for share_id=%s owner_id=%s: %w", shareID.String(), ownerID, err)
+	}
+
+	return &shareItem, nil
+}
+
+// DeleteShare revokes a share. Owners can revoke any of their shares and
+// collaborators can remove themselves.
+func (r *ShareRepository) DeleteShare(ctx context.Context, userID string, shareID uuid.UUID) (*share.Share, error) {
+	stmt := `
+		DELETE FROM todo_shares
+		WHERE
+			id=@id
+			AND (
+				owner_id=@user_id
+				OR user_id=@user_id
+			)
+		RETURNING
+		*
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"id":      shareID,
+		"user_id": userID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute delete share query for share_id=%s user_id=%s: %w", shareID.String(), userID, err)
+	}
+
+	shareItem, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[share.Share])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect row from table:todo_shares for share_id=%s user_id=%s: %w", shareID.String(), userID, err)
+	}
+
+	return &shareItem, nil
+}
+
+// GetPendingInvitation loads the pending share an invitation token belongs to. In a
+// unit of work the share stays locked until it ends, so an invitation is only
+// accepted once.
+func (r *ShareRepository) GetPendingInvitation(ctx context.Context, token string) (*share.Share, error) {
+	stmt := `
+		SELECT
+			*
+		FROM
+			todo_shares
+		WHERE
+			invite_token=@invite_token
+			AND status='pending'
+		FOR UPDATE
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"invite_token": token,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get invitation query: %w", err)
+	}
+
+	shareItem, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[share.Share])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect row from table:todo_shares for invitation: %w", err)
+	}
+
+	return &shareItem, nil
+}
+
+// AcceptInvitation gives the user the invited role, provided the invitation was
+// sent to one of the user's verified email addresses, given in lower case.
+func (r *ShareRepository) AcceptInvitation(ctx context.Context, userID string, token string,
+	verifiedEmails []string,
+) (*share.Share, error) {
+	stmt := `
+		UPDATE
+			todo_shares
+		SET
+			user_id=@user_id,
+			status='accepted',
+			accepted_at=CURRENT_TIMESTAMP
+		WHERE
+			invite_token=@invite_token
+			AND status='pending'
+			AND owner_id!=@user_id
+			AND email=ANY (@emails)
+		RETURNING
+		*
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"user_id":      userID,
+		"invite_token": token,
+		"emails":       verifiedEmails,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute accept invitation query for user_id=%s: %w", userID, err)
+	}
+
+	shareItem, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[share.Share])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect row from table:todo_shares for user_id=%s: %w", userID, err)
+	}
+
+	return &shareItem, nil
+}
@@ -7,8 +7,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ApoorvYdv/go-tasker/internal/database"
 	"github.com/ApoorvYdv/go-tasker/internal/errs"
 	"github.com/ApoorvYdv/go-tasker/internal/model"
+	"github.com/ApoorvYdv/go-tasker/internal/model/event"
+	"github.com/ApoorvYdv/go-tasker/internal/model/share"
 	"github.com/ApoorvYdv/go-tasker/internal/model/todo"
 	"github.com/ApoorvYdv/go-tasker/internal/server"
 	"github.com/google/uuid"
@@ -25,8 +28,8 @@ func NewTodoRepository(server *server.Server) *TodoRepository {
 
 func (r *TodoRepository) CreateTodo(ctx context.Context, user_id string, request *todo.CreateTodoPayload) (*todo.Todo, error) {
 	stmt := `INSERT INTO todos 
-				(user_id, title, description, due_date, priority, parent_todo_id, category_id, metadata) 
-				VALUES (@user_id, @title, @description, @due_date, @priority, @parent_todo_id, @category_id, @metadata) 
+				(user_id, organization_id, title, description, due_date, priority, parent_todo_id, category_id, metadata) 
+				VALUES (@user_id, @org_id, @title, @description, @due_date, @priority, @parent_todo_id, @category_id, @metadata) 
 				RETURNING *`
 
 	priority := todo.PriorityMedium
@@ -34,92 +37,135 @@ func (r *TodoRepository) CreateTodo(ctx context.Context, user_id string, request
 		priority = *request.Priority
 	}
 
-	rows, err := r.server.DB.Pool.Query(ctx, stmt, pgx.NamedArgs{
-		"user_id":        user_id,
-		"title":          request.Title,
-		"description":    request.Description,
-		"due_date":       request.DueDate,
-		"priority":       priority,
-		"parent_todo_id": request.ParentTodoID,
-		"category_id":    request.CategoryID,
-		"metadata":       request.Metadata,
-	})
+	var todoItem todo.Todo
+	err := r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
+
+		rows, err := q.Query(ctx, stmt, withScope(ctx, pgx.NamedArgs{
+			"user_id":        user_id,
+			"title":          request.Title,
+			"description":    request.Description,
+			"due_date":       request.DueDate,
+			"priority":       priority,
+			"parent_todo_id": request.ParentTodoID,
+			"category_id":    request.CategoryID,
+			"metadata":       request.Metadata,
+		}))
+
+		if err != nil {
+			return fmt.Errorf("failed to create a todo for user_id=%s with title=%s: %w", user_id, request.Title, err)
+		}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to create a todo for user_id=%s with title=%s: %w", user_id, request.Title, err)
-	}
+		todoItem, err = pgx.CollectOneRow(rows, pgx.RowToStructByName[todo.Todo])
+		if err != nil {
+			return fmt.Errorf("failed to scan a todo for user_id=%s with title=%s: %w", user_id, request.Title, err)
+		}
 
-	todoItem, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[todo.Todo])
+		return recordEvents(ctx, q, user_id, event.TodoCreated{Todo: todoItem})
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to scan a todo for user_id=%s with title=%s: %w", user_id, request.Title, err)
+		return nil, err
 	}
 
 	return &todoItem, nil
 }
 
+// populatedTodoSelect selects a todo with its category, subtasks, comments and attachments.
+// Comments come back flat; callers nest them into threads with threadComments.
+// Relations are aggregated in correlated subqueries so they don't multiply each other.
+const populatedTodoSelect = `
+	SELECT
+		t.*,
+		todo_access_role(t.id, @user_id, @org_id, @todo_role) AS access_role,
+		CASE
+			WHEN c.id IS NOT NULL THEN to_jsonb(camel (c))
+			ELSE NULL
+		END AS category,
+		COALESCE(
+			(
+				SELECT
+					jsonb_agg(
+						to_jsonb(camel (child))
+						ORDER BY
+							child.sort_order ASC,
+							child.created_at ASC
+					)
+				FROM
+					todos child
+				WHERE
+					child.parent_todo_id=t.id
+			),
+			'[]'::JSONB
+		) AS children,
+		COALESCE(
+			(
+				SELECT
+					jsonb_agg(
+						to_jsonb(camel (com)) || jsonb_build_object(
+							'reactions',
+							` + commentReactionsSelect + `
+						)
+						ORDER BY
+							com.created_at ASC
+					)
+				FROM
+					todo_comments com
+				WHERE
+					com.todo_id=t.id
+			),
+			'[]'::JSONB
+		) AS comments,
+		COALESCE(
+			(
+				SELECT
+					jsonb_agg(
+						to_jsonb(camel (att))
+						ORDER BY
+							att.created_at DESC
+					)
+				FROM
+					todo_attachments att
+				WHERE
+					att.todo_id=t.id
+			),
+			'[]'::JSONB
+		) AS attachments,
+		ARRAY(
+			SELECT
+				a.user_id
+			FROM
+				todo_assignees a
+			WHERE
+				a.todo_id=t.id
+			ORDER BY
+				a.created_at ASC
+		) AS assignee_ids,
+		ARRAY(
+			SELECT
+				w.user_id
+			FROM
+				todo_watchers w
+			WHERE
+				w.todo_id=t.id
+			ORDER BY
+				w.created_at ASC
+		) AS watcher_ids
+	FROM
+		todos t
+		LEFT JOIN todo_categories c ON c.id=t.category_id
+`
+
 func (r *TodoRepository) GetTodoByID(ctx context.Context, user_id string, todoID uuid.UUID) (*todo.PopulatedTodo, error) {
-	stmt := `SELECT
-			t.*,
-			CASE
-				WHEN c.id IS NOT NULL THEN to_jsonb(camel (c))
-				ELSE NULL
-			END AS category,
-			COALESCE(
-				jsonb_agg(
-					to_jsonb(camel (child))
-					ORDER BY
-						child.sort_order ASC,
-						child.created_at ASC
-				) FILTER (
-					WHERE
-						child.id IS NOT NULL
-				),
-				'[]'::JSONB
-			) AS children,
-			COALESCE(
-				jsonb_agg(
-					to_jsonb(camel (com))
-					ORDER BY
-						com.created_at ASC
-				) FILTER (
-					WHERE
-						com.id IS NOT NULL
-				),
-				'[]'::JSONB
-			) AS comments,
-			COALESCE(
-				jsonb_agg(
-					to_jsonb(camel (att))
-					ORDER BY
-						att.created_at DESC
-				) FILTER (
-					WHERE
-						att.id IS NOT NULL
-				),
-				'[]'::JSONB
-			) AS attachments
-		FROM
-			todos t
-			LEFT JOIN todo_categories c ON c.id=t.category_id
-			AND c.user_id=@user_id
-			LEFT JOIN todos child ON child.parent_todo_id=t.id
-			AND child.user_id=@user_id
-			LEFT JOIN todo_comments com ON com.todo_id=t.id
-			AND com.user_id=@user_id
-			LEFT JOIN todo_attachments att ON att.todo_id=t.id
+	stmt := populatedTodoSelect + `
 		WHERE
 			t.id=@id
-			AND t.user_id=@user_id
-		GROUP BY
-			t.id,
-			c.id,
-			att.id
+			AND todo_access_role(t.id, @user_id, @org_id, @todo_role) IS NOT NULL
 	`
 
-	rows, err := r.server.DB.Pool.Query(ctx, stmt, pgx.NamedArgs{
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, withScope(ctx, pgx.NamedArgs{
 		"id":      todoID,
 		"user_id": user_id,
-	})
+	}))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to get todo with id=%s for user_id=%s: %w", todoID, user_id, err)
@@ -130,88 +176,155 @@ func (r *TodoRepository) GetTodoByID(ctx context.Context, user_id string, todoID
 		return nil, fmt.Errorf("failed to scan a todo for user_id=%s with id=%s: %w", user_id, todoID, err)
 	}
 
+	todoItem.Comments = threadComments(todoItem.Comments)
+
 	return &todoItem, nil
 }
 
-func (r *TodoRepository) CheckTodoExists(ctx context.Context, userID string, todoID uuid.UUID) (*todo.Todo, error) {
+type todoWithAccess struct {
+	todo.Todo
+	AccessRole share.Role `db:"access_role"`
+}
+
+// CheckTodoAccess loads a todo the user can reach as owner or collaborator and
+// verifies their role is at least minRole.
+func (r *TodoRepository) CheckTodoAccess(ctx context.Context, userID string, todoID uuid.UUID, minRole share.Role) (*todo.Todo, error) {
 	stmt := `
-		SELECT * FROM todos
-		WHERE id=@id AND user_id=@user_id
+		SELECT
+			t.*,
+			todo_access_role(t.id, @user_id, @org_id, @todo_role) AS access_role
+		FROM
+			todos t
+		WHERE
+			t.id=@id
+			AND todo_access_role(t.id, @user_id, @org_id, @todo_role) IS NOT NULL
 	`
 
-	rows, err := r.server.DB.Pool.Query(ctx, stmt, pgx.NamedArgs{
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, withScope(ctx, pgx.NamedArgs{
 		"id":      todoID,
 		"user_id": userID,
-	})
+	}))
 	if err != nil {
-		return nil, fmt.Errorf("failed to check if todo exists for todo_id=%s user_id=%s: %w", todoID.String(), userID, err)
+		return nil, fmt.Errorf("failed to check todo access for todo_id=%s user_id=%s: %w", todoID.String(), userID, err)
 	}
 
-	todoItem, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[todo.Todo])
+	item, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[todoWithAccess])
 	if err != nil {
 		return nil, fmt.Errorf("failed to collect row from table:todos for todo_id=%s user_id=%s: %w", todoID.String(), userID, err)
 	}
 
-	return &todoItem, nil
+	if !item.AccessRole.AtLeast(minRole) {
+		return nil, errs.NewForbiddenError(fmt.Sprintf("this action requires %s access to the todo", minRole), false)
+	}
+
+	return &item.Todo, nil
 }
 
-func (r *TodoRepository) GetTodos(ctx context.Context, userID string, query *todo.GetTodosQuery) (*model.PaginatedResponse[todo.PopulatedTodo], error) {
+// GetTodo looks a todo up without an access check, for background work such as
+// event subscribers.
+func (r *TodoRepository) GetTodo(ctx context.Context, todoID uuid.UUID) (*todo.Todo, error) {
 	stmt := `
-	SELECT
-		t.*,
-		CASE
-			WHEN c.id IS NOT NULL THEN to_jsonb(camel (c))
-			ELSE NULL
-		END AS category,
-		COALESCE(
-			jsonb_agg(
-				to_jsonb(camel (child))
-				ORDER BY
-					child.sort_order ASC,
-					child.created_at ASC
-			) FILTER (
-				WHERE
-					child.id IS NOT NULL
-			),
-			'[]'::JSONB
-		) AS children,
-		COALESCE(
-			jsonb_agg(
-				to_jsonb(camel (com))
-				ORDER BY
-					com.created_at ASC
-			) FILTER (
-				WHERE
-					com.id IS NOT NULL
-			),
-			'[]'::JSONB
-		) AS comments,
-		COALESCE(
-				jsonb_agg(
-					to_jsonb(camel (att))
-					ORDER BY
-						att.created_at DESC
-				) FILTER (
-					WHERE
-						att.id IS NOT NULL
-				),
-				'[]'::JSONB
-			) AS attachments
-	FROM
-		todos t
-		LEFT JOIN todo_categories c ON c.id=t.category_id
-		AND c.user_id=@user_id
-		LEFT JOIN todos child ON child.parent_todo_id=t.id
-		AND child.user_id=@user_id
-		LEFT JOIN todo_comments com ON com.todo_id=t.id
-		AND com.user_id=@user_id
-		LEFT JOIN todo_attachments att ON att.todo_id=t.id
-`
+		SELECT
+			*
+		FROM
+			todos
+		WHERE
+			id=@todo_id
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"todo_id": todoID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get todo query for todo_id=%s: %w", todoID.String(), err)
+	}
+
+	item, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[todo.Todo])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			code := "TODO_NOT_FOUND"
+			return nil, errs.NewNotFoundError("todo not found", false, &code)
+		}
+		return nil, fmt.Errorf("failed to collect row from table:todos for todo_id=%s: %w", todoID.String(), err)
+	}
+
+	return &item, nil
+}
+
+// GetTodoMemberIDs returns the owner and every accepted collaborator of a todo,
+// including those who reach it through its parent or category.
+func (r *TodoRepository) GetTodoMemberIDs(ctx context.Context, todoID uuid.UUID) ([]string, error) {
+	return queryTodoMemberIDs(ctx, r.server.DB.Querier(ctx), todoID)
+}
+
+func queryTodoMemberIDs(ctx context.Context, q database.Querier, todoID uuid.UUID) ([]string, error) {
+	stmt := `
+		SELECT
+			t.user_id
+		FROM
+			todos t
+		WHERE
+			t.id=@todo_id
+		UNION
+		SELECT
+			p.user_id
+		FROM
+			todos t
+			JOIN todos p ON p.id=t.parent_todo_id
+		WHERE
+			t.id=@todo_id
+		UNION
+		SELECT
+			s.user_id
+		FROM
+			todos t
+			LEFT JOIN todos p ON p.id=t.parent_todo_id
+			JOIN todo_shares s ON s.status='accepted'
+			AND (
+				s.todo_id IN (t.id, t.parent_todo_id)
+				OR s.category_id IN (
+					SELECT
+						category_ancestor_ids(t.category_id)
+					UNION
+					SELECT
+						category_ancestor_ids(p.category_id)
+				)
+			)
+		WHERE
+			t.id=@todo_id
+	`
+
+	rows, err := q.Query(ctx, stmt, pgx.NamedArgs{
+		"todo_id": todoID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get members for todo_id=%s: %w", todoID.String(), err)
+	}
 
-	args := pgx.NamedArgs{
+	memberIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:todo_shares for todo_id=%s: %w", todoID.String(), err)
+	}
+
+	return memberIDs, nil
+}
+
+func (r *TodoRepository) GetTodos(ctx context.Context, userID string, query *todo.GetTodosQuery) (*model.PaginatedResponse[todo.PopulatedTodo], error) {
+	stmt := populatedTodoSelect
+
+	args := withScope(ctx, pgx.NamedArgs{
 		"user_id": userID,
+	})
+	conditions := []string{todoReach("t"), "todo_access_role(t.id, @user_id, @org_id, @todo_role) IS NOT NULL"}
+
+	if query.Ownership != nil {
+		switch *query.Ownership {
+		case "owned":
+			conditions = append(conditions, "todo_access_role(t.id, @user_id, @org_id, @todo_role) = 'owner'")
+		case "shared":
+			conditions = append(conditions, "todo_access_role(t.id, @user_id, @org_id, @todo_role) != 'owner'")
+		}
 	}
-	conditions := []string{"t.user_id = @user_id"}
 
 	if query.Status != nil {
 		conditions = append(conditions, "t.status = @status")
@@ -224,14 +337,34 @@ func (r *TodoRepository) GetTodos(ctx context.Context, userID string, query *tod
 	}
 
 	if query.CategoryID != nil {
-		conditions = append(conditions, "t.category_id = @category_id")
+		if query.IncludeSubcategories != nil && *query.IncludeSubcategories {
+			conditions = append(conditions, `t.category_id IN (
+				WITH RECURSIVE subtree AS (
+					SELECT @category_id::UUID AS id
+					UNION
+					SELECT c.id FROM todo_categories c JOIN subtree s ON c.parent_id = s.id
+				)
+				SELECT id FROM subtree
+			)`)
+		} else {
+			conditions = append(conditions, "t.category_id = @category_id")
+		}
 		args["category_id"] = *query.CategoryID
 	}
 
+	if query.Assignee != nil {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM todo_assignees a WHERE a.todo_id = t.id AND a.user_id = @assignee)")
+		if *query.Assignee == "me" {
+			args["assignee"] = userID
+		} else {
+			args["assignee"] = *query.Assignee
+		}
+	}
+
 	if query.ParentTodoID != nil {
 		conditions = append(conditions, "t.parent_todo_id = @parent_todo_id")
 		args["parent_todo_id"] = *query.ParentTodoID
-	} else {
+	} else if query.Assignee == nil {
 		// By default, only show root todos (no parent)
 		conditions = append(conditions, "t.parent_todo_id IS NULL")
 	}
@@ -273,13 +406,11 @@ func (r *TodoRepository) GetTodos(ctx context.Context, userID string, query *tod
 	}
 
 	var total int
-	err := r.server.DB.Pool.QueryRow(ctx, countStmt, args).Scan(&total)
+	err := r.server.DB.Querier(ctx).QueryRow(ctx, countStmt, args).Scan(&total)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get total count for todos user_id=%s: %w", userID, err)
 	}
 
-	stmt += " GROUP BY t.id, c.id"
-
 	if query.Sort != nil {
 		stmt += " ORDER BY t." + *query.Sort
 		if query.Order != nil && *query.Order == "desc" {
@@ -295,7 +426,7 @@ func (r *TodoRepository) GetTodos(ctx context.Context, userID string, query *tod
 	args["limit"] = *query.Limit
 	args["offset"] = (*query.Page - 1) * (*query.Limit)
 
-	rows, err := r.server.DB.Pool.Query(ctx, stmt, args)
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, args)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute get todos query for user_id=%s: %w", userID, err)
 	}
@@ -314,6 +445,10 @@ func (r *TodoRepository) GetTodos(ctx context.Context, userID string, query *tod
 		return nil, fmt.Errorf("failed to collect rows from table:todos for user_id=%s: %w", userID, err)
 	}
 
+	for i := range todos {
+		todos[i].Comments = threadComments(todos[i].Comments)
+	}
+
 	return &model.PaginatedResponse[todo.PopulatedTodo]{
 		Data:       todos,
 		Page:       *query.Page,
@@ -325,25 +460,29 @@ func (r *TodoRepository) GetTodos(ctx context.Context, userID string, query *tod
 
 func (r *TodoRepository) UpdateTodo(ctx context.Context, userID string, payload *todo.UpdateTodoPayload) (*todo.Todo, error) {
 	stmt := "UPDATE todos SET "
-	args := pgx.NamedArgs{
+	args := withScope(ctx, pgx.NamedArgs{
 		"todo_id": payload.ID,
 		"user_id": userID,
-	}
+	})
 	setClauses := []string{}
+	changed := []string{}
 
 	if payload.Title != nil {
 		setClauses = append(setClauses, "title = @title")
 		args["title"] = *payload.Title
+		changed = append(changed, "title")
 	}
 
 	if payload.Description != nil {
 		setClauses = append(setClauses, "description = @description")
 		args["description"] = *payload.Description
+		changed = append(changed, "description")
 	}
 
 	if payload.Status != nil {
 		setClauses = append(setClauses, "status = @status")
 		args["status"] = *payload.Status
+		changed = append(changed, "status")
 
 		// Auto-set completed_at when status changes to completed
 		if *payload.Status == todo.StatusCompleted {
@@ -355,21 +494,25 @@ func (r *TodoRepository) UpdateTodo(ctx context.Context, userID string, payload
 	}
 
 	if payload.Priority != nil {
+		changed = append(changed, "priority")
 		setClauses = append(setClauses, "priority = @priority")
 		args["priority"] = *payload.Priority
 	}
 
 	if payload.DueDate != nil {
+		changed = append(changed, "dueDate")
 		setClauses = append(setClauses, "due_date = @due_date")
 		args["due_date"] = *payload.DueDate
 	}
 
 	if payload.ParentTodoID != nil {
+		changed = append(changed, "parentTodoId")
 		setClauses = append(setClauses, "parent_todo_id = @parent_todo_id")
 		args["parent_todo_id"] = *payload.ParentTodoID
 	}
 
 	if payload.CategoryID != nil {
+		changed = append(changed, "categoryId")
 		setClauses = append(setClauses, "category_id = @category_id")
 		args["category_id"] = *payload.CategoryID
 	}
@@ -377,6 +520,7 @@ func (r *TodoRepository) UpdateTodo(ctx context.Context, userID string, payload
 	if payload.Metadata != nil {
 		setClauses = append(setClauses, "metadata = @metadata")
 		args["metadata"] = payload.Metadata
+		changed = append(changed, "metadata")
 	}
 
 	if len(setClauses) == 0 {
@@ -384,43 +528,148 @@ func (r *TodoRepository) UpdateTodo(ctx context.Context, userID string, payload
 	}
 
 	stmt += strings.Join(setClauses, ", ")
-	stmt += " WHERE id = @todo_id AND user_id = @user_id RETURNING *"
+	stmt += " WHERE id = @todo_id AND share_role_rank(todo_access_role(id, @user_id, @org_id, @todo_role)) >= share_role_rank('editor') RETURNING *"
+
+	updated := event.TodoUpdated{Changed: changed}
+	err := r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
+
+		// Lock the todo while reading what the update replaces; the update itself checks access
+		err := q.QueryRow(ctx, `
+			SELECT
+				status,
+				due_date
+			FROM
+				todos
+			WHERE
+				id=@todo_id
+			FOR UPDATE
+		`, args).Scan(&updated.PreviousStatus, &updated.PreviousDueDate)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
 
-	rows, err := r.server.DB.Pool.Query(ctx, stmt, args)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %w", err)
-	}
+		rows, err := q.Query(ctx, stmt, args)
+		if err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+
+		updated.Todo, err = pgx.CollectOneRow(rows, pgx.RowToStructByName[todo.Todo])
+		if err != nil {
+			return fmt.Errorf("failed to collect row from table:todos: %w", err)
+		}
 
-	updatedTodo, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[todo.Todo])
+		return recordEvents(ctx, q, userID, updated)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to collect row from table:todos: %w", err)
+		return nil, err
 	}
 
-	return &updatedTodo, nil
+	return &updated.Todo, nil
 }
 
-func (r *TodoRepository) DeleteTodo(ctx context.Context, userID string, todoID uuid.UUID) error {
-	stmt := `
-		DELETE FROM todos
-		WHERE
-			id=@todo_id
-			AND user_id=@user_id
-	`
+// DeleteTodo deletes a todo with its attachments and pending uploads, releasing
+// the blobs they reference. It returns the storage keys of content nothing
+// references anymore and of the pending uploads, for the caller to delete.
+func (r *TodoRepository) DeleteTodo(ctx context.Context, userID string, todoID uuid.UUID) ([]string, error) {
+	var keys []string
+	err := r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
+
+		// Locking the todo keeps attachments from being added until it is gone
+		deleted := event.TodoDeleted{TodoID: todoID}
+		err := q.QueryRow(ctx, `
+			SELECT
+				parent_todo_id,
+				title
+			FROM
+				todos
+			WHERE
+				id=@todo_id
+				AND todo_access_role(id, @user_id, @org_id, @todo_role)='owner'
+			FOR UPDATE
+		`, withScope(ctx, pgx.NamedArgs{
+			"todo_id": todoID,
+			"user_id": userID,
+		})).Scan(&deleted.ParentTodoID, &deleted.Title)
+		if errors.Is(err, pgx.ErrNoRows) {
+			code := "TODO_NOT_FOUND"
+			return errs.NewNotFoundError("todo not found", false, &code)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
 
-	result, err := r.server.DB.Pool.Exec(ctx, stmt, pgx.NamedArgs{
-		"todo_id": todoID,
-		"user_id": userID,
+		// Resolve collaborators before the shares are deleted along with the todo
+		deleted.MemberIDs, err = queryTodoMemberIDs(ctx, q, todoID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := q.Query(ctx, `
+			SELECT
+				v.*
+			FROM
+				attachment_versions v
+				JOIN todo_attachments a ON a.id=v.attachment_id
+			WHERE
+				a.todo_id=@todo_id
+		`, pgx.NamedArgs{
+			"todo_id": todoID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+
+		versions, err := pgx.CollectRows(rows, pgx.RowToStructByName[todo.AttachmentVersion])
+		if err != nil {
+			return fmt.Errorf("failed to collect rows from table:attachment_versions for todo_id=%s: %w", todoID.String(), err)
+		}
+
+		rows, err = q.Query(ctx, `
+			SELECT
+				storage_key
+			FROM
+				attachment_uploads
+			WHERE
+				todo_id=@todo_id
+				AND completed_at IS NULL
+		`, pgx.NamedArgs{
+			"todo_id": todoID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+
+		keys, err = pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return fmt.Errorf("failed to collect rows from table:attachment_uploads for todo_id=%s: %w", todoID.String(), err)
+		}
+
+		_, err = q.Exec(ctx, `
+			DELETE FROM todos
+			WHERE
+				id=@todo_id
+		`, pgx.NamedArgs{
+			"todo_id": todoID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+
+		orphanedKeys, err := releaseVersions(ctx, q, versions)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, orphanedKeys...)
+
+		return recordEvents(ctx, q, userID, deleted)
 	})
 	if err != nil {
-		return fmt.Errorf("failed to execute query: %w", err)
-	}
-
-	if result.RowsAffected() == 0 {
-		code := "TODO_NOT_FOUND"
-		return errs.NewNotFoundError("todo not found", false, &code)
+		return nil, err
 	}
 
-	return nil
+	return keys, nil
 }
 
 func (r *TodoRepository) GetTodoStats(ctx context.Context, userID string) (*todo.TodoStats, error) {
@@ -452,16 +701,46 @@ func (r *TodoRepository) GetTodoStats(ctx context.Context, userID string) (*todo
 					WHEN due_date<NOW()
 					AND status!='completed' THEN 1
 				END
-			) AS overdue
+			) AS overdue,
+			(
+				SELECT
+					COUNT(*)
+				FROM
+					todos at
+					JOIN todo_assignees a ON a.todo_id=at.id
+				WHERE
+					a.user_id=@user_id
+					AND at.status NOT IN ('completed', 'archived')
+					AND ` + todoReach("at") + `
+					AND todo_access_role(at.id, @user_id, @org_id, @todo_role) IS NOT NULL
+			) AS assigned_to_me,
+			(
+				SELECT
+					COUNT(*)
+				FROM
+					todos at
+					JOIN todo_assignees a ON a.todo_id=at.id
+				WHERE
+					a.user_id=@user_id
+					AND at.status NOT IN ('completed', 'archived')
+					AND at.due_date<NOW()
+					AND ` + todoReach("at") + `
+					AND todo_access_role(at.id, @user_id, @org_id, @todo_role) IS NOT NULL
+			) AS assigned_to_me_overdue
 		FROM
 			todos
 		WHERE
-			user_id=@user_id
+			organization_id=@org_id
+			OR (
+				@org_id::TEXT IS NULL
+				AND organization_id IS NULL
+				AND user_id=@user_id
+			)
 	`
 
-	rows, err := r.server.DB.Pool.Query(ctx, stmt, pgx.NamedArgs{
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, withScope(ctx, pgx.NamedArgs{
 		"user_id": userID,
-	})
+	}))
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
@@ -474,6 +753,35 @@ func (r *TodoRepository) GetTodoStats(ctx context.Context, userID string) (*todo
 	return &stats, nil
 }
 
+// GetSubtasks returns a todo's subtasks in their display order.
+func (r *TodoRepository) GetSubtasks(ctx context.Context, todoID uuid.UUID) ([]todo.Todo, error) {
+	stmt := `
+		SELECT
+			*
+		FROM
+			todos
+		WHERE
+			parent_todo_id=@todo_id
+		ORDER BY
+			sort_order ASC,
+			created_at ASC
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"todo_id": todoID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get subtasks query for todo_id=%s: %w", todoID.String(), err)
+	}
+
+	subtasks, err := pgx.CollectRows(rows, pgx.RowToStructByName[todo.Todo])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:todos for todo_id=%s: %w", todoID.String(), err)
+	}
+
+	return subtasks, nil
+}
+
 func (r *TodoRepository) GetTodoAttachment(ctx context.Context, todoID uuid.UUID, attachmentID uuid.UUID) (*todo.Attachment, error) {
 	stmt := `
 		SELECT *
@@ -481,7 +789,7 @@ func (r *TodoRepository) GetTodoAttachment(ctx context.Context, todoID uuid.UUID
 		WHERE todo_id=@todo_id AND id=@attachment_id
 	`
 
-	rows, err := r.server.DB.Pool.Query(ctx, stmt, pgx.NamedArgs{
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
 		"todo_id":       todoID,
 		"attachment_id": attachmentID,
 	})
@@ -501,6 +809,30 @@ func (r *TodoRepository) GetTodoAttachment(ctx context.Context, todoID uuid.UUID
 	return &attachments, nil
 }
 
+// GetAttachmentTodoID returns the todo an attachment belongs to, for routes that
+// name the attachment alone. Callers still check access to that todo.
+func (r *TodoRepository) GetAttachmentTodoID(ctx context.Context, attachmentID uuid.UUID) (uuid.UUID, error) {
+	stmt := `
+		SELECT todo_id
+		FROM todo_attachments
+		WHERE id=@attachment_id
+	`
+
+	var todoID uuid.UUID
+	err := r.server.DB.Querier(ctx).QueryRow(ctx, stmt, pgx.NamedArgs{
+		"attachment_id": attachmentID,
+	}).Scan(&todoID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			code := "ATTACHMENT_NOT_FOUND"
+			return uuid.Nil, errs.NewNotFoundError("attachment not found", false, &code)
+		}
+		return uuid.Nil, fmt.Errorf("failed to get todo of attachment: %w", err)
+	}
+
+	return todoID, nil
+}
+
 func (r *TodoRepository) GetTodoAttachments(ctx context.Context, todoID uuid.UUID) ([]todo.Attachment, error) {
 	stmt := `
 		SELECT *
@@ -508,7 +840,7 @@ func (r *TodoRepository) GetTodoAttachments(ctx context.Context, todoID uuid.UUI
 		WHERE todo_id=@todo_id ORDER BY created_at DESC
 	`
 
-	rows, err := r.server.DB.Pool.Query(ctx, stmt, pgx.NamedArgs{
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
 		"todo_id": todoID,
 	})
 	if err != nil {
@@ -526,51 +858,1654 @@ func (r *TodoRepository) GetTodoAttachments(ctx context.Context, todoID uuid.UUI
 	return attachments, nil
 }
 
-func (r *TodoRepository) DeleteTodoAttachment(ctx context.Context, todoID uuid.UUID, attachmentID uuid.UUID) error {
+// GetAttachmentByID looks an attachment up without an access check, for
+// background jobs.
+func (r *TodoRepository) GetAttachmentByID(ctx context.Context, attachmentID uuid.UUID) (*todo.Attachment, error) {
 	stmt := `
-		DELETE FROM todo_attachments
-		WHERE todo_id=@todo_id AND id=@attachment_id
+		SELECT
+			*
+		FROM
+			todo_attachments
+		WHERE
+			id=@attachment_id
 	`
 
-	result, err := r.server.DB.Pool.Exec(ctx, stmt, pgx.NamedArgs{
-		"todo_id":       todoID,
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
 		"attachment_id": attachmentID,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to execute query: %w", err)
+		return nil, fmt.Errorf("failed to execute get attachment query for attachment_id=%s: %w", attachmentID.String(), err)
 	}
 
-	if result.RowsAffected() == 0 {
-		code := "ATTACHMENT_NOT_FOUND"
-		return errs.NewNotFoundError("attachment not found", false, &code)
+	attachment, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[todo.Attachment])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			code := "ATTACHMENT_NOT_FOUND"
+			return nil, errs.NewNotFoundError("attachment not found", false, &code)
+		}
+		return nil, fmt.Errorf("failed to collect row from table:todo_attachments for attachment_id=%s: %w", attachmentID.String(), err)
 	}
 
-	return nil
+	return &attachment, nil
 }
 
-func (r *TodoRepository) UploadTodoAttachment(ctx context.Context, userID string, todoID uuid.UUID, fileName string, fileSize int64, mimeType string, key string) (*todo.Attachment, error) {
+// UpdateAttachmentPreview records the outcome of generating an attachment's preview.
+func (r *TodoRepository) UpdateAttachmentPreview(ctx context.Context, attachmentID uuid.UUID,
+	status todo.PreviewStatus, thumbnailKey *string, pageCount *int,
+) (*todo.Attachment, error) {
 	stmt := `
-		INSERT INTO todo_attachments (todo_id, name, uploaded_by, download_key, file_size, mime_type)
-		VALUES (@todo_id, @file_name, @uploaded_by, @download_key, @file_size, @mime_type)
-		RETURNING *
+		UPDATE
+			todo_attachments
+		SET
+			preview_status=@preview_status,
+			thumbnail_key=@thumbnail_key,
+			page_count=@page_count
+		WHERE
+			id=@attachment_id
+		RETURNING
+			*
 	`
 
-	rows, err := r.server.DB.Pool.Query(ctx, stmt, pgx.NamedArgs{
-		"todo_id":      todoID,
-		"file_name":    fileName,
-		"file_size":    fileSize,
-		"mime_type":    mimeType,
-		"download_key": key,
-		"uploaded_by":  userID,
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"attachment_id":  attachmentID,
+		"preview_status": status,
+		"thumbnail_key":  thumbnailKey,
+		"page_count":     pageCount,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %w", err)
+		return nil, fmt.Errorf("failed to execute update attachment preview query for attachment_id=%s: %w", attachmentID.String(), err)
 	}
 
 	attachment, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[todo.Attachment])
 	if err != nil {
-		return nil, fmt.Errorf("failed to collect rows from table:todo_attachments: %w", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			code := "ATTACHMENT_NOT_FOUND"
+			return nil, errs.NewNotFoundError("attachment not found", false, &code)
+		}
+		return nil, fmt.Errorf("failed to collect row from table:todo_attachments for attachment_id=%s: %w", attachmentID.String(), err)
 	}
 
 	return &attachment, nil
 }
+
+// DeleteTodoAttachment deletes an attachment with all its versions, releasing
+// their blobs. It returns the storage keys to delete once nothing references the
+// content anymore.
+func (r *TodoRepository) DeleteTodoAttachment(ctx context.Context, userID string, todoID uuid.UUID,
+	attachmentID uuid.UUID,
+) ([]string, error) {
+	var keys []string
+	err := r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
+
+		attachment, err := lockAttachment(ctx, q, todoID, attachmentID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := q.Query(ctx, `
+			DELETE FROM attachment_versions
+			WHERE
+				attachment_id=@attachment_id
+			RETURNING
+				*
+		`, pgx.NamedArgs{
+			"attachment_id": attachmentID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+
+		versions, err := pgx.CollectRows(rows, pgx.RowToStructByName[todo.AttachmentVersion])
+		if err != nil {
+			return fmt.Errorf("failed to collect rows from table:attachment_versions for attachment_id=%s: %w", attachmentID.String(), err)
+		}
+
+		_, err = q.Exec(ctx, `
+			DELETE FROM todo_attachments
+			WHERE
+				id=@attachment_id
+		`, pgx.NamedArgs{
+			"attachment_id": attachmentID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+
+		keys, err = releaseVersions(ctx, q, versions)
+		if err != nil {
+			return err
+		}
+
+		return recordEvents(ctx, q, userID, event.AttachmentDeleted{
+			AttachmentID: attachmentID,
+			TodoID:       todoID,
+			Name:         attachment.Name,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// UploadTodoAttachment records an attachment of content stored under its checksum,
+// taking a reference on the user's blob for it.
+func (r *TodoRepository) UploadTodoAttachment(ctx context.Context, userID string, todoID uuid.UUID, fileName string, fileSize int64, mimeType string, key string, checksum string) (*todo.Attachment, error) {
+	var attachment todo.Attachment
+	err := r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
+
+		if err := acquireBlob(ctx, q, userID, checksum, key, fileSize, mimeType); err != nil {
+			return err
+		}
+
+		stmt := `
+			INSERT INTO todo_attachments (todo_id, name, uploaded_by, download_key, file_size, mime_type, checksum, preview_status)
+			VALUES (@todo_id, @file_name, @uploaded_by, @download_key, @file_size, @mime_type, @checksum, @preview_status)
+			RETURNING *
+		`
+
+		rows, err := q.Query(ctx, stmt, pgx.NamedArgs{
+			"todo_id":        todoID,
+			"file_name":      fileName,
+			"file_size":      fileSize,
+			"mime_type":      mimeType,
+			"download_key":   key,
+			"uploaded_by":    userID,
+			"checksum":       checksum,
+			"preview_status": todo.InitialPreviewStatus(mimeType),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+
+		attachment, err = pgx.CollectOneRow(rows, pgx.RowToStructByName[todo.Attachment])
+		if err != nil {
+			return fmt.Errorf("failed to collect rows from table:todo_attachments: %w", err)
+		}
+
+		if err := recordVersion(ctx, q, currentVersion(&attachment, userID)); err != nil {
+			return err
+		}
+
+		if err := r.checkStorageQuota(ctx, q, userID); err != nil {
+			return err
+		}
+
+		return recordEvents(ctx, q, userID, event.AttachmentAdded{TodoID: todoID, Attachment: attachment})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &attachment, nil
+}
+
+// AddAttachmentVersion makes newly stored content the current version of an
+// attachment, taking a reference on the uploader's blob for it. It returns the
+// updated attachment and the storage keys of versions pruned beyond maxVersions.
+func (r *TodoRepository) AddAttachmentVersion(ctx context.Context, userID string, todoID uuid.UUID,
+	attachmentID uuid.UUID, fileName string, fileSize int64, mimeType string, key string, checksum string,
+	maxVersions int,
+) (*todo.Attachment, []string, error) {
+	var (
+		attachment *todo.Attachment
+		keys       []string
+	)
+	err := r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
+
+		if _, err := lockAttachment(ctx, q, todoID, attachmentID); err != nil {
+			return err
+		}
+
+		if err := acquireBlob(ctx, q, userID, checksum, key, fileSize, mimeType); err != nil {
+			return err
+		}
+
+		var err error
+		attachment, keys, err = addVersion(ctx, q, &todo.AttachmentVersion{
+			AttachmentID: attachmentID,
+			UploadedBy:   userID,
+			Name:         fileName,
+			DownloadKey:  key,
+			FileSize:     &fileSize,
+			MimeType:     &mimeType,
+			Checksum:     &checksum,
+			ScanStatus:   todo.ScanPending,
+		}, maxVersions)
+		if err != nil {
+			return err
+		}
+
+		if err := r.checkStorageQuota(ctx, q, userID); err != nil {
+			return err
+		}
+
+		return recordEvents(ctx, q, userID, event.AttachmentVersionAdded{
+			TodoID:     todoID,
+			Attachment: *attachment,
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return attachment, keys, nil
+}
+
+// RestoreAttachmentVersion brings back the content of an earlier version as a new
+// current version, so the history stays intact. It returns the updated attachment
+// and the storage keys of versions pruned beyond maxVersions.
+func (r *TodoRepository) RestoreAttachmentVersion(ctx context.Context, userID string, todoID uuid.UUID,
+	attachmentID uuid.UUID, version int, maxVersions int,
+) (*todo.Attachment, []string, error) {
+	var (
+		attachment *todo.Attachment
+		keys       []string
+	)
+	err := r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
+
+		current, err := lockAttachment(ctx, q, todoID, attachmentID)
+		if err != nil {
+			return err
+		}
+
+		if version == current.Version {
+			code := "VERSION_ALREADY_CURRENT"
+			return errs.NewBadRequestError("this version is already the current one", false, &code, nil, nil)
+		}
+
+		rows, err := q.Query(ctx, `
+			SELECT
+				*
+			FROM
+				attachment_versions
+			WHERE
+				attachment_id=@attachment_id
+				AND version=@version
+		`, pgx.NamedArgs{
+			"attachment_id": attachmentID,
+			"version":       version,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+
+		source, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[todo.AttachmentVersion])
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				code := "VERSION_NOT_FOUND"
+				return errs.NewNotFoundError("attachment version not found", false, &code)
+			}
+			return fmt.Errorf("failed to collect row from table:attachment_versions for attachment_id=%s: %w", attachmentID.String(), err)
+		}
+
+		if source.ScanStatus == todo.ScanInfected {
+			code := "VERSION_INFECTED"
+			return errs.NewBadRequestError("this version contains malware and can't be restored", false, &code, nil, nil)
+		}
+
+		// The restored version shares the blob and scan verdict of the one it restores
+		if source.Checksum != nil {
+			var fileSize int64
+			if source.FileSize != nil {
+				fileSize = *source.FileSize
+			}
+			var mimeType string
+			if source.MimeType != nil {
+				mimeType = *source.MimeType
+			}
+			if err := acquireBlob(ctx, q, source.UploadedBy, *source.Checksum, source.DownloadKey, fileSize, mimeType); err != nil {
+				return err
+			}
+		}
+
+		restored := source
+		restored.RestoredFrom = &source.Version
+		restored.RestoredBy = &userID
+
+		attachment, keys, err = addVersion(ctx, q, &restored, maxVersions)
+		if err != nil {
+			return err
+		}
+
+		return recordEvents(ctx, q, userID, event.AttachmentVersionAdded{
+			TodoID:       todoID,
+			Attachment:   *attachment,
+			RestoredFrom: &source.Version,
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return attachment, keys, nil
+}
+
+// GetAttachmentVersions lists every kept version of an attachment, newest first.
+func (r *TodoRepository) GetAttachmentVersions(ctx context.Context, attachmentID uuid.UUID) ([]todo.AttachmentVersion, error) {
+	stmt := `
+		SELECT
+			*
+		FROM
+			attachment_versions
+		WHERE
+			attachment_id=@attachment_id
+		ORDER BY
+			version DESC
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"attachment_id": attachmentID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get attachment versions query for attachment_id=%s: %w", attachmentID.String(), err)
+	}
+
+	versions, err := pgx.CollectRows(rows, pgx.RowToStructByName[todo.AttachmentVersion])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:attachment_versions for attachment_id=%s: %w", attachmentID.String(), err)
+	}
+
+	return versions, nil
+}
+
+func (r *TodoRepository) GetAttachmentVersion(ctx context.Context, attachmentID uuid.UUID, version int) (*todo.AttachmentVersion, error) {
+	stmt := `
+		SELECT
+			*
+		FROM
+			attachment_versions
+		WHERE
+			attachment_id=@attachment_id
+			AND version=@version
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"attachment_id": attachmentID,
+		"version":       version,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get attachment version query for attachment_id=%s: %w", attachmentID.String(), err)
+	}
+
+	attachmentVersion, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[todo.AttachmentVersion])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			code := "VERSION_NOT_FOUND"
+			return nil, errs.NewNotFoundError("attachment version not found", false, &code)
+		}
+		return nil, fmt.Errorf("failed to collect row from table:attachment_versions for attachment_id=%s: %w", attachmentID.String(), err)
+	}
+
+	return &attachmentVersion, nil
+}
+
+// lockAttachment reads an attachment of the todo and locks it until the
+// transaction ends, so its versions change one at a time.
+func lockAttachment(ctx context.Context, q database.Querier, todoID uuid.UUID, attachmentID uuid.UUID) (*todo.Attachment, error) {
+	rows, err := q.Query(ctx, `
+		SELECT
+			*
+		FROM
+			todo_attachments
+		WHERE
+			todo_id=@todo_id
+			AND id=@attachment_id
+		FOR UPDATE
+	`, pgx.NamedArgs{
+		"todo_id":       todoID,
+		"attachment_id": attachmentID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	attachment, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[todo.Attachment])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			code := "ATTACHMENT_NOT_FOUND"
+			return nil, errs.NewNotFoundError("attachment not found", false, &code)
+		}
+		return nil, fmt.Errorf("failed to collect row from table:todo_attachments for attachment_id=%s: %w", attachmentID.String(), err)
+	}
+
+	return &attachment, nil
+}
+
+// addVersion makes the content of version the attachment's current one, numbering
+// it after the latest, and prunes versions beyond maxVersions. The caller holds the
+// version's blob reference and the attachment's lock.
+func addVersion(ctx context.Context, q database.Querier, version *todo.AttachmentVersion, maxVersions int,
+) (*todo.Attachment, []string, error) {
+	var mimeType string
+	if version.MimeType != nil {
+		mimeType = *version.MimeType
+	}
+
+	rows, err := q.Query(ctx, `
+		UPDATE
+			todo_attachments
+		SET
+			version=version + 1,
+			name=@name,
+			download_key=@download_key,
+			file_size=@file_size,
+			mime_type=@mime_type,
+			checksum=@checksum,
+			scan_status=@scan_status,
+			preview_status=@preview_status,
+			thumbnail_key=NULL,
+			page_count=NULL
+		WHERE
+			id=@attachment_id
+		RETURNING
+			*
+	`, pgx.NamedArgs{
+		"attachment_id":  version.AttachmentID,
+		"name":           version.Name,
+		"download_key":   version.DownloadKey,
+		"file_size":      version.FileSize,
+		"mime_type":      version.MimeType,
+		"checksum":       version.Checksum,
+		"scan_status":    version.ScanStatus,
+		"preview_status": todo.InitialPreviewStatus(mimeType),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to execute update attachment version query for attachment_id=%s: %w", version.AttachmentID.String(), err)
+	}
+
+	attachment, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[todo.Attachment])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to collect row from table:todo_attachments for attachment_id=%s: %w", version.AttachmentID.String(), err)
+	}
+
+	version.Version = attachment.Version
+	if err := recordVersion(ctx, q, version); err != nil {
+		return nil, nil, err
+	}
+
+	keys, err := pruneVersions(ctx, q, attachment.ID, attachment.Version, maxVersions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &attachment, keys, nil
+}
+
+// currentVersion describes an attachment's current content as a version uploaded by
+// uploadedBy.
+func currentVersion(attachment *todo.Attachment, uploadedBy string) *todo.AttachmentVersion {
+	return &todo.AttachmentVersion{
+		AttachmentID: attachment.ID,
+		Version:      attachment.Version,
+		UploadedBy:   uploadedBy,
+		Name:         attachment.Name,
+		DownloadKey:  attachment.DownloadKey,
+		FileSize:     attachment.FileSize,
+		MimeType:     attachment.MimeType,
+		Checksum:     attachment.Checksum,
+		ScanStatus:   attachment.ScanStatus,
+	}
+}
+
+func recordVersion(ctx context.Context, q database.Querier, version *todo.AttachmentVersion) error {
+	_, err := q.Exec(ctx, `
+		INSERT INTO
+			attachment_versions (
+				attachment_id,
+				version,
+				uploaded_by,
+				name,
+				download_key,
+				file_size,
+				mime_type,
+				checksum,
+				scan_status,
+				restored_from,
+				restored_by
+			)
+		VALUES
+			(
+				@attachment_id,
+				@version,
+				@uploaded_by,
+				@name,
+				@download_key,
+				@file_size,
+				@mime_type,
+				@checksum,
+				@scan_status,
+				@restored_from,
+				@restored_by
+			)
+	`, pgx.NamedArgs{
+		"attachment_id": version.AttachmentID,
+		"version":       version.Version,
+		"uploaded_by":   version.UploadedBy,
+		"name":          version.Name,
+		"download_key":  version.DownloadKey,
+		"file_size":     version.FileSize,
+		"mime_type":     version.MimeType,
+		"checksum":      version.Checksum,
+		"scan_status":   version.ScanStatus,
+		"restored_from": version.RestoredFrom,
+		"restored_by":   version.RestoredBy,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record version for attachment_id=%s: %w", version.AttachmentID.String(), err)
+	}
+
+	return nil
+}
+
+// pruneVersions deletes the versions of an attachment older than the newest keep
+// and returns the storage keys left unreferenced. keep 0 keeps every version.
+func pruneVersions(ctx context.Context, q database.Querier, attachmentID uuid.UUID, current int, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+
+	rows, err := q.Query(ctx, `
+		DELETE FROM attachment_versions
+		WHERE
+			attachment_id=@attachment_id
+			AND version<=@oldest_pruned
+		RETURNING
+			*
+	`, pgx.NamedArgs{
+		"attachment_id": attachmentID,
+		"oldest_pruned": current - keep,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute prune attachment versions query for attachment_id=%s: %w", attachmentID.String(), err)
+	}
+
+	versions, err := pgx.CollectRows(rows, pgx.RowToStructByName[todo.AttachmentVersion])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:attachment_versions for attachment_id=%s: %w", attachmentID.String(), err)
+	}
+
+	return releaseVersions(ctx, q, versions)
+}
+
+// releaseVersions drops the blob references of deleted versions and returns the
+// storage keys nothing references anymore. Versions stored before blobs existed
+// own their object outright.
+func releaseVersions(ctx context.Context, q database.Querier, versions []todo.AttachmentVersion) ([]string, error) {
+	var keys []string
+
+	for _, version := range versions {
+		if version.Checksum == nil {
+			keys = append(keys, version.DownloadKey)
+			continue
+		}
+
+		orphanedKey, err := releaseBlob(ctx, q, version.UploadedBy, *version.Checksum)
+		if err != nil {
+			return nil, err
+		}
+		if orphanedKey != "" {
+			keys = append(keys, orphanedKey)
+		}
+	}
+
+	return keys, nil
+}
+
+// FindBlobKey returns where the user already stores content with this checksum,
+// or "" when they don't. Quarantined content keeps its blob under a new key.
+func (r *TodoRepository) FindBlobKey(ctx context.Context, userID string, checksum string) (string, error) {
+	stmt := `
+		SELECT
+			storage_key
+		FROM
+			attachment_blobs
+		WHERE
+			user_id=@user_id
+			AND checksum=@checksum
+	`
+
+	var key string
+	err := r.server.DB.Querier(ctx).QueryRow(ctx, stmt, pgx.NamedArgs{
+		"user_id":  userID,
+		"checksum": checksum,
+	}).Scan(&key)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to find blob for user_id=%s: %w", userID, err)
+	}
+
+	return key, nil
+}
+
+// acquireBlob takes a reference on the user's blob for checksum, creating it on
+// first use.
+func acquireBlob(ctx context.Context, q database.Querier, userID, checksum, key string, fileSize int64, mimeType string) error {
+	_, err := q.Exec(ctx, `
+		INSERT INTO
+			attachment_blobs (user_id, checksum, storage_key, file_size, mime_type, ref_count)
+		VALUES
+			(@user_id, @checksum, @storage_key, @file_size, @mime_type, 1)
+		ON CONFLICT (user_id, checksum) DO UPDATE
+		SET
+			ref_count=attachment_blobs.ref_count + 1
+	`, pgx.NamedArgs{
+		"user_id":     userID,
+		"checksum":    checksum,
+		"storage_key": key,
+		"file_size":   fileSize,
+		"mime_type":   mimeType,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to acquire blob for user_id=%s: %w", userID, err)
+	}
+
+	return nil
+}
+
+// releaseBlob drops a reference on a blob and deletes it with the last one,
+// returning its storage key in that case and "" otherwise.
+func releaseBlob(ctx context.Context, q database.Querier, userID, checksum string) (string, error) {
+	var refCount int
+	var key string
+	err := q.QueryRow(ctx, `
+		UPDATE
+			attachment_blobs
+		SET
+			ref_count=ref_count - 1
+		WHERE
+			user_id=@user_id
+			AND checksum=@checksum
+		RETURNING
+			ref_count,
+			storage_key
+	`, pgx.NamedArgs{
+		"user_id":  userID,
+		"checksum": checksum,
+	}).Scan(&refCount, &key)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to release blob for user_id=%s: %w", userID, err)
+	}
+
+	if refCount > 0 {
+		return "", nil
+	}
+
+	_, err = q.Exec(ctx, `
+		DELETE FROM attachment_blobs
+		WHERE
+			user_id=@user_id
+			AND checksum=@checksum
+			AND ref_count=0
+	`, pgx.NamedArgs{
+		"user_id":  userID,
+		"checksum": checksum,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to delete blob for user_id=%s: %w", userID, err)
+	}
+
+	return key, nil
+}
+
+// CreateAttachmentUpload records a pending upload, reserving its size against the
+// user's quota.
+func (r *TodoRepository) CreateAttachmentUpload(ctx context.Context, upload *todo.AttachmentUpload) (*todo.AttachmentUpload, error) {
+	var created todo.AttachmentUpload
+	err := r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
+
+		stmt := `
+			INSERT INTO
+				attachment_uploads (id, todo_id, user_id, name, storage_key, file_size, mime_type, expires_at)
+			VALUES
+				(@id, @todo_id, @user_id, @name, @storage_key, @file_size, @mime_type, @expires_at)
+			RETURNING
+				*
+		`
+
+		rows, err := q.Query(ctx, stmt, pgx.NamedArgs{
+			"id":          upload.ID,
+			"todo_id":     upload.TodoID,
+			"user_id":     upload.UserID,
+			"name":        upload.Name,
+			"storage_key": upload.StorageKey,
+			"file_size":   upload.FileSize,
+			"mime_type":   upload.MimeType,
+			"expires_at":  upload.ExpiresAt,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to execute create attachment upload query for todo_id=%s: %w", upload.TodoID.String(), err)
+		}
+
+		created, err = pgx.CollectOneRow(rows, pgx.RowToStructByName[todo.AttachmentUpload])
+		if err != nil {
+			return fmt.Errorf("failed to collect row from table:attachment_uploads for todo_id=%s: %w", upload.TodoID.String(), err)
+		}
+
+		return r.checkStorageQuota(ctx, q, upload.UserID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+func (r *TodoRepository) GetAttachmentUpload(ctx context.Context, userID string, todoID uuid.UUID, uploadID uuid.UUID) (*todo.AttachmentUpload, error) {
+	stmt := `
+		SELECT
+			*
+		FROM
+			attachment_uploads
+		WHERE
+			id=@id
+			AND todo_id=@todo_id
+			AND user_id=@user_id
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"id":      uploadID,
+		"todo_id": todoID,
+		"user_id": userID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get attachment upload query for upload_id=%s: %w", uploadID.String(), err)
+	}
+
+	upload, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[todo.AttachmentUpload])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			code := "UPLOAD_NOT_FOUND"
+			return nil, errs.NewNotFoundError("upload not found", false, &code)
+		}
+		return nil, fmt.Errorf("failed to collect row from table:attachment_uploads for upload_id=%s: %w", uploadID.String(), err)
+	}
+
+	return &upload, nil
+}
+
+// CompleteAttachmentUpload marks a pending upload completed and records its
+// attachment in one transaction, so a retried completion can't attach twice.
+func (r *TodoRepository) CompleteAttachmentUpload(ctx context.Context, upload *todo.AttachmentUpload, key string,
+	checksum string,
+) (*todo.Attachment, error) {
+	var attachment todo.Attachment
+	err := r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
+
+		result, err := q.Exec(ctx, `
+			UPDATE
+				attachment_uploads
+			SET
+				completed_at=CURRENT_TIMESTAMP
+			WHERE
+				id=@id
+				AND completed_at IS NULL
+		`, pgx.NamedArgs{
+			"id": upload.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to complete attachment upload for upload_id=%s: %w", upload.ID.String(), err)
+		}
+
+		if result.RowsAffected() == 0 {
+			code := "UPLOAD_ALREADY_COMPLETED"
+			return errs.NewBadRequestError("upload already completed", false, &code, nil, nil)
+		}
+
+		if err := acquireBlob(ctx, q, upload.UserID, checksum, key, upload.FileSize, upload.MimeType); err != nil {
+			return err
+		}
+
+		rows, err := q.Query(ctx, `
+			INSERT INTO
+				todo_attachments (todo_id, name, uploaded_by, download_key, file_size, mime_type, checksum, preview_status)
+			VALUES
+				(@todo_id, @name, @uploaded_by, @download_key, @file_size, @mime_type, @checksum, @preview_status)
+			RETURNING
+				*
+		`, pgx.NamedArgs{
+			"todo_id":        upload.TodoID,
+			"name":           upload.Name,
+			"uploaded_by":    upload.UserID,
+			"download_key":   key,
+			"file_size":      upload.FileSize,
+			"mime_type":      upload.MimeType,
+			"checksum":       checksum,
+			"preview_status": todo.InitialPreviewStatus(upload.MimeType),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to execute create attachment query for upload_id=%s: %w", upload.ID.String(), err)
+		}
+
+		attachment, err = pgx.CollectOneRow(rows, pgx.RowToStructByName[todo.Attachment])
+		if err != nil {
+			return fmt.Errorf("failed to collect row from table:todo_attachments for upload_id=%s: %w", upload.ID.String(), err)
+		}
+
+		if err := recordVersion(ctx, q, currentVersion(&attachment, upload.UserID)); err != nil {
+			return err
+		}
+
+		return recordEvents(ctx, q, upload.UserID, event.AttachmentAdded{
+			TodoID:     upload.TodoID,
+			Attachment: attachment,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &attachment, nil
+}
+
+func (r *TodoRepository) DeleteAttachmentUpload(ctx context.Context, uploadID uuid.UUID) error {
+	stmt := `
+		DELETE FROM attachment_uploads
+		WHERE
+			id=@id
+	`
+
+	_, err := r.server.DB.Querier(ctx).Exec(ctx, stmt, pgx.NamedArgs{
+		"id": uploadID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to execute delete attachment upload query for upload_id=%s: %w", uploadID.String(), err)
+	}
+
+	return nil
+}
+
+// GetAbandonedUploads returns pending uploads whose URL expired before the cutoff.
+func (r *TodoRepository) GetAbandonedUploads(ctx context.Context, before time.Time, limit int) ([]todo.AttachmentUpload, error) {
+	stmt := `
+		SELECT
+			*
+		FROM
+			attachment_uploads
+		WHERE
+			completed_at IS NULL
+			AND expires_at<@before
+		ORDER BY
+			expires_at ASC
+		LIMIT
+			@limit
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"before": before,
+		"limit":  limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get abandoned uploads query: %w", err)
+	}
+
+	uploads, err := pgx.CollectRows(rows, pgx.RowToStructByName[todo.AttachmentUpload])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:attachment_uploads: %w", err)
+	}
+
+	return uploads, nil
+}
+
+// FindUnreferencedKeys returns the keys that no blob, attachment or pending upload
+// stores its content under.
+func (r *TodoRepository) FindUnreferencedKeys(ctx context.Context, keys []string) ([]string, error) {
+	stmt := `
+		SELECT
+			k.key
+		FROM
+			UNNEST(@keys::TEXT[]) AS k (key)
+		WHERE
+			NOT EXISTS (
+				SELECT
+					1
+				FROM
+					attachment_blobs b
+				WHERE
+					b.storage_key=k.key
+			)
+			AND NOT EXISTS (
+				SELECT
+					1
+				FROM
+					todo_attachments a
+				WHERE
+					a.download_key=k.key
+			)
+			AND NOT EXISTS (
+				SELECT
+					1
+				FROM
+					attachment_versions v
+				WHERE
+					v.download_key=k.key
+			)
+			AND NOT EXISTS (
+				SELECT
+					1
+				FROM
+					attachment_uploads u
+				WHERE
+					u.storage_key=k.key
+					AND u.completed_at IS NULL
+			)
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"keys": keys,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute find unreferenced keys query: %w", err)
+	}
+
+	unreferenced, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows for unreferenced keys: %w", err)
+	}
+
+	return unreferenced, nil
+}
+
+// HasPendingScan reports whether any version stored under key awaits its scan.
+func (r *TodoRepository) HasPendingScan(ctx context.Context, key string) (bool, error) {
+	stmt := `
+		SELECT
+			EXISTS (
+				SELECT
+					1
+				FROM
+					attachment_versions
+				WHERE
+					download_key=@key
+					AND scan_status='pending'
+			)
+	`
+
+	var pending bool
+	err := r.server.DB.Querier(ctx).QueryRow(ctx, stmt, pgx.NamedArgs{
+		"key": key,
+	}).Scan(&pending)
+	if err != nil {
+		return false, fmt.Errorf("failed to check pending scan for key=%s: %w", key, err)
+	}
+
+	return pending, nil
+}
+
+// SetScanStatus records the verdict on the content stored under key for every
+// version and attachment still waiting for it, and returns the attachments it
+// changed.
+func (r *TodoRepository) SetScanStatus(ctx context.Context, key string, status todo.ScanStatus) ([]todo.Attachment, error) {
+	stmt := `
+		WITH
+			versions AS (
+				UPDATE
+					attachment_versions
+				SET
+					scan_status=@scan_status
+				WHERE
+					download_key=@key
+					AND scan_status='pending'
+			)
+		UPDATE
+			todo_attachments
+		SET
+			scan_status=@scan_status
+		WHERE
+			download_key=@key
+			AND scan_status='pending'
+		RETURNING
+			*
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"key":         key,
+		"scan_status": status,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute set scan status query for key=%s: %w", key, err)
+	}
+
+	attachments, err := pgx.CollectRows(rows, pgx.RowToStructByName[todo.Attachment])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:todo_attachments for key=%s: %w", key, err)
+	}
+
+	return attachments, nil
+}
+
+// QuarantineContent points the blob, versions and attachments stored under key at
+// quarantineKey, where the content has been copied, and marks them infected.
+// Thumbnails of infected content are dropped. It returns the attachments it
+// changed.
+func (r *TodoRepository) QuarantineContent(ctx context.Context, key string, quarantineKey string) ([]todo.Attachment, error) {
+	stmt := `
+		WITH
+			blobs AS (
+				UPDATE
+					attachment_blobs
+				SET
+					storage_key=@quarantine_key
+				WHERE
+					storage_key=@key
+			),
+			versions AS (
+				UPDATE
+					attachment_versions
+				SET
+					download_key=@quarantine_key,
+					scan_status='infected'
+				WHERE
+					download_key=@key
+			)
+		UPDATE
+			todo_attachments
+		SET
+			download_key=@quarantine_key,
+			scan_status='infected',
+			preview_status='unsupported',
+			thumbnail_key=NULL,
+			page_count=NULL
+		WHERE
+			download_key=@key
+		RETURNING
+			*
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"key":            key,
+		"quarantine_key": quarantineKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute quarantine query for key=%s: %w", key, err)
+	}
+
+	attachments, err := pgx.CollectRows(rows, pgx.RowToStructByName[todo.Attachment])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:todo_attachments for key=%s: %w", key, err)
+	}
+
+	return attachments, nil
+}
+
+// GetPendingScanKeys returns up to limit storage keys of versions created before
+// the cutoff that still await their scan.
+func (r *TodoRepository) GetPendingScanKeys(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	stmt := `
+		SELECT
+			download_key
+		FROM
+			attachment_versions
+		WHERE
+			scan_status='pending'
+			AND created_at<@before
+		GROUP BY
+			download_key
+		ORDER BY
+			MIN(created_at) ASC
+		LIMIT
+			@limit
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"before": before,
+		"limit":  limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get pending scan keys query: %w", err)
+	}
+
+	keys, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:attachment_versions: %w", err)
+	}
+
+	return keys, nil
+}
+
+// GetAttachmentsCreatedBefore pages through attachments created before the cutoff
+// in id order, starting after the given id.
+func (r *TodoRepository) GetAttachmentsCreatedBefore(ctx context.Context, before time.Time, afterID uuid.UUID,
+	limit int,
+) ([]todo.Attachment, error) {
+	stmt := `
+		SELECT
+			*
+		FROM
+			todo_attachments
+		WHERE
+			created_at<@before
+			AND id>@after_id
+		ORDER BY
+			id ASC
+		LIMIT
+			@limit
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"before":   before,
+		"after_id": afterID,
+		"limit":    limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get attachments query: %w", err)
+	}
+
+	attachments, err := pgx.CollectRows(rows, pgx.RowToStructByName[todo.Attachment])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:todo_attachments: %w", err)
+	}
+
+	return attachments, nil
+}
+
+// GetUserStorageUsage reads the running totals of a user's storage.
+func (r *TodoRepository) GetUserStorageUsage(ctx context.Context, userID string) (*todo.StorageUsage, error) {
+	stmt := `
+		SELECT
+			used_bytes,
+			file_count,
+			reserved_bytes
+		FROM
+			user_storage_usage
+		WHERE
+			user_id=@user_id
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"user_id": userID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get storage usage query for user_id=%s: %w", userID, err)
+	}
+
+	usage, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[todo.StorageUsage])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &todo.StorageUsage{}, nil
+		}
+		return nil, fmt.Errorf("failed to collect row from table:user_storage_usage for user_id=%s: %w", userID, err)
+	}
+
+	return &usage, nil
+}
+
+// GetStorageUsageByTodo sums the attachments a user uploaded per todo, largest first.
+func (r *TodoRepository) GetStorageUsageByTodo(ctx context.Context, userID string) ([]todo.TodoStorageUsage, error) {
+	stmt := `
+		SELECT
+			t.id AS todo_id,
+			t.title,
+			COALESCE(SUM(a.file_size), 0)::BIGINT AS bytes,
+			COUNT(*)::INTEGER AS file_count
+		FROM
+			todo_attachments a
+			JOIN todos t ON t.id=a.todo_id
+		WHERE
+			a.uploaded_by=@user_id
+		GROUP BY
+			t.id,
+			t.title
+		ORDER BY
+			bytes DESC,
+			t.title ASC
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"user_id": userID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute storage usage by todo query for user_id=%s: %w", userID, err)
+	}
+
+	usage, err := pgx.CollectRows(rows, pgx.RowToStructByName[todo.TodoStorageUsage])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:todo_attachments for user_id=%s: %w", userID, err)
+	}
+
+	return usage, nil
+}
+
+// GetStorageUsageByMimeType sums the attachments a user uploaded per content type,
+// largest first.
+func (r *TodoRepository) GetStorageUsageByMimeType(ctx context.Context, userID string) ([]todo.MimeTypeStorageUsage, error) {
+	stmt := `
+		SELECT
+			COALESCE(mime_type, 'application/octet-stream') AS mime_type,
+			COALESCE(SUM(file_size), 0)::BIGINT AS bytes,
+			COUNT(*)::INTEGER AS file_count
+		FROM
+			todo_attachments
+		WHERE
+			uploaded_by=@user_id
+		GROUP BY
+			1
+		ORDER BY
+			bytes DESC,
+			mime_type ASC
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"user_id": userID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute storage usage by mime type query for user_id=%s: %w", userID, err)
+	}
+
+	usage, err := pgx.CollectRows(rows, pgx.RowToStructByName[todo.MimeTypeStorageUsage])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:todo_attachments for user_id=%s: %w", userID, err)
+	}
+
+	return usage, nil
+}
+
+// checkStorageQuota fails the transaction's upload when it takes the user past the
+// configured quota. The usage row was just updated by the upload's own triggers, so
+// it stays locked until commit and concurrent uploads are checked one at a time.
+func (r *TodoRepository) checkStorageQuota(ctx context.Context, q database.Querier, userID string) error {
+	quota := r.server.Config.Storage.MaxUserStorage
+	if quota == 0 {
+		return nil
+	}
+
+	var total int64
+	err := q.QueryRow(ctx, `
+		SELECT
+			used_bytes + reserved_bytes
+		FROM
+			user_storage_usage
+		WHERE
+			user_id=@user_id
+	`, pgx.NamedArgs{
+		"user_id": userID,
+	}).Scan(&total)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check storage quota for user_id=%s: %w", userID, err)
+	}
+
+	if total > quota {
+		code := "STORAGE_QUOTA_EXCEEDED"
+		return errs.NewPayloadTooLargeError("file exceeds your storage quota", false, &code)
+	}
+
+	return nil
+}
+
+// AddTodoAssignees assigns users to a todo and returns only the assignments that
+// didn't exist yet, so callers can notify newly assigned users.
+func (r *TodoRepository) AddTodoAssignees(ctx context.Context, todoID uuid.UUID, assignedBy string, userIDs []string) ([]todo.Assignee, error) {
+	stmt := `
+		INSERT INTO
+			todo_assignees (todo_id, user_id, assigned_by)
+		SELECT
+			@todo_id,
+			u,
+			@assigned_by
+		FROM
+			UNNEST(@user_ids::TEXT[]) AS u
+		ON CONFLICT (todo_id, user_id) DO NOTHING
+		RETURNING
+		*
+	`
+
+	var added []todo.Assignee
+	err := r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
+
+		rows, err := q.Query(ctx, stmt, pgx.NamedArgs{
+			"todo_id":     todoID,
+			"assigned_by": assignedBy,
+			"user_ids":    userIDs,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to execute add assignees query for todo_id=%s: %w", todoID.String(), err)
+		}
+
+		added, err = pgx.CollectRows(rows, pgx.RowToStructByName[todo.Assignee])
+		if err != nil {
+			return fmt.Errorf("failed to collect rows from table:todo_assignees for todo_id=%s: %w", todoID.String(), err)
+		}
+
+		// Assigning users who already are changes nothing
+		if len(added) == 0 {
+			return nil
+		}
+
+		changed := event.TodoAssigneesChanged{
+			TodoID:  todoID,
+			Added:   make([]string, 0, len(added)),
+			Removed: []string{},
+		}
+		for _, assignee := range added {
+			changed.Added = append(changed.Added, assignee.UserID)
+		}
+
+		changed.Assignees, err = queryTodoAssignees(ctx, q, todoID)
+		if err != nil {
+			return err
+		}
+
+		return recordEvents(ctx, q, assignedBy, changed)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return added, nil
+}
+
+func (r *TodoRepository) GetTodoAssignees(ctx context.Context, todoID uuid.UUID) ([]todo.Assignee, error) {
+	return queryTodoAssignees(ctx, r.server.DB.Querier(ctx), todoID)
+}
+
+func queryTodoAssignees(ctx context.Context, q database.Querier, todoID uuid.UUID) ([]todo.Assignee, error) {
+	stmt := `
+		SELECT
+			*
+		FROM
+			todo_assignees
+		WHERE
+			todo_id=@todo_id
+		ORDER BY
+			created_at ASC
+	`
+
+	rows, err := q.Query(ctx, stmt, pgx.NamedArgs{
+		"todo_id": todoID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get assignees query for todo_id=%s: %w", todoID.String(), err)
+	}
+
+	assignees, err := pgx.CollectRows(rows, pgx.RowToStructByName[todo.Assignee])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:todo_assignees for todo_id=%s: %w", todoID.String(), err)
+	}
+
+	return assignees, nil
+}
+
+// RemoveTodoAssignee unassigns userID from a todo on behalf of actorID.
+func (r *TodoRepository) RemoveTodoAssignee(ctx context.Context, actorID string, todoID uuid.UUID, userID string) error {
+	stmt := `
+		DELETE FROM todo_assignees
+		WHERE
+			todo_id=@todo_id
+			AND user_id=@user_id
+	`
+
+	return r.server.DB.WithTx(ctx, func(ctx context.Context) error {
+		q := r.server.DB.Querier(ctx)
+
+		result, err := q.Exec(ctx, stmt, pgx.NamedArgs{
+			"todo_id": todoID,
+			"user_id": userID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to execute remove assignee query for todo_id=%s user_id=%s: %w", todoID.String(), userID, err)
+		}
+
+		if result.RowsAffected() == 0 {
+			code := "ASSIGNEE_NOT_FOUND"
+			return errs.NewNotFoundError("assignee not found", false, &code)
+		}
+
+		assignees, err := queryTodoAssignees(ctx, q, todoID)
+		if err != nil {
+			return err
+		}
+
+		return recordEvents(ctx, q, actorID, event.TodoAssigneesChanged{
+			TodoID:    todoID,
+			Assignees: assignees,
+			Added:     []string{},
+			Removed:   []string{userID},
+		})
+	})
+}
+
+func (r *TodoRepository) AddTodoWatcher(ctx context.Context, todoID uuid.UUID, userID string) (*todo.Watcher, error) {
+	stmt := `
+		INSERT INTO
+			todo_watchers (todo_id, user_id)
+		VALUES
+			(@todo_id, @user_id)
+		ON CONFLICT (todo_id, user_id) DO UPDATE
+		SET
+			updated_at=CURRENT_TIMESTAMP
+		RETURNING
+		*
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"todo_id": todoID,
+		"user_id": userID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute add watcher query for todo_id=%s user_id=%s: %w", todoID.String(), userID, err)
+	}
+
+	watcher, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[todo.Watcher])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect row from table:todo_watchers for todo_id=%s user_id=%s: %w", todoID.String(), userID, err)
+	}
+
+	return &watcher, nil
+}
+
+func (r *TodoRepository) RemoveTodoWatcher(ctx context.Context, todoID uuid.UUID, userID string) error {
+	stmt := `
+		DELETE FROM todo_watchers
+		WHERE
+			todo_id=@todo_id
+			AND user_id=@user_id
+	`
+
+	result, err := r.server.DB.Querier(ctx).Exec(ctx, stmt, pgx.NamedArgs{
+		"todo_id": todoID,
+		"user_id": userID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to execute remove watcher query for todo_id=%s user_id=%s: %w", todoID.String(), userID, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		code := "WATCHER_NOT_FOUND"
+		return errs.NewNotFoundError("you are not watching this todo", false, &code)
+	}
+
+	return nil
+}
+
+// GetTodoFollowerIDs returns the users who are assigned to or watching a todo.
+func (r *TodoRepository) GetTodoFollowerIDs(ctx context.Context, todoID uuid.UUID) ([]string, error) {
+	stmt := `
+		SELECT
+			user_id
+		FROM
+			todo_assignees
+		WHERE
+			todo_id=@todo_id
+		UNION
+		SELECT
+			user_id
+		FROM
+			todo_watchers
+		WHERE
+			todo_id=@todo_id
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"todo_id": todoID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get followers for todo_id=%s: %w", todoID.String(), err)
+	}
+
+	followerIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:todo_watchers for todo_id=%s: %w", todoID.String(), err)
+	}
+
+	return followerIDs, nil
+}
+
+// GetUsersWithoutTodoAccess returns the subset of userIDs that can't see the todo
+// from the caller's workspace. memberIDs are the users among them who belong to the
+// caller's organization; the others reach its todos through shares only.
+func (r *TodoRepository) GetUsersWithoutTodoAccess(ctx context.Context, todoID uuid.UUID, userIDs []string,
+	memberIDs []string,
+) ([]string, error) {
+	stmt := `
+		SELECT
+			u
+		FROM
+			UNNEST(@user_ids::TEXT[]) AS u
+		WHERE
+			todo_access_role(
+				@todo_id,
+				u,
+				@org_id,
+				CASE
+					WHEN u=ANY (@member_ids::TEXT[]) THEN 'viewer'
+				END
+			) IS NULL
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, withScope(ctx, pgx.NamedArgs{
+		"todo_id":    todoID,
+		"user_ids":   userIDs,
+		"member_ids": memberIDs,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check user access for todo_id=%s: %w", todoID.String(), err)
+	}
+
+	userIDs, err = pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows for todo access of todo_id=%s: %w", todoID.String(), err)
+	}
+
+	return userIDs, nil
+}
+
+// ClaimDueSoonTodos returns open todos falling due within the window that haven't been
+// alerted about for their current due date, recording the alert so each due date is
+// announced once even with several workers.
+func (r *TodoRepository) ClaimDueSoonTodos(ctx context.Context, window time.Duration) ([]todo.Todo, error) {
+	stmt := `
+		WITH
+			due AS (
+				SELECT
+					*
+				FROM
+					todos
+				WHERE
+					due_date>CURRENT_TIMESTAMP
+					AND due_date<=CURRENT_TIMESTAMP + @window::INTERVAL
+					AND status NOT IN ('completed', 'archived')
+			),
+			claimed AS (
+				INSERT INTO
+					todo_alerts (todo_id, kind, fires_at)
+				SELECT
+					id,
+					'due_soon',
+					due_date
+				FROM
+					due
+				ON CONFLICT (todo_id, kind, fires_at) DO NOTHING
+				RETURNING
+					todo_id
+			)
+		SELECT
+			due.*
+		FROM
+			due
+			JOIN claimed ON claimed.todo_id=due.id
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"window": window,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute claim due soon todos query: %w", err)
+	}
+
+	todos, err := pgx.CollectRows(rows, pgx.RowToStructByName[todo.Todo])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:todos for due soon alerts: %w", err)
+	}
+
+	return todos, nil
+}
+
+// ClaimDueReminders returns open todos whose reminder time has passed within the
+// lookback, recording each reminder so it fires once. Reminders older than the
+// lookback are skipped rather than delivered late.
+func (r *TodoRepository) ClaimDueReminders(ctx context.Context, lookback time.Duration) ([]todo.Todo, error) {
+	stmt := `
+		WITH
+			due AS (
+				SELECT
+					*,
+					try_timestamptz(metadata->>'reminder') AS remind_at
+				FROM
+					todos
+				WHERE
+					metadata->>'reminder' IS NOT NULL
+					AND status NOT IN ('completed', 'archived')
+			),
+			claimed AS (
+				INSERT INTO
+					todo_alerts (todo_id, kind, fires_at)
+				SELECT
+					id,
+					'reminder',
+					remind_at
+				FROM
+					due
+				WHERE
+					remind_at<=CURRENT_TIMESTAMP
+					AND remind_at>CURRENT_TIMESTAMP - @lookback::INTERVAL
+				ON CONFLICT (todo_id, kind, fires_at) DO NOTHING
+				RETURNING
+					todo_id
+			)
+		SELECT
+			t.*
+		FROM
+			todos t
+			JOIN claimed ON claimed.todo_id=t.id
+	`
+
+	rows, err := r.server.DB.Querier(ctx).Query(ctx, stmt, pgx.NamedArgs{
+		"lookback": lookback,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute claim due reminders query: %w", err)
+	}
+
+	todos, err := pgx.CollectRows(rows, pgx.RowToStructByName[todo.Todo])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect rows from table:todos for reminders: %w", err)
+	}
+
+	return todos, nil
+}
@@ -0,0 +1,13 @@
+package router
+
+import (
+	"github.com/ApoorvYdv/go-tasker/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+func registerDevRoutes(r *echo.Echo, h *handler.Handlers) {
+	dev := r.Group("/dev")
+
+	dev.GET("/emails/:template", h.Dev.PreviewEmail)
+}
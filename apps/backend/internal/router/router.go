@@ -54,6 +54,11 @@ func NewRouter(s *server.Server, h *handler.Handlers, services *service.Services
 	// register system routes
 	registerSystemRoutes(router, h)
 
+	// development tools never ship to production
+	if s.Config.Primary.Env != "production" {
+		registerDevRoutes(router, h)
+	}
+
 	// register versioned routes
 	v1Router := router.Group("/api/v1")
 	v1.RegisterV1Routes(v1Router, h, middlewares)
@@ -2,21 +2,36 @@ package v1
 
 import (
 	"github.com/ApoorvYdv/go-tasker/internal/handler"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/workspace"
 	"github.com/ApoorvYdv/go-tasker/internal/middleware"
 	"github.com/labstack/echo/v4"
 )
 
-func registerCategoryRoutes(r *echo.Group, h *handler.CategoryHandler, auth *middleware.AuthMiddleware) {
+func registerCategoryRoutes(r *echo.Group, h *handler.CategoryHandler,
+	sh *handler.ShareHandler, auth *middleware.AuthMiddleware,
+) {
 	// Category operations
 	categories := r.Group("/categories")
 	categories.Use(auth.RequireAuth)
 
+	// Writes in organization workspaces require the matching Clerk permission
+	canWriteCategories := auth.RequirePermission(workspace.PermissionCategoriesWrite)
+
 	// Category collection operations
-	categories.POST("", h.CreateCategory)
+	categories.POST("", h.CreateCategory, canWriteCategories)
 	categories.GET("", h.GetCategories)
 
 	// Individual category operations
 	dynamicCategory := categories.Group("/:id")
-	dynamicCategory.PATCH("", h.UpdateCategory)
-	dynamicCategory.DELETE("", h.DeleteCategory)
+	dynamicCategory.PATCH("", h.UpdateCategory, canWriteCategories)
+	dynamicCategory.DELETE("", h.DeleteCategory, canWriteCategories)
+
+	// Archived categories are hidden from listings but keep their todos
+	dynamicCategory.POST("/archive", h.ArchiveCategory, canWriteCategories)
+	dynamicCategory.DELETE("/archive", h.UnarchiveCategory, canWriteCategories)
+
+	// Category shares
+	categoryShares := dynamicCategory.Group("/shares")
+	categoryShares.POST("", sh.ShareCategory, canWriteCategories)
+	categoryShares.GET("", sh.GetCategoryShares)
 }
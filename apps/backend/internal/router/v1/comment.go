@@ -2,6 +2,7 @@ package v1
 
 import (
 	"github.com/ApoorvYdv/go-tasker/internal/handler"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/workspace"
 	"github.com/ApoorvYdv/go-tasker/internal/middleware"
 	"github.com/labstack/echo/v4"
 )
@@ -11,8 +12,16 @@ func registerCommentRoutes(r *echo.Group, h *handler.CommentHandler, auth *middl
 	comments := r.Group("/comments")
 	comments.Use(auth.RequireAuth)
 
+	canWriteComments := auth.RequirePermission(workspace.PermissionCommentsWrite)
+
 	// Individual comment operations
 	dynamicComment := comments.Group("/:id")
-	dynamicComment.PATCH("", h.UpdateComment)
-	dynamicComment.DELETE("", h.DeleteComment)
+	dynamicComment.PATCH("", h.UpdateComment, canWriteComments)
+	dynamicComment.DELETE("", h.DeleteComment, canWriteComments)
+
+	// Edit history
+	dynamicComment.GET("/revisions", h.GetCommentRevisions)
+
+	// Reactions
+	dynamicComment.POST("/reactions", h.ToggleReaction, canWriteComments)
 }
@@ -0,0 +1,12 @@
+package v1
+
+import (
+	"github.com/ApoorvYdv/go-tasker/internal/handler"
+	"github.com/labstack/echo/v4"
+)
+
+func registerInboundRoutes(r *echo.Group, h *handler.InboundHandler) {
+	// Provider webhook, authenticated with the shared inbound secret instead of Clerk
+	webhooks := r.Group("/webhooks")
+	webhooks.POST("/inbound-email", h.ReceiveEmail)
+}
@@ -0,0 +1,20 @@
+package v1
+
+import (
+	"github.com/ApoorvYdv/go-tasker/internal/handler"
+	"github.com/ApoorvYdv/go-tasker/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+func registerMeRoutes(r *echo.Group, th *handler.TodoHandler, ih *handler.InboundHandler, auth *middleware.AuthMiddleware) {
+	// Resources of the current user
+	me := r.Group("/me")
+	me.Use(auth.RequireAuth)
+
+	// Storage used by the current user's attachments
+	me.GET("/storage", th.GetStorageUsage)
+
+	// Personal inbound address
+	me.GET("/inbound-address", ih.GetInboundAddress)
+	me.POST("/inbound-address/rotate", ih.RotateInboundAddress)
+}
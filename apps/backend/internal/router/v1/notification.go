@@ -0,0 +1,26 @@
+package v1
+
+import (
+	"github.com/ApoorvYdv/go-tasker/internal/handler"
+	"github.com/ApoorvYdv/go-tasker/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+func registerNotificationRoutes(r *echo.Group, h *handler.NotificationHandler, auth *middleware.AuthMiddleware) {
+	// Notification operations
+	notifications := r.Group("/notifications")
+	notifications.Use(auth.RequireAuth)
+
+	// Collection operations
+	notifications.GET("", h.GetNotifications)
+	notifications.GET("/unread-count", h.GetUnreadCount)
+	notifications.POST("/read-all", h.MarkAllNotificationsRead)
+
+	// Preferences
+	notifications.GET("/preferences", h.GetPreferences)
+	notifications.PUT("/preferences", h.UpdatePreferences)
+
+	// Individual notification operations
+	notifications.POST("/:id/read", h.MarkNotificationRead)
+	notifications.POST("/:id/archive", h.ArchiveNotification)
+}
@@ -0,0 +1,21 @@
+package v1
+
+import (
+	"github.com/ApoorvYdv/go-tasker/internal/handler"
+	"github.com/ApoorvYdv/go-tasker/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+func registerShareRoutes(r *echo.Group, h *handler.ShareHandler, auth *middleware.AuthMiddleware) {
+	// Share operations
+	shares := r.Group("/shares")
+	shares.Use(auth.RequireAuth)
+
+	// Invitations
+	shares.POST("/invitations/:token/accept", h.AcceptInvitation)
+
+	// Individual share operations
+	dynamicShare := shares.Group("/:id")
+	dynamicShare.PATCH("", h.UpdateShare)
+	dynamicShare.DELETE("", h.DeleteShare)
+}
@@ -0,0 +1,12 @@
+package v1
+
+import (
+	"github.com/ApoorvYdv/go-tasker/internal/handler"
+	"github.com/labstack/echo/v4"
+)
+
+func registerStorageRoutes(r *echo.Group, h *handler.StorageHandler) {
+	// Signed local storage downloads and uploads; the URL signature replaces authentication
+	r.GET("/storage/*", h.ServeObject)
+	r.PUT("/storage/*", h.ReceiveObject)
+}
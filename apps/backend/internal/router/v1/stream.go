@@ -0,0 +1,15 @@
+package v1
+
+import (
+	"github.com/ApoorvYdv/go-tasker/internal/handler"
+	"github.com/ApoorvYdv/go-tasker/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+func registerStreamRoutes(r *echo.Group, h *handler.StreamHandler, auth *middleware.AuthMiddleware) {
+	// Realtime change stream (SSE, or WebSocket when the request asks for an upgrade)
+	stream := r.Group("/stream")
+	stream.Use(auth.RequireAuth)
+
+	stream.GET("", h.Stream)
+}
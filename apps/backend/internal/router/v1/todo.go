@@ -2,34 +2,69 @@ package v1
 
 import (
 	"github.com/ApoorvYdv/go-tasker/internal/handler"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/workspace"
 	"github.com/ApoorvYdv/go-tasker/internal/middleware"
 	"github.com/labstack/echo/v4"
 )
 
-func registerTodoRoutes(r *echo.Group, h *handler.TodoHandler, ch *handler.CommentHandler, auth *middleware.AuthMiddleware) {
+func registerTodoRoutes(r *echo.Group, h *handler.TodoHandler, ch *handler.CommentHandler,
+	sh *handler.ShareHandler, auth *middleware.AuthMiddleware,
+) {
 	// Todo operations
 	todos := r.Group("/todos")
 	todos.Use(auth.RequireAuth)
 
+	// Writes in organization workspaces require the matching Clerk permission
+	canWriteTodos := auth.RequirePermission(workspace.PermissionTodosWrite)
+	canWriteComments := auth.RequirePermission(workspace.PermissionCommentsWrite)
+
 	// Collection operations
-	todos.POST("", h.CreateTodo)
+	todos.POST("", h.CreateTodo, canWriteTodos)
 	todos.GET("", h.GetTodos)
 	todos.GET("/stats", h.GetTodoStats)
 
 	// Individual todo operations
 	dynamicTodo := todos.Group("/:id")
 	dynamicTodo.GET("", h.GetTodoByID)
-	dynamicTodo.PATCH("", h.UpdateTodo)
-	dynamicTodo.DELETE("", h.DeleteTodo)
+	dynamicTodo.PATCH("", h.UpdateTodo, canWriteTodos)
+	dynamicTodo.DELETE("", h.DeleteTodo, canWriteTodos)
 
 	// Todo comments
 	todoComments := dynamicTodo.Group("/comments")
-	todoComments.POST("", ch.AddComment)
+	todoComments.POST("", ch.AddComment, canWriteComments)
 	todoComments.GET("", ch.GetCommentsByTodoID)
 
+	// Todo assignees
+	todoAssignees := dynamicTodo.Group("/assignees")
+	todoAssignees.POST("", h.AddTodoAssignees, canWriteTodos)
+	todoAssignees.GET("", h.GetTodoAssignees)
+	todoAssignees.DELETE("/:userId", h.RemoveTodoAssignee, canWriteTodos)
+
+	// Watching a todo subscribes the current user to its notifications
+	dynamicTodo.POST("/watch", h.WatchTodo)
+	dynamicTodo.DELETE("/watch", h.UnwatchTodo)
+
+	// Todo shares
+	todoShares := dynamicTodo.Group("/shares")
+	todoShares.POST("", sh.ShareTodo, canWriteTodos)
+	todoShares.GET("", sh.GetTodoShares)
+
 	// Todo attachments
 	todoAttachments := dynamicTodo.Group("/attachments")
-	todoAttachments.POST("", h.UploadTodoAttachment)
-	todoAttachments.DELETE("/:attachmentId", h.DeleteTodoAttachment)
+	todoAttachments.GET("", h.GetTodoAttachments)
+	todoAttachments.GET("/archive", h.DownloadTodoAttachmentsArchive)
+	todoAttachments.POST("", h.UploadTodoAttachment, canWriteTodos)
+	todoAttachments.POST("/uploads", h.CreateAttachmentUpload, canWriteTodos)
+	todoAttachments.POST("/uploads/:uploadId/complete", h.CompleteAttachmentUpload, canWriteTodos)
+	todoAttachments.DELETE("/:attachmentId", h.DeleteTodoAttachment, canWriteTodos)
 	todoAttachments.GET("/:attachmentId/download", h.GetAttachmentPresignedURL)
+	todoAttachments.GET("/:attachmentId/content", h.GetAttachmentContent)
+	todoAttachments.HEAD("/:attachmentId/content", h.GetAttachmentContent)
+
+	// Attachment versions, addressed by attachment alone
+	attachments := r.Group("/attachments")
+	attachments.Use(auth.RequireAuth)
+	attachments.GET("/:id/versions", h.GetAttachmentVersions)
+	attachments.POST("/:id/versions", h.UploadAttachmentVersion, canWriteTodos)
+	attachments.POST("/:id/versions/:version/restore", h.RestoreAttachmentVersion, canWriteTodos)
 }
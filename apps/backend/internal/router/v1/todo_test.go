@@ -0,0 +1,35 @@
+package v1
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ApoorvYdv/go-tasker/internal/handler"
+	"github.com/ApoorvYdv/go-tasker/internal/middleware"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterTodoRoutes_AttachmentVersions(t *testing.T) {
+	e := echo.New()
+	registerTodoRoutes(e.Group("/api/v1"), &handler.TodoHandler{}, &handler.CommentHandler{},
+		&handler.ShareHandler{}, &middleware.AuthMiddleware{})
+
+	routes := make(map[string]struct{})
+	for _, route := range e.Routes() {
+		routes[route.Method+" "+route.Path] = struct{}{}
+	}
+
+	for _, route := range []string{
+		http.MethodGet + " /api/v1/attachments/:id/versions",
+		http.MethodPost + " /api/v1/attachments/:id/versions",
+		http.MethodPost + " /api/v1/attachments/:id/versions/:version/restore",
+	} {
+		assert.Contains(t, routes, route)
+	}
+
+	// Versions are only served by attachment, never nested under a todo
+	for route := range routes {
+		assert.NotContains(t, route, "/todos/:id/attachments/:attachmentId/versions")
+	}
+}
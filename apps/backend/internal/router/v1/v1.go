@@ -8,11 +8,29 @@ import (
 
 func RegisterV1Routes(router *echo.Group, handlers *handler.Handlers, middleware *middleware.Middlewares) {
 	// Register todo routes
-	registerTodoRoutes(router, handlers.Todo, handlers.Comment, middleware.Auth)
+	registerTodoRoutes(router, handlers.Todo, handlers.Comment, handlers.Share, middleware.Auth)
 
 	// Register category routes
-	registerCategoryRoutes(router, handlers.Category, middleware.Auth)
+	registerCategoryRoutes(router, handlers.Category, handlers.Share, middleware.Auth)
 
 	// Register comment routes
 	registerCommentRoutes(router, handlers.Comment, middleware.Auth)
+
+	// Register share routes
+	registerShareRoutes(router, handlers.Share, middleware.Auth)
+
+	// Register notification routes
+	registerNotificationRoutes(router, handlers.Notification, middleware.Auth)
+
+	// Register inbound email routes
+	registerInboundRoutes(router, handlers.Inbound)
+
+	// Register current user routes
+	registerMeRoutes(router, handlers.Todo, handlers.Inbound, middleware.Auth)
+
+	// Register storage routes
+	registerStorageRoutes(router, handlers.Storage)
+
+	// Register realtime stream routes
+	registerStreamRoutes(router, handlers.Stream, middleware.Auth)
 }
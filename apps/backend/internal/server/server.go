@@ -10,6 +10,7 @@ import (
 	"github.com/ApoorvYdv/go-tasker/internal/config"
 	"github.com/ApoorvYdv/go-tasker/internal/database"
 	"github.com/ApoorvYdv/go-tasker/internal/lib/job"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/realtime"
 	loggerPkg "github.com/ApoorvYdv/go-tasker/internal/logger"
 	"github.com/newrelic/go-agent/v3/integrations/nrredis-v9"
 	"github.com/redis/go-redis/v9"
@@ -24,6 +25,7 @@ type Server struct {
 	Redis         *redis.Client
 	httpServer    *http.Server
 	Job           *job.JobService
+	Realtime      *realtime.Broker
 }
 
 func New(cfg *config.Config, logger *zerolog.Logger, loggerService *loggerPkg.LoggerService) (*Server, error) {
@@ -55,13 +57,14 @@ func New(cfg *config.Config, logger *zerolog.Logger, loggerService *loggerPkg.Lo
 
 	// job service
 	jobService := job.NewJobService(logger, cfg)
-	jobService.InitHandlers(cfg, logger)
-
-	// Start job server
-	if err := jobService.Start(); err != nil {
+	if err := jobService.InitHandlers(cfg, logger); err != nil {
 		return nil, err
 	}
 
+	// Realtime change stream fan-out over Redis pub/sub
+	realtimeBroker := realtime.NewBroker(redisClient, logger)
+	realtimeBroker.Start()
+
 	server := &Server{
 		Config:        cfg,
 		Logger:        logger,
@@ -69,6 +72,7 @@ func New(cfg *config.Config, logger *zerolog.Logger, loggerService *loggerPkg.Lo
 		DB:            db,
 		Redis:         redisClient,
 		Job:           jobService,
+		Realtime:      realtimeBroker,
 	}
 
 	// Start metrics collection
@@ -92,6 +96,11 @@ func (s *Server) Start() error {
 		return errors.New("HTTP server not initialized")
 	}
 
+	// Started here rather than in New so services can register their task handlers first
+	if err := s.Job.Start(); err != nil {
+		return fmt.Errorf("failed to start job server: %w", err)
+	}
+
 	s.Logger.Info().
 		Str("port", s.Config.Server.Port).
 		Str("env", s.Config.Primary.Env).
@@ -101,6 +110,14 @@ func (s *Server) Start() error {
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
+	// Close the realtime broker first so open stream connections end and the
+	// HTTP server can drain instead of waiting for them until the deadline.
+	if s.Realtime != nil {
+		if err := s.Realtime.Close(); err != nil {
+			return fmt.Errorf("failed to close realtime broker: %w", err)
+		}
+	}
+
 	if err := s.httpServer.Shutdown(ctx); err != nil {
 		return fmt.Errorf("failed to shutdown HTTP server: %w", err)
 	}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/ApoorvYdv/go-tasker/internal/errs"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/filetype"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/storage"
+	"github.com/ApoorvYdv/go-tasker/internal/middleware"
+	"github.com/ApoorvYdv/go-tasker/internal/model"
+	"github.com/ApoorvYdv/go-tasker/internal/model/share"
+	"github.com/ApoorvYdv/go-tasker/internal/model/todo"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// archiveEntry is one file of an attachments archive.
+type archiveEntry struct {
+	name       string
+	attachment todo.Attachment
+}
+
+// GetTodoAttachmentsArchive returns a ZIP of a todo's attachments, and optionally
+// of its subtasks' attachments in a folder per subtask. The archive is built while
+// it is sent, reading one file from storage at a time.
+func (s *TodoService) GetTodoAttachmentsArchive(ctx echo.Context, userID string, todoID uuid.UUID,
+	includeSubtasks bool,
+) (*model.FileStream, error) {
+	logger := middleware.GetLogger(ctx)
+
+	// Validate todo exists and user can view it
+	todoItem, err := s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, todoID, share.RoleViewer)
+	if err != nil {
+		logger.Error().Err(err).Msg("todo validation failed")
+		return nil, err
+	}
+
+	attachments, err := s.todoRepo.GetTodoAttachments(ctx.Request().Context(), todoID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to fetch todo attachments")
+		return nil, err
+	}
+
+	names := archiveNames{}
+	entries := make([]archiveEntry, 0, len(attachments))
+	for _, attachment := range s.downloadable(logger, attachments) {
+		entries = append(entries, archiveEntry{
+			name:       names.file(filetype.SanitizeFilename(attachment.Name)),
+			attachment: attachment,
+		})
+	}
+
+	if includeSubtasks {
+		subtasks, err := s.todoRepo.GetSubtasks(ctx.Request().Context(), todoID)
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to fetch subtasks")
+			return nil, err
+		}
+
+		for _, subtask := range subtasks {
+			subtaskAttachments, err := s.todoRepo.GetTodoAttachments(ctx.Request().Context(), subtask.ID)
+			if err != nil {
+				logger.Error().Err(err).Msg("failed to fetch subtask attachments")
+				return nil, err
+			}
+			if len(subtaskAttachments) == 0 {
+				continue
+			}
+
+			subtaskAttachments = s.downloadable(logger, subtaskAttachments)
+			if len(subtaskAttachments) == 0 {
+				continue
+			}
+
+			folder := names.folder(filetype.SanitizeFilename(subtask.Title))
+			for _, attachment := range subtaskAttachments {
+				entries = append(entries, archiveEntry{
+					name:       names.file(folder + "/" + filetype.SanitizeFilename(attachment.Name)),
+					attachment: attachment,
+				})
+			}
+		}
+	}
+
+	if len(entries) == 0 {
+		code := "NO_ATTACHMENTS"
+		return nil, errs.NewNotFoundError("todo has no attachments", false, &code)
+	}
+
+	// Business event log
+	eventLogger := middleware.GetLogger(ctx)
+	eventLogger.Info().
+		Str("event", "todo_attachments_archived").
+		Str("todo_id", todoID.String()).
+		Int("files", len(entries)).
+		Bool("include_subtasks", includeSubtasks).
+		Msg("Attachments archive started")
+
+	requestCtx := ctx.Request().Context()
+	return &model.FileStream{
+		Filename:    filetype.SanitizeFilename(todoItem.Title + ".zip"),
+		ContentType: "application/zip",
+		Write: func(w io.Writer) error {
+			return s.writeArchive(requestCtx, logger, w, entries)
+		},
+	}, nil
+}
+
+// downloadable leaves out attachments that haven't passed the malware scan.
+func (s *TodoService) downloadable(logger *zerolog.Logger, attachments []todo.Attachment) []todo.Attachment {
+	kept := attachments[:0]
+	for _, attachment := range attachments {
+		if err := s.checkDownloadable(&attachment); err != nil {
+			logger.Warn().
+				Str("attachment_id", attachment.ID.String()).
+				Str("scan_status", string(attachment.ScanStatus)).
+				Msg("skipping attachment not cleared by malware scan in archive")
+			continue
+		}
+		kept = append(kept, attachment)
+	}
+	return kept
+}
+
+// writeArchive streams the entries into a ZIP. Files missing from storage are left
+// out rather than failing the whole archive.
+func (s *TodoService) writeArchive(ctx context.Context, logger *zerolog.Logger, w io.Writer,
+	entries []archiveEntry,
+) error {
+	zw := zip.NewWriter(w)
+
+	for _, entry := range entries {
+		err := s.writeArchiveEntry(ctx, zw, entry)
+		if errors.Is(err, storage.ErrNotFound) {
+			logger.Warn().
+				Str("attachment_id", entry.attachment.ID.String()).
+				Str("storage_key", entry.attachment.DownloadKey).
+				Msg("skipping attachment missing from storage in archive")
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %s to archive: %w", entry.name, err)
+		}
+	}
+
+	return zw.Close()
+}
+
+func (s *TodoService) writeArchiveEntry(ctx context.Context, zw *zip.Writer, entry archiveEntry) error {
+	body, _, err := s.storage.Get(ctx, entry.attachment.DownloadKey)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	header := &zip.FileHeader{
+		Name:     entry.name,
+		Method:   zip.Deflate,
+		Modified: entry.attachment.CreatedAt,
+	}
+	if entry.attachment.MimeType != nil && alreadyCompressed(*entry.attachment.MimeType) {
+		header.Method = zip.Store
+	}
+
+	fw, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(fw, body)
+	return err
+}
+
+// archiveNames hands out unique paths within an archive, numbering repeats the way
+// file managers do: "report.pdf", "report (1).pdf". Case is ignored since most
+// desktop filesystems ignore it.
+type archiveNames map[string]bool
+
+func (n archiveNames) file(name string) string {
+	ext := path.Ext(name)
+	return n.unique(strings.TrimSuffix(name, ext), ext)
+}
+
+func (n archiveNames) folder(name string) string {
+	return n.unique(name, "")
+}
+
+func (n archiveNames) unique(base, ext string) string {
+	name := base + ext
+	for i := 1; n[strings.ToLower(name)]; i++ {
+		name = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	n[strings.ToLower(name)] = true
+	return name
+}
+
+// alreadyCompressed reports content that deflate would only slow down.
+func alreadyCompressed(mimeType string) bool {
+	media, _, _ := strings.Cut(strings.ToLower(mimeType), ";")
+
+	switch {
+	case strings.HasPrefix(media, "video/"), strings.HasPrefix(media, "audio/"):
+		return true
+	case strings.HasPrefix(media, "application/vnd.openxmlformats-officedocument."):
+		return true
+	}
+
+	switch media {
+	case "image/jpeg", "image/png", "image/gif", "image/webp",
+		"application/zip", "application/gzip", "application/x-7z-compressed", "application/x-rar-compressed":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ApoorvYdv/go-tasker/internal/errs"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/job"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/preview"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/realtime"
+	"github.com/ApoorvYdv/go-tasker/internal/middleware"
+	"github.com/ApoorvYdv/go-tasker/internal/model/todo"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// enqueuePreview queues preview generation for a new attachment. Previews are
+// best effort, so failing to queue marks the preview failed instead of failing
+// the upload.
+func (s *TodoService) enqueuePreview(ctx echo.Context, attachment *todo.Attachment) {
+	if attachment.PreviewStatus != todo.PreviewPending {
+		return
+	}
+
+	logger := middleware.GetLogger(ctx)
+
+	task, err := job.NewGenerateAttachmentPreviewTask(attachment.ID)
+	if err == nil {
+		_, err = s.server.Job.Client.Enqueue(task)
+	}
+	if err == nil {
+		return
+	}
+
+	logger.Error().Err(err).Str("attachment_id", attachment.ID.String()).Msg("failed to enqueue attachment preview")
+
+	updated, err := s.todoRepo.UpdateAttachmentPreview(ctx.Request().Context(), attachment.ID, todo.PreviewFailed, nil, nil)
+	if err != nil {
+		logger.Error().Err(err).Str("attachment_id", attachment.ID.String()).Msg("failed to update attachment preview")
+		return
+	}
+	*attachment = *updated
+}
+
+// handleGenerateAttachmentPreviewTask stores a thumbnail next to an image
+// attachment, or reads the page count of a PDF. Storage errors are retried and the
+// preview is marked failed once retries run out; files that can't be decoded fail
+// right away.
+func (s *TodoService) handleGenerateAttachmentPreviewTask(ctx context.Context, t *asynq.Task) error {
+	logger := s.server.Logger
+
+	var p job.GenerateAttachmentPreviewPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("failed to unmarshal attachment preview payload: %w: %w", err, asynq.SkipRetry)
+	}
+
+	attachmentID, err := uuid.Parse(p.AttachmentID)
+	if err != nil {
+		return fmt.Errorf("invalid attachment id %q: %w", p.AttachmentID, asynq.SkipRetry)
+	}
+
+	attachment, err := s.todoRepo.GetAttachmentByID(ctx, attachmentID)
+	if err != nil {
+		var httpErr *errs.HTTPError
+		if errors.As(err, &httpErr) && httpErr.Status == http.StatusNotFound {
+			// Deleted before its preview was generated
+			return nil
+		}
+		return err
+	}
+
+	if attachment.PreviewStatus == todo.PreviewReady {
+		return nil
+	}
+
+	// Infected content is quarantined rather than decoded
+	if attachment.ScanStatus == todo.ScanInfected {
+		return s.finishPreview(ctx, attachment, todo.PreviewUnsupported, nil, nil)
+	}
+
+	var mimeType string
+	if attachment.MimeType != nil {
+		mimeType = *attachment.MimeType
+	}
+
+	var thumbnailKey *string
+	var pageCount *int
+
+	switch {
+	case preview.IsImage(mimeType):
+		thumbnailKey, err = s.generateThumbnail(ctx, attachment.DownloadKey)
+	case preview.IsPDF(mimeType):
+		pageCount, err = s.readPageCount(ctx, attachment.DownloadKey)
+	default:
+		return s.finishPreview(ctx, attachment, todo.PreviewUnsupported, nil, nil)
+	}
+
+	switch {
+	case err == nil:
+		return s.finishPreview(ctx, attachment, todo.PreviewReady, thumbnailKey, pageCount)
+	case errors.Is(err, preview.ErrTooLarge):
+		return s.finishPreview(ctx, attachment, todo.PreviewUnsupported, nil, nil)
+	case errors.Is(err, preview.ErrUnsupported):
+		logger.Warn().Err(err).Str("attachment_id", attachment.ID.String()).Msg("failed to decode attachment for preview")
+		return s.finishPreview(ctx, attachment, todo.PreviewFailed, nil, nil)
+	}
+
+	logger.Error().Err(err).Str("attachment_id", attachment.ID.String()).Msg("failed to generate attachment preview")
+
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	if retried >= maxRetry {
+		if finishErr := s.finishPreview(ctx, attachment, todo.PreviewFailed, nil, nil); finishErr != nil {
+			return finishErr
+		}
+	}
+
+	return err
+}
+
+func (s *TodoService) generateThumbnail(ctx context.Context, key string) (*string, error) {
+	body, _, err := s.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	thumbnail, err := preview.Thumbnail(body)
+	if err != nil {
+		return nil, err
+	}
+
+	thumbnailKey := todo.ThumbnailKey(key)
+	if err := s.storage.Put(ctx, thumbnailKey, bytes.NewReader(thumbnail), preview.ThumbnailContentType); err != nil {
+		return nil, err
+	}
+
+	return &thumbnailKey, nil
+}
+
+func (s *TodoService) readPageCount(ctx context.Context, key string) (*int, error) {
+	body, _, err := s.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	pageCount, err := preview.PageCount(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pageCount, nil
+}
+
+// finishPreview records the preview outcome and tells the todo's members, so open
+// clients can show the thumbnail without reloading.
+func (s *TodoService) finishPreview(ctx context.Context, attachment *todo.Attachment, status todo.PreviewStatus,
+	thumbnailKey *string, pageCount *int,
+) error {
+	logger := s.server.Logger
+
+	updated, err := s.todoRepo.UpdateAttachmentPreview(ctx, attachment.ID, status, thumbnailKey, pageCount)
+	if err != nil {
+		var httpErr *errs.HTTPError
+		if errors.As(err, &httpErr) && httpErr.Status == http.StatusNotFound {
+			return nil
+		}
+		logger.Error().Err(err).Str("attachment_id", attachment.ID.String()).Msg("failed to update attachment preview")
+		return err
+	}
+
+	logger.Info().
+		Str("event", "todo_attachment_preview_generated").
+		Str("attachment_id", updated.ID.String()).
+		Str("preview_status", string(updated.PreviewStatus)).
+		Msg("Attachment preview generated")
+
+	s.publishAttachmentUpdate(ctx, updated)
+
+	return nil
+}
+
+// publishAttachmentUpdate tells the todo's members about a change made to an
+// attachment in the background, so open clients catch up without reloading.
+func (s *TodoService) publishAttachmentUpdate(ctx context.Context, attachment *todo.Attachment) {
+	logger := s.server.Logger
+
+	todoID, err := uuid.Parse(attachment.TodoID)
+	if err != nil {
+		return
+	}
+
+	memberIDs, err := s.todoRepo.GetTodoMemberIDs(ctx, todoID)
+	if err != nil {
+		logger.Warn().Err(err).Msg("failed to resolve todo members for realtime event")
+		return
+	}
+
+	attachments := []todo.Attachment{*attachment}
+	s.withThumbnailURLs(ctx, attachments)
+	publishToUsers(ctx, logger, s.server, memberIDs, realtime.EventAttachmentUpdated, attachment.ID.String(), attachments[0])
+}
+
+// withThumbnailURLs presigns the thumbnail of each attachment that has one. A
+// thumbnail that can't be presigned is left out rather than failing the request.
+func (s *TodoService) withThumbnailURLs(ctx context.Context, attachments []todo.Attachment) {
+	for i := range attachments {
+		if attachments[i].ThumbnailKey == nil {
+			continue
+		}
+
+		url, err := s.storage.Presign(ctx, *attachments[i].ThumbnailKey, s.presignExpiry(), "")
+		if err != nil {
+			s.server.Logger.Warn().Err(err).Str("attachment_id", attachments[i].ID.String()).Msg("failed to presign attachment thumbnail")
+			continue
+		}
+		attachments[i].ThumbnailURL = &url
+	}
+}
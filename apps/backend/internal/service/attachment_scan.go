@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/errs"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/job"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/scan"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/storage"
+	"github.com/ApoorvYdv/go-tasker/internal/middleware"
+	"github.com/ApoorvYdv/go-tasker/internal/model/todo"
+	"github.com/hibiken/asynq"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	// scanPendingInterval must match scanPendingSchedule
+	scanPendingInterval = 15 * time.Minute
+	scanPendingSchedule = "*/15 * * * *"
+	scanPendingBatch    = 500
+	// Scans queued on upload get this long before the sweep queues them again
+	scanPendingGrace = 15 * time.Minute
+)
+
+// enqueueScan queues the malware scan of an attachment's content. Scans that fail
+// to queue are picked up by the sweep for pending scans. Without a scanner content
+// stays pending, so the sweep scans it once one is configured.
+func (s *TodoService) enqueueScan(ctx echo.Context, attachment *todo.Attachment) {
+	if !s.scanner.Enabled() || attachment.ScanStatus != todo.ScanPending {
+		return
+	}
+
+	logger := middleware.GetLogger(ctx)
+
+	task, err := job.NewScanAttachmentTask(attachment.DownloadKey)
+	if err == nil {
+		_, err = s.server.Job.Client.Enqueue(task)
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("attachment_id", attachment.ID.String()).Msg("failed to enqueue attachment scan")
+	}
+}
+
+// handleScanAttachmentTask scans stored content and records the verdict on every
+// attachment and version sharing it; infected content is quarantined. Scanner
+// outages are retried and recorded as errors once retries run out, while content
+// the scanner can't handle is recorded as an error right away.
+func (s *TodoService) handleScanAttachmentTask(ctx context.Context, t *asynq.Task) error {
+	logger := s.server.Logger
+
+	var p job.ScanAttachmentPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("failed to unmarshal scan attachment payload: %w: %w", err, asynq.SkipRetry)
+	}
+
+	// Already scanned, or deleted since the task was queued
+	pending, err := s.todoRepo.HasPendingScan(ctx, p.StorageKey)
+	if err != nil {
+		return err
+	}
+	if !pending {
+		return nil
+	}
+
+	result, err := s.scanObject(ctx, p.StorageKey)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		logger.Warn().Str("storage_key", p.StorageKey).Msg("attachment to scan is missing from storage")
+		return s.recordScan(ctx, p.StorageKey, todo.ScanError)
+	case errors.Is(err, scan.ErrScanFailed):
+		logger.Warn().Err(err).Str("storage_key", p.StorageKey).Msg("attachment could not be scanned")
+		return s.recordScan(ctx, p.StorageKey, todo.ScanError)
+	case err != nil:
+		logger.Error().Err(err).Str("storage_key", p.StorageKey).Msg("failed to scan attachment")
+
+		retried, _ := asynq.GetRetryCount(ctx)
+		maxRetry, _ := asynq.GetMaxRetry(ctx)
+		if retried >= maxRetry {
+			if recordErr := s.recordScan(ctx, p.StorageKey, todo.ScanError); recordErr != nil {
+				return recordErr
+			}
+		}
+		return err
+	}
+
+	if !result.Infected {
+		return s.recordScan(ctx, p.StorageKey, todo.ScanClean)
+	}
+
+	logger.Warn().
+		Str("event", "attachment_malware_found").
+		Str("storage_key", p.StorageKey).
+		Str("signature", result.Signature).
+		Msg("Malware found in attachment")
+
+	return s.quarantine(ctx, p.StorageKey)
+}
+
+func (s *TodoService) scanObject(ctx context.Context, key string) (*scan.Result, error) {
+	body, _, err := s.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return s.scanner.Scan(ctx, body)
+}
+
+func (s *TodoService) recordScan(ctx context.Context, key string, status todo.ScanStatus) error {
+	attachments, err := s.todoRepo.SetScanStatus(ctx, key, status)
+	if err != nil {
+		s.server.Logger.Error().Err(err).Str("storage_key", key).Msg("failed to record attachment scan")
+		return err
+	}
+
+	for i := range attachments {
+		s.publishAttachmentUpdate(ctx, &attachments[i])
+	}
+
+	return nil
+}
+
+// quarantine moves infected content under the quarantine prefix, where nothing is
+// served from, and drops its thumbnail. Objects left behind when deleting fails
+// are unreferenced, so the reconciliation job removes them.
+func (s *TodoService) quarantine(ctx context.Context, key string) error {
+	logger := s.server.Logger
+
+	// Content quarantined before is uploaded again under its quarantined key
+	quarantineKey := key
+	if !strings.HasPrefix(key, todo.QuarantinePrefix) {
+		quarantineKey = todo.QuarantinePrefix + key
+		if err := s.storage.Copy(ctx, key, quarantineKey); err != nil {
+			logger.Error().Err(err).Str("storage_key", key).Msg("failed to copy infected attachment to quarantine")
+			return err
+		}
+	}
+
+	attachments, err := s.todoRepo.QuarantineContent(ctx, key, quarantineKey)
+	if err != nil {
+		logger.Error().Err(err).Str("storage_key", key).Msg("failed to quarantine infected attachment")
+		return err
+	}
+
+	if quarantineKey != key {
+		for _, staleKey := range []string{key, todo.ThumbnailKey(key)} {
+			if err := s.storage.Delete(ctx, staleKey); err != nil {
+				logger.Warn().Err(err).Str("storage_key", staleKey).Msg("failed to delete infected attachment outside quarantine")
+			}
+		}
+	}
+
+	logger.Info().
+		Str("event", "attachment_quarantined").
+		Str("storage_key", quarantineKey).
+		Int("attachments", len(attachments)).
+		Msg("Infected attachment quarantined")
+
+	for i := range attachments {
+		s.publishAttachmentUpdate(ctx, &attachments[i])
+	}
+
+	return nil
+}
+
+// handleScanPendingAttachmentsTask queues scans for content still pending well
+// after upload: scans that failed to queue, and content stored before scanning
+// existed. Content whose scan is already queued keeps its task.
+func (s *TodoService) handleScanPendingAttachmentsTask(ctx context.Context, _ *asynq.Task) error {
+	logger := s.server.Logger
+
+	keys, err := s.todoRepo.GetPendingScanKeys(ctx, time.Now().Add(-scanPendingGrace), scanPendingBatch)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to fetch attachments pending a scan")
+		return err
+	}
+
+	queued := 0
+	for _, key := range keys {
+		task, err := job.NewScanAttachmentTask(key)
+		if err == nil {
+			_, err = s.server.Job.Client.Enqueue(task, asynq.TaskID("scan:"+key))
+		}
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			continue
+		}
+		if err != nil {
+			logger.Error().Err(err).Str("storage_key", key).Msg("failed to enqueue attachment scan")
+			continue
+		}
+		queued++
+	}
+
+	if queued > 0 {
+		logger.Info().Int("count", queued).Msg("queued pending attachment scans")
+	}
+
+	return nil
+}
+
+// checkDownloadable lets content out only once it has been scanned clean, unless
+// no scanner is configured.
+func (s *TodoService) checkDownloadable(attachment *todo.Attachment) error {
+	if !s.scanner.Enabled() {
+		return nil
+	}
+
+	switch attachment.ScanStatus {
+	case todo.ScanClean:
+		return nil
+	case todo.ScanInfected:
+		err := errs.NewForbiddenError("this file contains malware and can't be downloaded", false)
+		err.Code = "FILE_INFECTED"
+		return err
+	case todo.ScanError:
+		err := errs.NewForbiddenError("this file couldn't be scanned for malware and can't be downloaded", false)
+		err.Code = "SCAN_FAILED"
+		return err
+	default:
+		code := "SCAN_PENDING"
+		return errs.NewConflictError("this file is still being scanned for malware, try again shortly", false, &code)
+	}
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/config"
+	"github.com/ApoorvYdv/go-tasker/internal/errs"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/job"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/scan"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/scan/scantest"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/storage"
+	"github.com/ApoorvYdv/go-tasker/internal/model/todo"
+	"github.com/ApoorvYdv/go-tasker/internal/repository"
+	tasktest "github.com/ApoorvYdv/go-tasker/internal/testing"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleScanAttachmentTask(t *testing.T) {
+	_, srv, cleanup := tasktest.SetupTest(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID := "user_" + uuid.NewString()
+
+	local, err := storage.NewLocalStorage(&config.StorageConfig{
+		Backend:    "local",
+		LocalDir:   t.TempDir(),
+		SigningKey: "test-signing-key",
+		PublicURL:  "http://localhost:8080",
+	})
+	require.NoError(t, err)
+
+	todoRepo := repository.NewTodoRepository(srv)
+	todoItem, err := todoRepo.CreateTodo(ctx, userID, &todo.CreateTodoPayload{Title: "Scanned attachments"})
+	require.NoError(t, err)
+
+	// attach stores content the way uploads do, under its checksum, pending a scan
+	attach := func(t *testing.T, content []byte) *todo.Attachment {
+		t.Helper()
+
+		sum := sha256.Sum256(content)
+		checksum := hex.EncodeToString(sum[:])
+		key := "attachments/" + userID + "/" + checksum
+
+		require.NoError(t, local.Put(ctx, key, bytes.NewReader(content), "text/plain"))
+		attachment, err := todoRepo.UploadTodoAttachment(ctx, userID, todoItem.ID, "file.txt",
+			int64(len(content)), "text/plain", key, checksum)
+		require.NoError(t, err)
+		require.Equal(t, todo.ScanPending, attachment.ScanStatus)
+
+		return attachment
+	}
+
+	newService := func(scanner scan.Scanner) *TodoService {
+		return &TodoService{
+			server:   srv,
+			todoRepo: todoRepo,
+			storage:  local,
+			scanner:  scanner,
+		}
+	}
+
+	runScan := func(t *testing.T, s *TodoService, key string) error {
+		t.Helper()
+
+		task, err := job.NewScanAttachmentTask(key)
+		require.NoError(t, err)
+		return s.handleScanAttachmentTask(ctx, task)
+	}
+
+	reload := func(t *testing.T, attachment *todo.Attachment) *todo.Attachment {
+		t.Helper()
+
+		reloaded, err := todoRepo.GetAttachmentByID(ctx, attachment.ID)
+		require.NoError(t, err)
+		return reloaded
+	}
+
+	assertStored := func(t *testing.T, key string, stored bool) {
+		t.Helper()
+
+		body, _, err := local.Get(ctx, key)
+		if stored {
+			require.NoError(t, err)
+			body.Close()
+			return
+		}
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	}
+
+	assertVersionsScanned := func(t *testing.T, attachment *todo.Attachment, status todo.ScanStatus, key string) {
+		t.Helper()
+
+		versions, err := todoRepo.GetAttachmentVersions(ctx, attachment.ID)
+		require.NoError(t, err)
+		require.NotEmpty(t, versions)
+		for _, version := range versions {
+			assert.Equal(t, status, version.ScanStatus)
+			assert.Equal(t, key, version.DownloadKey)
+		}
+	}
+
+	t.Run("clean content is released", func(t *testing.T) {
+		d := scantest.NewClamd(t, 1<<20)
+		s := newService(scan.NewClamAVScanner(d.Address(), 5*time.Second))
+		attachment := attach(t, []byte("clean content"))
+
+		require.NoError(t, runScan(t, s, attachment.DownloadKey))
+
+		scanned := reload(t, attachment)
+		assert.Equal(t, todo.ScanClean, scanned.ScanStatus)
+		assert.Equal(t, attachment.DownloadKey, scanned.DownloadKey)
+		assertVersionsScanned(t, attachment, todo.ScanClean, attachment.DownloadKey)
+		assertStored(t, attachment.DownloadKey, true)
+		assert.NoError(t, s.checkDownloadable(scanned))
+
+		// Scanning again is a no-op
+		require.NoError(t, runScan(t, s, attachment.DownloadKey))
+		assert.Len(t, d.Streams(), 1)
+	})
+
+	t.Run("infected content is quarantined", func(t *testing.T) {
+		d := scantest.NewClamd(t, 1<<20)
+		s := newService(scan.NewClamAVScanner(d.Address(), 5*time.Second))
+		content := []byte("payload " + scantest.EICARMarker)
+		attachment := attach(t, content)
+		quarantineKey := todo.QuarantinePrefix + attachment.DownloadKey
+
+		require.NoError(t, runScan(t, s, attachment.DownloadKey))
+
+		scanned := reload(t, attachment)
+		assert.Equal(t, todo.ScanInfected, scanned.ScanStatus)
+		assert.Equal(t, quarantineKey, scanned.DownloadKey)
+		assert.Equal(t, todo.PreviewUnsupported, scanned.PreviewStatus)
+		assert.Nil(t, scanned.ThumbnailKey)
+		assertVersionsScanned(t, attachment, todo.ScanInfected, quarantineKey)
+
+		// The content moved under the quarantine prefix, and its blob with it
+		assertStored(t, attachment.DownloadKey, false)
+		assertStored(t, quarantineKey, true)
+		blobKey, err := todoRepo.FindBlobKey(ctx, userID, *attachment.Checksum)
+		require.NoError(t, err)
+		assert.Equal(t, quarantineKey, blobKey)
+
+		err = s.checkDownloadable(scanned)
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusForbidden, httpErr.Status)
+		assert.Equal(t, "FILE_INFECTED", httpErr.Code)
+	})
+
+	t.Run("oversize content is marked unscannable", func(t *testing.T) {
+		d := scantest.NewClamd(t, 16)
+		s := newService(scan.NewClamAVScanner(d.Address(), 5*time.Second))
+		attachment := attach(t, []byte(strings.Repeat("too large for clamd ", 10)))
+
+		// Scanning again can't help, so the task doesn't fail
+		require.NoError(t, runScan(t, s, attachment.DownloadKey))
+
+		scanned := reload(t, attachment)
+		assert.Equal(t, todo.ScanError, scanned.ScanStatus)
+		assert.Equal(t, attachment.DownloadKey, scanned.DownloadKey)
+		assertVersionsScanned(t, attachment, todo.ScanError, attachment.DownloadKey)
+		assertStored(t, attachment.DownloadKey, true)
+
+		err := s.checkDownloadable(scanned)
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, "SCAN_FAILED", httpErr.Code)
+	})
+
+	t.Run("daemon down fails the task", func(t *testing.T) {
+		s := newService(scan.NewClamAVScanner(scantest.DownAddress(t), time.Second))
+		attachment := attach(t, []byte("content scanned during an outage"))
+
+		pending := reload(t, attachment)
+		err := s.checkDownloadable(pending)
+		var httpErr *errs.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusConflict, httpErr.Status)
+		assert.Equal(t, "SCAN_PENDING", httpErr.Code)
+
+		// Outside a worker the task has no retries left, so the outage is recorded
+		// as well as returned
+		err = runScan(t, s, attachment.DownloadKey)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, scan.ErrScanFailed)
+
+		scanned := reload(t, attachment)
+		assert.Equal(t, todo.ScanError, scanned.ScanStatus)
+		assertStored(t, attachment.DownloadKey, true)
+	})
+
+	t.Run("missing content is marked unscannable", func(t *testing.T) {
+		d := scantest.NewClamd(t, 1<<20)
+		s := newService(scan.NewClamAVScanner(d.Address(), 5*time.Second))
+		attachment := attach(t, []byte("content deleted before its scan"))
+		require.NoError(t, local.Delete(ctx, attachment.DownloadKey))
+
+		require.NoError(t, runScan(t, s, attachment.DownloadKey))
+
+		assert.Equal(t, todo.ScanError, reload(t, attachment).ScanStatus)
+		assert.Empty(t, d.Streams())
+	})
+}
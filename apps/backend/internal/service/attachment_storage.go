@@ -0,0 +1,330 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/lib/job"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/storage"
+	"github.com/ApoorvYdv/go-tasker/internal/middleware"
+	"github.com/ApoorvYdv/go-tasker/internal/model/todo"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	// storageReconcileInterval must match storageReconcileSchedule
+	storageReconcileInterval = 24 * time.Hour
+	storageReconcileSchedule = "45 3 * * *"
+	storageReconcileBatch    = 500
+	// Objects and rows younger than this may belong to an upload in progress, whose
+	// object is written before its row or the other way round
+	storageReconcileGrace = 24 * time.Hour
+)
+
+// reconcilePrefixes are where attachment content lives: blobs and their
+// thumbnails, uploads waiting for their checksum, attachments stored before
+// content addressing, and infected content.
+var reconcilePrefixes = []string{"attachments/", "uploads/", "todos/attachments/", todo.QuarantinePrefix}
+
+// deleteObjects queues the deletion of content no attachment references anymore,
+// along with its thumbnail. Objects left behind when queueing fails are picked up
+// by the reconciliation job.
+func (s *TodoService) deleteObjects(ctx echo.Context, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	logger := middleware.GetLogger(ctx)
+
+	all := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		all = append(all, key, todo.ThumbnailKey(key))
+	}
+
+	task, err := job.NewDeleteStorageObjectsTask(all)
+	if err == nil {
+		_, err = s.server.Job.Client.Enqueue(task)
+	}
+	if err != nil {
+		logger.Error().Err(err).Strs("storage_keys", all).Msg("failed to enqueue storage deletion")
+	}
+}
+
+// handleDeleteStorageObjectsTask deletes objects that were unreferenced when the
+// task was queued. The same content may have been uploaded again since, so each
+// key is checked once more before deleting it.
+func (s *TodoService) handleDeleteStorageObjectsTask(ctx context.Context, t *asynq.Task) error {
+	logger := s.server.Logger
+
+	var p job.DeleteStorageObjectsPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("failed to unmarshal delete storage objects payload: %w: %w", err, asynq.SkipRetry)
+	}
+
+	unreferenced, err := s.unreferencedKeys(ctx, p.Keys)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, key := range p.Keys {
+		if !unreferenced[key] {
+			continue
+		}
+		if err := s.storage.Delete(ctx, key); err != nil {
+			logger.Warn().Err(err).Str("storage_key", key).Msg("failed to delete file from storage")
+			failed++
+		}
+	}
+
+	// Deletes are idempotent, so the whole task is retried
+	if failed > 0 {
+		return fmt.Errorf("failed to delete %d of %d storage objects", failed, len(p.Keys))
+	}
+
+	return nil
+}
+
+// unreferencedKeys reports which keys nothing stores content under. Thumbnails
+// count as referenced while their original is.
+func (s *TodoService) unreferencedKeys(ctx context.Context, keys []string) (map[string]bool, error) {
+	sources := make([]string, len(keys))
+	for i, key := range keys {
+		sources[i] = strings.TrimSuffix(key, todo.ThumbnailSuffix)
+	}
+
+	found, err := s.todoRepo.FindUnreferencedKeys(ctx, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	unreferencedSources := make(map[string]bool, len(found))
+	for _, source := range found {
+		unreferencedSources[source] = true
+	}
+
+	unreferenced := make(map[string]bool, len(keys))
+	for i, key := range keys {
+		unreferenced[key] = unreferencedSources[sources[i]]
+	}
+
+	return unreferenced, nil
+}
+
+type storageReport struct {
+	dryRun            bool
+	objects           int
+	orphaned          int
+	orphanedBytes     int64
+	deleted           int
+	attachments       int
+	missing           int
+	missingThumbnails int
+}
+
+// handleReconcileStorageTask compares storage with the database. Objects no row
+// references are deleted, or only reported in a dry run. Attachments whose object
+// is missing can't be restored and are reported; missing thumbnails are
+// regenerated.
+func (s *TodoService) handleReconcileStorageTask(ctx context.Context, t *asynq.Task) error {
+	logger := s.server.Logger
+
+	var p job.ReconcileStoragePayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("failed to unmarshal reconcile storage payload: %w", err)
+	}
+
+	report := &storageReport{dryRun: p.DryRun}
+	cutoff := time.Now().Add(-storageReconcileGrace)
+
+	for _, prefix := range reconcilePrefixes {
+		batch := make([]*storage.Object, 0, storageReconcileBatch)
+
+		err := s.storage.List(ctx, prefix, func(object *storage.Object) error {
+			report.objects++
+			if object.LastModified.After(cutoff) {
+				return nil
+			}
+
+			batch = append(batch, object)
+			if len(batch) < storageReconcileBatch {
+				return nil
+			}
+
+			err := s.reconcileObjects(ctx, batch, report)
+			batch = batch[:0]
+			return err
+		})
+		if err == nil && len(batch) > 0 {
+			err = s.reconcileObjects(ctx, batch, report)
+		}
+		if err != nil {
+			logger.Error().Err(err).Str("prefix", prefix).Msg("failed to reconcile storage objects")
+			return err
+		}
+	}
+
+	if err := s.reconcileAttachments(ctx, cutoff, report); err != nil {
+		logger.Error().Err(err).Msg("failed to reconcile attachments")
+		return err
+	}
+
+	logger.Info().
+		Str("event", "storage_reconciled").
+		Bool("dry_run", report.dryRun).
+		Int("objects", report.objects).
+		Int("orphaned_objects", report.orphaned).
+		Int64("orphaned_bytes", report.orphanedBytes).
+		Int("deleted_objects", report.deleted).
+		Int("attachments", report.attachments).
+		Int("missing_objects", report.missing).
+		Int("missing_thumbnails", report.missingThumbnails).
+		Msg("Storage reconciliation finished")
+
+	return nil
+}
+
+// reconcileObjects finds the objects in a batch that nothing references and
+// deletes them unless this is a dry run.
+func (s *TodoService) reconcileObjects(ctx context.Context, objects []*storage.Object, report *storageReport) error {
+	logger := s.server.Logger
+
+	keys := make([]string, len(objects))
+	for i, object := range objects {
+		keys[i] = object.Key
+	}
+
+	unreferenced, err := s.unreferencedKeys(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	for _, object := range objects {
+		if !unreferenced[object.Key] {
+			continue
+		}
+
+		report.orphaned++
+		report.orphanedBytes += object.Size
+
+		logger.Warn().
+			Str("storage_key", object.Key).
+			Int64("size", object.Size).
+			Time("last_modified", object.LastModified).
+			Bool("dry_run", report.dryRun).
+			Msg("found storage object without a database row")
+
+		if report.dryRun {
+			continue
+		}
+
+		if err := s.storage.Delete(ctx, object.Key); err != nil {
+			logger.Error().Err(err).Str("storage_key", object.Key).Msg("failed to delete orphaned storage object")
+			continue
+		}
+		report.deleted++
+	}
+
+	return nil
+}
+
+// reconcileAttachments checks that the content and thumbnail of every attachment
+// exist.
+func (s *TodoService) reconcileAttachments(ctx context.Context, cutoff time.Time, report *storageReport) error {
+	logger := s.server.Logger
+	afterID := uuid.Nil
+
+	for {
+		attachments, err := s.todoRepo.GetAttachmentsCreatedBefore(ctx, cutoff, afterID, storageReconcileBatch)
+		if err != nil {
+			return err
+		}
+		if len(attachments) == 0 {
+			return nil
+		}
+		afterID = attachments[len(attachments)-1].ID
+
+		// Deduplicated attachments share their content, so each key is checked once
+		exists := make(map[string]bool)
+		for i := range attachments {
+			attachment := &attachments[i]
+			report.attachments++
+
+			found, err := s.objectExists(ctx, attachment.DownloadKey, exists)
+			if err != nil {
+				return err
+			}
+			if !found {
+				report.missing++
+				logger.Warn().
+					Str("attachment_id", attachment.ID.String()).
+					Str("todo_id", attachment.TodoID).
+					Str("storage_key", attachment.DownloadKey).
+					Msg("found attachment without a storage object")
+				continue
+			}
+
+			if attachment.ThumbnailKey == nil {
+				continue
+			}
+
+			found, err = s.objectExists(ctx, *attachment.ThumbnailKey, exists)
+			if err != nil {
+				return err
+			}
+			if found {
+				continue
+			}
+
+			report.missingThumbnails++
+			logger.Warn().
+				Str("attachment_id", attachment.ID.String()).
+				Str("storage_key", *attachment.ThumbnailKey).
+				Bool("dry_run", report.dryRun).
+				Msg("found attachment without its thumbnail")
+
+			if !report.dryRun {
+				s.regeneratePreview(ctx, attachment)
+			}
+		}
+	}
+}
+
+func (s *TodoService) objectExists(ctx context.Context, key string, cache map[string]bool) (bool, error) {
+	if found, ok := cache[key]; ok {
+		return found, nil
+	}
+
+	_, err := s.storage.Head(ctx, key)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return false, err
+	}
+
+	cache[key] = err == nil
+	return err == nil, nil
+}
+
+// regeneratePreview resets a preview whose thumbnail went missing and queues it
+// again.
+func (s *TodoService) regeneratePreview(ctx context.Context, attachment *todo.Attachment) {
+	logger := s.server.Logger
+
+	if _, err := s.todoRepo.UpdateAttachmentPreview(ctx, attachment.ID, todo.PreviewPending, nil, nil); err != nil {
+		logger.Error().Err(err).Str("attachment_id", attachment.ID.String()).Msg("failed to reset attachment preview")
+		return
+	}
+
+	task, err := job.NewGenerateAttachmentPreviewTask(attachment.ID)
+	if err == nil {
+		_, err = s.server.Job.Client.Enqueue(task)
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("attachment_id", attachment.ID.String()).Msg("failed to enqueue attachment preview")
+	}
+}
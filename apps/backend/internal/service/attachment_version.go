@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"io"
+
+	"github.com/ApoorvYdv/go-tasker/internal/database"
+	"github.com/ApoorvYdv/go-tasker/internal/middleware"
+	"github.com/ApoorvYdv/go-tasker/internal/model/share"
+	"github.com/ApoorvYdv/go-tasker/internal/model/todo"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// UploadAttachmentVersion streams a file to storage as the new current version of
+// an attachment. Earlier versions stay downloadable until retention prunes them.
+func (s *TodoService) UploadAttachmentVersion(ctx echo.Context, userID string, attachmentID uuid.UUID,
+	filename string, body io.Reader,
+) (*todo.Attachment, error) {
+	logger := middleware.GetLogger(ctx)
+
+	// Fail before storing anything when the attachment doesn't exist
+	todoID, err := s.attachmentTodoID(ctx, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate todo exists and user can edit it
+	_, err = s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, todoID, share.RoleEditor)
+	if err != nil {
+		logger.Error().Err(err).Msg("todo validation failed")
+		return nil, err
+	}
+
+	file, err := s.storeFile(ctx, userID, filename, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record the version with its blob reference in one unit of work, and delete the
+	// content of pruned versions once that commits
+	var (
+		attachment *todo.Attachment
+		prunedKeys []string
+		failure    string
+	)
+	err = s.server.DB.WithTx(ctx.Request().Context(), func(txCtx context.Context) error {
+		// Access may have been revoked while the file was stored
+		if _, err := s.todoRepo.CheckTodoAccess(txCtx, userID, todoID, share.RoleEditor); err != nil {
+			failure = "todo validation failed"
+			return err
+		}
+
+		var err error
+		attachment, prunedKeys, err = s.todoRepo.AddAttachmentVersion(txCtx, userID, todoID, attachmentID,
+			file.name, file.size, file.mimeType, file.key, file.checksum, s.server.Config.Storage.MaxAttachmentVersions)
+		if err != nil {
+			failure = "failed to create attachment version"
+			return err
+		}
+
+		database.AfterCommit(txCtx, func() { s.deleteObjects(ctx, prunedKeys) })
+		return nil
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg(failure)
+		// The content stays if another attachment already references it
+		s.deleteObjects(ctx, []string{file.key})
+		return nil, err
+	}
+
+	// Business event log
+	eventLogger := middleware.GetLogger(ctx)
+	eventLogger.Info().
+		Str("event", "todo_attachment_version_uploaded").
+		Str("todo_id", todoID.String()).
+		Str("attachment_id", attachment.ID.String()).
+		Int("version", attachment.Version).
+		Int64("size", file.size).
+		Int("pruned_versions", len(prunedKeys)).
+		Msg("Attachment version uploaded successfully")
+
+	s.enqueueScan(ctx, attachment)
+	s.enqueuePreview(ctx, attachment)
+
+	return attachment, nil
+}
+
+// RestoreAttachmentVersion makes the content of an earlier version current again
+// by adding it as a new version, so restoring can itself be undone.
+func (s *TodoService) RestoreAttachmentVersion(ctx echo.Context, userID string,
+	payload *todo.RestoreAttachmentVersionPayload,
+) (*todo.Attachment, error) {
+	logger := middleware.GetLogger(ctx)
+
+	todoID, err := s.attachmentTodoID(ctx, payload.AttachmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Restoring takes a blob reference for the restored content, so it runs in one
+	// unit of work with the access check, and pruned content is deleted once it commits
+	var (
+		attachment *todo.Attachment
+		prunedKeys []string
+		failure    string
+	)
+	err = s.server.DB.WithTx(ctx.Request().Context(), func(txCtx context.Context) error {
+		// Validate todo exists and user can edit it
+		if _, err := s.todoRepo.CheckTodoAccess(txCtx, userID, todoID, share.RoleEditor); err != nil {
+			failure = "todo validation failed"
+			return err
+		}
+
+		var err error
+		attachment, prunedKeys, err = s.todoRepo.RestoreAttachmentVersion(txCtx, userID, todoID,
+			payload.AttachmentID, payload.Version, s.server.Config.Storage.MaxAttachmentVersions)
+		if err != nil {
+			failure = "failed to restore attachment version"
+			return err
+		}
+
+		database.AfterCommit(txCtx, func() { s.deleteObjects(ctx, prunedKeys) })
+		return nil
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg(failure)
+		return nil, err
+	}
+
+	// Business event log
+	eventLogger := middleware.GetLogger(ctx)
+	eventLogger.Info().
+		Str("event", "todo_attachment_version_restored").
+		Str("todo_id", todoID.String()).
+		Str("attachment_id", attachment.ID.String()).
+		Int("restored_version", payload.Version).
+		Int("version", attachment.Version).
+		Msg("Attachment version restored successfully")
+
+	s.enqueueScan(ctx, attachment)
+	s.enqueuePreview(ctx, attachment)
+
+	return attachment, nil
+}
+
+// GetAttachmentVersions lists the kept versions of an attachment, newest first.
+func (s *TodoService) GetAttachmentVersions(ctx echo.Context, userID string, attachmentID uuid.UUID,
+) ([]todo.AttachmentVersion, error) {
+	logger := middleware.GetLogger(ctx)
+
+	todoID, err := s.attachmentTodoID(ctx, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate todo exists and user can view it
+	_, err = s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, todoID, share.RoleViewer)
+	if err != nil {
+		logger.Error().Err(err).Msg("todo validation failed")
+		return nil, err
+	}
+
+	versions, err := s.todoRepo.GetAttachmentVersions(ctx.Request().Context(), attachmentID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to fetch attachment versions")
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+func (s *TodoService) attachmentTodoID(ctx echo.Context, attachmentID uuid.UUID) (uuid.UUID, error) {
+	logger := middleware.GetLogger(ctx)
+
+	todoID, err := s.todoRepo.GetAttachmentTodoID(ctx.Request().Context(), attachmentID)
+	if err != nil {
+		logger.Error().Err(err).Msg("attachment validation failed")
+		return uuid.Nil, err
+	}
+
+	return todoID, nil
+}
+
+// attachmentAt returns the attachment as it was at the given version, or as it is
+// when version is nil.
+func (s *TodoService) attachmentAt(ctx context.Context, attachment *todo.Attachment, version *int,
+) (*todo.Attachment, error) {
+	if version == nil || *version == attachment.Version {
+		return attachment, nil
+	}
+
+	attachmentVersion, err := s.todoRepo.GetAttachmentVersion(ctx, attachment.ID, *version)
+	if err != nil {
+		return nil, err
+	}
+
+	return attachment.AtVersion(attachmentVersion), nil
+}
@@ -1,12 +1,17 @@
 package service
 
 import (
+	"context"
+
+	"github.com/ApoorvYdv/go-tasker/internal/errs"
 	"github.com/ApoorvYdv/go-tasker/internal/middleware"
 	"github.com/ApoorvYdv/go-tasker/internal/model"
 	"github.com/ApoorvYdv/go-tasker/internal/model/category"
+	"github.com/ApoorvYdv/go-tasker/internal/model/share"
 	"github.com/ApoorvYdv/go-tasker/internal/repository"
 	"github.com/ApoorvYdv/go-tasker/internal/server"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/labstack/echo/v4"
 )
 
@@ -27,6 +32,14 @@ func (s *CategoryService) CreateCategory(ctx echo.Context, userID string,
 ) (*category.Category, error) {
 	logger := middleware.GetLogger(ctx)
 
+	// Validate parent category exists and user can edit it (if provided)
+	if payload.ParentID != nil {
+		if err := s.checkParent(ctx.Request().Context(), userID, *payload.ParentID); err != nil {
+			logger.Error().Err(err).Msg("parent category validation failed")
+			return nil, err
+		}
+	}
+
 	categoryItem, err := s.categoryRepo.CreateCategory(ctx.Request().Context(), userID, payload)
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to create category")
@@ -47,7 +60,7 @@ func (s *CategoryService) CreateCategory(ctx echo.Context, userID string,
 
 func (s *CategoryService) GetCategories(ctx echo.Context, userID string,
 	query *category.GetCategoriesQuery,
-) (*model.PaginatedResponse[category.Category], error) {
+) (*model.PaginatedResponse[category.PopulatedCategory], error) {
 	logger := middleware.GetLogger(ctx)
 
 	categories, err := s.categoryRepo.GetCategories(ctx.Request().Context(), userID, query)
@@ -76,9 +89,48 @@ func (s *CategoryService) UpdateCategory(ctx echo.Context, userID string, catego
 ) (*category.Category, error) {
 	logger := middleware.GetLogger(ctx)
 
-	categoryItem, err := s.categoryRepo.UpdateCategory(ctx.Request().Context(), userID, categoryID, payload)
+	// A move is serializable, so two moves that would only make a cycle together
+	// can't both commit; the one that loses is run again and rejected
+	opts := pgx.TxOptions{}
+	if payload.ParentID != nil {
+		opts.IsoLevel = pgx.Serializable
+	}
+
+	// The unit of work may run more than once, so failures are logged once it is done
+	var (
+		categoryItem *category.Category
+		failure      string
+	)
+	err := s.server.DB.WithTxOptions(ctx.Request().Context(), opts, func(txCtx context.Context) error {
+		// Validate new parent category exists, user can edit it and it isn't below the category (if provided)
+		if payload.ParentID != nil {
+			if err := s.checkParent(txCtx, userID, *payload.ParentID); err != nil {
+				failure = "parent category validation failed"
+				return err
+			}
+
+			cycle, err := s.categoryRepo.IsInCategorySubtree(txCtx, categoryID, *payload.ParentID)
+			if err != nil {
+				failure = "failed to check category hierarchy"
+				return err
+			}
+			if cycle {
+				failure = "category cannot be moved under itself"
+				return errs.NewBadRequestError("A category cannot be moved under itself or one of its subcategories", false, nil, nil, nil)
+			}
+		}
+
+		var err error
+		categoryItem, err = s.categoryRepo.UpdateCategory(txCtx, userID, categoryID, payload)
+		if err != nil {
+			failure = "failed to update category"
+			return err
+		}
+
+		return nil
+	})
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to update category")
+		logger.Error().Err(err).Msg(failure)
 		return nil, err
 	}
 
@@ -96,9 +148,28 @@ func (s *CategoryService) UpdateCategory(ctx echo.Context, userID string, catego
 func (s *CategoryService) DeleteCategory(ctx echo.Context, userID string, categoryID uuid.UUID) error {
 	logger := middleware.GetLogger(ctx)
 
-	err := s.categoryRepo.DeleteCategory(ctx.Request().Context(), userID, categoryID)
+	// Deleting moves subcategories up to the category's parent, so like a move it is
+	// serializable: a subcategory moved under the category at the same time isn't
+	// left at the root
+	opts := pgx.TxOptions{IsoLevel: pgx.Serializable}
+
+	var failure string
+	err := s.server.DB.WithTxOptions(ctx.Request().Context(), opts, func(txCtx context.Context) error {
+		// Validate category exists and user owns it
+		if _, err := s.categoryRepo.CheckCategoryAccess(txCtx, userID, categoryID, share.RoleOwner); err != nil {
+			failure = "category validation failed"
+			return err
+		}
+
+		if err := s.categoryRepo.DeleteCategory(txCtx, userID, categoryID); err != nil {
+			failure = "failed to delete category"
+			return err
+		}
+
+		return nil
+	})
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to delete category")
+		logger.Error().Err(err).Msg(failure)
 		return err
 	}
 
@@ -111,3 +182,50 @@ func (s *CategoryService) DeleteCategory(ctx echo.Context, userID string, catego
 
 	return nil
 }
+
+// ArchiveCategory hides a category and its subcategories from listings without
+// touching their todos.
+func (s *CategoryService) ArchiveCategory(ctx echo.Context, userID string, categoryID uuid.UUID) (*category.Category, error) {
+	return s.setArchived(ctx, userID, categoryID, true)
+}
+
+func (s *CategoryService) UnarchiveCategory(ctx echo.Context, userID string, categoryID uuid.UUID) (*category.Category, error) {
+	return s.setArchived(ctx, userID, categoryID, false)
+}
+
+func (s *CategoryService) setArchived(ctx echo.Context, userID string, categoryID uuid.UUID,
+	archived bool,
+) (*category.Category, error) {
+	logger := middleware.GetLogger(ctx)
+
+	categoryItem, err := s.categoryRepo.SetCategoryArchived(ctx.Request().Context(), userID, categoryID, archived)
+	if err != nil {
+		logger.Error().Err(err).Bool("archived", archived).Msg("failed to archive category")
+		return nil, err
+	}
+
+	// Business event log
+	eventLogger := middleware.GetLogger(ctx)
+	eventLogger.Info().
+		Str("event", "category_archived").
+		Str("category_id", categoryItem.ID.String()).
+		Bool("archived", archived).
+		Msg("Category archive state changed")
+
+	return categoryItem, nil
+}
+
+// checkParent validates that a category can be placed under the parent: the user
+// can edit it and it isn't archived.
+func (s *CategoryService) checkParent(ctx context.Context, userID string, parentID uuid.UUID) error {
+	parent, err := s.categoryRepo.CheckCategoryAccess(ctx, userID, parentID, share.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	if parent.IsArchived() {
+		return errs.NewBadRequestError("Subcategories cannot be added to an archived category", false, nil, nil, nil)
+	}
+
+	return nil
+}
@@ -1,8 +1,12 @@
 package service
 
 import (
+	"context"
+
+	"github.com/ApoorvYdv/go-tasker/internal/errs"
 	"github.com/ApoorvYdv/go-tasker/internal/middleware"
 	"github.com/ApoorvYdv/go-tasker/internal/model/comment"
+	"github.com/ApoorvYdv/go-tasker/internal/model/share"
 	"github.com/ApoorvYdv/go-tasker/internal/repository"
 	"github.com/ApoorvYdv/go-tasker/internal/server"
 	"github.com/google/uuid"
@@ -28,16 +32,48 @@ func (s *CommentService) AddComment(ctx echo.Context, userID string, todoID uuid
 ) (*comment.Comment, error) {
 	logger := middleware.GetLogger(ctx)
 
-	// Validate todo exists and belongs to user
-	_, err := s.todoRepo.CheckTodoExists(ctx.Request().Context(), userID, todoID)
-	if err != nil {
-		logger.Error().Err(err).Msg("todo validation failed")
-		return nil, err
-	}
-
-	commentItem, err := s.commentRepo.AddComment(ctx.Request().Context(), userID, todoID, payload)
+	// Resolving mentions looks users up in the user directory, so it happens before
+	// the unit of work, which may run more than once
+	mentions := resolveMentions(ctx, s.todoRepo, todoID, payload.Content)
+
+	// Check access and the parent, and add the comment with its mentions and the
+	// event its notifications follow from, in one unit of work
+	var (
+		commentItem *comment.Comment
+		failure     string
+	)
+	err := s.server.DB.WithTx(ctx.Request().Context(), func(txCtx context.Context) error {
+		// Validate todo exists and user may comment on it
+		_, err := s.todoRepo.CheckTodoAccess(txCtx, userID, todoID, share.RoleCommenter)
+		if err != nil {
+			failure = "todo validation failed"
+			return err
+		}
+
+		var parent *comment.Comment
+		if payload.ParentCommentID != nil {
+			parent, err = s.commentRepo.GetComment(txCtx, userID, *payload.ParentCommentID)
+			if err != nil {
+				failure = "parent comment validation failed"
+				return err
+			}
+
+			if parent.TodoID != todoID {
+				failure = "parent comment belongs to a different todo"
+				return errs.NewBadRequestError("Parent comment belongs to a different todo", false, nil, nil, nil)
+			}
+		}
+
+		commentItem, err = s.commentRepo.AddComment(txCtx, userID, todoID, payload, parent, mentions)
+		if err != nil {
+			failure = "failed to add comment"
+			return err
+		}
+
+		return nil
+	})
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to add comment")
+		logger.Error().Err(err).Msg(failure)
 		return nil, err
 	}
 
@@ -55,8 +91,8 @@ func (s *CommentService) AddComment(ctx echo.Context, userID string, todoID uuid
 func (s *CommentService) GetCommentsByTodoID(ctx echo.Context, userID string, todoID uuid.UUID) ([]comment.Comment, error) {
 	logger := middleware.GetLogger(ctx)
 
-	// Validate todo exists and belongs to user
-	_, err := s.todoRepo.CheckTodoExists(ctx.Request().Context(), userID, todoID)
+	// Validate todo exists and user can view it
+	_, err := s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, todoID, share.RoleViewer)
 	if err != nil {
 		logger.Error().Err(err).Msg("todo validation failed")
 		return nil, err
@@ -75,13 +111,27 @@ func (s *CommentService) UpdateComment(ctx echo.Context, userID string, commentI
 	logger := middleware.GetLogger(ctx)
 
 	// Validate comment exists and belongs to user
-	_, err := s.commentRepo.GetCommentByID(ctx.Request().Context(), userID, commentID)
+	existing, err := s.commentRepo.GetCommentByID(ctx.Request().Context(), userID, commentID)
 	if err != nil {
 		logger.Error().Err(err).Msg("comment validation failed")
 		return nil, err
 	}
 
-	commentItem, err := s.commentRepo.UpdateComment(ctx.Request().Context(), userID, commentID, content)
+	// Editing needs the same role as commenting, so authors lose it when downgraded
+	_, err = s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, existing.TodoID, share.RoleCommenter)
+	if err != nil {
+		logger.Error().Err(err).Msg("todo validation failed")
+		return nil, err
+	}
+
+	// Saving unchanged content isn't an edit and leaves no revision
+	if existing.Content == content {
+		return existing, nil
+	}
+
+	mentions := resolveMentions(ctx, s.todoRepo, existing.TodoID, content)
+
+	commentItem, err := s.commentRepo.UpdateComment(ctx.Request().Context(), userID, commentID, content, mentions)
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to update comment")
 		return nil, err
@@ -97,17 +147,49 @@ func (s *CommentService) UpdateComment(ctx echo.Context, userID string, commentI
 	return commentItem, nil
 }
 
+func (s *CommentService) GetCommentRevisions(ctx echo.Context, userID string, commentID uuid.UUID) ([]comment.Revision, error) {
+	logger := middleware.GetLogger(ctx)
+
+	commentItem, err := s.commentRepo.GetComment(ctx.Request().Context(), userID, commentID)
+	if err != nil {
+		logger.Error().Err(err).Msg("comment validation failed")
+		return nil, err
+	}
+
+	// Validate user can view the todo the comment belongs to
+	_, err = s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, commentItem.TodoID, share.RoleViewer)
+	if err != nil {
+		logger.Error().Err(err).Msg("todo validation failed")
+		return nil, err
+	}
+
+	revisions, err := s.commentRepo.GetCommentRevisions(ctx.Request().Context(), commentItem.ID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to fetch comment revisions")
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
 func (s *CommentService) DeleteComment(ctx echo.Context, userID string, commentID uuid.UUID) error {
 	logger := middleware.GetLogger(ctx)
 
 	// Validate comment exists and belongs to user
-	_, err := s.commentRepo.GetCommentByID(ctx.Request().Context(), userID, commentID)
+	existing, err := s.commentRepo.GetCommentByID(ctx.Request().Context(), userID, commentID)
 	if err != nil {
 		logger.Error().Err(err).Msg("comment validation failed")
 		return err
 	}
 
-	err = s.commentRepo.DeleteComment(ctx.Request().Context(), userID, commentID)
+	// Deleting needs the same role as commenting, so authors lose it when downgraded
+	_, err = s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, existing.TodoID, share.RoleCommenter)
+	if err != nil {
+		logger.Error().Err(err).Msg("todo validation failed")
+		return err
+	}
+
+	tombstoned, err := s.commentRepo.DeleteComment(ctx.Request().Context(), userID, commentID)
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to delete comment")
 		return err
@@ -118,7 +200,45 @@ func (s *CommentService) DeleteComment(ctx echo.Context, userID string, commentI
 	eventLogger.Info().
 		Str("event", "comment_deleted").
 		Str("comment_id", commentID.String()).
+		Bool("tombstoned", tombstoned).
 		Msg("Comment deleted successfully")
 
 	return nil
 }
+
+// ToggleReaction adds or removes the user's emoji reaction on a comment.
+func (s *CommentService) ToggleReaction(ctx echo.Context, userID string,
+	payload *comment.ToggleReactionPayload,
+) ([]comment.Reaction, error) {
+	logger := middleware.GetLogger(ctx)
+
+	commentItem, err := s.commentRepo.GetComment(ctx.Request().Context(), userID, payload.ID)
+	if err != nil {
+		logger.Error().Err(err).Msg("comment validation failed")
+		return nil, err
+	}
+
+	// Reacting is a form of commenting
+	_, err = s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, commentItem.TodoID, share.RoleCommenter)
+	if err != nil {
+		logger.Error().Err(err).Msg("todo validation failed")
+		return nil, err
+	}
+
+	reactions, added, err := s.commentRepo.ToggleReaction(ctx.Request().Context(), userID, commentItem.ID, payload.Emoji)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to toggle reaction")
+		return nil, err
+	}
+
+	// Business event log
+	eventLogger := middleware.GetLogger(ctx)
+	eventLogger.Info().
+		Str("event", "comment_reaction_toggled").
+		Str("comment_id", commentItem.ID.String()).
+		Str("emoji", payload.Emoji).
+		Bool("added", added).
+		Msg("Comment reaction toggled successfully")
+
+	return reactions, nil
+}
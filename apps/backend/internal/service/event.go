@@ -0,0 +1,403 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/lib/job"
+	"github.com/ApoorvYdv/go-tasker/internal/model/event"
+	"github.com/ApoorvYdv/go-tasker/internal/repository"
+	"github.com/ApoorvYdv/go-tasker/internal/server"
+	"github.com/hibiken/asynq"
+)
+
+const (
+	// eventBatchSize is how many events the relay claims at a time
+	eventBatchSize = 100
+	// eventClaimLease is how long a claimed event is held for the relay that claimed
+	// it; events not marked by then are claimed again
+	eventClaimLease = time.Minute
+	eventMaxBackoff = time.Hour
+	// eventPartitions is how many partitions of claimed events are published at the
+	// same time
+	eventPartitions = 8
+
+	// pruneEventsInterval must match pruneEventsSchedule
+	pruneEventsInterval = 24 * time.Hour
+	pruneEventsSchedule = "30 4 * * *"
+)
+
+// EventHandler reacts to a domain event. An event may be handled more than once
+// when its delivery is retried; e.ID is the same on every delivery, for handlers
+// whose effects must not repeat.
+type EventHandler func(ctx context.Context, e *event.Event) error
+
+type eventSubscriber struct {
+	name string
+	// types the subscriber wants; empty for every type
+	types   map[event.Type]bool
+	handler EventHandler
+	// async subscribers are delivered to by a background task
+	async bool
+}
+
+func (sub *eventSubscriber) wants(eventType event.Type) bool {
+	return len(sub.types) == 0 || sub.types[eventType]
+}
+
+// taskEnqueuer enqueues background tasks; *asynq.Client is one.
+type taskEnqueuer interface {
+	Enqueue(task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error)
+}
+
+// EventService relays domain events from the outbox to subscribers. Events are
+// published at least once, and each aggregate's events in the order they were
+// recorded: an event is only published once the one before it reached every
+// subscriber. Claimed events are split into partitions by aggregate and the
+// partitions are published concurrently, so a slow subscriber only holds up the
+// aggregates that share a partition. Every instance runs a relay; claims keep them
+// from publishing the same event at the same time. Asynchronous subscribers are
+// handed each event through one asynq task, identified by the event ID.
+type EventService struct {
+	server    *server.Server
+	eventRepo *repository.EventRepository
+	tasks     taskEnqueuer
+
+	mu          sync.RWMutex
+	subscribers []*eventSubscriber
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewEventService(server *server.Server, eventRepo *repository.EventRepository) *EventService {
+	s := &EventService{
+		server:    server,
+		eventRepo: eventRepo,
+		tasks:     server.Job.Client,
+	}
+
+	server.Job.Handle(job.TaskDeliverEvent, s.handleDeliverEventTask)
+	server.Job.Handle(job.TaskPruneEvents, s.handlePruneEventsTask)
+	if err := server.Job.Schedule(pruneEventsSchedule, job.NewPruneEventsTask(pruneEventsInterval)); err != nil {
+		server.Logger.Error().Err(err).Msg("failed to schedule event pruning")
+	}
+
+	return s
+}
+
+// Subscribe registers a handler the relay calls for events of the given types, or
+// of every type when none are given. The relay waits for it, so it should be quick;
+// an error has the event published again later. The name identifies the subscriber
+// in delivery records and must stay the same across releases.
+func (s *EventService) Subscribe(name string, handler EventHandler, types ...event.Type) {
+	s.subscribe(name, handler, false, types)
+}
+
+// SubscribeAsync registers a handler run in a background task for events of the
+// given types, or of every type when none are given. The relay only waits for the
+// task to be enqueued, so a slow or failing handler doesn't hold up other events,
+// but an aggregate's events may then be handled out of order.
+func (s *EventService) SubscribeAsync(name string, handler EventHandler, types ...event.Type) {
+	s.subscribe(name, handler, true, types)
+}
+
+func (s *EventService) subscribe(name string, handler EventHandler, async bool, types []event.Type) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sub := range s.subscribers {
+		if sub.name == name {
+			panic(fmt.Sprintf("event subscriber %q registered twice", name))
+		}
+	}
+
+	sub := &eventSubscriber{
+		name:    name,
+		types:   make(map[event.Type]bool, len(types)),
+		handler: handler,
+		async:   async,
+	}
+	for _, eventType := range types {
+		sub.types[eventType] = true
+	}
+
+	s.subscribers = append(s.subscribers, sub)
+}
+
+// Start runs the relay until Stop. Register subscribers before starting it.
+func (s *EventService) Start() {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go s.run()
+
+	s.server.Logger.Info().Msg("event relay started")
+}
+
+// Stop waits for the relay to finish the event it is publishing. Events it claimed
+// but didn't get to are picked up again once their claim lapses.
+func (s *EventService) Stop() {
+	if s.stop == nil {
+		return
+	}
+
+	close(s.stop)
+	<-s.done
+
+	s.server.Logger.Info().Msg("event relay stopped")
+}
+
+func (s *EventService) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(time.Duration(s.server.Config.Events.PollInterval) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		s.relay(context.Background())
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relay publishes events until the outbox has none ready.
+func (s *EventService) relay(ctx context.Context) {
+	for {
+		events, err := s.eventRepo.ClaimEvents(ctx, eventBatchSize, eventClaimLease)
+		if err != nil {
+			s.server.Logger.Error().Err(err).Msg("failed to claim events")
+			return
+		}
+
+		// An aggregate always lands in the same partition, and each partition is
+		// published in order, so its events never overtake each other
+		partitions := make([][]*event.Event, eventPartitions)
+		for i := range events {
+			p := eventPartition(&events[i])
+			partitions[p] = append(partitions[p], &events[i])
+		}
+
+		var wg sync.WaitGroup
+		for _, partition := range partitions {
+			if len(partition) == 0 {
+				continue
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for _, e := range partition {
+					select {
+					case <-s.stop:
+						return
+					default:
+					}
+
+					s.publishEvent(ctx, e)
+				}
+			}()
+		}
+		wg.Wait()
+
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+
+		if len(events) < eventBatchSize {
+			return
+		}
+	}
+}
+
+// eventPartition assigns an event to a partition by its aggregate.
+func eventPartition(e *event.Event) int {
+	h := fnv.New32a()
+	h.Write([]byte(e.AggregateType))
+	h.Write(e.AggregateID[:])
+	return int(h.Sum32() % eventPartitions)
+}
+
+// publishEvent publishes a claimed event and records the outcome: published, held
+// back for a retry with backoff, or failed once it is out of attempts.
+func (s *EventService) publishEvent(ctx context.Context, e *event.Event) {
+	logger := s.server.Logger
+
+	err := s.publish(ctx, e)
+	if err == nil {
+		if err := s.eventRepo.MarkEventPublished(ctx, e.ID); err != nil {
+			logger.Error().Err(err).Str("event_id", e.ID.String()).Msg("failed to mark event published")
+		}
+		return
+	}
+
+	if e.Attempts >= s.server.Config.Events.MaxAttempts {
+		logger.Error().
+			Err(err).
+			Str("event_id", e.ID.String()).
+			Str("event_type", string(e.Type)).
+			Int("attempts", e.Attempts).
+			Msg("giving up on publishing event")
+
+		if err := s.eventRepo.FailEvent(ctx, e.ID, err.Error()); err != nil {
+			logger.Error().Err(err).Str("event_id", e.ID.String()).Msg("failed to mark event failed")
+		}
+		return
+	}
+
+	logger.Warn().
+		Err(err).
+		Str("event_id", e.ID.String()).
+		Str("event_type", string(e.Type)).
+		Int("attempts", e.Attempts).
+		Msg("failed to publish event, will retry")
+
+	if err := s.eventRepo.RetryEvent(ctx, e.ID, err.Error(), time.Now().Add(eventBackoff(e.Attempts))); err != nil {
+		logger.Error().Err(err).Str("event_id", e.ID.String()).Msg("failed to schedule event retry")
+	}
+}
+
+// eventBackoff doubles from a second after the first attempt up to an hour.
+func eventBackoff(attempts int) time.Duration {
+	return min(time.Second<<min(max(attempts-1, 0), 12), eventMaxBackoff)
+}
+
+// publish hands the event to every subscriber that wants it, and enqueues its task
+// when an async subscriber wants it. Subscribers that already have the event are
+// skipped, so a retry only reaches the ones that failed.
+func (s *EventService) publish(ctx context.Context, e *event.Event) error {
+	var (
+		errs  []error
+		async bool
+	)
+	for _, sub := range s.subscribersFor(e.Type) {
+		if sub.async {
+			async = true
+			continue
+		}
+
+		if err := s.deliver(ctx, sub, e); err != nil {
+			errs = append(errs, fmt.Errorf("subscriber %s: %w", sub.name, err))
+		}
+	}
+
+	if async {
+		if err := s.enqueueDelivery(e); err != nil {
+			errs = append(errs, fmt.Errorf("failed to enqueue event delivery: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *EventService) subscribersFor(eventType event.Type) []*eventSubscriber {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var subscribers []*eventSubscriber
+	for _, sub := range s.subscribers {
+		if sub.wants(eventType) {
+			subscribers = append(subscribers, sub)
+		}
+	}
+	return subscribers
+}
+
+// enqueueDelivery enqueues the event's task for the async subscribers. The task ID
+// is the event ID, so an event published again, for instance after its claim
+// lapsed, doesn't get a second task.
+func (s *EventService) enqueueDelivery(e *event.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	_, err = s.tasks.Enqueue(job.NewDeliverEventTask(data), asynq.TaskID(e.ID.String()))
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		return nil
+	}
+	return err
+}
+
+// deliver calls the subscriber's handler unless it already handled the event, and
+// records the delivery once it succeeds.
+func (s *EventService) deliver(ctx context.Context, sub *eventSubscriber, e *event.Event) (err error) {
+	delivered, err := s.eventRepo.IsEventDelivered(ctx, sub.name, e.ID)
+	if err != nil {
+		return err
+	}
+	if delivered {
+		return nil
+	}
+
+	// A panicking handler fails its delivery rather than the relay
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+
+	if err := sub.handler(ctx, e); err != nil {
+		return err
+	}
+
+	return s.eventRepo.RecordEventDelivery(ctx, sub.name, e.ID)
+}
+
+// handleDeliverEventTask delivers an event to the async subscribers that want it.
+// A retried task only reaches the subscribers that haven't handled the event yet.
+func (s *EventService) handleDeliverEventTask(ctx context.Context, t *asynq.Task) error {
+	var e event.Event
+	if err := json.Unmarshal(t.Payload(), &e); err != nil {
+		return fmt.Errorf("failed to unmarshal event: %w: %w", err, asynq.SkipRetry)
+	}
+
+	var errs []error
+	for _, sub := range s.subscribersFor(e.Type) {
+		if !sub.async {
+			continue
+		}
+
+		if err := s.deliver(ctx, sub, &e); err != nil {
+			s.server.Logger.Error().
+				Err(err).
+				Str("subscriber", sub.name).
+				Str("event_id", e.ID.String()).
+				Str("event_type", string(e.Type)).
+				Msg("failed to deliver event")
+			errs = append(errs, fmt.Errorf("subscriber %s: %w", sub.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// handlePruneEventsTask deletes events published longer ago than the retention.
+// Failed events are kept until they are dealt with by hand.
+func (s *EventService) handlePruneEventsTask(ctx context.Context, _ *asynq.Task) error {
+	retention := time.Duration(s.server.Config.Events.Retention) * 24 * time.Hour
+
+	pruned, err := s.eventRepo.PruneEvents(ctx, time.Now().Add(-retention))
+	if err != nil {
+		s.server.Logger.Error().Err(err).Msg("failed to prune events")
+		return err
+	}
+
+	if pruned > 0 {
+		s.server.Logger.Info().Int64("count", pruned).Msg("pruned published events")
+	}
+
+	return nil
+}
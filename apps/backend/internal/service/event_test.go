@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/lib/job"
+	"github.com/ApoorvYdv/go-tasker/internal/model/event"
+	"github.com/ApoorvYdv/go-tasker/internal/model/todo"
+	"github.com/ApoorvYdv/go-tasker/internal/repository"
+	tasktest "github.com/ApoorvYdv/go-tasker/internal/testing"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventRecorder is a subscriber that keeps the events it handled, and fails events
+// of the types it is told to fail.
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []event.Event
+	fail   map[event.Type]bool
+}
+
+func (r *eventRecorder) handle(_ context.Context, e *event.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.fail[e.Type] {
+		return errors.New("subscriber failed")
+	}
+	r.events = append(r.events, *e)
+	return nil
+}
+
+func (r *eventRecorder) setFail(eventType event.Type, fail bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.fail == nil {
+		r.fail = map[event.Type]bool{}
+	}
+	r.fail[eventType] = fail
+}
+
+func (r *eventRecorder) handled() []event.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]event.Event(nil), r.events...)
+}
+
+// taskRecorder enqueues tasks in memory, refusing a task ID it already has like
+// asynq does.
+type taskRecorder struct {
+	mu    sync.Mutex
+	tasks []*asynq.Task
+	ids   []string
+}
+
+func (r *taskRecorder) Enqueue(task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var id string
+	for _, opt := range opts {
+		if opt.Type() == asynq.TaskIDOpt {
+			id = opt.Value().(string)
+		}
+	}
+	for _, existing := range r.ids {
+		if id != "" && existing == id {
+			return nil, asynq.ErrTaskIDConflict
+		}
+	}
+
+	r.tasks = append(r.tasks, task)
+	r.ids = append(r.ids, id)
+	return &asynq.TaskInfo{ID: id, Type: task.Type(), Payload: task.Payload()}, nil
+}
+
+func TestEventService_Relay(t *testing.T) {
+	testDB, srv, cleanup := tasktest.SetupTest(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID := "user_" + uuid.NewString()
+	srv.Config.Events.MaxAttempts = 5
+
+	todoRepo := repository.NewTodoRepository(srv)
+	eventRepo := repository.NewEventRepository(srv)
+
+	newService := func() *EventService {
+		return &EventService{
+			server:    srv,
+			eventRepo: eventRepo,
+			tasks:     &taskRecorder{},
+		}
+	}
+
+	createTodo := func(t *testing.T, updates int) *todo.Todo {
+		t.Helper()
+
+		todoItem, err := todoRepo.CreateTodo(ctx, userID, &todo.CreateTodoPayload{Title: "Relayed"})
+		require.NoError(t, err)
+
+		for range updates {
+			status := todo.StatusActive
+			_, err := todoRepo.UpdateTodo(ctx, userID, &todo.UpdateTodoPayload{ID: todoItem.ID, Status: &status})
+			require.NoError(t, err)
+		}
+
+		return todoItem
+	}
+
+	type outboxState struct {
+		published bool
+		failed    bool
+		attempts  int
+	}
+
+	state := func(t *testing.T, eventID uuid.UUID) outboxState {
+		t.Helper()
+
+		var s outboxState
+		err := testDB.Pool.QueryRow(ctx, `
+			SELECT
+				published_at IS NOT NULL,
+				failed_at IS NOT NULL,
+				attempts
+			FROM
+				event_outbox
+			WHERE
+				id=$1
+		`, eventID).Scan(&s.published, &s.failed, &s.attempts)
+		require.NoError(t, err)
+		return s
+	}
+
+	// makeAvailable lets an event held back for a retry be claimed right away
+	makeAvailable := func(t *testing.T, eventID uuid.UUID) {
+		t.Helper()
+		require.NoError(t, eventRepo.RetryEvent(ctx, eventID, "subscriber failed", time.Now().Add(-time.Minute)))
+	}
+
+	t.Run("publishes every aggregate's events in order", func(t *testing.T) {
+		s := newService()
+		recorder := &eventRecorder{}
+		s.Subscribe("recorder", recorder.handle)
+
+		// More aggregates than partitions, so partitions hold several each
+		todos := make([]*todo.Todo, 0, 3*eventPartitions)
+		for range 3 * eventPartitions {
+			todos = append(todos, createTodo(t, 2))
+		}
+
+		for range 3 {
+			s.relay(ctx)
+		}
+
+		handled := recorder.handled()
+		require.Len(t, handled, 3*len(todos))
+
+		sequences := map[uuid.UUID][]int64{}
+		for _, e := range handled {
+			sequences[e.AggregateID] = append(sequences[e.AggregateID], e.Sequence)
+			assert.True(t, state(t, e.ID).published)
+		}
+		for _, item := range todos {
+			assert.Equal(t, []int64{1, 2, 3}, sequences[item.ID])
+		}
+	})
+
+	t.Run("subscriber types filter events", func(t *testing.T) {
+		s := newService()
+		recorder := &eventRecorder{}
+		s.Subscribe("deletions", recorder.handle, event.TypeTodoDeleted)
+
+		item := createTodo(t, 1)
+		_, err := todoRepo.DeleteTodo(ctx, userID, item.ID)
+		require.NoError(t, err)
+
+		for range 3 {
+			s.relay(ctx)
+		}
+
+		handled := recorder.handled()
+		require.Len(t, handled, 1)
+		assert.Equal(t, event.TypeTodoDeleted, handled[0].Type)
+
+		deleted, err := event.Decode[event.TodoDeleted](&handled[0])
+		require.NoError(t, err)
+		assert.Equal(t, item.ID, deleted.TodoID)
+		assert.Equal(t, []string{userID}, deleted.MemberIDs)
+	})
+
+	t.Run("failed delivery is retried for that subscriber only", func(t *testing.T) {
+		srv.Config.Events.MaxAttempts = 5
+
+		s := newService()
+		ok := &eventRecorder{}
+		failing := &eventRecorder{}
+		failing.setFail(event.TypeTodoCreated, true)
+		s.Subscribe("ok", ok.handle)
+		s.Subscribe("failing", failing.handle)
+
+		item := createTodo(t, 1)
+		s.relay(ctx)
+
+		// The update waits for the creation to reach every subscriber
+		require.Len(t, ok.handled(), 1)
+		assert.Empty(t, failing.handled())
+		created := ok.handled()[0]
+		assert.Equal(t, event.TypeTodoCreated, created.Type)
+		assert.False(t, state(t, created.ID).published)
+
+		failing.setFail(event.TypeTodoCreated, false)
+		makeAvailable(t, created.ID)
+		for range 2 {
+			s.relay(ctx)
+		}
+
+		published := state(t, created.ID)
+		assert.True(t, published.published)
+		assert.Equal(t, 2, published.attempts)
+
+		failingHandled := failing.handled()
+		require.Len(t, failingHandled, 2)
+		assert.Equal(t, created.ID, failingHandled[0].ID)
+		assert.Equal(t, item.ID, failingHandled[1].AggregateID)
+		assert.Equal(t, event.TypeTodoUpdated, failingHandled[1].Type)
+
+		// The subscriber that had the creation didn't get it again
+		okHandled := ok.handled()
+		require.Len(t, okHandled, 2)
+		assert.Equal(t, created.ID, okHandled[0].ID)
+		assert.Equal(t, event.TypeTodoUpdated, okHandled[1].Type)
+	})
+
+	t.Run("event out of attempts is set aside", func(t *testing.T) {
+		srv.Config.Events.MaxAttempts = 2
+
+		s := newService()
+		recorder := &eventRecorder{}
+		recorder.setFail(event.TypeTodoCreated, true)
+		s.Subscribe("recorder", recorder.handle)
+
+		item := createTodo(t, 1)
+		s.relay(ctx)
+
+		var createdID uuid.UUID
+		err := testDB.Pool.QueryRow(ctx, `
+			SELECT
+				id
+			FROM
+				event_outbox
+			WHERE
+				aggregate_id=$1
+				AND sequence=1
+		`, item.ID).Scan(&createdID)
+		require.NoError(t, err)
+
+		retried := state(t, createdID)
+		assert.False(t, retried.failed)
+		assert.Equal(t, 1, retried.attempts)
+
+		// The second attempt is the last
+		makeAvailable(t, createdID)
+		s.relay(ctx)
+
+		failed := state(t, createdID)
+		assert.True(t, failed.failed)
+		assert.False(t, failed.published)
+		assert.Equal(t, 2, failed.attempts)
+
+		// The aggregate's next event goes through
+		s.relay(ctx)
+		handled := recorder.handled()
+		require.Len(t, handled, 1)
+		assert.Equal(t, event.TypeTodoUpdated, handled[0].Type)
+	})
+
+	t.Run("async subscribers get one task per event", func(t *testing.T) {
+		srv.Config.Events.MaxAttempts = 5
+
+		tasks := &taskRecorder{}
+		s := newService()
+		s.tasks = tasks
+		syncRecorder := &eventRecorder{}
+		asyncRecorder := &eventRecorder{}
+		s.Subscribe("sync", syncRecorder.handle)
+		s.SubscribeAsync("async", asyncRecorder.handle, event.TypeTodoCreated)
+
+		item := createTodo(t, 1)
+		for range 2 {
+			s.relay(ctx)
+		}
+
+		// The update isn't wanted by the async subscriber, so it gets no task
+		require.Len(t, syncRecorder.handled(), 2)
+		require.Len(t, tasks.tasks, 1)
+		task := tasks.tasks[0]
+		assert.Equal(t, job.TaskDeliverEvent, task.Type())
+
+		var relayed event.Event
+		require.NoError(t, json.Unmarshal(task.Payload(), &relayed))
+		assert.Equal(t, item.ID, relayed.AggregateID)
+		assert.Equal(t, event.TypeTodoCreated, relayed.Type)
+		assert.Equal(t, relayed.ID.String(), tasks.ids[0])
+		assert.True(t, state(t, relayed.ID).published)
+
+		// The relay waits for the task to be enqueued, not for it to run
+		assert.Empty(t, asyncRecorder.handled())
+
+		// Publishing the event again, as after a lapsed claim, enqueues no second task
+		require.NoError(t, s.publish(ctx, &relayed))
+		assert.Len(t, tasks.tasks, 1)
+
+		// Running the task again doesn't deliver the event twice
+		for range 2 {
+			require.NoError(t, s.handleDeliverEventTask(ctx, task))
+		}
+		handled := asyncRecorder.handled()
+		require.Len(t, handled, 1)
+		assert.Equal(t, relayed.ID, handled[0].ID)
+	})
+
+	t.Run("failed async delivery fails the task", func(t *testing.T) {
+		tasks := &taskRecorder{}
+		s := newService()
+		s.tasks = tasks
+		recorder := &eventRecorder{}
+		recorder.setFail(event.TypeTodoCreated, true)
+		s.SubscribeAsync("async", recorder.handle, event.TypeTodoCreated)
+
+		createTodo(t, 0)
+		s.relay(ctx)
+		require.Len(t, tasks.tasks, 1)
+
+		require.Error(t, s.handleDeliverEventTask(ctx, tasks.tasks[0]))
+		recorder.setFail(event.TypeTodoCreated, false)
+		require.NoError(t, s.handleDeliverEventTask(ctx, tasks.tasks[0]))
+		assert.Len(t, recorder.handled(), 1)
+	})
+
+	t.Run("panicking subscriber fails its delivery", func(t *testing.T) {
+		srv.Config.Events.MaxAttempts = 5
+
+		s := newService()
+		s.Subscribe("panicking", func(context.Context, *event.Event) error {
+			panic("boom")
+		})
+
+		item := createTodo(t, 0)
+		assert.NotPanics(t, func() { s.relay(ctx) })
+
+		var (
+			eventID   uuid.UUID
+			lastError string
+		)
+		err := testDB.Pool.QueryRow(ctx, `
+			SELECT
+				id,
+				last_error
+			FROM
+				event_outbox
+			WHERE
+				aggregate_id=$1
+		`, item.ID).Scan(&eventID, &lastError)
+		require.NoError(t, err)
+		assert.False(t, state(t, eventID).published)
+		assert.Contains(t, lastError, "handler panicked: boom")
+
+		require.NoError(t, eventRepo.FailEvent(ctx, eventID, "test done"))
+	})
+}
+
+func TestEventPartition(t *testing.T) {
+	e := &event.Event{AggregateType: event.AggregateTodo, AggregateID: uuid.New()}
+	partition := eventPartition(e)
+
+	assert.GreaterOrEqual(t, partition, 0)
+	assert.Less(t, partition, eventPartitions)
+	// An aggregate always lands in the same partition
+	for range 10 {
+		later := &event.Event{AggregateType: e.AggregateType, AggregateID: e.AggregateID, Sequence: 2}
+		assert.Equal(t, partition, eventPartition(later))
+	}
+}
@@ -0,0 +1,342 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ApoorvYdv/go-tasker/internal/errs"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/email"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/inboundmail"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/workspace"
+	"github.com/ApoorvYdv/go-tasker/internal/middleware"
+	"github.com/ApoorvYdv/go-tasker/internal/model/comment"
+	"github.com/ApoorvYdv/go-tasker/internal/model/inbound"
+	"github.com/ApoorvYdv/go-tasker/internal/model/todo"
+	"github.com/ApoorvYdv/go-tasker/internal/repository"
+	"github.com/ApoorvYdv/go-tasker/internal/server"
+	"github.com/clerk/clerk-sdk-go/v2"
+	"github.com/clerk/clerk-sdk-go/v2/organizationmembership"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	inboundTokenBytes = 16
+
+	// Limits for what an email turns into; the rest is dropped, not rejected
+	maxInboundAttachments    = 10
+	maxInboundAttachmentSize = 10 << 20
+	todoTitleMaxLength       = 255
+	todoDescriptionMaxLength = 1000
+	commentContentMaxLength  = 1000
+)
+
+type InboundService struct {
+	server         *server.Server
+	inboundRepo    *repository.InboundRepository
+	todoService    *TodoService
+	commentService *CommentService
+}
+
+func NewInboundService(server *server.Server, inboundRepo *repository.InboundRepository,
+	todoService *TodoService,
+	commentService *CommentService,
+) *InboundService {
+	return &InboundService{
+		server:         server,
+		inboundRepo:    inboundRepo,
+		todoService:    todoService,
+		commentService: commentService,
+	}
+}
+
+func (s *InboundService) GetAddress(ctx echo.Context, userID string) (*inbound.Address, error) {
+	logger := middleware.GetLogger(ctx)
+
+	if !s.server.Config.Integration.InboundEmailEnabled() {
+		return nil, errs.NewNotFoundError("Inbound email is not enabled", false, nil)
+	}
+
+	token, err := generateInboundToken()
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to generate inbound address token")
+		return nil, err
+	}
+
+	address, err := s.inboundRepo.GetOrCreateAddress(ctx.Request().Context(), userID, token)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to fetch inbound address")
+		return nil, err
+	}
+
+	address.Email = inbound.PersonalAddress(address.Token, s.server.Config.Integration.InboundEmailDomain)
+	return address, nil
+}
+
+// RotateAddress gives the user a new personal address, for when the old one leaked.
+func (s *InboundService) RotateAddress(ctx echo.Context, userID string) (*inbound.Address, error) {
+	logger := middleware.GetLogger(ctx)
+
+	if !s.server.Config.Integration.InboundEmailEnabled() {
+		return nil, errs.NewNotFoundError("Inbound email is not enabled", false, nil)
+	}
+
+	token, err := generateInboundToken()
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to generate inbound address token")
+		return nil, err
+	}
+
+	address, err := s.inboundRepo.RotateAddress(ctx.Request().Context(), userID, token)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to rotate inbound address")
+		return nil, err
+	}
+
+	// Business event log
+	eventLogger := middleware.GetLogger(ctx)
+	eventLogger.Info().
+		Str("event", "inbound_address_rotated").
+		Msg("Inbound address rotated successfully")
+
+	address.Email = inbound.PersonalAddress(address.Token, s.server.Config.Integration.InboundEmailDomain)
+	return address, nil
+}
+
+// ProcessEmail turns an inbound email into a todo when it was sent to a personal
+// address, or into a comment when it replies to a notification email.
+func (s *InboundService) ProcessEmail(ctx echo.Context, msg *inboundmail.Message) (*inbound.Result, error) {
+	logger := middleware.GetLogger(ctx)
+
+	domain := s.server.Config.Integration.InboundEmailDomain
+	for _, recipient := range msg.Recipients {
+		kind, token, ok := inbound.ParseRecipient(recipient, domain)
+		if !ok {
+			continue
+		}
+
+		switch kind {
+		case inbound.RecipientReply:
+			return s.processReply(ctx, token, msg)
+		default:
+			return s.processTodo(ctx, token, msg)
+		}
+	}
+
+	logger.Warn().Strs("recipients", msg.Recipients).Msg("inbound email has no known recipient")
+	return &inbound.Result{Status: inbound.ResultIgnored, Reason: "no known recipient"}, nil
+}
+
+func (s *InboundService) processTodo(ctx echo.Context, token string, msg *inboundmail.Message) (*inbound.Result, error) {
+	logger := middleware.GetLogger(ctx)
+
+	address, err := s.inboundRepo.GetAddressByToken(ctx.Request().Context(), token)
+	if errors.Is(err, pgx.ErrNoRows) {
+		logger.Warn().Msg("inbound email sent to an unknown personal address")
+		return &inbound.Result{Status: inbound.ResultIgnored, Reason: "unknown address"}, nil
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to resolve inbound address")
+		return nil, err
+	}
+
+	payload := &todo.CreateTodoPayload{
+		Title: todoTitle(msg),
+	}
+	if description := truncateRunes(messageText(msg), todoDescriptionMaxLength); description != "" {
+		payload.Description = &description
+	}
+
+	todoItem, err := s.todoService.CreateTodo(ctx, address.UserID, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	attached := 0
+	for _, a := range msg.Attachments {
+		if attached == maxInboundAttachments || len(a.Data) > maxInboundAttachmentSize {
+			logger.Warn().Str("filename", a.Filename).Int("size", len(a.Data)).Msg("skipping inbound email attachment")
+			continue
+		}
+
+		// Attachments are best-effort; the todo already exists
+		if _, err := s.todoService.AttachFile(ctx, address.UserID, todoItem.ID, a.Filename, a.Data); err != nil {
+			continue
+		}
+		attached++
+	}
+
+	// Business event log
+	eventLogger := middleware.GetLogger(ctx)
+	eventLogger.Info().
+		Str("event", "inbound_email_processed").
+		Str("result", string(inbound.ResultTodoCreated)).
+		Str("todo_id", todoItem.ID.String()).
+		Int("attachments", attached).
+		Msg("Inbound email converted to todo")
+
+	return &inbound.Result{Status: inbound.ResultTodoCreated, TodoID: &todoItem.ID}, nil
+}
+
+func (s *InboundService) processReply(ctx echo.Context, token string, msg *inboundmail.Message) (*inbound.Result, error) {
+	logger := middleware.GetLogger(ctx)
+
+	replyToken, err := s.inboundRepo.GetReplyToken(ctx.Request().Context(), token)
+	if errors.Is(err, pgx.ErrNoRows) {
+		logger.Warn().Msg("inbound reply to an unknown reply address")
+		return &inbound.Result{Status: inbound.ResultIgnored, Reason: "unknown reply address"}, nil
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to resolve email reply token")
+		return nil, err
+	}
+
+	// The reply address went to one recipient; a forwarded copy mustn't let others post as them
+	if !s.userOwnsEmail(ctx, replyToken.UserID, msg.From) {
+		logger.Warn().Str("user_id", replyToken.UserID).Msg("inbound reply sender does not match the recipient")
+		return &inbound.Result{Status: inbound.ResultIgnored, Reason: "sender does not match recipient"}, nil
+	}
+
+	content := replyContent(msg)
+	if content == "" {
+		return &inbound.Result{Status: inbound.ResultIgnored, Reason: "empty reply"}, nil
+	}
+
+	if replyToken.OrganizationID != nil {
+		ws, err := organizationWorkspace(ctx, *replyToken.OrganizationID, replyToken.UserID)
+		if err != nil {
+			logger.Warn().Err(err).Msg("inbound reply author is no longer an organization member")
+			return &inbound.Result{Status: inbound.ResultIgnored, Reason: "not an organization member"}, nil
+		}
+		if !ws.HasPermission(workspace.PermissionCommentsWrite) {
+			return &inbound.Result{Status: inbound.ResultIgnored, Reason: "missing permission"}, nil
+		}
+		ctx.SetRequest(ctx.Request().WithContext(workspace.WithContext(ctx.Request().Context(), ws)))
+	}
+
+	payload := &comment.AddCommentPayload{
+		TodoID:          replyToken.TodoID,
+		Content:         content,
+		ParentCommentID: replyToken.CommentID,
+	}
+
+	commentItem, err := s.commentService.AddComment(ctx, replyToken.UserID, replyToken.TodoID, payload)
+	if errors.Is(err, pgx.ErrNoRows) && payload.ParentCommentID != nil {
+		// The comment being replied to was deleted; keep the reply at the top level
+		payload.ParentCommentID = nil
+		commentItem, err = s.commentService.AddComment(ctx, replyToken.UserID, replyToken.TodoID, payload)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Business event log
+	eventLogger := middleware.GetLogger(ctx)
+	eventLogger.Info().
+		Str("event", "inbound_email_processed").
+		Str("result", string(inbound.ResultCommentAdded)).
+		Str("todo_id", replyToken.TodoID.String()).
+		Str("comment_id", commentItem.ID.String()).
+		Msg("Inbound reply converted to comment")
+
+	return &inbound.Result{
+		Status:    inbound.ResultCommentAdded,
+		TodoID:    &replyToken.TodoID,
+		CommentID: &commentItem.ID,
+	}, nil
+}
+
+// userOwnsEmail reports whether address is one of the user's verified Clerk addresses.
+func (s *InboundService) userOwnsEmail(ctx echo.Context, userID, address string) bool {
+	if address == "" {
+		return false
+	}
+
+	emails, err := verifiedEmails(ctx.Request().Context(), userID)
+	if err != nil {
+		middleware.GetLogger(ctx).Warn().Err(err).Msg("failed to fetch user for inbound reply")
+		return false
+	}
+
+	return slices.Contains(emails, strings.ToLower(address))
+}
+
+// organizationWorkspace builds the workspace a session in the organization would have,
+// failing when the user is no longer a member.
+func organizationWorkspace(ctx echo.Context, orgID, userID string) (workspace.Workspace, error) {
+	params := &organizationmembership.ListParams{
+		OrganizationID: orgID,
+		UserIDs:        []string{userID},
+	}
+	params.Limit = clerk.Int64(1)
+
+	memberships, err := organizationmembership.List(ctx.Request().Context(), params)
+	if err != nil {
+		return workspace.Workspace{}, fmt.Errorf("failed to list organization memberships: %w", err)
+	}
+	if len(memberships.OrganizationMemberships) == 0 {
+		return workspace.Workspace{}, fmt.Errorf("user %s is not a member of organization %s", userID, orgID)
+	}
+
+	membership := memberships.OrganizationMemberships[0]
+	return workspace.Workspace{
+		OrgID:       orgID,
+		Role:        membership.Role,
+		Permissions: membership.Permissions,
+	}, nil
+}
+
+func todoTitle(msg *inboundmail.Message) string {
+	title := strings.Join(strings.Fields(inboundmail.CleanSubject(msg.Subject)), " ")
+	if utf8.RuneCountInString(title) < 3 {
+		title = "Email from " + msg.From
+		if msg.From == "" {
+			title = "Emailed todo"
+		}
+	}
+	return truncateRunes(title, todoTitleMaxLength)
+}
+
+// replyContent is the comment a reply makes: its text without the quoted
+// message, cut to the longest comment allowed.
+func replyContent(msg *inboundmail.Message) string {
+	return truncateRunes(inboundmail.StripQuotedReply(messageText(msg)), commentContentMaxLength)
+}
+
+// messageText prefers the plain-text body and falls back to the HTML one.
+func messageText(msg *inboundmail.Message) string {
+	if strings.TrimSpace(msg.Text) != "" {
+		return strings.TrimSpace(strings.ReplaceAll(msg.Text, "\r\n", "\n"))
+	}
+	if msg.HTML == "" {
+		return ""
+	}
+
+	text, err := email.HTMLToText(msg.HTML)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(text)
+}
+
+func truncateRunes(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+func generateInboundToken() (string, error) {
+	b := make([]byte, inboundTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to read random bytes for inbound token: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/ApoorvYdv/go-tasker/internal/lib/inboundmail"
+	"github.com/ApoorvYdv/go-tasker/internal/model/comment"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplyContent(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{"short reply", "Sounds good", "Sounds good"},
+		{"quoted message dropped", "Sounds good\n\nOn Mon, Jan 5, 2026 at 9:00 AM Ana <ana@example.com> wrote:\n> Ship it?", "Sounds good"},
+		{"empty reply", "> Ship it?", ""},
+		{"at the limit", strings.Repeat("a", 1000), strings.Repeat("a", 1000)},
+		{"over the limit", strings.Repeat("a", 1500), strings.Repeat("a", 999) + "…"},
+		{"over the limit in runes", strings.Repeat("é", 1001), strings.Repeat("é", 999) + "…"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			content := replyContent(&inboundmail.Message{Text: tc.text})
+			assert.Equal(t, tc.expected, content)
+			assert.LessOrEqual(t, utf8.RuneCountInString(content), 1000)
+		})
+	}
+
+	t.Run("long replies still make valid comments", func(t *testing.T) {
+		payload := &comment.AddCommentPayload{
+			TodoID:  uuid.New(),
+			Content: replyContent(&inboundmail.Message{Text: strings.Repeat("word ", 400)}),
+		}
+		assert.NoError(t, payload.Validate())
+	})
+}
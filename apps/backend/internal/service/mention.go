@@ -0,0 +1,80 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/ApoorvYdv/go-tasker/internal/lib/mention"
+	"github.com/ApoorvYdv/go-tasker/internal/middleware"
+	"github.com/ApoorvYdv/go-tasker/internal/model/comment"
+	"github.com/ApoorvYdv/go-tasker/internal/repository"
+	"github.com/clerk/clerk-sdk-go/v2"
+	"github.com/clerk/clerk-sdk-go/v2/user"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// resolveMentions maps the @handles in content to Clerk users by username, keeping
+// only users who can access the todo. Resolution is best-effort: if the user
+// directory is unavailable the comment is saved without mentions.
+func resolveMentions(ctx echo.Context, todoRepo *repository.TodoRepository, todoID uuid.UUID, content string) []comment.Mention {
+	logger := middleware.GetLogger(ctx)
+
+	handles := mention.ParseHandles(content)
+	if len(handles) == 0 {
+		return []comment.Mention{}
+	}
+
+	params := &user.ListParams{
+		Usernames: handles,
+	}
+	params.Limit = clerk.Int64(int64(len(handles)))
+	if ws := middleware.GetWorkspace(ctx); ws.IsOrg() {
+		params.OrganizationIDs = []string{ws.OrgID}
+	}
+
+	users, err := user.List(ctx.Request().Context(), params)
+	if err != nil {
+		logger.Warn().Err(err).Msg("failed to resolve mentioned users")
+		return []comment.Mention{}
+	}
+
+	userIDsByHandle := make(map[string]string, len(users.Users))
+	candidateIDs := make([]string, 0, len(users.Users))
+	for _, u := range users.Users {
+		if u.Username == nil {
+			continue
+		}
+		userIDsByHandle[strings.ToLower(*u.Username)] = u.ID
+		candidateIDs = append(candidateIDs, u.ID)
+	}
+
+	if len(candidateIDs) == 0 {
+		return []comment.Mention{}
+	}
+
+	// Users were only listed from the session's organization, so they are its members
+	withoutAccess, err := todoRepo.GetUsersWithoutTodoAccess(ctx.Request().Context(), todoID, candidateIDs, candidateIDs)
+	if err != nil {
+		logger.Warn().Err(err).Msg("failed to check access of mentioned users")
+		return []comment.Mention{}
+	}
+
+	excluded := make(map[string]struct{}, len(withoutAccess))
+	for _, id := range withoutAccess {
+		excluded[id] = struct{}{}
+	}
+
+	mentions := make([]comment.Mention, 0, len(handles))
+	for _, handle := range handles {
+		userID, ok := userIDsByHandle[handle]
+		if !ok {
+			continue
+		}
+		if _, ok := excluded[userID]; ok {
+			continue
+		}
+		mentions = append(mentions, comment.Mention{UserID: userID, Handle: handle})
+	}
+
+	return mentions
+}
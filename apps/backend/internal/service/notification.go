@@ -0,0 +1,751 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ApoorvYdv/go-tasker/internal/errs"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/job"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/realtime"
+	"github.com/ApoorvYdv/go-tasker/internal/middleware"
+	"github.com/ApoorvYdv/go-tasker/internal/model/comment"
+	"github.com/ApoorvYdv/go-tasker/internal/model/event"
+	"github.com/ApoorvYdv/go-tasker/internal/model/inbound"
+	"github.com/ApoorvYdv/go-tasker/internal/model/notification"
+	"github.com/ApoorvYdv/go-tasker/internal/model/todo"
+	"github.com/ApoorvYdv/go-tasker/internal/repository"
+	"github.com/ApoorvYdv/go-tasker/internal/server"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+)
+
+const (
+	webhookSecretBytes = 32
+
+	// dueTodosScanInterval must match dueTodosScanSchedule
+	dueTodosScanInterval = 5 * time.Minute
+	dueTodosScanSchedule = "*/5 * * * *"
+	// dueSoonWindow is how far ahead of its due date a todo is announced
+	dueSoonWindow = 24 * time.Hour
+	// reminderLookback bounds how late a missed reminder is still delivered
+	reminderLookback = 24 * time.Hour
+)
+
+// TodoActivity describes a change to a todo that its followers should hear about.
+type TodoActivity struct {
+	Type      notification.Type
+	Todo      *todo.Todo
+	CommentID *uuid.UUID
+	// ActorID is empty for system events such as reminders
+	ActorID string
+	// Summary completes the sentence "<actor> ..." in notification copy
+	Summary string
+}
+
+type NotificationService struct {
+	server           *server.Server
+	todoRepo         *repository.TodoRepository
+	notificationRepo *repository.NotificationRepository
+	inboundRepo      *repository.InboundRepository
+}
+
+func NewNotificationService(server *server.Server, todoRepo *repository.TodoRepository,
+	notificationRepo *repository.NotificationRepository,
+	inboundRepo *repository.InboundRepository,
+) *NotificationService {
+	s := &NotificationService{
+		server:           server,
+		todoRepo:         todoRepo,
+		notificationRepo: notificationRepo,
+		inboundRepo:      inboundRepo,
+	}
+
+	server.Job.Handle(job.TaskScanDueTodos, s.handleScanDueTodosTask)
+	if err := server.Job.Schedule(dueTodosScanSchedule, job.NewScanDueTodosTask(dueTodosScanInterval)); err != nil {
+		server.Logger.Error().Err(err).Msg("failed to schedule due todos scan")
+	}
+
+	return s
+}
+
+// notificationEventTypes are the domain events handleEvent turns into notifications.
+var notificationEventTypes = []event.Type{
+	event.TypeTodoUpdated,
+	event.TypeTodoAssigneesChanged,
+	event.TypeCommentAdded,
+	event.TypeCommentUpdated,
+}
+
+// handleEvent notifies the users a domain event concerns. It subscribes to the
+// outbox, so notifications only go out for changes that committed. Delivery to a
+// recipient is best-effort; only failing to load what the event is about has the
+// event delivered again.
+func (s *NotificationService) handleEvent(ctx context.Context, e *event.Event) error {
+	logger := s.server.Logger
+
+	var actorID string
+	if e.ActorID != nil {
+		actorID = *e.ActorID
+	}
+
+	switch e.Type {
+	case event.TypeTodoUpdated:
+		p, err := event.Decode[event.TodoUpdated](e)
+		if err != nil {
+			return err
+		}
+
+		if slices.Contains(p.Changed, "status") && p.Todo.Status != p.PreviousStatus {
+			s.notifyFollowers(ctx, logger, TodoActivity{
+				Type:    notification.TypeTodoStatusChanged,
+				Todo:    &p.Todo,
+				ActorID: actorID,
+				Summary: fmt.Sprintf("changed the status to %s", p.Todo.Status),
+			}, nil)
+		}
+
+		if slices.Contains(p.Changed, "dueDate") && dueDateMoved(p.PreviousDueDate, p.Todo.DueDate) {
+			summary := "removed the due date"
+			if p.Todo.DueDate != nil {
+				summary = fmt.Sprintf("moved the due date to %s", p.Todo.DueDate.Format("Jan 2, 2006"))
+			}
+			s.notifyFollowers(ctx, logger, TodoActivity{
+				Type:    notification.TypeTodoDueDateChanged,
+				Todo:    &p.Todo,
+				ActorID: actorID,
+				Summary: summary,
+			}, nil)
+		}
+
+	case event.TypeTodoAssigneesChanged:
+		p, err := event.Decode[event.TodoAssigneesChanged](e)
+		if err != nil {
+			return err
+		}
+		if len(p.Added) == 0 {
+			return nil
+		}
+
+		todoItem, err := s.eventTodo(ctx, p.TodoID)
+		if err != nil || todoItem == nil {
+			return err
+		}
+
+		s.dispatch(ctx, logger, p.Added, TodoActivity{
+			Type:    notification.TypeTodoAssigned,
+			Todo:    todoItem,
+			ActorID: actorID,
+			Summary: "assigned you to this todo",
+		})
+
+	case event.TypeCommentAdded:
+		p, err := event.Decode[event.CommentAdded](e)
+		if err != nil {
+			return err
+		}
+
+		todoItem, err := s.eventTodo(ctx, p.Comment.TodoID)
+		if err != nil || todoItem == nil {
+			return err
+		}
+
+		notifiedIDs := p.Comment.MentionedUserIDs()
+		s.notifyMentioned(ctx, logger, todoItem, &p.Comment, actorID, notifiedIDs)
+
+		if p.ParentUserID != nil && !slices.Contains(notifiedIDs, *p.ParentUserID) {
+			s.dispatch(ctx, logger, []string{*p.ParentUserID}, TodoActivity{
+				Type:      notification.TypeCommentReply,
+				Todo:      todoItem,
+				CommentID: &p.Comment.ID,
+				ActorID:   actorID,
+				Summary:   fmt.Sprintf("replied to your comment: %s", excerpt(p.Comment.Content, 140)),
+			})
+			notifiedIDs = append(notifiedIDs, *p.ParentUserID)
+		}
+
+		// Users already told about a mention or reply don't also get the generic notification
+		s.notifyFollowers(ctx, logger, TodoActivity{
+			Type:      notification.TypeTodoCommented,
+			Todo:      todoItem,
+			CommentID: &p.Comment.ID,
+			ActorID:   actorID,
+			Summary:   fmt.Sprintf("commented: %s", excerpt(p.Comment.Content, 140)),
+		}, nil, notifiedIDs...)
+
+	case event.TypeCommentUpdated:
+		p, err := event.Decode[event.CommentUpdated](e)
+		if err != nil {
+			return err
+		}
+
+		// Only users newly mentioned by the edit are notified
+		newlyMentionedIDs := make([]string, 0)
+		for _, id := range p.Comment.MentionedUserIDs() {
+			if !slices.Contains(p.PreviousMentionIDs, id) {
+				newlyMentionedIDs = append(newlyMentionedIDs, id)
+			}
+		}
+		if len(newlyMentionedIDs) == 0 {
+			return nil
+		}
+
+		todoItem, err := s.eventTodo(ctx, p.Comment.TodoID)
+		if err != nil || todoItem == nil {
+			return err
+		}
+
+		s.notifyMentioned(ctx, logger, todoItem, &p.Comment, actorID, newlyMentionedIDs)
+	}
+
+	return nil
+}
+
+// dueDateMoved reports whether a todo update changed its due date, setting or
+// clearing it included.
+func dueDateMoved(before, after *time.Time) bool {
+	if before == nil || after == nil {
+		return before != after
+	}
+	return !before.Equal(*after)
+}
+
+// eventTodo loads the todo an event is about. It returns nil when the todo was
+// deleted since, as there is nothing left to notify about.
+func (s *NotificationService) eventTodo(ctx context.Context, todoID uuid.UUID) (*todo.Todo, error) {
+	todoItem, err := s.todoRepo.GetTodo(ctx, todoID)
+	if err != nil {
+		var httpErr *errs.HTTPError
+		if errors.As(err, &httpErr) && httpErr.Status == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return todoItem, nil
+}
+
+func (s *NotificationService) notifyMentioned(ctx context.Context, logger *zerolog.Logger, todoItem *todo.Todo,
+	commentItem *comment.Comment, actorID string, userIDs []string,
+) {
+	if len(userIDs) == 0 {
+		return
+	}
+
+	s.dispatch(ctx, logger, userIDs, TodoActivity{
+		Type:      notification.TypeCommentMention,
+		Todo:      todoItem,
+		CommentID: &commentItem.ID,
+		ActorID:   actorID,
+		Summary:   fmt.Sprintf("mentioned you in a comment: %s", excerpt(commentItem.Content, 140)),
+	})
+}
+
+func (s *NotificationService) notifyFollowers(ctx context.Context, logger *zerolog.Logger, activity TodoActivity,
+	extraUserIDs []string, skipUserIDs ...string,
+) {
+	followerIDs, err := s.todoRepo.GetTodoFollowerIDs(ctx, activity.Todo.ID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to resolve todo followers for notification")
+		return
+	}
+
+	skipped := make(map[string]struct{}, len(skipUserIDs))
+	for _, id := range skipUserIDs {
+		skipped[id] = struct{}{}
+	}
+
+	recipientIDs := make([]string, 0, len(followerIDs)+len(extraUserIDs))
+	for _, id := range append(extraUserIDs, followerIDs...) {
+		if _, ok := skipped[id]; !ok {
+			recipientIDs = append(recipientIDs, id)
+		}
+	}
+
+	s.dispatch(ctx, logger, recipientIDs, activity)
+}
+
+// dispatch is the single place notifications are delivered. Each recipient's
+// preferences for the activity type choose the channels: in-app notifications are
+// stored and pushed over realtime, while emails and webhooks are queued and held
+// until the end of the recipient's quiet hours.
+func (s *NotificationService) dispatch(ctx context.Context, logger *zerolog.Logger, userIDs []string, activity TodoActivity) {
+	recipientIDs := make([]string, 0, len(userIDs))
+	seen := make(map[string]struct{}, len(userIDs))
+	for _, id := range userIDs {
+		if _, ok := seen[id]; ok || id == activity.ActorID {
+			continue
+		}
+		seen[id] = struct{}{}
+		recipientIDs = append(recipientIDs, id)
+	}
+
+	if len(recipientIDs) == 0 {
+		return
+	}
+
+	deliveries, err := s.notificationRepo.GetDeliveries(ctx, recipientIDs, activity.Type)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to resolve notification preferences")
+		return
+	}
+
+	inAppIDs := make([]string, 0, len(deliveries))
+	for _, d := range deliveries {
+		if d.InApp {
+			inAppIDs = append(inAppIDs, d.UserID)
+		}
+	}
+
+	if len(inAppIDs) > 0 {
+		var actorID *string
+		if activity.ActorID != "" {
+			actorID = &activity.ActorID
+		}
+
+		notifications, err := s.notificationRepo.CreateNotifications(ctx, inAppIDs, &notification.Notification{
+			Type:      activity.Type,
+			ActorID:   actorID,
+			TodoID:    &activity.Todo.ID,
+			CommentID: activity.CommentID,
+			Title:     activity.Todo.Title,
+			Body:      activity.Summary,
+		})
+		if err != nil {
+			// The other channels don't depend on the in-app copy
+			logger.Error().Err(err).Msg("failed to create in-app notifications")
+		}
+
+		for i := range notifications {
+			n := &notifications[i]
+			publishToUsers(ctx, logger, s.server, []string{n.UserID}, realtime.EventNotificationCreated, n.ID.String(), n)
+		}
+	}
+
+	now := time.Now()
+	for _, d := range deliveries {
+		var opts []asynq.Option
+		if until, quiet := d.QuietUntil(now); quiet {
+			opts = append(opts, asynq.ProcessAt(until))
+		}
+
+		channels := make([]string, 0, 3)
+		if d.InApp {
+			channels = append(channels, string(notification.ChannelInApp))
+		}
+		if d.Email && s.enqueueEmail(ctx, logger, d.UserID, activity, opts) {
+			channels = append(channels, string(notification.ChannelEmail))
+		}
+		if d.Webhook && d.WebhookURL != nil && d.WebhookSecret != nil &&
+			s.enqueueWebhook(logger, d, activity, now, opts) {
+			channels = append(channels, string(notification.ChannelWebhook))
+		}
+
+		logger.Info().
+			Str("event", "notification_sent").
+			Str("type", string(activity.Type)).
+			Str("todo_id", activity.Todo.ID.String()).
+			Str("recipient_id", d.UserID).
+			Strs("channels", channels).
+			Msg("Notification delivered")
+	}
+}
+
+func (s *NotificationService) enqueueEmail(ctx context.Context, logger *zerolog.Logger, recipientID string,
+	activity TodoActivity, opts []asynq.Option,
+) bool {
+	task, err := job.NewTodoActivityEmailTask(job.TodoActivityEmailPayload{
+		RecipientID: recipientID,
+		ActorID:     activity.ActorID,
+		TodoID:      activity.Todo.ID.String(),
+		TodoTitle:   activity.Todo.Title,
+		Summary:     activity.Summary,
+		Reason:      notificationReason(activity.Type),
+		TodoURL:     appURL(s.server, "/todos/"+activity.Todo.ID.String()),
+		ReplyTo:     s.replyAddress(ctx, logger, recipientID, activity),
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to create todo activity email task")
+		return false
+	}
+
+	if _, err := s.server.Job.Client.Enqueue(task, opts...); err != nil {
+		logger.Error().Err(err).
+			Str("recipient_id", recipientID).
+			Msg("failed to enqueue todo activity email")
+		return false
+	}
+
+	return true
+}
+
+// replyAddress issues a reply-to address that turns the recipient's reply into a
+// comment. It returns "" when inbound email is off or the token can't be stored.
+func (s *NotificationService) replyAddress(ctx context.Context, logger *zerolog.Logger, recipientID string,
+	activity TodoActivity,
+) string {
+	if !s.server.Config.Integration.InboundEmailEnabled() {
+		return ""
+	}
+
+	token, err := generateInboundToken()
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to generate email reply token")
+		return ""
+	}
+
+	err = s.inboundRepo.CreateReplyToken(ctx, token, recipientID, activity.Todo.ID, activity.CommentID,
+		activity.Todo.OrganizationID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to store email reply token")
+		return ""
+	}
+
+	return inbound.ReplyAddress(token, s.server.Config.Integration.InboundEmailDomain)
+}
+
+// webhookEvent is the JSON body posted to a user's notification webhook.
+type webhookEvent struct {
+	Type      notification.Type `json:"type"`
+	UserID    string            `json:"userId"`
+	ActorID   *string           `json:"actorId"`
+	TodoID    uuid.UUID         `json:"todoId"`
+	CommentID *uuid.UUID        `json:"commentId"`
+	Title     string            `json:"title"`
+	Body      string            `json:"body"`
+	URL       string            `json:"url"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+func (s *NotificationService) enqueueWebhook(logger *zerolog.Logger, d notification.Delivery, activity TodoActivity,
+	now time.Time, opts []asynq.Option,
+) bool {
+	event := webhookEvent{
+		Type:      activity.Type,
+		UserID:    d.UserID,
+		TodoID:    activity.Todo.ID,
+		CommentID: activity.CommentID,
+		Title:     activity.Todo.Title,
+		Body:      activity.Summary,
+		URL:       appURL(s.server, "/todos/"+activity.Todo.ID.String()),
+		CreatedAt: now,
+	}
+	if activity.ActorID != "" {
+		event.ActorID = &activity.ActorID
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to encode notification webhook body")
+		return false
+	}
+
+	task, err := job.NewNotificationWebhookTask(*d.WebhookURL, *d.WebhookSecret, body, opts...)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to create notification webhook task")
+		return false
+	}
+
+	if _, err := s.server.Job.Client.Enqueue(task); err != nil {
+		logger.Error().Err(err).
+			Str("recipient_id", d.UserID).
+			Msg("failed to enqueue notification webhook")
+		return false
+	}
+
+	return true
+}
+
+// handleScanDueTodosTask announces todos that fall due soon and reminders that are due.
+// Claims are recorded in the database, so overlapping scans never notify twice.
+func (s *NotificationService) handleScanDueTodosTask(ctx context.Context, _ *asynq.Task) error {
+	logger := s.server.Logger
+
+	dueSoon, err := s.todoRepo.ClaimDueSoonTodos(ctx, dueSoonWindow)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to claim due soon todos")
+		return err
+	}
+
+	for i := range dueSoon {
+		todoItem := &dueSoon[i]
+		s.notifyFollowers(ctx, logger, TodoActivity{
+			Type:    notification.TypeTodoDueSoon,
+			Todo:    todoItem,
+			Summary: fmt.Sprintf("this todo is due %s", dueIn(time.Until(*todoItem.DueDate))),
+		}, []string{todoItem.UserID})
+	}
+
+	reminders, err := s.todoRepo.ClaimDueReminders(ctx, reminderLookback)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to claim due reminders")
+		return err
+	}
+
+	for i := range reminders {
+		todoItem := &reminders[i]
+		s.dispatch(ctx, logger, []string{todoItem.UserID}, TodoActivity{
+			Type:    notification.TypeTodoReminder,
+			Todo:    todoItem,
+			Summary: "you asked to be reminded about this todo",
+		})
+	}
+
+	if len(dueSoon) > 0 || len(reminders) > 0 {
+		logger.Info().
+			Str("event", "due_todos_scanned").
+			Int("due_soon", len(dueSoon)).
+			Int("reminders", len(reminders)).
+			Msg("Due todo notifications dispatched")
+	}
+
+	return nil
+}
+
+// dueIn phrases the time left until a due date for notification copy.
+func dueIn(d time.Duration) string {
+	switch hours := int(d.Round(time.Hour) / time.Hour); {
+	case hours < 1:
+		return "within the hour"
+	case hours == 1:
+		return "in 1 hour"
+	default:
+		return fmt.Sprintf("in %d hours", hours)
+	}
+}
+
+func (s *NotificationService) GetNotifications(ctx echo.Context, userID string,
+	query *notification.GetNotificationsQuery,
+) (*notification.Inbox, error) {
+	logger := middleware.GetLogger(ctx)
+
+	result, err := s.notificationRepo.GetNotifications(ctx.Request().Context(), userID, query)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to fetch notifications")
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *NotificationService) GetUnreadCount(ctx echo.Context, userID string) (*notification.UnreadCount, error) {
+	logger := middleware.GetLogger(ctx)
+
+	count, err := s.notificationRepo.GetUnreadCount(ctx.Request().Context(), userID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to fetch unread notification count")
+		return nil, err
+	}
+
+	return &notification.UnreadCount{Count: count}, nil
+}
+
+func (s *NotificationService) MarkRead(ctx echo.Context, userID string, notificationID uuid.UUID) (*notification.Notification, error) {
+	logger := middleware.GetLogger(ctx)
+
+	notificationItem, err := s.notificationRepo.MarkRead(ctx.Request().Context(), userID, notificationID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to mark notification read")
+		return nil, err
+	}
+
+	// Keep the user's other open tabs in sync
+	publishToUsers(ctx.Request().Context(), logger, s.server, []string{userID},
+		realtime.EventNotificationUpdated, notificationItem.ID.String(), notificationItem)
+
+	return notificationItem, nil
+}
+
+func (s *NotificationService) MarkAllRead(ctx echo.Context, userID string) error {
+	logger := middleware.GetLogger(ctx)
+
+	count, err := s.notificationRepo.MarkAllRead(ctx.Request().Context(), userID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to mark all notifications read")
+		return err
+	}
+
+	publishToUsers(ctx.Request().Context(), logger, s.server, []string{userID},
+		realtime.EventNotificationsReadAll, userID, nil)
+
+	// Business event log
+	eventLogger := middleware.GetLogger(ctx)
+	eventLogger.Info().
+		Str("event", "notifications_read_all").
+		Int64("count", count).
+		Msg("Notifications marked read successfully")
+
+	return nil
+}
+
+func (s *NotificationService) Archive(ctx echo.Context, userID string, notificationID uuid.UUID) (*notification.Notification, error) {
+	logger := middleware.GetLogger(ctx)
+
+	notificationItem, err := s.notificationRepo.Archive(ctx.Request().Context(), userID, notificationID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to archive notification")
+		return nil, err
+	}
+
+	publishToUsers(ctx.Request().Context(), logger, s.server, []string{userID},
+		realtime.EventNotificationUpdated, notificationItem.ID.String(), notificationItem)
+
+	return notificationItem, nil
+}
+
+// GetPreferences returns the user's settings and a preference for every type,
+// filling in defaults for types the user hasn't configured.
+func (s *NotificationService) GetPreferences(ctx echo.Context, userID string) (*notification.Preferences, error) {
+	logger := middleware.GetLogger(ctx)
+
+	settings, err := s.notificationRepo.GetSettings(ctx.Request().Context(), userID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to fetch notification settings")
+		return nil, err
+	}
+
+	configured, err := s.notificationRepo.GetPreferences(ctx.Request().Context(), userID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to fetch notification preferences")
+		return nil, err
+	}
+
+	byType := make(map[notification.Type]notification.Preference, len(configured))
+	for _, p := range configured {
+		byType[p.Type] = p
+	}
+
+	preferences := make([]notification.Preference, 0, len(notification.Types))
+	for _, t := range notification.Types {
+		if p, ok := byType[t]; ok {
+			preferences = append(preferences, p)
+		} else {
+			preferences = append(preferences, notification.DefaultPreference(t))
+		}
+	}
+
+	return &notification.Preferences{
+		Settings: *settings,
+		Types:    preferences,
+	}, nil
+}
+
+func (s *NotificationService) UpdatePreferences(ctx echo.Context, userID string,
+	payload *notification.UpdatePreferencesPayload,
+) (*notification.Preferences, error) {
+	logger := middleware.GetLogger(ctx)
+
+	settings, err := s.notificationRepo.GetSettings(ctx.Request().Context(), userID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to fetch notification settings")
+		return nil, err
+	}
+
+	if payload.Timezone != nil {
+		settings.Timezone = *payload.Timezone
+	}
+
+	if payload.QuietHoursStart != nil {
+		if *payload.QuietHoursStart == "" {
+			settings.QuietHoursStart, settings.QuietHoursEnd = nil, nil
+		} else {
+			settings.QuietHoursStart, settings.QuietHoursEnd = payload.QuietHoursStart, payload.QuietHoursEnd
+		}
+	}
+
+	if payload.WebhookURL != nil {
+		switch {
+		case *payload.WebhookURL == "":
+			settings.WebhookURL, settings.WebhookSecret = nil, nil
+		case settings.WebhookURL == nil || *settings.WebhookURL != *payload.WebhookURL:
+			// A new endpoint gets a new signing secret
+			secret, err := generateWebhookSecret()
+			if err != nil {
+				logger.Error().Err(err).Msg("failed to generate webhook secret")
+				return nil, err
+			}
+			settings.WebhookURL, settings.WebhookSecret = payload.WebhookURL, &secret
+		}
+	}
+
+	if err := s.notificationRepo.SaveSettings(ctx.Request().Context(), userID, settings); err != nil {
+		logger.Error().Err(err).Msg("failed to save notification settings")
+		return nil, err
+	}
+
+	if len(payload.Types) > 0 {
+		if err := s.notificationRepo.SavePreferences(ctx.Request().Context(), userID, payload.Types); err != nil {
+			logger.Error().Err(err).Msg("failed to save notification preferences")
+			return nil, err
+		}
+	}
+
+	// Business event log
+	eventLogger := middleware.GetLogger(ctx)
+	eventLogger.Info().
+		Str("event", "notification_preferences_updated").
+		Bool("quiet_hours", settings.QuietHoursStart != nil).
+		Bool("webhook", settings.WebhookURL != nil).
+		Int("types", len(payload.Types)).
+		Msg("Notification preferences updated successfully")
+
+	return s.GetPreferences(ctx, userID)
+}
+
+// excerpt shortens text to at most limit runes on a single line for notification copy.
+func excerpt(text string, limit int) string {
+	text = strings.Join(strings.Fields(text), " ")
+
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+// notificationReason explains in the email footer why the recipient got the email.
+func notificationReason(t notification.Type) string {
+	switch t {
+	case notification.TypeTodoAssigned:
+		return "You're receiving this because you were assigned to this todo."
+	case notification.TypeCommentMention:
+		return "You're receiving this because you were mentioned in a comment."
+	case notification.TypeCommentReply:
+		return "You're receiving this because someone replied to your comment."
+	case notification.TypeTodoDueSoon:
+		return "You're receiving this because you own, are assigned to or are watching this todo."
+	case notification.TypeTodoReminder:
+		return "You're receiving this because you set a reminder on this todo."
+	default:
+		return "You're receiving this because you are assigned to or watching this todo."
+	}
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to read random bytes for webhook secret: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// appURL builds a link into the web app, falling back to the first allowed CORS
+// origin when no frontend URL is configured.
+func appURL(s *server.Server, path string) string {
+	baseURL := s.Config.Server.FrontendURL
+	if baseURL == "" && len(s.Config.Server.CORSAllowedOrigins) > 0 {
+		baseURL = s.Config.Server.CORSAllowedOrigins[0]
+	}
+
+	return fmt.Sprintf("%s%s", strings.TrimRight(baseURL, "/"), path)
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+
+	"github.com/ApoorvYdv/go-tasker/internal/lib/realtime"
+	"github.com/ApoorvYdv/go-tasker/internal/model/event"
+	"github.com/ApoorvYdv/go-tasker/internal/repository"
+	"github.com/ApoorvYdv/go-tasker/internal/server"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// realtimeSubscriber pushes domain events from the outbox to the realtime streams
+// of the users who can see the change, so clients only hear about changes that
+// committed, and about each todo's or category's changes in order.
+type realtimeSubscriber struct {
+	server       *server.Server
+	todoRepo     *repository.TodoRepository
+	categoryRepo *repository.CategoryRepository
+}
+
+func newRealtimeSubscriber(server *server.Server, todoRepo *repository.TodoRepository,
+	categoryRepo *repository.CategoryRepository,
+) *realtimeSubscriber {
+	return &realtimeSubscriber{
+		server:       server,
+		todoRepo:     todoRepo,
+		categoryRepo: categoryRepo,
+	}
+}
+
+func (s *realtimeSubscriber) handleEvent(ctx context.Context, e *event.Event) error {
+	if s.server.Realtime == nil {
+		return nil
+	}
+
+	switch e.Type {
+	case event.TypeTodoCreated:
+		p, err := event.Decode[event.TodoCreated](e)
+		if err != nil {
+			return err
+		}
+		return s.publishToTodoMembers(ctx, e, p.Todo.ID, realtime.EventTodoCreated, p.Todo.ID.String(), p.Todo)
+
+	case event.TypeTodoUpdated:
+		p, err := event.Decode[event.TodoUpdated](e)
+		if err != nil {
+			return err
+		}
+		return s.publishToTodoMembers(ctx, e, p.Todo.ID, realtime.EventTodoUpdated, p.Todo.ID.String(), p.Todo)
+
+	case event.TypeTodoDeleted:
+		p, err := event.Decode[event.TodoDeleted](e)
+		if err != nil {
+			return err
+		}
+		s.publish(ctx, e, p.MemberIDs, realtime.EventTodoDeleted, p.TodoID.String(), nil)
+
+	case event.TypeTodoAssigneesChanged:
+		p, err := event.Decode[event.TodoAssigneesChanged](e)
+		if err != nil {
+			return err
+		}
+		return s.publishToTodoMembers(ctx, e, p.TodoID, realtime.EventTodoAssigneesChanged, p.TodoID.String(), p.Assignees)
+
+	case event.TypeCommentAdded:
+		p, err := event.Decode[event.CommentAdded](e)
+		if err != nil {
+			return err
+		}
+		return s.publishToTodoMembers(ctx, e, p.Comment.TodoID, realtime.EventCommentAdded, p.Comment.ID.String(), p.Comment)
+
+	case event.TypeCommentUpdated:
+		p, err := event.Decode[event.CommentUpdated](e)
+		if err != nil {
+			return err
+		}
+		return s.publishToTodoMembers(ctx, e, p.Comment.TodoID, realtime.EventCommentUpdated, p.Comment.ID.String(), p.Comment)
+
+	case event.TypeCommentDeleted:
+		p, err := event.Decode[event.CommentDeleted](e)
+		if err != nil {
+			return err
+		}
+		// Tombstoned comments stay in the thread with their content cleared
+		return s.publishToTodoMembers(ctx, e, p.TodoID, realtime.EventCommentDeleted, p.CommentID.String(), map[string]any{
+			"todoId":    p.TodoID.String(),
+			"tombstone": p.Tombstoned,
+		})
+
+	case event.TypeCommentReactionToggled:
+		p, err := event.Decode[event.CommentReactionToggled](e)
+		if err != nil {
+			return err
+		}
+		// reactedByMe differs per viewer, so members receive the change rather than the totals
+		return s.publishToTodoMembers(ctx, e, p.TodoID, realtime.EventCommentReacted, p.CommentID.String(), map[string]any{
+			"todoId": p.TodoID.String(),
+			"userId": p.UserID,
+			"emoji":  p.Emoji,
+			"added":  p.Added,
+		})
+
+	case event.TypeAttachmentAdded:
+		p, err := event.Decode[event.AttachmentAdded](e)
+		if err != nil {
+			return err
+		}
+		return s.publishToTodoMembers(ctx, e, p.TodoID, realtime.EventAttachmentAdded, p.Attachment.ID.String(), p.Attachment)
+
+	case event.TypeAttachmentVersionAdded:
+		p, err := event.Decode[event.AttachmentVersionAdded](e)
+		if err != nil {
+			return err
+		}
+		return s.publishToTodoMembers(ctx, e, p.TodoID, realtime.EventAttachmentUpdated, p.Attachment.ID.String(), p.Attachment)
+
+	case event.TypeAttachmentDeleted:
+		p, err := event.Decode[event.AttachmentDeleted](e)
+		if err != nil {
+			return err
+		}
+		return s.publishToTodoMembers(ctx, e, p.TodoID, realtime.EventAttachmentDeleted, p.AttachmentID.String(), map[string]string{
+			"todoId": p.TodoID.String(),
+		})
+
+	case event.TypeCategoryCreated:
+		p, err := event.Decode[event.CategoryCreated](e)
+		if err != nil {
+			return err
+		}
+		return s.publishToCategoryMembers(ctx, e, p.Category.ID, realtime.EventCategoryCreated, p.Category.ID.String(), p.Category)
+
+	case event.TypeCategoryUpdated:
+		p, err := event.Decode[event.CategoryUpdated](e)
+		if err != nil {
+			return err
+		}
+		return s.publishToCategoryMembers(ctx, e, p.Category.ID, realtime.EventCategoryUpdated, p.Category.ID.String(), p.Category)
+
+	case event.TypeCategoryDeleted:
+		p, err := event.Decode[event.CategoryDeleted](e)
+		if err != nil {
+			return err
+		}
+		s.publish(ctx, e, p.MemberIDs, realtime.EventCategoryDeleted, p.CategoryID.String(), nil)
+	}
+
+	return nil
+}
+
+// publishToTodoMembers sends a change to the owner and every collaborator of a todo.
+func (s *realtimeSubscriber) publishToTodoMembers(ctx context.Context, e *event.Event, todoID uuid.UUID,
+	eventType realtime.EventType, resourceID string, data any,
+) error {
+	memberIDs, err := s.todoRepo.GetTodoMemberIDs(ctx, todoID)
+	if err != nil {
+		return err
+	}
+
+	s.publish(ctx, e, memberIDs, eventType, resourceID, data)
+	return nil
+}
+
+// publishToCategoryMembers sends a change to the owner and every collaborator of a
+// category.
+func (s *realtimeSubscriber) publishToCategoryMembers(ctx context.Context, e *event.Event, categoryID uuid.UUID,
+	eventType realtime.EventType, resourceID string, data any,
+) error {
+	memberIDs, err := s.categoryRepo.GetCategoryMemberIDs(ctx, categoryID)
+	if err != nil {
+		return err
+	}
+
+	s.publish(ctx, e, memberIDs, eventType, resourceID, data)
+	return nil
+}
+
+// publish pushes a change to the realtime stream of every given user, or to the
+// organization stream for changes made in an organization since every member can
+// see them.
+func (s *realtimeSubscriber) publish(ctx context.Context, e *event.Event, userIDs []string,
+	eventType realtime.EventType, resourceID string, data any,
+) {
+	if e.OrganizationID != nil {
+		userIDs = []string{realtime.OrgStreamKey(*e.OrganizationID)}
+	}
+
+	publishToUsers(ctx, s.server.Logger, s.server, userIDs, eventType, resourceID, data)
+}
+
+// publishToUsers pushes an event to the personal stream of each given user. Events that
+// belong to one user, such as notifications, use it directly. Delivery is best-effort:
+// a failure is logged and never fails the work that caused it.
+func publishToUsers(ctx context.Context, logger *zerolog.Logger, s *server.Server, userIDs []string,
+	eventType realtime.EventType, resourceID string, data any,
+) {
+	if s.Realtime == nil {
+		return
+	}
+
+	for _, userID := range userIDs {
+		if err := s.Realtime.Publish(ctx, userID, eventType, resourceID, data); err != nil {
+			logger.Warn().Err(err).
+				Str("event_type", string(eventType)).
+				Str("resource_id", resourceID).
+				Str("recipient_id", userID).
+				Msg("failed to publish realtime event")
+		}
+	}
+}
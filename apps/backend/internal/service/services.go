@@ -3,32 +3,58 @@ package service
 import (
 	"fmt"
 
-	"github.com/ApoorvYdv/go-tasker/internal/lib/aws"
 	"github.com/ApoorvYdv/go-tasker/internal/lib/job"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/scan"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/storage"
 	"github.com/ApoorvYdv/go-tasker/internal/repository"
 	"github.com/ApoorvYdv/go-tasker/internal/server"
 )
 
 type Services struct {
-	Auth     *AuthService
-	Job      *job.JobService
-	Todo     *TodoService
-	Comment  *CommentService
-	Category *CategoryService
+	Auth         *AuthService
+	Job          *job.JobService
+	Storage      storage.Storage
+	Todo         *TodoService
+	Comment      *CommentService
+	Category     *CategoryService
+	Share        *ShareService
+	Notification *NotificationService
+	Inbound      *InboundService
+	Event        *EventService
 }
 
 func NewServices(s *server.Server, repos *repository.Repositories) (*Services, error) {
 	authService := NewAuthService(s)
-	awsClient, err := aws.NewAWS(s)
+	storageBackend, err := storage.New(s.Config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize AWS client: %w", err)
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
 	}
 
+	scanner, err := scan.New(&s.Config.Scan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize malware scanner: %w", err)
+	}
+
+	notificationService := NewNotificationService(s, repos.Todo, repos.Notification, repos.Inbound)
+	todoService := NewTodoService(s, repos.Todo, repos.Category, storageBackend, scanner)
+	commentService := NewCommentService(s, repos.Comment, repos.Todo)
+
+	// Realtime updates and notifications follow committed changes through the outbox.
+	// Notifications send email and webhooks, so they run in background tasks.
+	eventService := NewEventService(s, repos.Event)
+	eventService.Subscribe("realtime", newRealtimeSubscriber(s, repos.Todo, repos.Category).handleEvent)
+	eventService.SubscribeAsync("notifications", notificationService.handleEvent, notificationEventTypes...)
+
 	return &Services{
-		Job:      s.Job,
-		Auth:     authService,
-		Category: NewCategoryService(s, repos.Category),
-		Todo:     NewTodoService(s, repos.Todo, repos.Category, awsClient),
-		Comment:  NewCommentService(s, repos.Comment, repos.Todo),
+		Job:          s.Job,
+		Storage:      storageBackend,
+		Auth:         authService,
+		Category:     NewCategoryService(s, repos.Category),
+		Todo:         todoService,
+		Comment:      commentService,
+		Share:        NewShareService(s, repos.Share, repos.Todo, repos.Category),
+		Notification: notificationService,
+		Inbound:      NewInboundService(s, repos.Inbound, todoService, commentService),
+		Event:        eventService,
 	}, nil
 }
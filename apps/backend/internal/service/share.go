@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ApoorvYdv/go-tasker/internal/errs"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/job"
+	"github.com/ApoorvYdv/go-tasker/internal/middleware"
+	"github.com/ApoorvYdv/go-tasker/internal/model/share"
+	"github.com/ApoorvYdv/go-tasker/internal/repository"
+	"github.com/ApoorvYdv/go-tasker/internal/server"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const inviteTokenBytes = 32
+
+type ShareService struct {
+	server       *server.Server
+	shareRepo    *repository.ShareRepository
+	todoRepo     *repository.TodoRepository
+	categoryRepo *repository.CategoryRepository
+}
+
+func NewShareService(server *server.Server, shareRepo *repository.ShareRepository,
+	todoRepo *repository.TodoRepository,
+	categoryRepo *repository.CategoryRepository,
+) *ShareService {
+	return &ShareService{
+		server:       server,
+		shareRepo:    shareRepo,
+		todoRepo:     todoRepo,
+		categoryRepo: categoryRepo,
+	}
+}
+
+func (s *ShareService) ShareTodo(ctx echo.Context, userID string, payload *share.CreateTodoSharePayload) (*share.Share, error) {
+	logger := middleware.GetLogger(ctx)
+
+	// Only the owner of a todo tree can invite collaborators. Shares of organization
+	// todos set a member's role on them or let outside collaborators in.
+	todoItem, err := s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, payload.TodoID, share.RoleOwner)
+	if err != nil {
+		logger.Error().Err(err).Msg("todo validation failed")
+		return nil, err
+	}
+
+	if todoItem.ParentTodoID != nil {
+		err := errs.NewBadRequestError("Subtasks are shared together with their parent todo", false, nil, nil, nil)
+		logger.Warn().Msg("cannot share a subtask on its own")
+		return nil, err
+	}
+
+	return s.createShare(ctx, userID, share.ResourceTypeTodo, todoItem.ID, todoItem.Title, payload.Email, payload.Role)
+}
+
+func (s *ShareService) ShareCategory(ctx echo.Context, userID string, payload *share.CreateCategorySharePayload) (*share.Share, error) {
+	logger := middleware.GetLogger(ctx)
+
+	// Only the owner of a category can invite collaborators. Shares of organization
+	// categories set a member's role on them or let outside collaborators in.
+	categoryItem, err := s.categoryRepo.CheckCategoryAccess(ctx.Request().Context(), userID, payload.CategoryID, share.RoleOwner)
+	if err != nil {
+		logger.Error().Err(err).Msg("category validation failed")
+		return nil, err
+	}
+
+	return s.createShare(ctx, userID, share.ResourceTypeCategory, categoryItem.ID, categoryItem.Name, payload.Email, payload.Role)
+}
+
+func (s *ShareService) createShare(ctx echo.Context, userID string, resourceType share.ResourceType,
+	resourceID uuid.UUID, resourceName string, email string, role share.Role,
+) (*share.Share, error) {
+	logger := middleware.GetLogger(ctx)
+
+	token, err := generateInviteToken()
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to generate invite token")
+		return nil, err
+	}
+
+	shareItem, err := s.shareRepo.CreateShare(ctx.Request().Context(), userID, resourceType, resourceID,
+		strings.ToLower(email), role, token)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to create share")
+		return nil, err
+	}
+
+	task, err := job.NewShareInvitationEmailTask(job.ShareInvitationEmailPayload{
+		To:           shareItem.Email,
+		InviterID:    userID,
+		ResourceType: string(resourceType),
+		ResourceName: resourceName,
+		Role:         string(role),
+		InviteURL:    appURL(s.server, "/invitations/"+token),
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to create share invitation task")
+	} else if _, err := s.server.Job.Client.Enqueue(task); err != nil {
+		// The share still exists; the owner can resend by revoking and inviting again
+		logger.Error().Err(err).Msg("failed to enqueue share invitation email")
+	}
+
+	// Business event log
+	eventLogger := middleware.GetLogger(ctx)
+	eventLogger.Info().
+		Str("event", "share_created").
+		Str("share_id", shareItem.ID.String()).
+		Str("resource_type", string(resourceType)).
+		Str("resource_id", resourceID.String()).
+		Str("role", string(role)).
+		Msg("Share invitation created successfully")
+
+	return shareItem, nil
+}
+
+func (s *ShareService) GetTodoShares(ctx echo.Context, userID string, todoID uuid.UUID) ([]share.Share, error) {
+	logger := middleware.GetLogger(ctx)
+
+	// Validate todo exists and user can view it
+	_, err := s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, todoID, share.RoleViewer)
+	if err != nil {
+		logger.Error().Err(err).Msg("todo validation failed")
+		return nil, err
+	}
+
+	// Collaborators below editor only get their own share back
+	shares, err := s.shareRepo.GetShares(ctx.Request().Context(), userID, share.ResourceTypeTodo, todoID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to fetch todo shares")
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+func (s *ShareService) GetCategoryShares(ctx echo.Context, userID string, categoryID uuid.UUID) ([]share.Share, error) {
+	logger := middleware.GetLogger(ctx)
+
+	// Validate category exists and user can view it
+	_, err := s.categoryRepo.CheckCategoryAccess(ctx.Request().Context(), userID, categoryID, share.RoleViewer)
+	if err != nil {
+		logger.Error().Err(err).Msg("category validation failed")
+		return nil, err
+	}
+
+	// Collaborators below editor only get their own share back
+	shares, err := s.shareRepo.GetShares(ctx.Request().Context(), userID, share.ResourceTypeCategory, categoryID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to fetch category shares")
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+func (s *ShareService) UpdateShare(ctx echo.Context, userID string, payload *share.UpdateSharePayload) (*share.Share, error) {
+	logger := middleware.GetLogger(ctx)
+
+	shareItem, err := s.shareRepo.UpdateShareRole(ctx.Request().Context(), userID, payload.ID, payload.Role)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to update share")
+		return nil, err
+	}
+
+	// Business event log
+	eventLogger := middleware.GetLogger(ctx)
+	eventLogger.Info().
+		Str("event", "share_updated").
+		Str("share_id", shareItem.ID.String()).
+		Str("role", string(shareItem.Role)).
+		Msg("Share updated successfully")
+
+	return shareItem, nil
+}
+
+func (s *ShareService) DeleteShare(ctx echo.Context, userID string, shareID uuid.UUID) error {
+	logger := middleware.GetLogger(ctx)
+
+	shareItem, err := s.shareRepo.DeleteShare(ctx.Request().Context(), userID, shareID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to delete share")
+		return err
+	}
+
+	// Business event log
+	eventLogger := middleware.GetLogger(ctx)
+	eventLogger.Info().
+		Str("event", "share_deleted").
+		Str("share_id", shareItem.ID.String()).
+		Str("resource_type", string(shareItem.ResourceType)).
+		Msg("Share revoked successfully")
+
+	return nil
+}
+
+func (s *ShareService) AcceptInvitation(ctx echo.Context, userID string, token string) (*share.Share, error) {
+	logger := middleware.GetLogger(ctx)
+
+	// The link alone isn't enough: it must be accepted by the invited address. The
+	// addresses come from the user directory, so they are fetched before the unit of
+	// work, which may run more than once.
+	emails, err := verifiedEmails(ctx.Request().Context(), userID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to fetch verified email addresses")
+		return nil, err
+	}
+
+	var (
+		shareItem *share.Share
+		failure   string
+	)
+	err = s.server.DB.WithTx(ctx.Request().Context(), func(txCtx context.Context) error {
+		invitation, err := s.shareRepo.GetPendingInvitation(txCtx, token)
+		if err != nil {
+			failure = "invitation validation failed"
+			return err
+		}
+
+		if !slices.Contains(emails, invitation.Email) {
+			failure = "invitation sent to a different email address"
+			err := errs.NewForbiddenError("This invitation was sent to a different email address", false)
+			err.Code = "INVITATION_EMAIL_MISMATCH"
+			return err
+		}
+
+		shareItem, err = s.shareRepo.AcceptInvitation(txCtx, userID, token, emails)
+		if err != nil {
+			failure = "failed to accept invitation"
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg(failure)
+		return nil, err
+	}
+
+	// Business event log
+	eventLogger := middleware.GetLogger(ctx)
+	eventLogger.Info().
+		Str("event", "share_accepted").
+		Str("share_id", shareItem.ID.String()).
+		Str("resource_type", string(shareItem.ResourceType)).
+		Str("role", string(shareItem.Role)).
+		Msg("Share invitation accepted successfully")
+
+	return shareItem, nil
+}
+
+func generateInviteToken() (string, error) {
+	b := make([]byte, inviteTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to read random bytes for invite token: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -1,71 +1,147 @@
 package service
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"mime/multipart"
-	"net/http"
+	"io"
+	"mime"
+	"strings"
+	"time"
 
+	"github.com/ApoorvYdv/go-tasker/internal/database"
 	"github.com/ApoorvYdv/go-tasker/internal/errs"
-	"github.com/ApoorvYdv/go-tasker/internal/lib/aws"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/filetype"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/job"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/scan"
+	"github.com/ApoorvYdv/go-tasker/internal/lib/storage"
 	"github.com/ApoorvYdv/go-tasker/internal/middleware"
 	"github.com/ApoorvYdv/go-tasker/internal/model"
+	"github.com/ApoorvYdv/go-tasker/internal/model/share"
 	"github.com/ApoorvYdv/go-tasker/internal/model/todo"
 	"github.com/ApoorvYdv/go-tasker/internal/repository"
 	"github.com/ApoorvYdv/go-tasker/internal/server"
 	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
 	"github.com/labstack/echo/v4"
 	"github.com/pkg/errors"
 )
 
+const (
+	// attachmentUploadExpiry is how long a client has to upload after asking for a URL
+	attachmentUploadExpiry = 15 * time.Minute
+
+	// Uploads still pending this long after their URL expired are abandoned
+	abandonedUploadGrace = time.Hour
+	// abandonedUploadsInterval must match abandonedUploadsSchedule
+	abandonedUploadsInterval = time.Hour
+	abandonedUploadsSchedule = "15 * * * *"
+	abandonedUploadsBatch    = 500
+)
+
 type TodoService struct {
 	server       *server.Server
 	todoRepo     *repository.TodoRepository
 	categoryRepo *repository.CategoryRepository
-	awsClient    *aws.AWS
+	storage      storage.Storage
+	scanner      scan.Scanner
+	filePolicy   *filetype.Policy
 }
 
 func NewTodoService(server *server.Server, todoRepo *repository.TodoRepository,
 	categoryRepo *repository.CategoryRepository,
-	awsClient *aws.AWS,
+	storage storage.Storage,
+	scanner scan.Scanner,
 ) *TodoService {
-	return &TodoService{
+	s := &TodoService{
 		server:       server,
 		todoRepo:     todoRepo,
 		categoryRepo: categoryRepo,
-		awsClient:    awsClient,
+		storage:      storage,
+		scanner:      scanner,
+	}
+
+	denied := server.Config.Storage.DeniedMimeTypes
+	if denied == nil {
+		denied = filetype.DefaultDenied
+	}
+	s.filePolicy = filetype.NewPolicy(server.Config.Storage.AllowedMimeTypes, denied)
+
+	server.Job.Handle(job.TaskCleanupAttachmentUploads, s.handleCleanupAttachmentUploadsTask)
+	server.Job.Handle(job.TaskGenerateAttachmentPreview, s.handleGenerateAttachmentPreviewTask)
+	server.Job.Handle(job.TaskDeleteStorageObjects, s.handleDeleteStorageObjectsTask)
+	server.Job.Handle(job.TaskReconcileStorage, s.handleReconcileStorageTask)
+	server.Job.Handle(job.TaskScanAttachment, s.handleScanAttachmentTask)
+	server.Job.Handle(job.TaskScanPendingAttachments, s.handleScanPendingAttachmentsTask)
+	if mode := server.Config.Storage.ReconcileMode; mode != "off" {
+		task, err := job.NewReconcileStorageTask(mode == "dry-run", storageReconcileInterval)
+		if err == nil {
+			err = server.Job.Schedule(storageReconcileSchedule, task)
+		}
+		if err != nil {
+			server.Logger.Error().Err(err).Msg("failed to schedule storage reconciliation")
+		}
+	}
+	if err := server.Job.Schedule(abandonedUploadsSchedule, job.NewCleanupAttachmentUploadsTask(abandonedUploadsInterval)); err != nil {
+		server.Logger.Error().Err(err).Msg("failed to schedule attachment upload cleanup")
+	}
+	if scanner.Enabled() {
+		if err := server.Job.Schedule(scanPendingSchedule, job.NewScanPendingAttachmentsTask(scanPendingInterval)); err != nil {
+			server.Logger.Error().Err(err).Msg("failed to schedule pending attachment scans")
+		}
 	}
+
+	return s
 }
 
 func (s *TodoService) CreateTodo(ctx echo.Context, userID string, payload *todo.CreateTodoPayload) (*todo.Todo, error) {
 	logger := middleware.GetLogger(ctx)
 
-	// Validate parent todo exists and belongs to user (if provided)
-	if payload.ParentTodoID != nil {
-		parentTodo, err := s.todoRepo.CheckTodoExists(ctx.Request().Context(), userID, *payload.ParentTodoID)
-		if err != nil {
-			logger.Error().Err(err).Msg("parent todo validation failed")
-			return nil, err
+	// Validate and create in one unit of work, so the checks and the insert run in
+	// the same transaction. The unit of work may run more than once, so failures are
+	// logged once it is done.
+	var (
+		todoItem *todo.Todo
+		failure  string
+	)
+	err := s.server.DB.WithTx(ctx.Request().Context(), func(txCtx context.Context) error {
+		// Validate parent todo exists and user can edit it (if provided)
+		if payload.ParentTodoID != nil {
+			parentTodo, err := s.todoRepo.CheckTodoAccess(txCtx, userID, *payload.ParentTodoID, share.RoleEditor)
+			if err != nil {
+				failure = "parent todo validation failed"
+				return err
+			}
+
+			if !parentTodo.CanHaveChildren() {
+				failure = "parent todo cannot have children"
+				return errs.NewBadRequestError("Parent todo cannot have children (subtasks can't have subtasks)", false, nil, nil, nil)
+			}
 		}
 
-		if !parentTodo.CanHaveChildren() {
-			err := errs.NewBadRequestError("Parent todo cannot have children (subtasks can't have subtasks)", false, nil, nil, nil)
-			logger.Warn().Msg("parent todo cannot have children")
-			return nil, err
+		// Validate category exists and user can edit it (if provided)
+		if payload.CategoryID != nil {
+			_, err := s.categoryRepo.CheckCategoryAccess(txCtx, userID, *payload.CategoryID, share.RoleEditor)
+			if err != nil {
+				failure = "category validation failed"
+				return err
+			}
 		}
-	}
 
-	// Validate category exists and belongs to user (if provided)
-	if payload.CategoryID != nil {
-		_, err := s.categoryRepo.GetCategoryByID(ctx.Request().Context(), userID, *payload.CategoryID)
+		var err error
+		todoItem, err = s.todoRepo.CreateTodo(txCtx, userID, payload)
 		if err != nil {
-			logger.Error().Err(err).Msg("category validation failed")
-			return nil, err
+			failure = "failed to create todo"
+			return err
 		}
-	}
 
-	todoItem, err := s.todoRepo.CreateTodo(ctx.Request().Context(), userID, payload)
+		return nil
+	})
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to create todo")
+		logger.Error().Err(err).Msg(failure)
 		return nil, err
 	}
 
@@ -96,6 +172,8 @@ func (s *TodoService) GetTodoByID(ctx echo.Context, userID string, todoID uuid.U
 		return nil, err
 	}
 
+	s.withThumbnailURLs(ctx.Request().Context(), todoItem.Attachments)
+
 	return todoItem, nil
 }
 
@@ -108,49 +186,68 @@ func (s *TodoService) GetTodos(ctx echo.Context, userID string, query *todo.GetT
 		return nil, err
 	}
 
+	for i := range result.Data {
+		s.withThumbnailURLs(ctx.Request().Context(), result.Data[i].Attachments)
+	}
+
 	return result, nil
 }
 
 func (s *TodoService) UpdateTodo(ctx echo.Context, userID string, payload *todo.UpdateTodoPayload) (*todo.Todo, error) {
 	logger := middleware.GetLogger(ctx)
 
-	// Validate parent todo exists and belongs to user (if provided)
-	if payload.ParentTodoID != nil {
-		parentTodo, err := s.todoRepo.CheckTodoExists(ctx.Request().Context(), userID, *payload.ParentTodoID)
-		if err != nil {
-			logger.Error().Err(err).Msg("parent todo validation failed")
-			return nil, err
+	// Validate and update in one unit of work, so a concurrent delete or move can't
+	// slip between the checks and the write
+	var (
+		updatedTodo *todo.Todo
+		failure     string
+	)
+	err := s.server.DB.WithTx(ctx.Request().Context(), func(txCtx context.Context) error {
+		// Validate todo exists and user can edit it
+		if _, err := s.todoRepo.CheckTodoAccess(txCtx, userID, payload.ID, share.RoleEditor); err != nil {
+			failure = "todo validation failed"
+			return err
 		}
 
-		if parentTodo.ID == payload.ID {
-			err := errs.NewBadRequestError("Todo cannot be its own parent", false, nil, nil, nil)
-			logger.Warn().Msg("todo cannot be its own parent")
-			return nil, err
-		}
+		// Validate parent todo exists and user can edit it (if provided)
+		if payload.ParentTodoID != nil {
+			parentTodo, err := s.todoRepo.CheckTodoAccess(txCtx, userID, *payload.ParentTodoID, share.RoleEditor)
+			if err != nil {
+				failure = "parent todo validation failed"
+				return err
+			}
 
-		if !parentTodo.CanHaveChildren() {
-			err := errs.NewBadRequestError("Parent todo cannot have children (subtasks can't have subtasks)", false, nil, nil, nil)
-			logger.Warn().Msg("parent todo cannot have children")
-			return nil, err
+			if parentTodo.ID == payload.ID {
+				failure = "todo cannot be its own parent"
+				return errs.NewBadRequestError("Todo cannot be its own parent", false, nil, nil, nil)
+			}
+
+			if !parentTodo.CanHaveChildren() {
+				failure = "parent todo cannot have children"
+				return errs.NewBadRequestError("Parent todo cannot have children (subtasks can't have subtasks)", false, nil, nil, nil)
+			}
 		}
 
-		logger.Debug().Msg("parent todo validation passed")
-	}
+		// Validate category exists and user can edit it (if provided)
+		if payload.CategoryID != nil {
+			_, err := s.categoryRepo.CheckCategoryAccess(txCtx, userID, *payload.CategoryID, share.RoleEditor)
+			if err != nil {
+				failure = "category validation failed"
+				return err
+			}
+		}
 
-	// Validate category exists and belongs to user (if provided)
-	if payload.CategoryID != nil {
-		_, err := s.categoryRepo.GetCategoryByID(ctx.Request().Context(), userID, *payload.CategoryID)
+		var err error
+		updatedTodo, err = s.todoRepo.UpdateTodo(txCtx, userID, payload)
 		if err != nil {
-			logger.Error().Err(err).Msg("category validation failed")
-			return nil, err
+			failure = "failed to update todo"
+			return err
 		}
 
-		logger.Debug().Msg("category validation passed")
-	}
-
-	updatedTodo, err := s.todoRepo.UpdateTodo(ctx.Request().Context(), userID, payload)
+		return nil
+	})
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to update todo")
+		logger.Error().Err(err).Msg(failure)
 		return nil, err
 	}
 
@@ -176,12 +273,14 @@ func (s *TodoService) UpdateTodo(ctx echo.Context, userID string, payload *todo.
 func (s *TodoService) DeleteTodo(ctx echo.Context, userID string, todoID uuid.UUID) error {
 	logger := middleware.GetLogger(ctx)
 
-	err := s.todoRepo.DeleteTodo(ctx.Request().Context(), userID, todoID)
+	orphanedKeys, err := s.todoRepo.DeleteTodo(ctx.Request().Context(), userID, todoID)
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to delete todo")
 		return err
 	}
 
+	s.deleteObjects(ctx, orphanedKeys)
+
 	// Business event log
 	eventLogger := middleware.GetLogger(ctx)
 	eventLogger.Info().
@@ -204,54 +303,273 @@ func (s *TodoService) GetTodoStats(ctx echo.Context, userID string) (*todo.TodoS
 	return stats, nil
 }
 
-func (s *TodoService) UploadTodoAttachment(ctx echo.Context, userID string, todoID uuid.UUID, fileHeader *multipart.FileHeader) (*todo.Attachment, error) {
+// UploadTodoAttachment streams a file to storage as a todo attachment, holding at
+// most one storage part of it in memory.
+func (s *TodoService) UploadTodoAttachment(ctx echo.Context, userID string, todoID uuid.UUID, filename string,
+	body io.Reader,
+) (*todo.Attachment, error) {
+	logger := middleware.GetLogger(ctx)
+
+	// Validate todo exists and user can edit it
+	_, err := s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, todoID, share.RoleEditor)
+	if err != nil {
+		logger.Error().Err(err).Msg("todo validation failed")
+		return nil, err
+	}
+
+	return s.storeAttachment(ctx, userID, todoID, filename, body)
+}
+
+// AttachFile stores an in-memory file as a todo attachment, for files that don't
+// arrive as uploads such as inbound email attachments.
+func (s *TodoService) AttachFile(ctx echo.Context, userID string, todoID uuid.UUID, filename string,
+	data []byte,
+) (*todo.Attachment, error) {
 	logger := middleware.GetLogger(ctx)
 
-	// Validate todo exists and belongs to user
-	_, err := s.todoRepo.CheckTodoExists(ctx.Request().Context(), userID, todoID)
+	// Validate todo exists and user can edit it
+	_, err := s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, todoID, share.RoleEditor)
 	if err != nil {
 		logger.Error().Err(err).Msg("todo validation failed")
 		return nil, err
 	}
 
-	// Upload file to S3
-	file, err := fileHeader.Open()
+	return s.storeAttachment(ctx, userID, todoID, filename, bytes.NewReader(data))
+}
+
+// storedFile is content stored under its checksum, yet to be recorded as an
+// attachment or a version of one.
+type storedFile struct {
+	name     string
+	mimeType string
+	key      string
+	checksum string
+	size     int64
+}
+
+// storeFile checks the file's type from its first bytes and streams it to storage
+// within the user's limits.
+func (s *TodoService) storeFile(ctx echo.Context, userID string, filename string, body io.Reader) (*storedFile, error) {
+	logger := middleware.GetLogger(ctx)
+
+	limit, err := s.uploadLimit(ctx.Request().Context(), userID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to compute upload limit")
+		return nil, err
+	}
+	if limit.bytes <= 0 {
+		return nil, limit.err
+	}
+
+	name := filetype.SanitizeFilename(filename)
+
+	reader := bufio.NewReaderSize(body, filetype.SniffLength)
+	head, err := reader.Peek(filetype.SniffLength)
+	if err != nil && !errors.Is(err, io.EOF) {
+		logger.Error().Err(err).Msg("failed to read file")
+		return nil, errs.NewBadRequestError("failed to read file", false, nil, nil, nil)
+	}
+
+	mimeType, err := s.filePolicy.Check(name, head)
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to open file")
-		return nil, errs.NewBadRequestError("failed to open file", false, nil, nil, nil)
+		return nil, fileTypeError(err)
 	}
-	defer file.Close()
 
-	// Generate unique key for S3
-	key := fmt.Sprintf("todos/attachments/%s/%s", todoID.String(), fileHeader.Filename)
+	// Upload to a temporary key, hashing on the way, since the content-addressed key
+	// is only known at the end
+	tmpKey := uploadKey(uuid.New())
+	limited := storage.NewLimitedReader(reader, limit.bytes)
+	hash := sha256.New()
 
-	// Upload to S3
-	_, err = s.awsClient.S3Client.UploadFile(ctx.Request().Context(), s.server.Config.AWS.Bucket, key, file)
+	err = s.storage.Put(ctx.Request().Context(), tmpKey, io.TeeReader(limited, hash), mimeType)
+	if errors.Is(err, storage.ErrTooLarge) {
+		return nil, limit.err
+	}
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to upload file to S3")
+		logger.Error().Err(err).Msg("failed to upload file to storage")
 		return nil, errors.Wrap(err, "failed to upload file")
 	}
 
-	// Detect MIME type
-	file, err = fileHeader.Open()
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	key, err := s.commitBlob(ctx.Request().Context(), userID, tmpKey, checksum)
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to open file")
-		return nil, errs.NewBadRequestError("failed to open file", false, nil, nil, nil)
+		logger.Error().Err(err).Msg("failed to store file under its checksum")
+		return nil, errors.Wrap(err, "failed to upload file")
 	}
-	defer file.Close()
 
-	buffer := make([]byte, 512)
-	_, err = file.Read(buffer)
+	return &storedFile{
+		name:     name,
+		mimeType: mimeType,
+		key:      key,
+		checksum: checksum,
+		size:     limited.N(),
+	}, nil
+}
+
+// storeAttachment stores a file and records it as a new attachment.
+func (s *TodoService) storeAttachment(ctx echo.Context, userID string, todoID uuid.UUID, filename string,
+	body io.Reader,
+) (*todo.Attachment, error) {
+	logger := middleware.GetLogger(ctx)
+
+	file, err := s.storeFile(ctx, userID, filename, body)
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to read file")
-		return nil, errs.NewBadRequestError("failed to read file", false, nil, nil, nil)
+		return nil, err
 	}
-	mimeType := http.DetectContentType(buffer)
 
 	// Create attachment record in database
-	attachment, err := s.todoRepo.UploadTodoAttachment(ctx.Request().Context(), userID, todoID, fileHeader.Filename, fileHeader.Size, mimeType, key)
+	attachment, err := s.todoRepo.UploadTodoAttachment(ctx.Request().Context(), userID, todoID, file.name,
+		file.size, file.mimeType, file.key, file.checksum)
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to create attachment record")
+		// The content stays if another attachment already references it
+		s.deleteObjects(ctx, []string{file.key})
+		return nil, err
+	}
+
+	// Business event log
+	eventLogger := middleware.GetLogger(ctx)
+	eventLogger.Info().
+		Str("event", "todo_attachment_uploaded").
+		Str("todo_id", todoID.String()).
+		Str("attachment_id", attachment.ID.String()).
+		Str("storage_key", attachment.DownloadKey).
+		Int64("size", file.size).
+		Msg("Attachment uploaded successfully")
+
+	s.enqueueScan(ctx, attachment)
+	s.enqueuePreview(ctx, attachment)
+
+	return attachment, nil
+}
+
+// CreateAttachmentUpload starts a direct-to-storage upload and returns where the
+// client should send the file. The attachment appears once the upload is completed.
+func (s *TodoService) CreateAttachmentUpload(ctx echo.Context, userID string,
+	payload *todo.CreateAttachmentUploadPayload,
+) (*todo.UploadTicket, error) {
+	logger := middleware.GetLogger(ctx)
+
+	// Validate todo exists and user can edit it
+	_, err := s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, payload.TodoID, share.RoleEditor)
+	if err != nil {
+		logger.Error().Err(err).Msg("todo validation failed")
+		return nil, err
+	}
+
+	limit, err := s.uploadLimit(ctx.Request().Context(), userID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to compute upload limit")
+		return nil, err
+	}
+	if payload.Size > limit.bytes {
+		return nil, limit.err
+	}
+
+	uploadID := uuid.New()
+	name := filetype.SanitizeFilename(payload.Filename)
+
+	if err := s.filePolicy.CheckDeclared(name, payload.ContentType); err != nil {
+		return nil, fileTypeError(err)
+	}
+
+	upload := &todo.AttachmentUpload{
+		TodoID:     payload.TodoID,
+		UserID:     userID,
+		Name:       name,
+		StorageKey: uploadKey(uploadID),
+		FileSize:   payload.Size,
+		MimeType:   payload.ContentType,
+		ExpiresAt:  time.Now().Add(attachmentUploadExpiry),
+	}
+	upload.ID = uploadID
+
+	target, err := s.storage.PresignUpload(ctx.Request().Context(), upload.StorageKey, upload.MimeType,
+		upload.FileSize, attachmentUploadExpiry)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to presign attachment upload")
+		return nil, err
+	}
+
+	upload, err = s.todoRepo.CreateAttachmentUpload(ctx.Request().Context(), upload)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to create attachment upload")
+		return nil, err
+	}
+
+	return &todo.UploadTicket{
+		Upload: upload,
+		Target: target,
+	}, nil
+}
+
+// CompleteAttachmentUpload checks that the uploaded object matches what was
+// announced and records it as an attachment. Mismatching uploads are discarded.
+func (s *TodoService) CompleteAttachmentUpload(ctx echo.Context, userID string, todoID uuid.UUID,
+	uploadID uuid.UUID,
+) (*todo.Attachment, error) {
+	logger := middleware.GetLogger(ctx)
+
+	// Validate todo exists and user can edit it
+	_, err := s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, todoID, share.RoleEditor)
+	if err != nil {
+		logger.Error().Err(err).Msg("todo validation failed")
+		return nil, err
+	}
+
+	upload, err := s.todoRepo.GetAttachmentUpload(ctx.Request().Context(), userID, todoID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	if upload.CompletedAt != nil {
+		code := "UPLOAD_ALREADY_COMPLETED"
+		return nil, errs.NewBadRequestError("upload already completed", false, &code, nil, nil)
+	}
+
+	if time.Now().After(upload.ExpiresAt.Add(abandonedUploadGrace)) {
+		code := "UPLOAD_EXPIRED"
+		return nil, errs.NewBadRequestError("upload has expired, start a new one", false, &code, nil, nil)
+	}
+
+	object, err := s.storage.Head(ctx.Request().Context(), upload.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		code := "UPLOAD_MISSING"
+		return nil, errs.NewBadRequestError("file has not been uploaded yet", false, &code, nil, nil)
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to verify uploaded file")
+		return nil, err
+	}
+
+	if object.Size != upload.FileSize || !sameMediaType(object.ContentType, upload.MimeType) {
+		logger.Warn().
+			Str("upload_id", upload.ID.String()).
+			Int64("size", object.Size).
+			Str("content_type", object.ContentType).
+			Msg("uploaded file does not match the upload")
+		s.discardUpload(ctx.Request().Context(), upload)
+
+		code := "UPLOAD_MISMATCH"
+		return nil, errs.NewBadRequestError("uploaded file does not match the announced size or content type",
+			false, &code, nil, nil)
+	}
+
+	checksum, err := s.inspectUpload(ctx.Request().Context(), upload)
+	if err != nil {
+		s.discardUpload(ctx.Request().Context(), upload)
+		return nil, err
+	}
+
+	key, err := s.commitBlob(ctx.Request().Context(), userID, upload.StorageKey, checksum)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to store file under its checksum")
+		return nil, err
+	}
+
+	attachment, err := s.todoRepo.CompleteAttachmentUpload(ctx.Request().Context(), upload, key, checksum)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to complete attachment upload")
 		return nil, err
 	}
 
@@ -261,17 +579,109 @@ func (s *TodoService) UploadTodoAttachment(ctx echo.Context, userID string, todo
 		Str("event", "todo_attachment_uploaded").
 		Str("todo_id", todoID.String()).
 		Str("attachment_id", attachment.ID.String()).
-		Str("s3_key", attachment.DownloadKey).
+		Str("storage_key", attachment.DownloadKey).
 		Msg("Attachment uploaded successfully")
 
+	s.enqueueScan(ctx, attachment)
+	s.enqueuePreview(ctx, attachment)
+
 	return attachment, nil
 }
 
+// inspectUpload sniffs the start of a direct upload, which the client could have
+// sent with any content under the announced type, and hashes all of it.
+func (s *TodoService) inspectUpload(ctx context.Context, upload *todo.AttachmentUpload) (string, error) {
+	body, _, err := s.storage.Get(ctx, upload.StorageKey)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	hash := sha256.New()
+	reader := bufio.NewReaderSize(io.TeeReader(body, hash), filetype.SniffLength)
+
+	head, err := reader.Peek(filetype.SniffLength)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+
+	if _, err := s.filePolicy.Check(upload.Name, head); err != nil {
+		return "", fileTypeError(err)
+	}
+
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return "", fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// commitBlob moves freshly uploaded content from tmpKey to its content-addressed
+// key, unless the user already stores the same content, and returns where the
+// content is stored. Copying over an existing blob is harmless as the content is
+// identical.
+func (s *TodoService) commitBlob(ctx context.Context, userID, tmpKey, checksum string) (string, error) {
+	key, err := s.todoRepo.FindBlobKey(ctx, userID, checksum)
+	if err != nil {
+		return "", err
+	}
+
+	if key == "" {
+		key = blobKey(userID, checksum)
+		if err := s.storage.Copy(ctx, tmpKey, key); err != nil {
+			return "", err
+		}
+	}
+
+	if err := s.storage.Delete(ctx, tmpKey); err != nil {
+		s.server.Logger.Warn().Err(err).Str("storage_key", tmpKey).Msg("failed to delete temporary upload")
+	}
+
+	return key, nil
+}
+
+// handleCleanupAttachmentUploadsTask deletes uploads that were never completed,
+// along with whatever the client managed to upload.
+func (s *TodoService) handleCleanupAttachmentUploadsTask(ctx context.Context, _ *asynq.Task) error {
+	logger := s.server.Logger
+
+	uploads, err := s.todoRepo.GetAbandonedUploads(ctx, time.Now().Add(-abandonedUploadGrace), abandonedUploadsBatch)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to fetch abandoned uploads")
+		return err
+	}
+
+	for i := range uploads {
+		s.discardUpload(ctx, &uploads[i])
+	}
+
+	if len(uploads) > 0 {
+		logger.Info().Int("count", len(uploads)).Msg("cleaned up abandoned attachment uploads")
+	}
+
+	return nil
+}
+
+// discardUpload removes an upload and its object. The row stays when the object
+// can't be deleted, so the cleanup job retries it.
+func (s *TodoService) discardUpload(ctx context.Context, upload *todo.AttachmentUpload) {
+	logger := s.server.Logger
+
+	if err := s.storage.Delete(ctx, upload.StorageKey); err != nil {
+		logger.Error().Err(err).Str("storage_key", upload.StorageKey).Msg("failed to delete uploaded file")
+		return
+	}
+
+	if err := s.todoRepo.DeleteAttachmentUpload(ctx, upload.ID); err != nil {
+		logger.Error().Err(err).Str("upload_id", upload.ID.String()).Msg("failed to delete attachment upload")
+	}
+}
+
 func (s *TodoService) GetTodoAttachments(ctx echo.Context, userID string, todoID uuid.UUID) ([]todo.Attachment, error) {
 	logger := middleware.GetLogger(ctx)
 
-	// Validate todo exists and belongs to user
-	_, err := s.todoRepo.CheckTodoExists(ctx.Request().Context(), userID, todoID)
+	// Validate todo exists and user can view it
+	_, err := s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, todoID, share.RoleViewer)
 	if err != nil {
 		logger.Error().Err(err).Msg("todo validation failed")
 		return nil, err
@@ -283,35 +693,33 @@ func (s *TodoService) GetTodoAttachments(ctx echo.Context, userID string, todoID
 		return nil, err
 	}
 
+	s.withThumbnailURLs(ctx.Request().Context(), attachments)
+
 	return attachments, nil
 }
 
 func (s *TodoService) DeleteTodoAttachment(ctx echo.Context, userID string, todoID uuid.UUID, attachmentID uuid.UUID) error {
 	logger := middleware.GetLogger(ctx)
 
-	// Validate todo exists and belongs to user
-	_, err := s.todoRepo.CheckTodoExists(ctx.Request().Context(), userID, todoID)
+	// Validate todo exists and user can edit it
+	_, err := s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, todoID, share.RoleEditor)
 	if err != nil {
 		logger.Error().Err(err).Msg("todo validation failed")
 		return err
 	}
 
-	// Get attachment to get S3 key
-	attachment, err := s.todoRepo.GetTodoAttachment(ctx.Request().Context(), todoID, attachmentID)
-	if err != nil {
-		logger.Error().Err(err).Msg("attachment validation failed")
-		return err
-	}
-
-	// Delete from S3 asynchronously
-	go func() {
-		if err := s.awsClient.S3Client.DeleteFile(ctx.Request().Context(), s.server.Config.AWS.Bucket, attachment.DownloadKey); err != nil {
-			logger.Error().Err(err).Msg("failed to delete file from S3")
+	// Delete from database with every version, releasing their content, and from
+	// storage once that commits and no attachment references the content
+	err = s.server.DB.WithTx(ctx.Request().Context(), func(txCtx context.Context) error {
+		orphanedKeys, err := s.todoRepo.DeleteTodoAttachment(txCtx, userID, todoID, attachmentID)
+		if err != nil {
+			return err
 		}
-	}()
 
-	// Delete from database
-	if err := s.todoRepo.DeleteTodoAttachment(ctx.Request().Context(), todoID, attachmentID); err != nil {
+		database.AfterCommit(txCtx, func() { s.deleteObjects(ctx, orphanedKeys) })
+		return nil
+	})
+	if err != nil {
 		logger.Error().Err(err).Msg("failed to delete attachment record")
 		return err
 	}
@@ -327,25 +735,40 @@ func (s *TodoService) DeleteTodoAttachment(ctx echo.Context, userID string, todo
 	return nil
 }
 
-func (s *TodoService) GetTodoAttachmentURL(ctx echo.Context, userID string, todoID uuid.UUID, attachmentID uuid.UUID) (string, error) {
+// GetTodoAttachmentURL presigns a download of the attachment's current content, or
+// of the given version.
+func (s *TodoService) GetTodoAttachmentURL(ctx echo.Context, userID string, todoID uuid.UUID, attachmentID uuid.UUID,
+	version *int,
+) (string, error) {
 	logger := middleware.GetLogger(ctx)
 
-	// Validate todo exists and belongs to user
-	_, err := s.todoRepo.CheckTodoExists(ctx.Request().Context(), userID, todoID)
+	// Validate todo exists and user can view it
+	_, err := s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, todoID, share.RoleViewer)
 	if err != nil {
 		logger.Error().Err(err).Msg("todo validation failed")
 		return "", err
 	}
 
-	// Get attachment to get S3 key
+	// Get attachment to get storage key
 	attachment, err := s.todoRepo.GetTodoAttachment(ctx.Request().Context(), todoID, attachmentID)
 	if err != nil {
 		logger.Error().Err(err).Msg("attachment validation failed")
 		return "", err
 	}
 
-	// Get presigned URL from S3
-	url, err := s.awsClient.S3Client.GetPresignedUrl(ctx.Request().Context(), s.server.Config.AWS.Bucket, attachment.DownloadKey)
+	attachment, err = s.attachmentAt(ctx.Request().Context(), attachment, version)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.checkDownloadable(attachment); err != nil {
+		logger.Warn().Str("scan_status", string(attachment.ScanStatus)).Msg("attachment download blocked by malware scan")
+		return "", err
+	}
+
+	// Get presigned URL from storage
+	url, err := s.storage.Presign(ctx.Request().Context(), attachment.DownloadKey, s.presignExpiry(),
+		attachment.Name)
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to get presigned URL")
 		return "", err
@@ -353,3 +776,333 @@ func (s *TodoService) GetTodoAttachmentURL(ctx echo.Context, userID string, todo
 
 	return url, nil
 }
+
+// presignExpiry is how long presigned attachment download URLs stay valid.
+func (s *TodoService) presignExpiry() time.Duration {
+	return time.Duration(s.server.Config.Storage.PresignExpiry) * time.Second
+}
+
+// OpenTodoAttachment checks the user can view the todo and returns a reader over
+// the attachment's content, current or of the given version, for serving it
+// through the app instead of a presigned URL. The attachment is returned as it was
+// at that version. Nothing is downloaded until the reader is read; the caller
+// closes it.
+func (s *TodoService) OpenTodoAttachment(ctx echo.Context, userID string, todoID uuid.UUID,
+	attachmentID uuid.UUID, version *int,
+) (*todo.Attachment, *storage.ObjectReader, error) {
+	logger := middleware.GetLogger(ctx)
+
+	// Validate todo exists and user can view it
+	_, err := s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, todoID, share.RoleViewer)
+	if err != nil {
+		logger.Error().Err(err).Msg("todo validation failed")
+		return nil, nil, err
+	}
+
+	attachment, err := s.todoRepo.GetTodoAttachment(ctx.Request().Context(), todoID, attachmentID)
+	if err != nil {
+		logger.Error().Err(err).Msg("attachment validation failed")
+		return nil, nil, err
+	}
+
+	attachment, err = s.attachmentAt(ctx.Request().Context(), attachment, version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.checkDownloadable(attachment); err != nil {
+		logger.Warn().Str("scan_status", string(attachment.ScanStatus)).Msg("attachment download blocked by malware scan")
+		return nil, nil, err
+	}
+
+	object, err := s.storage.Head(ctx.Request().Context(), attachment.DownloadKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		logger.Warn().Str("storage_key", attachment.DownloadKey).Msg("attachment missing from storage")
+		code := "FILE_NOT_FOUND"
+		return nil, nil, errs.NewNotFoundError("attachment file not found", false, &code)
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to read attachment from storage")
+		return nil, nil, err
+	}
+
+	return attachment, storage.NewObjectReader(ctx.Request().Context(), s.storage, object), nil
+}
+
+// GetStorageUsage reports how much the user stores against their quota, broken
+// down by todo and content type.
+func (s *TodoService) GetStorageUsage(ctx echo.Context, userID string) (*todo.StorageReport, error) {
+	logger := middleware.GetLogger(ctx)
+	cfg := s.server.Config.Storage
+
+	usage, err := s.todoRepo.GetUserStorageUsage(ctx.Request().Context(), userID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to fetch storage usage")
+		return nil, err
+	}
+
+	byTodo, err := s.todoRepo.GetStorageUsageByTodo(ctx.Request().Context(), userID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to fetch storage usage by todo")
+		return nil, err
+	}
+
+	byMimeType, err := s.todoRepo.GetStorageUsageByMimeType(ctx.Request().Context(), userID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to fetch storage usage by mime type")
+		return nil, err
+	}
+
+	report := &todo.StorageReport{
+		StorageUsage: *usage,
+		MaxFileSize:  cfg.MaxFileSize,
+		ByTodo:       byTodo,
+		ByMimeType:   byMimeType,
+	}
+	if cfg.MaxUserStorage > 0 {
+		report.QuotaBytes = &cfg.MaxUserStorage
+	}
+
+	return report, nil
+}
+
+type uploadLimit struct {
+	bytes int64
+	err   *errs.HTTPError
+}
+
+// uploadLimit is how large a file the user may upload: the per-file limit, or the
+// rest of their storage quota when that is smaller, with the error for going past it.
+func (s *TodoService) uploadLimit(ctx context.Context, userID string) (*uploadLimit, error) {
+	cfg := s.server.Config.Storage
+
+	fileTooLarge := "FILE_TOO_LARGE"
+	limit := &uploadLimit{
+		bytes: cfg.MaxFileSize,
+		err:   errs.NewPayloadTooLargeError(fmt.Sprintf("file exceeds the %s limit", formatBytes(cfg.MaxFileSize)), false, &fileTooLarge),
+	}
+
+	if cfg.MaxUserStorage == 0 {
+		return limit, nil
+	}
+
+	usage, err := s.todoRepo.GetUserStorageUsage(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if remaining := cfg.MaxUserStorage - usage.UsedBytes - usage.ReservedBytes; remaining < limit.bytes {
+		quotaExceeded := "STORAGE_QUOTA_EXCEEDED"
+		limit.bytes = remaining
+		limit.err = errs.NewPayloadTooLargeError(fmt.Sprintf("file exceeds your %s storage quota", formatBytes(cfg.MaxUserStorage)), false, &quotaExceeded)
+	}
+
+	return limit, nil
+}
+
+func fileTypeError(err error) error {
+	switch {
+	case errors.Is(err, filetype.ErrTypeNotAllowed):
+		code := "FILE_TYPE_NOT_ALLOWED"
+		return errs.NewUnsupportedMediaTypeError("this file type is not allowed", false, &code)
+	case errors.Is(err, filetype.ErrTypeMismatch):
+		code := "FILE_TYPE_MISMATCH"
+		return errs.NewUnsupportedMediaTypeError("file content does not match its extension", false, &code)
+	default:
+		return err
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// blobKey is where a user's content with the given checksum is stored. Keys are
+// per user so deduplication never links one user's files to another's.
+func blobKey(userID, checksum string) string {
+	return fmt.Sprintf("attachments/%s/%s", userID, checksum)
+}
+
+// uploadKey is where an upload waits until its checksum is known.
+func uploadKey(uploadID uuid.UUID) string {
+	return "uploads/" + uploadID.String()
+}
+
+// sameMediaType compares content types ignoring parameters such as charset.
+func sameMediaType(a, b string) bool {
+	mediaA, _, errA := mime.ParseMediaType(a)
+	mediaB, _, errB := mime.ParseMediaType(b)
+	return errA == nil && errB == nil && mediaA == mediaB
+}
+
+func (s *TodoService) AddTodoAssignees(ctx echo.Context, userID string, payload *todo.AddTodoAssigneesPayload) ([]todo.Assignee, error) {
+	logger := middleware.GetLogger(ctx)
+
+	// Validate todo exists and user can edit it
+	_, err := s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, payload.TodoID, share.RoleEditor)
+	if err != nil {
+		logger.Error().Err(err).Msg("todo validation failed")
+		return nil, err
+	}
+
+	for i, assigneeID := range payload.UserIDs {
+		if assigneeID == "me" {
+			payload.UserIDs[i] = userID
+		}
+	}
+
+	// Assignees must be able to see the todo they are assigned to; in an organization
+	// that takes membership or a share
+	var memberIDs []string
+	if ws := middleware.GetWorkspace(ctx); ws.IsOrg() {
+		memberIDs, err = organizationMemberIDs(ctx.Request().Context(), ws.OrgID, payload.UserIDs)
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to check assignee memberships")
+			return nil, err
+		}
+	}
+
+	withoutAccess, err := s.todoRepo.GetUsersWithoutTodoAccess(ctx.Request().Context(), payload.TodoID, payload.UserIDs,
+		memberIDs)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to check assignee access")
+		return nil, err
+	}
+
+	if len(withoutAccess) > 0 {
+		code := "ASSIGNEE_WITHOUT_ACCESS"
+		err := errs.NewBadRequestError(
+			fmt.Sprintf("Users must have access to the todo before they can be assigned: %s", strings.Join(withoutAccess, ", ")),
+			true, &code, nil, nil,
+		)
+		logger.Warn().Strs("user_ids", withoutAccess).Msg("assignees without access to todo")
+		return nil, err
+	}
+
+	added, err := s.todoRepo.AddTodoAssignees(ctx.Request().Context(), payload.TodoID, userID, payload.UserIDs)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to add todo assignees")
+		return nil, err
+	}
+
+	assignees, err := s.todoRepo.GetTodoAssignees(ctx.Request().Context(), payload.TodoID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to fetch todo assignees")
+		return nil, err
+	}
+
+	// Business event log
+	eventLogger := middleware.GetLogger(ctx)
+	eventLogger.Info().
+		Str("event", "todo_assignees_added").
+		Str("todo_id", payload.TodoID.String()).
+		Int("added", len(added)).
+		Msg("Todo assignees added successfully")
+
+	return assignees, nil
+}
+
+func (s *TodoService) GetTodoAssignees(ctx echo.Context, userID string, todoID uuid.UUID) ([]todo.Assignee, error) {
+	logger := middleware.GetLogger(ctx)
+
+	// Validate todo exists and user can view it
+	_, err := s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, todoID, share.RoleViewer)
+	if err != nil {
+		logger.Error().Err(err).Msg("todo validation failed")
+		return nil, err
+	}
+
+	assignees, err := s.todoRepo.GetTodoAssignees(ctx.Request().Context(), todoID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to fetch todo assignees")
+		return nil, err
+	}
+
+	return assignees, nil
+}
+
+// RemoveTodoAssignee unassigns a user. Editors can unassign anyone and
+// assignees can always unassign themselves.
+func (s *TodoService) RemoveTodoAssignee(ctx echo.Context, userID string, todoID uuid.UUID, assigneeID string) error {
+	logger := middleware.GetLogger(ctx)
+
+	minRole := share.RoleEditor
+	if assigneeID == userID {
+		minRole = share.RoleViewer
+	}
+
+	_, err := s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, todoID, minRole)
+	if err != nil {
+		logger.Error().Err(err).Msg("todo validation failed")
+		return err
+	}
+
+	if err := s.todoRepo.RemoveTodoAssignee(ctx.Request().Context(), userID, todoID, assigneeID); err != nil {
+		logger.Error().Err(err).Msg("failed to remove todo assignee")
+		return err
+	}
+
+	// Business event log
+	eventLogger := middleware.GetLogger(ctx)
+	eventLogger.Info().
+		Str("event", "todo_assignee_removed").
+		Str("todo_id", todoID.String()).
+		Str("assignee_id", assigneeID).
+		Msg("Todo assignee removed successfully")
+
+	return nil
+}
+
+func (s *TodoService) WatchTodo(ctx echo.Context, userID string, todoID uuid.UUID) (*todo.Watcher, error) {
+	logger := middleware.GetLogger(ctx)
+
+	// Validate todo exists and user can view it
+	_, err := s.todoRepo.CheckTodoAccess(ctx.Request().Context(), userID, todoID, share.RoleViewer)
+	if err != nil {
+		logger.Error().Err(err).Msg("todo validation failed")
+		return nil, err
+	}
+
+	watcher, err := s.todoRepo.AddTodoWatcher(ctx.Request().Context(), todoID, userID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to watch todo")
+		return nil, err
+	}
+
+	// Business event log
+	eventLogger := middleware.GetLogger(ctx)
+	eventLogger.Info().
+		Str("event", "todo_watched").
+		Str("todo_id", todoID.String()).
+		Msg("Todo watched successfully")
+
+	return watcher, nil
+}
+
+func (s *TodoService) UnwatchTodo(ctx echo.Context, userID string, todoID uuid.UUID) error {
+	logger := middleware.GetLogger(ctx)
+
+	if err := s.todoRepo.RemoveTodoWatcher(ctx.Request().Context(), todoID, userID); err != nil {
+		logger.Error().Err(err).Msg("failed to unwatch todo")
+		return err
+	}
+
+	// Business event log
+	eventLogger := middleware.GetLogger(ctx)
+	eventLogger.Info().
+		Str("event", "todo_unwatched").
+		Str("todo_id", todoID.String()).
+		Msg("Todo unwatched successfully")
+
+	return nil
+}
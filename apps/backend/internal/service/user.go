@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/clerk/clerk-sdk-go/v2"
+	"github.com/clerk/clerk-sdk-go/v2/organizationmembership"
+	"github.com/clerk/clerk-sdk-go/v2/user"
+)
+
+// verifiedEmails returns the user's verified Clerk email addresses in lower case.
+func verifiedEmails(ctx context.Context, userID string) ([]string, error) {
+	u, err := user.Get(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user %s: %w", userID, err)
+	}
+
+	emails := make([]string, 0, len(u.EmailAddresses))
+	for _, e := range u.EmailAddresses {
+		if e.Verification != nil && e.Verification.Status == "verified" {
+			emails = append(emails, strings.ToLower(e.EmailAddress))
+		}
+	}
+
+	return emails, nil
+}
+
+// organizationMemberIDs returns the users among userIDs who are members of the
+// organization.
+func organizationMemberIDs(ctx context.Context, orgID string, userIDs []string) ([]string, error) {
+	if len(userIDs) == 0 {
+		return []string{}, nil
+	}
+
+	params := &organizationmembership.ListParams{
+		OrganizationID: orgID,
+		UserIDs:        userIDs,
+	}
+	params.Limit = clerk.Int64(int64(len(userIDs)))
+
+	memberships, err := organizationmembership.List(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization memberships: %w", err)
+	}
+
+	memberIDs := make([]string, 0, len(memberships.OrganizationMemberships))
+	for _, membership := range memberships.OrganizationMemberships {
+		if membership.PublicUserData != nil {
+			memberIDs = append(memberIDs, membership.PublicUserData.UserID)
+		}
+	}
+
+	return memberIDs, nil
+}
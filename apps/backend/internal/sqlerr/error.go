@@ -32,6 +32,10 @@ const (
 	// can be detected.
 	DeadlockDetected Code = "deadlock_detected"
 
+	// SerializationFailure is reported when a transaction conflicts with a concurrent
+	// one. Running it again can succeed.
+	SerializationFailure Code = "serialization_failure"
+
 	// TooManyConnections is reported when the database rejects a connection request
 	// due to reaching the maximum number of connections.
 	// This is different from blocking waiting on a connection pool.
@@ -53,6 +57,8 @@ func MapCode(code string) Code {
 		return ExcludeViolation
 	case "25P02":
 		return TransactionFailed
+	case "40001":
+		return SerializationFailure
 	case "40P01":
 		return DeadlockDetected
 	case "53300":
@@ -26,6 +26,9 @@ type TestDB struct {
 func SetupTestDB(t *testing.T) (*TestDB, func()) {
 	t.Helper()
 
+	// Integration tests need Docker; skip rather than fail where it isn't running
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
 	ctx := context.Background()
 	dbName := fmt.Sprintf("test_db_%s", uuid.New().String()[:8])
 	dbUser := "testuser"
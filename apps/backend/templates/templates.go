@@ -0,0 +1,11 @@
+// Package templates embeds the templates the backend renders so the binary doesn't
+// depend on its working directory.
+package templates
+
+import "embed"
+
+// Emails holds the HTML email templates: a shared layout, partials, and one content
+// template per email.
+//
+//go:embed emails/*.html emails/layouts/*.html emails/partials/*.html
+var Emails embed.FS
@@ -0,0 +1,98 @@
+import {
+  Body,
+  Button,
+  Container,
+  Head,
+  Heading,
+  Hr,
+  Html,
+  Link,
+  Preview,
+  Section,
+  Text,
+  Tailwind,
+} from "@react-email/components";
+
+interface ShareInvitationEmailProps {
+  inviterName: string;
+  resourceType: string;
+  resourceName: string;
+  role: string;
+  inviteUrl: string;
+}
+
+export const ShareInvitationEmail = ({
+  inviterName = "{{.InviterName}}",
+  resourceType = "{{.ResourceType}}",
+  resourceName = "{{.ResourceName}}",
+  role = "{{.Role}}",
+  inviteUrl = "{{.InviteURL}}",
+}: ShareInvitationEmailProps) => {
+  return (
+    <Html>
+      <Head />
+      <Preview>You've been invited to collaborate on Tasker</Preview>
+      <Tailwind>
+        <Body className="bg-gray-100 font-sans">
+          <Container className="bg-white p-8 rounded-lg shadow-sm my-10 mx-auto max-w-[600px]">
+            <Heading className="text-2xl font-bold text-gray-800 mt-4">
+              You've been invited to collaborate
+            </Heading>
+
+            <Section>
+              <Text className="text-gray-700 text-base">
+                Hi there,
+              </Text>
+              <Text className="text-gray-700 text-base">
+                {inviterName} shared the {resourceType} "{resourceName}" with you as {role}.
+              </Text>
+              <Text className="text-gray-700 text-base">
+                Accept the invitation to see it alongside your own todos.
+              </Text>
+            </Section>
+
+            <Section className="my-8 text-center">
+              <Button
+                className="bg-orange-600 hover:bg-orange-700 text-white font-medium rounded-md px-6 py-3"
+                href={inviteUrl}
+              >
+                Accept Invitation
+              </Button>
+            </Section>
+
+            <Hr className="border-gray-200 my-6" />
+
+            <Section>
+              <Text className="text-gray-600 text-sm">
+                If you have any questions, feel free to{" "}
+                <Link href={`/support`} className="text-orange-600 underline">
+                  contact our support team
+                </Link>
+                .
+              </Text>
+            </Section>
+
+            <Section className="mt-8 text-center">
+              <Text className="text-gray-500 text-xs">
+                © {new Date().getFullYear()} Alfred. All rights reserved.
+              </Text>
+              <Text className="text-gray-500 text-xs">
+                123 Project Street, Suite 100, San Francisco, CA 94103
+              </Text>
+            </Section>
+          </Container>
+        </Body>
+      </Tailwind>
+    </Html>
+  );
+};
+
+ShareInvitationEmail.PreviewProps = {
+  inviterName: "Jane",
+  resourceType: "category",
+  resourceName: "Work",
+  role: "editor",
+  inviteUrl: "https://tasker.app/invitations/token",
+};
+
+export default ShareInvitationEmail;
@@ -0,0 +1,98 @@
+import {
+  Body,
+  Button,
+  Container,
+  Head,
+  Heading,
+  Hr,
+  Html,
+  Link,
+  Preview,
+  Section,
+  Text,
+  Tailwind,
+} from "@react-email/components";
+
+interface TodoActivityEmailProps {
+  actorName: string;
+  todoTitle: string;
+  summary: string;
+  todoUrl: string;
+  reason: string;
+}
+
+export const TodoActivityEmail = ({
+  actorName = "{{.ActorName}}",
+  todoTitle = "{{.TodoTitle}}",
+  summary = "{{.Summary}}",
+  todoUrl = "{{.TodoURL}}",
+  reason = "{{.Reason}}",
+}: TodoActivityEmailProps) => {
+  return (
+    <Html>
+      <Head />
+      <Preview>There's an update on a todo you follow</Preview>
+      <Tailwind>
+        <Body className="bg-gray-100 font-sans">
+          <Container className="bg-white p-8 rounded-lg shadow-sm my-10 mx-auto max-w-[600px]">
+            <Heading className="text-2xl font-bold text-gray-800 mt-4">
+              Update on "{todoTitle}"
+            </Heading>
+
+            <Section>
+              <Text className="text-gray-700 text-base">
+                Hi there,
+              </Text>
+              <Text className="text-gray-700 text-base">
+                {actorName} {summary}.
+              </Text>
+              <Text className="text-gray-700 text-base">
+                {reason}
+              </Text>
+            </Section>
+
+            <Section className="my-8 text-center">
+              <Button
+                className="bg-orange-600 hover:bg-orange-700 text-white font-medium rounded-md px-6 py-3"
+                href={todoUrl}
+              >
+                View Todo
+              </Button>
+            </Section>
+
+            <Hr className="border-gray-200 my-6" />
+
+            <Section>
+              <Text className="text-gray-600 text-sm">
+                If you have any questions, feel free to{" "}
+                <Link href={`/support`} className="text-orange-600 underline">
+                  contact our support team
+                </Link>
+                .
+              </Text>
+            </Section>
+
+            <Section className="mt-8 text-center">
+              <Text className="text-gray-500 text-xs">
+                © {new Date().getFullYear()} Alfred. All rights reserved.
+              </Text>
+              <Text className="text-gray-500 text-xs">
+                123 Project Street, Suite 100, San Francisco, CA 94103
+              </Text>
+            </Section>
+          </Container>
+        </Body>
+      </Tailwind>
+    </Html>
+  );
+};
+
+TodoActivityEmail.PreviewProps = {
+  actorName: "Jane",
+  todoTitle: "Ship the Q3 report",
+  summary: "changed the status to completed",
+  todoUrl: "https://tasker.app/todos/123",
+  reason: "You're receiving this because you are watching this todo.",
+};
+
+export default TodoActivityEmail;